@@ -1 +1 @@
-module.exports = require('../src/index.js');
\ No newline at end of file
+module.exports = require('../src/index.js').app;
\ No newline at end of file
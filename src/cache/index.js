@@ -0,0 +1,119 @@
+const crypto = require('crypto');
+const config = require('../config');
+const MemoryCache = require('./memory');
+const RedisCache = require('./redis');
+
+const KEY_PREFIX = 'loan-money';
+// Version keys must outlive every entry cached under them
+const VERSION_TTL_SECONDS = 24 * 60 * 60;
+
+/**
+ * Per-user cache on top of a backend implementing get(key), set(key, value, ttlSeconds), delete(key),
+ * increment(key, ttlSeconds) and close().
+ *
+ * Keys embed a per-user version, so invalidating a user is a single write that orphans all their entries.
+ * Backend failures are logged and treated as misses; the cache never breaks a request.
+ */
+class Cache {
+  constructor(backend) {
+    this.backend = backend;
+  }
+
+  versionKey(userId) {
+    return `${KEY_PREFIX}:user:${userId}:version`;
+  }
+
+  /**
+   * Current cache version for a user, or null when the backend fails
+   */
+  async getVersion(userId) {
+    try {
+      return (await this.backend.get(this.versionKey(userId))) || '0';
+    } catch (error) {
+      console.error('Cache version error:', error);
+      return null;
+    }
+  }
+
+  /**
+   * Get a cached value for a user, or null; version defaults to the current one
+   */
+  async get(userId, key, version) {
+    const current = version === undefined ? await this.getVersion(userId) : version;
+    if (current === null) {
+      return null;
+    }
+    try {
+      const value = await this.backend.get(`${KEY_PREFIX}:user:${userId}:${current}:${key}`);
+      return value === null ? null : JSON.parse(value);
+    } catch (error) {
+      console.error('Cache get error:', error);
+      return null;
+    }
+  }
+
+  /**
+   * Cache a JSON-serializable value for a user under version, read before the value was loaded:
+   * if the user was invalidated meanwhile the entry is orphaned rather than served as current.
+   * version defaults to the current one.
+   */
+  async set(userId, key, value, ttlSeconds, version) {
+    const current = version === undefined ? await this.getVersion(userId) : version;
+    if (current === null) {
+      return;
+    }
+    try {
+      await this.backend.set(
+        `${KEY_PREFIX}:user:${userId}:${current}:${key}`,
+        JSON.stringify(value),
+        ttlSeconds
+      );
+    } catch (error) {
+      console.error('Cache set error:', error);
+    }
+  }
+
+  /**
+   * Increment a shared counter that expires ttlSeconds after its first hit; null when the backend fails
+   */
+  async increment(key, ttlSeconds) {
+    try {
+      return await this.backend.increment(`${KEY_PREFIX}:${key}`, ttlSeconds);
+    } catch (error) {
+      console.error('Cache increment error:', error);
+      return null;
+    }
+  }
+
+  /**
+   * Drop every cached entry for a user
+   */
+  async invalidateUser(userId) {
+    try {
+      await this.backend.set(this.versionKey(userId), crypto.randomBytes(6).toString('hex'), VERSION_TTL_SECONDS);
+    } catch (error) {
+      console.error('Cache invalidate error:', error);
+    }
+  }
+
+  /**
+   * Release the backend connection (on shutdown)
+   */
+  async close() {
+    try {
+      await this.backend.close();
+    } catch (error) {
+      console.error('Cache close error:', error);
+    }
+  }
+}
+
+/**
+ * Create cache from environment configuration (Redis when REDIS_URL is set, otherwise in-memory)
+ */
+function createCache() {
+  const backend = config.cache.redisUrl ? new RedisCache(config.cache.redisUrl) : new MemoryCache();
+  return new Cache(backend);
+}
+
+module.exports = createCache();
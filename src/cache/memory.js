@@ -0,0 +1,75 @@
+/**
+ * In-process cache used when Redis is not configured (per instance, lost on restart).
+ * Counters (rate limits) live apart from cached values, so a burst of cached responses cannot evict them.
+ */
+class MemoryCache {
+  constructor(maxEntries = 5000) {
+    this.maxEntries = maxEntries;
+    this.entries = new Map();
+    this.counters = new Map();
+  }
+
+  /**
+   * Get a cached string, or null when missing or expired
+   */
+  async get(key) {
+    const entry = this.entries.get(key);
+    if (!entry) {
+      return null;
+    }
+    if (entry.expiresAt <= Date.now()) {
+      this.entries.delete(key);
+      return null;
+    }
+    return entry.value;
+  }
+
+  /**
+   * Store a string for ttlSeconds, evicting the oldest entry when full
+   */
+  async set(key, value, ttlSeconds) {
+    this.entries.delete(key);
+    if (this.entries.size >= this.maxEntries) {
+      this.entries.delete(this.entries.keys().next().value);
+    }
+    this.entries.set(key, { value, expiresAt: Date.now() + ttlSeconds * 1000 });
+  }
+
+  /**
+   * Increment a counter, starting it at 1 with a ttlSeconds expiry when missing; returns the new count
+   */
+  async increment(key, ttlSeconds) {
+    const now = Date.now();
+    const counter = this.counters.get(key);
+    if (counter && counter.expiresAt > now) {
+      counter.value += 1;
+      return counter.value;
+    }
+
+    // Counters are never evicted before they expire; expired ones are swept once the map reaches maxEntries
+    if (this.counters.size >= this.maxEntries) {
+      for (const [counterKey, { expiresAt }] of this.counters) {
+        if (expiresAt <= now) {
+          this.counters.delete(counterKey);
+        }
+      }
+    }
+    this.counters.set(key, { value: 1, expiresAt: now + ttlSeconds * 1000 });
+    return 1;
+  }
+
+  /**
+   * Remove a key
+   */
+  async delete(key) {
+    this.entries.delete(key);
+    this.counters.delete(key);
+  }
+
+  async close() {
+    this.entries.clear();
+    this.counters.clear();
+  }
+}
+
+module.exports = MemoryCache;
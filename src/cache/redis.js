@@ -0,0 +1,202 @@
+const net = require('net');
+const tls = require('tls');
+
+const CONNECT_TIMEOUT_MS = 5000;
+
+/**
+ * Encode a command as a RESP array of bulk strings
+ */
+function encodeCommand(args) {
+  return `*${args.length}\r\n` + args.map(arg => {
+    const value = String(arg);
+    return `$${Buffer.byteLength(value)}\r\n${value}\r\n`;
+  }).join('');
+}
+
+/**
+ * Parse one RESP reply at offset; returns { value, offset } or null when more data is needed
+ */
+function parseReply(buffer, offset = 0) {
+  const lineEnd = buffer.indexOf('\r\n', offset);
+  if (lineEnd === -1) {
+    return null;
+  }
+
+  const type = String.fromCharCode(buffer[offset]);
+  const line = buffer.toString('utf8', offset + 1, lineEnd);
+  const next = lineEnd + 2;
+
+  switch (type) {
+    case '+':
+      return { value: line, offset: next };
+    case '-':
+      return { value: new Error(line), offset: next };
+    case ':':
+      return { value: parseInt(line), offset: next };
+    case '$': {
+      const length = parseInt(line);
+      if (length === -1) {
+        return { value: null, offset: next };
+      }
+      if (buffer.length < next + length + 2) {
+        return null;
+      }
+      return { value: buffer.toString('utf8', next, next + length), offset: next + length + 2 };
+    }
+    case '*': {
+      const count = parseInt(line);
+      const items = [];
+      let position = next;
+      for (let i = 0; i < count; i++) {
+        const item = parseReply(buffer, position);
+        if (!item) {
+          return null;
+        }
+        items.push(item.value);
+        position = item.offset;
+      }
+      return { value: count === -1 ? null : items, offset: position };
+    }
+    default:
+      throw new Error(`Unexpected Redis reply type: ${type}`);
+  }
+}
+
+/**
+ * Minimal Redis client (RESP2 over TCP/TLS) covering the commands the cache needs
+ */
+class RedisCache {
+  constructor(url) {
+    this.url = new URL(url);
+    this.socket = null;
+    this.connecting = null;
+    this.pending = [];
+    this.buffer = Buffer.alloc(0);
+  }
+
+  /**
+   * Open the connection, authenticating and selecting the database from the URL
+   */
+  connect() {
+    if (this.socket) {
+      return Promise.resolve();
+    }
+
+    if (!this.connecting) {
+      this.connecting = new Promise((resolve, reject) => {
+        const secure = this.url.protocol === 'rediss:';
+        const options = { host: this.url.hostname, port: parseInt(this.url.port) || 6379, servername: this.url.hostname };
+        const socket = secure ? tls.connect(options) : net.connect(options);
+
+        socket.setTimeout(CONNECT_TIMEOUT_MS, () => socket.destroy(new Error('Redis connection timed out')));
+        socket.once(secure ? 'secureConnect' : 'connect', async () => {
+          socket.setTimeout(0);
+          this.socket = socket;
+          try {
+            if (this.url.password) {
+              const username = decodeURIComponent(this.url.username);
+              const password = decodeURIComponent(this.url.password);
+              await this.command(username ? ['AUTH', username, password] : ['AUTH', password]);
+            }
+            const database = this.url.pathname.slice(1);
+            if (database) {
+              await this.command(['SELECT', database]);
+            }
+            resolve();
+          } catch (error) {
+            socket.destroy(error);
+            reject(error);
+          }
+        });
+        socket.on('data', chunk => this.onData(chunk));
+        socket.on('error', error => {
+          if (!this.socket) {
+            reject(error);
+          }
+        });
+        socket.on('close', () => this.onClose());
+      }).finally(() => {
+        this.connecting = null;
+      });
+    }
+
+    return this.connecting;
+  }
+
+  /**
+   * Resolve pending commands as replies arrive
+   */
+  onData(chunk) {
+    this.buffer = Buffer.concat([this.buffer, chunk]);
+
+    let reply;
+    while (this.pending.length > 0 && (reply = parseReply(this.buffer))) {
+      this.buffer = this.buffer.slice(reply.offset);
+      const { resolve, reject } = this.pending.shift();
+      if (reply.value instanceof Error) {
+        reject(reply.value);
+      } else {
+        resolve(reply.value);
+      }
+    }
+  }
+
+  /**
+   * Fail in-flight commands; the next command reconnects
+   */
+  onClose() {
+    this.socket = null;
+    this.buffer = Buffer.alloc(0);
+    this.pending.splice(0).forEach(({ reject }) => reject(new Error('Redis connection closed')));
+  }
+
+  /**
+   * Send a command on the open connection
+   */
+  command(args) {
+    return new Promise((resolve, reject) => {
+      this.pending.push({ resolve, reject });
+      this.socket.write(encodeCommand(args));
+    });
+  }
+
+  /**
+   * Connect if needed, then send a command
+   */
+  async send(args) {
+    await this.connect();
+    return this.command(args);
+  }
+
+  async get(key) {
+    return this.send(['GET', key]);
+  }
+
+  async set(key, value, ttlSeconds) {
+    await this.send(['SET', key, value, 'EX', Math.max(1, Math.ceil(ttlSeconds))]);
+  }
+
+  async delete(key) {
+    await this.send(['DEL', key]);
+  }
+
+  async increment(key, ttlSeconds) {
+    const count = await this.send(['INCR', key]);
+    if (count === 1) {
+      await this.send(['EXPIRE', key, Math.max(1, Math.ceil(ttlSeconds))]);
+    }
+    return count;
+  }
+
+  /**
+   * Close the connection after in-flight replies arrive
+   */
+  async close() {
+    if (this.socket) {
+      await this.send(['QUIT']).catch(() => {});
+      this.socket?.end();
+    }
+  }
+}
+
+module.exports = RedisCache;
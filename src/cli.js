@@ -0,0 +1,199 @@
+#!/usr/bin/env node
+require('dotenv').config();
+const fs = require('fs');
+const path = require('path');
+const { parseArgs } = require('util');
+
+/**
+ * Operator CLI: `loan-money <command> [options]`, sharing the config, database and service layers with the API.
+ * Modules are required per command so `help` works without a valid environment.
+ */
+
+class UsageError extends Error {}
+
+/**
+ * Parse command options, turning unknown or malformed flags into usage errors
+ */
+function parseOptions(args, options) {
+  try {
+    return parseArgs({ args, options, allowPositionals: true });
+  } catch (error) {
+    throw new UsageError(error.message);
+  }
+}
+
+/**
+ * First line of stdin (for secrets that should not appear in shell history or ps)
+ */
+async function readStdinLine() {
+  const chunks = [];
+  for await (const chunk of process.stdin) {
+    chunks.push(chunk);
+  }
+  return Buffer.concat(chunks).toString('utf8').split(/\r?\n/)[0];
+}
+
+const COMMANDS = {
+  serve: {
+    usage: 'serve',
+    description: 'Start the HTTP (and gRPC, when GRPC_PORT is set) server with background jobs',
+    async run() {
+      await require('./index').startServer();
+      // Keep running; the server owns the process from here (SIGTERM drains and exits)
+      return null;
+    }
+  },
+
+  migrate: {
+    usage: 'migrate up|down',
+    description: 'Apply the database schema (up); down is not supported, restore a backup instead',
+    async run(args) {
+      const { positionals } = parseOptions(args, {});
+      const direction = positionals[0];
+
+      if (direction === 'up') {
+        const db = require('./database/db');
+        try {
+          await db.createTables();
+          console.log('Database schema is up to date');
+        } finally {
+          await db.close();
+        }
+        return 0;
+      }
+      if (direction === 'down') {
+        // Schema changes are additive (CREATE/ALTER ... IF NOT EXISTS); there is no recorded history to reverse
+        console.error('migrate down is not supported: the schema is applied idempotently and has no down steps. Restore a database backup to roll back.');
+        return 1;
+      }
+      throw new UsageError('migrate needs a direction: up or down');
+    }
+  },
+
+  'create-user': {
+    usage: 'create-user --username=<name> (--password=<password> | --password-stdin) [--full-name=<name>] [--email=<email>]',
+    description: 'Create an account (same password policy as registration)',
+    async run(args) {
+      const { values } = parseOptions(args, {
+        username: { type: 'string' },
+        password: { type: 'string' },
+        'password-stdin': { type: 'boolean' },
+        'full-name': { type: 'string' },
+        email: { type: 'string' }
+      });
+      if (!values.username) {
+        throw new UsageError('--username is required');
+      }
+      if (!values.password === !values['password-stdin']) {
+        throw new UsageError('Pass exactly one of --password or --password-stdin');
+      }
+
+      const db = require('./database/db');
+      const userService = require('./services/user');
+      try {
+        const password = values['password-stdin'] ? await readStdinLine() : values.password;
+        const user = await userService.createUser({
+          username: values.username,
+          password,
+          fullName: values['full-name'] || null,
+          email: values.email || null
+        });
+        console.log(`Created user ${user.username} (${user.id})`);
+        return 0;
+      } finally {
+        await db.close();
+      }
+    }
+  },
+
+  export: {
+    usage: 'export --user=<username|id> [--format=xlsx|qif|ofx|backup] [--range=<preset> | --from=<date> --to=<date>] [--currency=<code>] [--out=<file>|-]',
+    description: "Write a user's export file (default xlsx into the current directory; --out=- for stdout)",
+    async run(args) {
+      const { values } = parseOptions(args, {
+        user: { type: 'string' },
+        format: { type: 'string', default: 'xlsx' },
+        range: { type: 'string' },
+        from: { type: 'string' },
+        to: { type: 'string' },
+        currency: { type: 'string' },
+        out: { type: 'string' }
+      });
+      if (!values.user) {
+        throw new UsageError('--user is required');
+      }
+
+      const { parseDateRange } = require('./utils/dateRange');
+      const range = parseDateRange(values);
+      if (range.error) {
+        throw new UsageError(range.error);
+      }
+
+      const db = require('./database/db');
+      const userService = require('./services/user');
+      const exportHandler = require('./handlers/export');
+      try {
+        const user = await userService.findUser(values.user);
+        if (!user) {
+          console.error(`User not found: ${values.user}`);
+          return 1;
+        }
+
+        const file = await exportHandler.generateExport(user.id, values.format, { range, currency: values.currency || null });
+        if (values.out === '-') {
+          process.stdout.write(file.content);
+        } else {
+          const target = path.resolve(values.out || file.fileName);
+          fs.writeFileSync(target, file.content);
+          console.error(`Wrote ${file.content.length} bytes to ${target}`);
+        }
+        return 0;
+      } finally {
+        await db.close();
+      }
+    }
+  }
+};
+
+/**
+ * Print the command list
+ */
+function printHelp(stream = process.stdout) {
+  stream.write('Usage: loan-money <command> [options]\n\nCommands:\n');
+  Object.values(COMMANDS).forEach(({ usage, description }) => {
+    stream.write(`  ${usage}\n      ${description}\n`);
+  });
+}
+
+async function main(argv) {
+  const [name, ...args] = argv;
+  if (!name || name === 'help' || name === '--help' || name === '-h') {
+    printHelp();
+    return 0;
+  }
+
+  const command = COMMANDS[name];
+  if (!command) {
+    console.error(`Unknown command "${name}"\n`);
+    printHelp(process.stderr);
+    return 2;
+  }
+
+  try {
+    return await command.run(args);
+  } catch (error) {
+    if (error instanceof UsageError) {
+      console.error(`${error.message}\nUsage: loan-money ${command.usage}`);
+      return 2;
+    }
+    console.error(`${name} failed:`, error.message);
+    (error.details || []).forEach(detail => console.error(`  ${detail.field}: ${detail.issue}`));
+    return 1;
+  }
+}
+
+main(process.argv.slice(2)).then(code => {
+  if (code !== null) {
+    process.exit(code);
+  }
+});
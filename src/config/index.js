@@ -0,0 +1,24 @@
+/**
+ * Application settings, loaded from the environment at startup.
+ *
+ * Every setting is declared in settings.js with its variable, type, default and description; values are
+ * parsed and validated together, and any problem stops the process with one error listing them all.
+ * The exported object is live: reload.js updates reloadable settings in place, so read them where they
+ * are used rather than copying them at require time. Run `npm run config` to print the documented
+ * settings and their effective values.
+ */
+const { loadConfig, describeConfig } = require('./settings');
+
+if (require.main === module) {
+  require('dotenv').config();
+  console.table(describeConfig());
+  try {
+    loadConfig();
+    console.log('Configuration is valid');
+  } catch (error) {
+    console.error(error.message);
+    process.exitCode = 1;
+  }
+} else {
+  module.exports = loadConfig();
+}
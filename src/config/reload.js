@@ -0,0 +1,116 @@
+const fs = require('fs');
+const path = require('path');
+const dotenv = require('dotenv');
+const config = require('./index');
+const { SETTINGS, assign, lookup, loadConfig } = require('./settings');
+
+const ENV_FILE = path.resolve(process.cwd(), '.env');
+
+const listeners = [];
+
+/**
+ * Parsed .env file, or nothing when there is none
+ */
+function readEnvFile() {
+  return fs.existsSync(ENV_FILE) ? dotenv.parse(fs.readFileSync(ENV_FILE)) : {};
+}
+
+// What dotenv loaded at startup: a variable still holding its file value came from the file, not the real environment
+let fileValues = readEnvFile();
+
+/**
+ * Register a callback run after a reload changed at least one setting
+ */
+function onReload(listener) {
+  listeners.push(listener);
+}
+
+/**
+ * Environment as it would be for a fresh start: the .env file is re-read, and like dotenv at startup
+ * it does not override variables set in the real process environment.
+ */
+function readEnvironment(fromFile) {
+  const env = { ...process.env };
+  Object.keys(fileValues).forEach(name => {
+    if (env[name] === fileValues[name]) {
+      delete env[name];
+    }
+  });
+  Object.entries(fromFile).forEach(([name, value]) => {
+    if (env[name] === undefined) {
+      env[name] = value;
+    }
+  });
+  return env;
+}
+
+/**
+ * Re-read and validate the configuration, then apply changed reloadable settings in place.
+ * An invalid configuration is rejected as a whole (ConfigError) and the running settings are kept.
+ * Returns the variables applied and those that changed but only take effect after a restart.
+ */
+function reloadConfig() {
+  const fromFile = readEnvFile();
+  const env = readEnvironment(fromFile);
+  const next = loadConfig(env);
+
+  // Keep process.env in step with the file so the next reload sees the same values
+  Object.keys({ ...fileValues, ...fromFile }).forEach(name => {
+    if (env[name] === undefined) {
+      delete process.env[name];
+    } else {
+      process.env[name] = env[name];
+    }
+  });
+  fileValues = fromFile;
+
+  const changed = [];
+  const restartRequired = [];
+
+  SETTINGS.forEach(setting => {
+    const value = lookup(next, setting.key);
+    if (JSON.stringify(value) === JSON.stringify(lookup(config, setting.key))) {
+      return;
+    }
+    if (setting.reloadable) {
+      assign(config, setting.key, value);
+      changed.push(setting.env);
+    } else {
+      restartRequired.push(setting.env);
+    }
+  });
+
+  if (changed.length > 0) {
+    listeners.forEach(listener => {
+      try {
+        listener(changed);
+      } catch (error) {
+        console.error('Config reload listener error:', error);
+      }
+    });
+  }
+  return { changed, restartRequired };
+}
+
+/**
+ * Reload on SIGHUP, logging the outcome
+ */
+function handleReloadSignal() {
+  process.on('SIGHUP', () => {
+    try {
+      const { changed, restartRequired } = reloadConfig();
+      console.log(`SIGHUP: config reloaded (${changed.length > 0 ? `changed ${changed.join(', ')}` : 'no changes'})`);
+      if (restartRequired.length > 0) {
+        console.warn(`SIGHUP: restart required to apply ${restartRequired.join(', ')}`);
+      }
+    } catch (error) {
+      console.error('SIGHUP: config reload rejected, keeping current settings:', error.message);
+    }
+  });
+}
+
+module.exports = {
+  onReload,
+  reloadConfig,
+  handleReloadSignal
+};
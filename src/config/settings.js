@@ -0,0 +1,344 @@
+/**
+ * Declared application settings: variable, type, default and description for each.
+ * Values are parsed and validated together, and any problem produces one error listing them all.
+ * Settings marked reloadable can change at runtime (see reload.js); the rest need a restart.
+ */
+
+// The placeholder shipped in .env.example; also the development fallback in utils/jwt.js
+const EXAMPLE_JWT_SECRET = 'your-super-secret-jwt-key-change-in-production';
+const SSL_MODES = ['disable', 'allow', 'prefer', 'require', 'verify-ca', 'verify-full'];
+// Classes PASSWORD_REQUIRED_CLASSES may list
+const PASSWORD_CLASSES = ['lowercase', 'uppercase', 'digit', 'symbol'];
+
+class ConfigError extends Error {
+  constructor(issues) {
+    super(`Invalid configuration:\n${issues.map(issue => `  - ${issue}`).join('\n')}`);
+    this.name = 'ConfigError';
+    this.issues = issues;
+  }
+}
+
+/**
+ * Parsers by type: return the parsed value or throw with what was expected
+ */
+const TYPES = {
+  string: raw => raw,
+  integer: (raw, { min = 0 }) => {
+    if (!/^-?\d+$/.test(raw) || parseInt(raw) < min) {
+      throw new Error(`must be an integer >= ${min}`);
+    }
+    return parseInt(raw);
+  },
+  port: raw => {
+    const port = Number(raw);
+    if (!Number.isInteger(port) || port < 1 || port > 65535) {
+      throw new Error('must be a port number between 1 and 65535');
+    }
+    return port;
+  },
+  boolean: raw => {
+    if (!['true', 'false'].includes(raw)) {
+      throw new Error('must be true or false');
+    }
+    return raw === 'true';
+  },
+  // Number between 0 and 1
+  fraction: raw => {
+    const value = Number(raw);
+    if (raw === '' || !Number.isFinite(value) || value < 0 || value > 1) {
+      throw new Error('must be a number between 0 and 1');
+    }
+    return value;
+  },
+  list: raw => raw.split(',').map(item => item.trim()).filter(Boolean),
+  // Comma-separated positive integers (days)
+  integerList: raw => {
+    const values = raw.split(',').map(item => item.trim()).filter(Boolean);
+    if (!values.every(item => /^\d+$/.test(item) && parseInt(item) > 0)) {
+      throw new Error('must be comma-separated positive integers');
+    }
+    return values.map(item => parseInt(item));
+  },
+  // Comma-separated CODE=rate pairs ("USD=36.5,EUR=39.2")
+  rates: raw => {
+    const pairs = raw.split(',').map(item => item.trim()).filter(Boolean).map(item => item.split('='));
+    if (!pairs.every(([code, rate]) => /^[A-Z]{3}$/.test(code) && Number(rate) > 0)) {
+      throw new Error('must be comma-separated CODE=rate pairs with positive rates');
+    }
+    return Object.fromEntries(pairs.map(([code, rate]) => [code, Number(rate)]));
+  },
+  // IANA time zone name
+  timezone: raw => {
+    try {
+      new Intl.DateTimeFormat('en-US', { timeZone: raw });
+    } catch (error) {
+      throw new Error('must be an IANA time zone such as Asia/Bangkok');
+    }
+    return raw;
+  },
+  enum: (raw, { values }) => {
+    if (!values.includes(raw)) {
+      throw new Error(`must be one of ${values.join(', ')}`);
+    }
+    return raw;
+  },
+  // Comma-separated subset of values
+  enumList: (raw, { values }) => {
+    const items = raw.split(',').map(item => item.trim()).filter(Boolean);
+    if (!items.every(item => values.includes(item))) {
+      throw new Error(`must be comma-separated values from ${values.join(', ')}`);
+    }
+    return items;
+  },
+  // Request body size understood by express.json ("100kb", "50mb" or plain bytes)
+  size: raw => {
+    if (!/^\d+(\.\d+)?\s*(b|kb|mb|gb)?$/i.test(raw)) {
+      throw new Error('must be a size such as 100kb or 50mb');
+    }
+    return raw;
+  },
+  // "<limit>/<windowSeconds>"; "off" or 0 disables the limit
+  rateLimit: raw => {
+    const value = raw.toLowerCase();
+    if (value === 'off' || value === '0') {
+      return null;
+    }
+    const [limit, windowSeconds] = value.split('/').map(Number);
+    if (!Number.isInteger(limit) || !Number.isInteger(windowSeconds) || limit < 1 || windowSeconds < 1) {
+      throw new Error('must be "<limit>/<windowSeconds>" or "off"');
+    }
+    return { limit, windowSeconds };
+  }
+};
+
+const LOG_LEVELS = ['debug', 'info', 'warn', 'error'];
+
+// key: path in the config object; null defaults mean "unset" and let the consumer decide
+const SETTINGS = [
+  { key: 'server.env', env: 'NODE_ENV', type: 'string', default: null, description: 'Runtime environment (production enables the strict checks)' },
+  { key: 'server.port', env: 'PORT', type: 'port', default: 3000, description: 'HTTP port' },
+  { key: 'server.grpcPort', env: 'GRPC_PORT', type: 'port', default: null, description: 'gRPC port (gRPC is off when unset)' },
+  { key: 'server.trustProxy', env: 'TRUST_PROXY', type: 'string', default: null, description: 'Express "trust proxy" setting: hop count, "loopback" or a subnet list' },
+  { key: 'server.headersTimeoutMs', env: 'HTTP_HEADERS_TIMEOUT_MS', type: 'integer', min: 1000, default: 10000, description: 'Time a client gets to send the request headers (slow-loris guard)' },
+  { key: 'server.requestTimeoutMs', env: 'HTTP_REQUEST_TIMEOUT_MS', type: 'integer', min: 1000, default: 120000, description: 'Time a client gets to send the whole request, body included' },
+  { key: 'server.socketTimeoutMs', env: 'HTTP_SOCKET_TIMEOUT_MS', type: 'integer', min: 1000, default: 120000, description: 'Close a connection with no traffic for this long, including a handler that never responds' },
+  { key: 'server.keepAliveTimeoutMs', env: 'HTTP_KEEP_ALIVE_TIMEOUT_MS', type: 'integer', min: 1000, default: 65000, description: 'Keep idle connections open this long (above the load balancer idle timeout)' },
+  { key: 'server.maxHeaderBytes', env: 'HTTP_MAX_HEADER_BYTES', type: 'integer', min: 1024, default: 16384, description: 'Maximum size of the request headers' },
+  { key: 'server.staticDir', env: 'STATIC_DIR', type: 'string', default: 'web', description: 'Directory of the web frontend, served at / and /static (relative to the project root)' },
+  { key: 'server.staticSource', env: 'STATIC_SOURCE', type: 'enum', values: ['auto', 'embedded', 'disk'], default: 'auto', description: 'Serve the frontend bundled by `npm run build` (embedded), from STATIC_DIR (disk), or the bundle when built (auto)' },
+  { key: 'server.staticMaxAgeSeconds', env: 'STATIC_MAX_AGE_SECONDS', type: 'integer', default: 3600, description: 'Browser cache lifetime for static assets (HTML pages are always revalidated)' },
+  { key: 'server.compressionThresholdBytes', env: 'COMPRESSION_THRESHOLD_BYTES', type: 'integer', default: 1024, description: 'Responses smaller than this are sent uncompressed' },
+  { key: 'server.publicUrl', env: 'PUBLIC_URL', type: 'string', default: null, description: 'Public base URL for signed download links (unset: the request host)' },
+  { key: 'server.frontendUrl', env: 'FRONTEND_URL', type: 'string', default: '', description: 'Web UI base URL that OAuth callbacks redirect back to' },
+  { key: 'server.vercel', env: 'VERCEL', type: 'string', default: null, description: 'Set by Vercel, which serves the app through api/index.js instead of a listening server' },
+
+  { key: 'database.url', env: 'DATABASE_URL', type: 'string', default: null, secret: true, description: 'Connection string; overrides DB_HOST..DB_SSLMODE' },
+  { key: 'database.replicaUrl', env: 'DATABASE_REPLICA_URL', type: 'string', default: null, secret: true, description: 'Read replica connection string for list, dashboard and report reads' },
+  { key: 'database.host', env: 'DB_HOST', type: 'string', default: 'localhost', description: 'Database host' },
+  { key: 'database.port', env: 'DB_PORT', type: 'port', default: 5432, description: 'Database port' },
+  { key: 'database.user', env: 'DB_USER', type: 'string', default: 'postgres', description: 'Database user' },
+  { key: 'database.password', env: 'DB_PASSWORD', type: 'string', default: '', secret: true, description: 'Database password' },
+  { key: 'database.name', env: 'DB_NAME', type: 'string', default: 'loan_management', description: 'Database name' },
+  { key: 'database.sslMode', env: 'DB_SSLMODE', type: 'enum', values: SSL_MODES, default: null, description: 'libpq-style SSL mode (unset: SSL without verification in production)' },
+  { key: 'database.poolMax', env: 'DB_POOL_MAX', type: 'integer', min: 1, default: null, description: 'Pool size (default: pool_max in DATABASE_URL, else 10)' },
+  { key: 'database.poolIdleTimeoutMs', env: 'DB_POOL_IDLE_TIMEOUT_MS', type: 'integer', default: 10000, description: 'Close pooled connections idle this long' },
+  { key: 'database.poolConnectionTimeoutMs', env: 'DB_POOL_CONNECTION_TIMEOUT_MS', type: 'integer', default: null, description: 'Wait this long for a connection (0 = forever; default: connect_timeout in DATABASE_URL)' },
+  { key: 'database.poolMaxLifetimeSeconds', env: 'DB_POOL_MAX_LIFETIME_SECONDS', type: 'integer', default: 0, description: 'Recycle connections after this long (0 = never)' },
+  { key: 'database.statementTimeoutMs', env: 'DB_STATEMENT_TIMEOUT_MS', type: 'integer', default: 0, description: 'Server-side cap for any single statement (0 = no limit)' },
+  { key: 'database.retryAttempts', env: 'DB_RETRY_ATTEMPTS', type: 'integer', min: 1, default: 3, description: 'Attempts for transient connection errors, including the first' },
+  { key: 'database.retryBaseDelayMs', env: 'DB_RETRY_BASE_DELAY_MS', type: 'integer', default: 100, description: 'Base delay of the jittered exponential retry backoff' },
+
+  {
+    key: 'jwt.secret',
+    env: 'JWT_SECRET',
+    type: 'string',
+    default: null,
+    secret: true,
+    requiredInProduction: true,
+    validate: value => (value === EXAMPLE_JWT_SECRET ? 'must be changed from the .env.example placeholder in production' : null),
+    description: 'Token signing secret (required in production)'
+  },
+  { key: 'jwt.previousSecrets', env: 'JWT_PREVIOUS_SECRETS', type: 'list', default: [], secret: true, description: 'Previous secrets still accepted while rotating JWT_SECRET' },
+  { key: 'jwt.urlSigningSecret', env: 'URL_SIGNING_SECRET', type: 'string', default: null, secret: true, description: 'Secret for signed download URLs (unset: JWT_SECRET)' },
+
+  { key: 'session.cookieName', env: 'SESSION_COOKIE_NAME', type: 'string', default: 'loan_money_session', description: 'Session cookie name; the CSRF cookie adds a _csrf suffix' },
+  { key: 'session.cookieSecure', env: 'SESSION_COOKIE_SECURE', type: 'boolean', default: null, description: 'Secure flag on session cookies (unset: on outside development)' },
+  { key: 'session.cookieSameSite', env: 'SESSION_COOKIE_SAMESITE', type: 'enum', values: ['Strict', 'Lax', 'None'], default: 'Lax', description: 'SameSite attribute; a web UI on another origin needs None' },
+
+  { key: 'password.minLength', env: 'PASSWORD_MIN_LENGTH', type: 'integer', min: 1, default: 8, description: 'Minimum length of new passwords' },
+  { key: 'password.requiredClasses', env: 'PASSWORD_REQUIRED_CLASSES', type: 'enumList', values: PASSWORD_CLASSES, default: [], description: 'Character classes new passwords must contain' },
+  { key: 'password.breachCheck', env: 'PASSWORD_BREACH_CHECK', type: 'boolean', default: false, description: 'Reject new passwords found in Have I Been Pwned' },
+  { key: 'password.pwnedPasswordsUrl', env: 'PWNED_PASSWORDS_URL', type: 'string', default: 'https://api.pwnedpasswords.com/range', description: 'Have I Been Pwned range API used by the breach check' },
+
+  { key: 'admin.token', env: 'ADMIN_TOKEN', type: 'string', default: null, secret: true, description: 'Bearer token for /api/v1/admin (the admin API is disabled when unset)' },
+  { key: 'admin.metricsToken', env: 'METRICS_TOKEN', type: 'string', default: null, secret: true, description: 'Bearer token required by GET /metrics (unset: no auth)' },
+
+  { key: 'cors.allowedOrigins', env: 'CORS_ALLOWED_ORIGINS', reloadable: true, type: 'list', default: null, description: 'Allowed origins (unset: any in development, same-origin only in production)' },
+  { key: 'cors.allowedMethods', env: 'CORS_ALLOWED_METHODS', reloadable: true, type: 'list', default: null, description: 'Allowed methods (unset: the built-in list)' },
+  { key: 'cors.allowedHeaders', env: 'CORS_ALLOWED_HEADERS', reloadable: true, type: 'list', default: null, description: 'Allowed request headers (unset: the built-in list)' },
+
+  { key: 'limits.jsonBody', env: 'JSON_BODY_LIMIT', type: 'size', default: '100kb', description: 'Maximum JSON request body' },
+  { key: 'limits.backupSize', env: 'BACKUP_MAX_SIZE', type: 'size', default: '50mb', description: 'Maximum backup restore body' },
+  { key: 'limits.batchOperations', env: 'BATCH_MAX_OPERATIONS', type: 'integer', min: 1, default: 20, description: 'Maximum operations per batch request' },
+  { key: 'limits.requestTimeoutMs', env: 'REQUEST_TIMEOUT_MS', type: 'integer', min: 1, default: 30000, description: 'Cancel a request\'s database work after this long' },
+  { key: 'limits.shutdownTimeoutMs', env: 'SHUTDOWN_TIMEOUT_MS', type: 'integer', min: 1, default: 25000, description: 'Drain time for requests and jobs on SIGTERM/SIGINT' },
+  { key: 'limits.rateLimit.auth', env: 'RATE_LIMIT_AUTH', reloadable: true, type: 'rateLimit', default: '10/60', description: 'Per-IP limit on credential endpoints' },
+  { key: 'limits.rateLimit.write', env: 'RATE_LIMIT_WRITE', reloadable: true, type: 'rateLimit', default: '120/60', description: 'Per-IP limit on API writes' },
+  { key: 'limits.rateLimit.read', env: 'RATE_LIMIT_READ', reloadable: true, type: 'rateLimit', default: '600/60', description: 'Per-IP limit on API reads' },
+
+  { key: 'jobs.intervalMs', env: 'JOB_INTERVAL_MS', type: 'integer', min: 1000, default: 60 * 60 * 1000, description: 'Interval of the accrual, plan, reminder, escalation and report jobs' },
+  { key: 'jobs.notificationIntervalMs', env: 'NOTIFICATION_INTERVAL_MS', type: 'integer', min: 1000, default: 30 * 1000, description: 'Notification delivery interval' },
+  { key: 'jobs.exportIntervalMs', env: 'EXPORT_INTERVAL_MS', type: 'integer', min: 1000, default: 15 * 1000, description: 'Export worker interval' },
+  { key: 'jobs.distributedLocks', env: 'JOB_DISTRIBUTED_LOCKS', type: 'boolean', default: true, description: 'Run each job on one instance at a time (Postgres advisory locks); needs a session-level connection, not a transaction-mode pooler' },
+  { key: 'jobs.notificationMaxAttempts', env: 'NOTIFICATION_MAX_ATTEMPTS', type: 'integer', min: 1, default: 5, description: 'Delivery attempts before a notification is marked failed' },
+  { key: 'jobs.notificationRetryDelaySeconds', env: 'NOTIFICATION_RETRY_DELAY_SECONDS', type: 'integer', min: 1, default: 60, description: 'Base delay of the exponential notification retry backoff' },
+  { key: 'jobs.exportBatchSize', env: 'EXPORT_BATCH_SIZE', type: 'integer', min: 1, default: 5, description: 'Export jobs claimed per worker pass' },
+  { key: 'jobs.exportRetentionHours', env: 'EXPORT_RETENTION_HOURS', type: 'integer', min: 1, default: 24, description: 'Keep finished export files this long' },
+  { key: 'jobs.exportDownloadTtlMinutes', env: 'EXPORT_DOWNLOAD_TTL_MINUTES', type: 'integer', min: 1, default: 15, description: 'Lifetime of signed export download URLs' },
+
+  { key: 'loans.interestAccrualPeriod', env: 'INTEREST_ACCRUAL_PERIOD', type: 'enum', values: ['daily', 'monthly'], default: 'daily', description: 'How often the accrual job adds interest' },
+  { key: 'loans.paymentGraceDays', env: 'PAYMENT_GRACE_DAYS', type: 'integer', default: 3, description: 'Days after the due date before an expected payment counts as missed or late' },
+  { key: 'loans.defaultAfterDays', env: 'LOAN_DEFAULT_AFTER_DAYS', type: 'integer', min: 1, default: 90, description: 'Days past due after which an unpaid loan counts as defaulted in collection metrics' },
+  { key: 'loans.riskyBorrowerScore', env: 'RISKY_BORROWER_SCORE', type: 'integer', default: 50, description: 'Risk scores below this rate a borrower as risky (0-100)' },
+  { key: 'loans.defaultCurrency', env: 'DEFAULT_CURRENCY', type: 'string', default: 'THB', description: 'Currency of loans created without one and base of consolidated totals' },
+
+  { key: 'reminders.daysBefore', env: 'REMINDER_DAYS_BEFORE', reloadable: true, type: 'integerList', default: [3], description: 'Days before the due date to remind users who have no reminder rules' },
+  { key: 'reminders.escalationDays', env: 'OVERDUE_ESCALATION_DAYS', reloadable: true, type: 'integerList', default: [3, 14, 30], description: 'Days overdue for the gentle, firm and final escalation steps' },
+
+  { key: 'dates.defaultTimezone', env: 'DEFAULT_TIMEZONE', type: 'timezone', default: 'Asia/Bangkok', description: 'Time zone that decides "today" (due and overdue dates) for users without a timezone preference' },
+
+  { key: 'rates.provider', env: 'EXCHANGE_RATES_PROVIDER', type: 'enum', values: ['manual', 'api'], default: 'manual', description: 'Exchange rate source for consolidated totals: EXCHANGE_RATES (manual) or EXCHANGE_RATES_API_URL (api)' },
+  { key: 'rates.manual', env: 'EXCHANGE_RATES', reloadable: true, type: 'rates', default: {}, description: 'Manual rates as CODE=value of one unit in DEFAULT_CURRENCY, e.g. USD=36.5,EUR=39.2' },
+  { key: 'rates.apiUrl', env: 'EXCHANGE_RATES_API_URL', type: 'string', default: 'https://open.er-api.com/v6/latest/{base}', description: 'Rates API URL; {base} is replaced with DEFAULT_CURRENCY' },
+  { key: 'rates.cacheSeconds', env: 'EXCHANGE_RATES_CACHE_SECONDS', type: 'integer', min: 1, default: 3600, description: 'How long fetched API rates are reused' },
+
+  { key: 'cache.redisUrl', env: 'REDIS_URL', type: 'string', default: null, secret: true, description: 'Redis (redis:// or rediss://) for the response cache and rate limit counters (unset: in-memory per instance)' },
+  { key: 'cache.dashboardTtlSeconds', env: 'DASHBOARD_CACHE_TTL_SECONDS', type: 'integer', min: 1, default: 60, description: 'Lifetime of cached dashboard responses' },
+  { key: 'events.heartbeatMs', env: 'EVENTS_HEARTBEAT_MS', type: 'integer', min: 1000, default: 25000, description: 'Heartbeat interval of the GET /api/v1/events stream' },
+
+  { key: 'storage.driver', env: 'STORAGE_DRIVER', type: 'enum', values: ['local'], default: 'local', description: 'File storage backend' },
+  { key: 'storage.dir', env: 'STORAGE_DIR', type: 'string', default: './uploads', description: 'Directory of the local storage driver' },
+
+  { key: 'notifications.email.provider', env: 'EMAIL_PROVIDER', type: 'enum', values: ['log', 'sendgrid', 'resend'], default: 'log', description: 'Email delivery provider' },
+  { key: 'notifications.email.apiKey', env: 'EMAIL_API_KEY', type: 'string', default: null, secret: true, description: 'API key of the email provider' },
+  { key: 'notifications.email.from', env: 'EMAIL_FROM', type: 'string', default: 'no-reply@loan-money.local', description: 'Sender address of notification emails' },
+  { key: 'notifications.sms.provider', env: 'SMS_PROVIDER', type: 'enum', values: ['twilio', 'thaibulksms', 'log'], default: null, description: 'SMS delivery provider (SMS is off when unset)' },
+  { key: 'notifications.sms.twilioAccountSid', env: 'TWILIO_ACCOUNT_SID', type: 'string', default: null, description: 'Twilio account SID' },
+  { key: 'notifications.sms.twilioAuthToken', env: 'TWILIO_AUTH_TOKEN', type: 'string', default: null, secret: true, description: 'Twilio auth token' },
+  { key: 'notifications.sms.twilioFrom', env: 'TWILIO_FROM_NUMBER', type: 'string', default: null, description: 'Twilio sender number' },
+  { key: 'notifications.sms.thaiBulkSmsApiKey', env: 'THAIBULKSMS_API_KEY', type: 'string', default: null, description: 'ThaiBulkSMS API key' },
+  { key: 'notifications.sms.thaiBulkSmsApiSecret', env: 'THAIBULKSMS_API_SECRET', type: 'string', default: null, secret: true, description: 'ThaiBulkSMS API secret' },
+  { key: 'notifications.sms.thaiBulkSmsSender', env: 'THAIBULKSMS_SENDER', type: 'string', default: null, description: 'ThaiBulkSMS sender name (unset: the account default)' },
+  { key: 'notifications.webPush.publicKey', env: 'VAPID_PUBLIC_KEY', type: 'string', default: null, description: 'VAPID public key (Web Push is off when unset)' },
+  { key: 'notifications.webPush.privateKey', env: 'VAPID_PRIVATE_KEY', type: 'string', default: null, secret: true, description: 'VAPID private key' },
+  { key: 'notifications.webPush.subject', env: 'VAPID_SUBJECT', type: 'string', default: 'mailto:admin@loan-money.local', description: 'VAPID contact (mailto: or https: URL)' },
+
+  { key: 'integrations.line.clientId', env: 'LINE_NOTIFY_CLIENT_ID', type: 'string', default: null, description: 'LINE Notify OAuth client ID (LINE is off when unset)' },
+  { key: 'integrations.line.clientSecret', env: 'LINE_NOTIFY_CLIENT_SECRET', type: 'string', default: null, secret: true, description: 'LINE Notify OAuth client secret' },
+  { key: 'integrations.line.redirectUri', env: 'LINE_NOTIFY_REDIRECT_URI', type: 'string', default: null, description: 'LINE Notify OAuth callback URL' },
+  { key: 'integrations.telegram.botToken', env: 'TELEGRAM_BOT_TOKEN', type: 'string', default: null, secret: true, description: 'Telegram bot token (Telegram is off when unset)' },
+  { key: 'integrations.telegram.botUsername', env: 'TELEGRAM_BOT_USERNAME', type: 'string', default: null, description: 'Telegram bot username used in link deep links' },
+  { key: 'integrations.telegram.webhookSecret', env: 'TELEGRAM_WEBHOOK_SECRET', type: 'string', default: null, secret: true, description: 'secret_token registered with setWebhook' },
+
+  { key: 'sentry.dsn', env: 'SENTRY_DSN', type: 'string', default: null, secret: true, description: 'Report unhandled errors and 5xx responses to this Sentry DSN' },
+  { key: 'sentry.environment', env: 'SENTRY_ENVIRONMENT', type: 'string', default: null, description: 'Sentry environment (unset: NODE_ENV, else development)' },
+  { key: 'sentry.release', env: 'SENTRY_RELEASE', type: 'string', default: null, description: 'Sentry release (unset: loan-money-api@<package version>)' },
+
+  { key: 'demo.username', env: 'DEMO_USERNAME', type: 'string', default: 'demo', description: 'Demo account created by `npm run seed`' },
+  { key: 'demo.password', env: 'DEMO_PASSWORD', type: 'string', default: 'demo1234', secret: true, description: 'Password of the demo account' },
+
+  { key: 'logging.level', env: 'LOG_LEVEL', reloadable: true, type: 'enum', values: LOG_LEVELS, default: 'info', description: 'Drop console output below this level' },
+  { key: 'logging.accessLogFormat', env: 'ACCESS_LOG_FORMAT', reloadable: true, type: 'enum', values: ['json', 'text'], default: 'json', description: 'Access log format' },
+  { key: 'logging.accessLogSampleRate', env: 'ACCESS_LOG_SAMPLE_RATE', reloadable: true, type: 'fraction', default: 1, description: 'Fraction of successful requests to log (errors are always logged)' },
+  { key: 'logging.accessLogVerbose', env: 'ACCESS_LOG_VERBOSE', reloadable: true, type: 'boolean', default: false, description: 'Also log redacted request headers, and bodies of failed requests' }
+];
+
+/**
+ * Set a dotted path on an object, creating intermediate objects
+ */
+function assign(target, key, value) {
+  const parts = key.split('.');
+  const last = parts.pop();
+  const parent = parts.reduce((node, part) => (node[part] = node[part] || {}), target);
+  parent[last] = value;
+}
+
+/**
+ * Read a dotted path from an object
+ */
+function lookup(source, key) {
+  return key.split('.').reduce((node, part) => (node ? node[part] : undefined), source);
+}
+
+/**
+ * Parse and validate every setting; throws ConfigError listing all problems at once
+ */
+function loadConfig(env = process.env) {
+  const production = env.NODE_ENV === 'production';
+  const config = {};
+  const issues = [];
+
+  SETTINGS.forEach(setting => {
+    const raw = env[setting.env] === undefined ? '' : String(env[setting.env]).trim();
+    if (raw === '') {
+      if (setting.requiredInProduction && production) {
+        issues.push(`${setting.env} is required in production`);
+      }
+      const fallback = typeof setting.default === 'string' && setting.type !== 'string'
+        ? TYPES[setting.type](setting.default, setting)
+        : setting.default;
+      assign(config, setting.key, fallback);
+      return;
+    }
+
+    try {
+      const value = TYPES[setting.type](raw, setting);
+      const problem = production && setting.validate ? setting.validate(value) : null;
+      if (problem) {
+        issues.push(`${setting.env} ${problem}`);
+      }
+      assign(config, setting.key, value);
+    } catch (error) {
+      issues.push(`${setting.env} ${error.message} (got "${setting.secret ? '***' : raw}")`);
+    }
+  });
+
+  if (config.server.headersTimeoutMs > config.server.requestTimeoutMs) {
+    issues.push('HTTP_HEADERS_TIMEOUT_MS must not exceed HTTP_REQUEST_TIMEOUT_MS');
+  }
+
+  if (issues.length > 0) {
+    throw new ConfigError(issues);
+  }
+
+  config.server.production = production;
+  return config;
+}
+
+/**
+ * Documented settings with their effective values (secrets masked), for `npm run config`
+ */
+function describeConfig(env = process.env) {
+  return SETTINGS.map(setting => {
+    const raw = env[setting.env];
+    const set = raw !== undefined && String(raw).trim() !== '';
+    return {
+      env: setting.env,
+      type: setting.values ? setting.values.join('|') : setting.type,
+      reloadable: Boolean(setting.reloadable),
+      default: setting.default === null ? '' : JSON.stringify(setting.default),
+      value: set ? (setting.secret ? '***' : raw) : '',
+      description: setting.description
+    };
+  });
+}
+
+module.exports = {
+  SETTINGS,
+  LOG_LEVELS,
+  ConfigError,
+  assign,
+  lookup,
+  loadConfig,
+  describeConfig
+};
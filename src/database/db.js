@@ -1,27 +1,189 @@
+const { AsyncLocalStorage } = require('async_hooks');
+const { setTimeout: sleep } = require('timers/promises');
 const { Pool } = require('pg');
+const config = require('../config');
+const { DEFAULT_CURRENCY, isSupportedCurrency } = require('../utils/currency');
+
+/**
+ * Translate a libpq sslmode into pg's ssl option (undefined when not set)
+ */
+function sslFromMode(mode) {
+  if (!mode) {
+    return undefined;
+  }
+  if (mode === 'disable') {
+    return false;
+  }
+  // verify-ca / verify-full check the certificate; require, prefer and allow only encrypt
+  return { rejectUnauthorized: mode.startsWith('verify') };
+}
+
+/**
+ * Parse a postgres:// DATABASE_URL into pool options.
+ * Supported query params: sslmode, connect_timeout (seconds), application_name,
+ * and pool size as pool_max or connection_limit.
+ */
+function parseDatabaseUrl(databaseUrl) {
+  const url = new URL(databaseUrl);
+  if (!['postgres:', 'postgresql:'].includes(url.protocol)) {
+    throw new Error('DATABASE_URL must be a postgres:// or postgresql:// URI');
+  }
+
+  const params = url.searchParams;
+  const connectTimeout = parseInt(params.get('connect_timeout'));
+  return {
+    host: decodeURIComponent(url.hostname),
+    port: parseInt(url.port) || 5432,
+    user: decodeURIComponent(url.username),
+    password: decodeURIComponent(url.password),
+    database: decodeURIComponent(url.pathname.slice(1)),
+    ssl: sslFromMode(params.get('sslmode')),
+    max: parseInt(params.get('pool_max') || params.get('connection_limit')) || undefined,
+    connectionTimeoutMillis: connectTimeout ? connectTimeout * 1000 : undefined,
+    application_name: params.get('application_name') || undefined
+  };
+}
+
+// Retries for transient failures (cold starts, pooler restarts, connection limits)
+const RETRY_ATTEMPTS = config.database.retryAttempts;
+const RETRY_BASE_DELAY_MS = config.database.retryBaseDelayMs;
+const RETRY_MAX_DELAY_MS = 2000;
+
+// Network errors and SQLSTATEs (class 08 connection exceptions, too_many_connections, server shutdown/startup)
+const TRANSIENT_NETWORK_CODES = ['ECONNRESET', 'ECONNREFUSED', 'ETIMEDOUT', 'EPIPE', 'EAI_AGAIN'];
+const TRANSIENT_SQLSTATES = ['53300', '57P01', '57P02', '57P03'];
+const TRANSIENT_MESSAGES = /timeout exceeded when trying to connect|Connection terminated unexpectedly|Connection terminated due to connection timeout/;
+
+/**
+ * Whether an error is worth retrying: the connection failed, not the statement
+ */
+function isTransientError(error) {
+  if (!error) {
+    return false;
+  }
+  const code = String(error.code || '');
+  return TRANSIENT_NETWORK_CODES.includes(code) ||
+    TRANSIENT_SQLSTATES.includes(code) ||
+    code.startsWith('08') ||
+    TRANSIENT_MESSAGES.test(error.message || '');
+}
 
 class Database {
   constructor() {
-    this.pool = new Pool({
-      host: process.env.DB_HOST || 'localhost',
-      port: process.env.DB_PORT || 5432,
-      user: process.env.DB_USER || 'postgres',
-      password: process.env.DB_PASSWORD || '',
-      database: process.env.DB_NAME || 'loan_management',
-      ssl: process.env.NODE_ENV === 'production' ? { rejectUnauthorized: false } : false,
+    // DATABASE_URL (Supabase, Neon, Railway) takes precedence over the discrete DB_* variables
+    const connection = config.database.url ? parseDatabaseUrl(config.database.url) : {
+      host: config.database.host,
+      port: config.database.port,
+      user: config.database.user,
+      password: config.database.password,
+      database: config.database.name,
+      ssl: sslFromMode(config.database.sslMode)
+    };
+
+    this.pool = this.createPool(connection, 'PostgreSQL database');
+
+    // Optional read-only replica for list, dashboard and report reads; falls back to the primary
+    this.replicaPool = config.database.replicaUrl
+      ? this.createPool(parseDatabaseUrl(config.database.replicaUrl), 'PostgreSQL read replica')
+      : null;
+    // Query interface (db.reader.query) with the same shape as db, for code that takes a client
+    this.reader = { query: (text, params) => this.readQuery(text, params) };
+
+    // Ambient transaction shared by everything running inside transaction()
+    this.transactionContext = new AsyncLocalStorage();
+    // Per-request { signal } that cancels in-flight queries on timeout or client disconnect
+    this.requestContext = new AsyncLocalStorage();
+  }
+
+  /**
+   * Create a connection pool with the shared sizing and timeout settings
+   */
+  createPool(connection, label) {
+    const pool = new Pool({
+      ...connection,
+      ssl: connection.ssl !== undefined
+        ? connection.ssl
+        : (config.server.production ? { rejectUnauthorized: false } : false),
+      // Pool sizing; keep max low on serverless and behind pooled providers (PgBouncer, Supabase, Neon)
+      max: config.database.poolMax || connection.max || 10,
+      idleTimeoutMillis: config.database.poolIdleTimeoutMs,
+      connectionTimeoutMillis: config.database.poolConnectionTimeoutMs ?? connection.connectionTimeoutMillis ?? 0,
+      maxLifetimeSeconds: config.database.poolMaxLifetimeSeconds,
+      // Server-side cap for any single statement, including background jobs (0 = no limit)
+      statement_timeout: config.database.statementTimeoutMs,
     });
 
-    this.pool.on('connect', () => {
-      console.log('Connected to PostgreSQL database');
+    pool.on('connect', () => {
+      console.log(`Connected to ${label}`);
     });
 
-    this.pool.on('error', (err) => {
+    pool.on('error', (err) => {
       console.error('Database connection error:', err);
     });
+
+    return pool;
+  }
+
+  /**
+   * Run fn, retrying transient errors with full-jitter exponential backoff.
+   * Stops early once the current request has been aborted.
+   */
+  async withRetry(fn) {
+    const context = this.requestContext.getStore();
+    for (let attempt = 1; ; attempt++) {
+      try {
+        return await fn();
+      } catch (error) {
+        if (error.retriesExhausted || !isTransientError(error) || (context && context.signal.aborted)) {
+          throw error;
+        }
+        if (attempt >= RETRY_ATTEMPTS) {
+          // Marked so an enclosing withRetry (readQuery around acquire) does not multiply attempts
+          error.retriesExhausted = true;
+          throw error;
+        }
+        const delay = Math.random() * Math.min(RETRY_MAX_DELAY_MS, RETRY_BASE_DELAY_MS * 2 ** (attempt - 1));
+        console.warn(`Transient database error (${error.code || error.message}), retrying in ${Math.round(delay)}ms`);
+        await sleep(delay);
+      }
+    }
+  }
+
+  /**
+   * Check out a pool client; inside a request context its running query is cancelled when the request aborts
+   */
+  async acquire(pool = this.pool) {
+    const context = this.requestContext.getStore();
+    if (context && context.signal.aborted) {
+      throw context.signal.reason;
+    }
+
+    const client = await this.withRetry(() => pool.connect());
+    if (!context) {
+      return client;
+    }
+
+    const cancel = () => {
+      pool.query('SELECT pg_cancel_backend($1)', [client.processID])
+        .catch(error => console.error('Query cancel error:', error));
+    };
+    context.signal.addEventListener('abort', cancel, { once: true });
+
+    const release = client.release;
+    client.release = error => {
+      context.signal.removeEventListener('abort', cancel);
+      return release(error);
+    };
+    return client;
   }
 
-  async query(text, params) {
-    const client = await this.pool.connect();
+  async query(text, params, pool = this.pool) {
+    const ambient = this.transactionContext.getStore();
+    if (ambient) {
+      return ambient.client.query(text, params);
+    }
+
+    const client = await this.acquire(pool);
     try {
       const result = await client.query(text, params);
       return result;
@@ -33,6 +195,64 @@ class Database {
     }
   }
 
+  /**
+   * Run a read-only query on the replica when one is configured, retrying transient failures.
+   * Inside a transaction the read stays on the transaction client so it sees its own writes.
+   */
+  async readQuery(text, params) {
+    if (this.transactionContext.getStore()) {
+      return this.query(text, params);
+    }
+    // Reads are idempotent, so the whole query is retried, not just the connection
+    return this.withRetry(() => this.query(text, params, this.replicaPool || this.pool));
+  }
+
+  /**
+   * Get a client for a unit of work. Inside transaction() this is the ambient client,
+   * with BEGIN/COMMIT/ROLLBACK mapped to a savepoint so callers nest without changes.
+   */
+  async connect() {
+    const ambient = this.transactionContext.getStore();
+    if (!ambient) {
+      return this.acquire();
+    }
+
+    const savepoint = `nested_${++ambient.savepoints}`;
+    const statements = {
+      BEGIN: `SAVEPOINT ${savepoint}`,
+      COMMIT: `RELEASE SAVEPOINT ${savepoint}`,
+      ROLLBACK: `ROLLBACK TO SAVEPOINT ${savepoint}`
+    };
+
+    return {
+      query: (text, params) => ambient.client.query(statements[text] || text, params),
+      release: () => {}
+    };
+  }
+
+  /**
+   * Run fn(client) in one database transaction; db.query and db.connect calls made inside it join the transaction.
+   * Commits when fn resolves, rolls back when it throws or returns { rollback: true }.
+   * Nested calls run in a savepoint of the outer transaction.
+   */
+  async transaction(fn) {
+    const nested = Boolean(this.transactionContext.getStore());
+    const client = await this.connect();
+    try {
+      await client.query('BEGIN');
+      const result = nested
+        ? await fn(client)
+        : await this.transactionContext.run({ client, savepoints: 0 }, () => fn(client));
+      await client.query(result && result.rollback ? 'ROLLBACK' : 'COMMIT');
+      return result;
+    } catch (error) {
+      await client.query('ROLLBACK');
+      throw error;
+    } finally {
+      client.release();
+    }
+  }
+
   async createTables() {
     try {
       // Users table
@@ -64,6 +284,113 @@ class Database {
         )
       `);
 
+      // User contact and notification columns
+      await this.query(`
+        ALTER TABLE users
+          ADD COLUMN IF NOT EXISTS email VARCHAR(255),
+          ADD COLUMN IF NOT EXISTS phone VARCHAR(50),
+          ADD COLUMN IF NOT EXISTS address TEXT,
+          ADD COLUMN IF NOT EXISTS email_notifications BOOLEAN DEFAULT true,
+          ADD COLUMN IF NOT EXISTS sms_borrower_reminders BOOLEAN DEFAULT false
+      `);
+
+      // Display preferences (null: the server default)
+      await this.query(`
+        ALTER TABLE users
+          ADD COLUMN IF NOT EXISTS preferred_currency VARCHAR(3),
+          ADD COLUMN IF NOT EXISTS calendar VARCHAR(16),
+          ADD COLUMN IF NOT EXISTS timezone VARCHAR(64),
+          ADD COLUMN IF NOT EXISTS locale VARCHAR(35)
+      `);
+
+      // Borrowers table
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS borrowers (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          user_id UUID REFERENCES users(id) NOT NULL,
+          name VARCHAR(255) NOT NULL,
+          phone VARCHAR(50),
+          email VARCHAR(255),
+          line_id VARCHAR(255),
+          address TEXT,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          deleted_at TIMESTAMP WITHOUT TIME ZONE
+        )
+      `);
+
+      // Borrower notes and interaction history
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS borrower_notes (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          borrower_id UUID REFERENCES borrowers(id) NOT NULL,
+          user_id UUID REFERENCES users(id) NOT NULL,
+          note_type VARCHAR(50) DEFAULT 'note',
+          content TEXT NOT NULL,
+          promised_amount NUMERIC,
+          promised_date DATE,
+          occurred_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+        )
+      `);
+
+      // Loan borrower columns
+      await this.query(`
+        ALTER TABLE loans
+          ADD COLUMN IF NOT EXISTS borrower_id UUID REFERENCES borrowers(id),
+          ADD COLUMN IF NOT EXISTS borrower_phone VARCHAR(50),
+          ADD COLUMN IF NOT EXISTS borrower_address TEXT,
+          ADD COLUMN IF NOT EXISTS notes TEXT
+      `);
+
+      // Backfill borrowers from existing loans
+      await this.query(`
+        INSERT INTO borrowers (user_id, name, phone, address)
+        SELECT DISTINCT ON (l.user_id, l.borrower_name)
+          l.user_id, l.borrower_name, l.borrower_phone, l.borrower_address
+        FROM loans l
+        WHERE l.borrower_id IS NULL
+        AND NOT EXISTS (
+          SELECT 1 FROM borrowers b WHERE b.user_id = l.user_id AND b.name = l.borrower_name
+        )
+        ORDER BY l.user_id, l.borrower_name, l.created_at DESC
+      `);
+      await this.query(`
+        UPDATE loans l
+        SET borrower_id = (
+          SELECT b.id FROM borrowers b
+          WHERE b.user_id = l.user_id AND b.name = l.borrower_name
+          ORDER BY b.created_at
+          LIMIT 1
+        )
+        WHERE l.borrower_id IS NULL
+      `);
+
+      // Loan interest columns
+      await this.query(`
+        ALTER TABLE loans
+          ADD COLUMN IF NOT EXISTS interest_rate NUMERIC DEFAULT 0,
+          ADD COLUMN IF NOT EXISTS interest_type VARCHAR(50) DEFAULT 'reducing',
+          ADD COLUMN IF NOT EXISTS term_months INTEGER,
+          ADD COLUMN IF NOT EXISTS last_accrued_at DATE
+      `);
+
+      // Loan direction: money lent out (owed to the user) or borrowed (owed by the user)
+      await this.query(`
+        ALTER TABLE loans
+          ADD COLUMN IF NOT EXISTS direction VARCHAR(20) DEFAULT 'lent'
+      `);
+
+      // Loan currency (ISO 4217); loans created before multi-currency support were in the default currency.
+      // Interpolated because DDL takes no parameters: the code is checked against the currency registry first.
+      if (!isSupportedCurrency(DEFAULT_CURRENCY)) {
+        throw new Error(`DEFAULT_CURRENCY ${DEFAULT_CURRENCY} is not a supported currency`);
+      }
+      await this.query(`
+        ALTER TABLE loans
+          ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT '${DEFAULT_CURRENCY}'
+      `);
+
       // Transactions table
       await this.query(`
         CREATE TABLE IF NOT EXISTS transactions (
@@ -78,6 +405,505 @@ class Database {
         )
       `);
 
+      // Transaction detail columns
+      await this.query(`
+        ALTER TABLE transactions
+          ADD COLUMN IF NOT EXISTS user_id UUID REFERENCES users(id),
+          ADD COLUMN IF NOT EXISTS transaction_type VARCHAR(50) DEFAULT 'payment',
+          ADD COLUMN IF NOT EXISTS transaction_date DATE,
+          ADD COLUMN IF NOT EXISTS description TEXT,
+          ADD COLUMN IF NOT EXISTS status VARCHAR(50) DEFAULT 'confirmed',
+          ADD COLUMN IF NOT EXISTS confirmed_at TIMESTAMP WITH TIME ZONE
+      `);
+
+      // Transaction currency, always the loan's currency (enforced by the transactions_currency trigger)
+      await this.query(`
+        ALTER TABLE transactions
+          ADD COLUMN IF NOT EXISTS currency VARCHAR(3)
+      `);
+      await this.query(`
+        UPDATE transactions t SET currency = l.currency
+        FROM loans l
+        WHERE t.loan_id = l.id AND t.currency IS NULL
+      `);
+
+      // Audit log
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS audit_logs (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          user_id UUID REFERENCES users(id) NOT NULL,
+          action VARCHAR(100) NOT NULL,
+          entity_type VARCHAR(100) NOT NULL,
+          entity_id UUID,
+          details JSONB,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+        )
+      `);
+
+      // Notification outbox
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS notifications (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          user_id UUID REFERENCES users(id) NOT NULL,
+          event VARCHAR(100) NOT NULL,
+          channel VARCHAR(50) NOT NULL,
+          recipient VARCHAR(512),
+          subject TEXT,
+          body TEXT,
+          payload JSONB,
+          status VARCHAR(50) DEFAULT 'pending',
+          attempts INTEGER DEFAULT 0,
+          next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          last_error TEXT,
+          sent_at TIMESTAMP WITH TIME ZONE,
+          read_at TIMESTAMP WITH TIME ZONE,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+        )
+      `);
+
+      // Third-party integrations per user (LINE, Telegram, ...)
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS user_integrations (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          user_id UUID REFERENCES users(id) NOT NULL,
+          provider VARCHAR(50) NOT NULL,
+          access_token TEXT NOT NULL,
+          metadata JSONB,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          UNIQUE (user_id, provider)
+        )
+      `);
+
+      // Per-user notification preferences (missing rows mean enabled)
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS notification_preferences (
+          user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+          event VARCHAR(100) NOT NULL,
+          channel VARCHAR(50) NOT NULL,
+          enabled BOOLEAN NOT NULL,
+          updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          PRIMARY KEY (user_id, event, channel)
+        )
+      `);
+
+      // Overdue escalation steps fired per loan and due date
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS loan_escalations (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          loan_id UUID REFERENCES loans(id) ON DELETE CASCADE NOT NULL,
+          due_date DATE NOT NULL,
+          step VARCHAR(20) NOT NULL,
+          days_overdue INTEGER NOT NULL,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          UNIQUE (loan_id, due_date, step)
+        )
+      `);
+
+      // Browser push subscriptions (endpoint is unique per browser profile)
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS push_subscriptions (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+          endpoint TEXT UNIQUE NOT NULL,
+          p256dh VARCHAR(255) NOT NULL,
+          auth VARCHAR(255) NOT NULL,
+          user_agent TEXT,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+        )
+      `);
+
+      // Outgoing webhook endpoints and their delivery log
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS webhook_endpoints (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+          url TEXT NOT NULL,
+          secret VARCHAR(255) NOT NULL,
+          events TEXT[] NOT NULL,
+          active BOOLEAN DEFAULT true,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+        )
+      `);
+
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS webhook_deliveries (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          webhook_id UUID REFERENCES webhook_endpoints(id) ON DELETE CASCADE NOT NULL,
+          notification_id UUID REFERENCES notifications(id) ON DELETE SET NULL,
+          event VARCHAR(100) NOT NULL,
+          attempt INTEGER NOT NULL,
+          status_code INTEGER,
+          response_body TEXT,
+          error TEXT,
+          duration_ms INTEGER,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+        )
+      `);
+
+      // One-time codes for linking chat integrations (e.g. Telegram deep links)
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS integration_link_codes (
+          code VARCHAR(64) PRIMARY KEY,
+          user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+          provider VARCHAR(50) NOT NULL,
+          expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+        )
+      `);
+
+      // Saved report definitions with optional scheduled delivery
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS saved_reports (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+          name VARCHAR(255) NOT NULL,
+          definition JSONB NOT NULL,
+          schedule VARCHAR(20),
+          next_run_at TIMESTAMP WITH TIME ZONE,
+          last_run_at TIMESTAMP WITH TIME ZONE,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+        )
+      `);
+
+      // Asynchronous export jobs; generated files live in storage until expires_at
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS export_jobs (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+          format VARCHAR(20) NOT NULL,
+          params JSONB NOT NULL DEFAULT '{}',
+          status VARCHAR(20) NOT NULL DEFAULT 'pending',
+          storage_key VARCHAR(512),
+          file_name VARCHAR(255),
+          content_type VARCHAR(255),
+          size INTEGER,
+          error TEXT,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          started_at TIMESTAMP WITH TIME ZONE,
+          completed_at TIMESTAMP WITH TIME ZONE,
+          expires_at TIMESTAMP WITH TIME ZONE
+        )
+      `);
+
+      // Encrypted exports keep the derived key until the file is generated
+      await this.query(`
+        ALTER TABLE export_jobs
+          ADD COLUMN IF NOT EXISTS encrypted BOOLEAN DEFAULT false,
+          ADD COLUMN IF NOT EXISTS encryption_key TEXT
+      `);
+
+      // Uploaded spreadsheets awaiting a column mapping before import
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS import_uploads (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+          target VARCHAR(50) NOT NULL,
+          file_name VARCHAR(255) NOT NULL,
+          content_type VARCHAR(255) NOT NULL,
+          storage_key VARCHAR(512) NOT NULL,
+          row_count INTEGER NOT NULL,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+        )
+      `);
+
+      // Sent loan reminders (prevents duplicate reminders per loan and key)
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS loan_reminders (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          loan_id UUID REFERENCES loans(id) ON DELETE CASCADE NOT NULL,
+          reminder_key VARCHAR(255) NOT NULL,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          UNIQUE (loan_id, reminder_key)
+        )
+      `);
+
+      // User-defined reminder rules (loan_id NULL applies to all of the user's loans)
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS reminder_rules (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+          loan_id UUID REFERENCES loans(id) ON DELETE CASCADE,
+          rule_type VARCHAR(20) NOT NULL,
+          days_before INTEGER[],
+          repeat_every_days INTEGER,
+          active BOOLEAN DEFAULT true,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+        )
+      `);
+
+      // Transaction attachments (e.g. bank transfer slips)
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS transaction_attachments (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          transaction_id UUID REFERENCES transactions(id) ON DELETE CASCADE NOT NULL,
+          user_id UUID REFERENCES users(id) NOT NULL,
+          file_name VARCHAR(255) NOT NULL,
+          content_type VARCHAR(255) NOT NULL,
+          size INTEGER NOT NULL,
+          storage_key VARCHAR(512) NOT NULL,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+        )
+      `);
+
+      // Recurring payment plans
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS payment_plans (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          loan_id UUID REFERENCES loans(id) ON DELETE CASCADE NOT NULL,
+          user_id UUID REFERENCES users(id) NOT NULL,
+          amount NUMERIC NOT NULL,
+          frequency VARCHAR(50) NOT NULL,
+          start_date DATE NOT NULL,
+          next_due_date DATE NOT NULL,
+          end_date DATE,
+          active BOOLEAN DEFAULT true,
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+        )
+      `);
+
+      // Expected payments generated from payment plans
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS expected_payments (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          plan_id UUID REFERENCES payment_plans(id) ON DELETE CASCADE NOT NULL,
+          loan_id UUID REFERENCES loans(id) ON DELETE CASCADE NOT NULL,
+          due_date DATE NOT NULL,
+          amount NUMERIC NOT NULL,
+          status VARCHAR(50) DEFAULT 'pending',
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          UNIQUE (plan_id, due_date)
+        )
+      `);
+
+      // Devices (by user agent) and countries each user has signed in from, for new-login alerts
+      await this.query(`
+        CREATE TABLE IF NOT EXISTS user_devices (
+          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+          user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+          fingerprint VARCHAR(64) NOT NULL,
+          user_agent TEXT,
+          last_ip VARCHAR(64),
+          last_country VARCHAR(2),
+          countries TEXT[] NOT NULL DEFAULT '{}',
+          trusted BOOLEAN DEFAULT false,
+          first_seen_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+          UNIQUE (user_id, fingerprint)
+        )
+      `);
+
+      // Indexes backing the keyset (cursor) pagination used by API v2
+      await this.query(`CREATE INDEX IF NOT EXISTS idx_loans_user_created ON loans (user_id, created_at DESC, id DESC)`);
+      await this.query(`CREATE INDEX IF NOT EXISTS idx_transactions_user_created ON transactions (user_id, created_at DESC, id DESC)`);
+
+      // Indexes for listing and dashboard queries
+      await this.query(`CREATE INDEX IF NOT EXISTS idx_loans_user_status ON loans (user_id, status)`);
+      await this.query(`CREATE INDEX IF NOT EXISTS idx_loans_due_date ON loans (due_date)`);
+      await this.query(`CREATE INDEX IF NOT EXISTS idx_transactions_loan_deleted ON transactions (loan_id, deleted_at)`);
+      await this.query(`CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON transactions (created_at)`);
+      await this.query(`CREATE INDEX IF NOT EXISTS idx_loans_user_active ON loans (user_id) WHERE deleted_at IS NULL`);
+
+      // Materialized loan balances, kept current by triggers on every loan and transaction write
+      await this.query(`
+        ALTER TABLE loans
+          ADD COLUMN IF NOT EXISTS total_paid NUMERIC NOT NULL DEFAULT 0,
+          ADD COLUMN IF NOT EXISTS total_charges NUMERIC NOT NULL DEFAULT 0,
+          ADD COLUMN IF NOT EXISTS remaining_debt NUMERIC,
+          ADD COLUMN IF NOT EXISTS pending_amount NUMERIC NOT NULL DEFAULT 0
+      `);
+
+      // Balance totals of one loan's live transactions (same rules as the loan_ledger view)
+      await this.query(`
+        CREATE OR REPLACE FUNCTION loan_balance_totals(p_loan_id UUID)
+        RETURNS TABLE (total_paid NUMERIC, total_charges NUMERIC, balance_effect NUMERIC, pending_amount NUMERIC) AS $$
+          SELECT
+            COALESCE(SUM(amount) FILTER (WHERE status = 'confirmed' AND transaction_type = 'payment'), 0),
+            COALESCE(SUM(amount) FILTER (WHERE status = 'confirmed' AND transaction_type IN ('interest', 'fee')), 0),
+            COALESCE(SUM(CASE WHEN transaction_type = 'payment' THEN -amount ELSE amount END) FILTER (WHERE status = 'confirmed'), 0),
+            COALESCE(SUM(amount) FILTER (WHERE status = 'pending' AND transaction_type = 'payment'), 0)
+          FROM transactions
+          WHERE loan_id = p_loan_id AND deleted_at IS NULL
+        $$ LANGUAGE sql STABLE
+      `);
+
+      await this.query(`
+        CREATE OR REPLACE FUNCTION refresh_loan_balance(p_loan_id UUID) RETURNS void AS $$
+          UPDATE loans l
+          SET total_paid = t.total_paid,
+              total_charges = t.total_charges,
+              remaining_debt = l.amount + t.balance_effect,
+              pending_amount = t.pending_amount
+          FROM loan_balance_totals(p_loan_id) t
+          WHERE l.id = p_loan_id
+        $$ LANGUAGE sql
+      `);
+
+      // New loans and principal changes recompute the balance of the row being written
+      await this.query(`
+        CREATE OR REPLACE FUNCTION loans_set_balance() RETURNS trigger AS $$
+        DECLARE
+          totals RECORD;
+        BEGIN
+          SELECT * INTO totals FROM loan_balance_totals(NEW.id);
+          NEW.total_paid := totals.total_paid;
+          NEW.total_charges := totals.total_charges;
+          NEW.remaining_debt := NEW.amount + totals.balance_effect;
+          NEW.pending_amount := totals.pending_amount;
+          RETURN NEW;
+        END
+        $$ LANGUAGE plpgsql
+      `);
+      await this.query('DROP TRIGGER IF EXISTS loans_balance ON loans');
+      await this.query(`
+        CREATE TRIGGER loans_balance
+        BEFORE INSERT OR UPDATE OF amount ON loans
+        FOR EACH ROW EXECUTE FUNCTION loans_set_balance()
+      `);
+
+      // Transaction writes refresh the old and new loan (moves change loan_id)
+      await this.query(`
+        CREATE OR REPLACE FUNCTION transactions_refresh_loan_balance() RETURNS trigger AS $$
+        BEGIN
+          IF TG_OP <> 'INSERT' THEN
+            PERFORM refresh_loan_balance(OLD.loan_id);
+          END IF;
+          IF TG_OP = 'INSERT' OR (TG_OP = 'UPDATE' AND NEW.loan_id IS DISTINCT FROM OLD.loan_id) THEN
+            PERFORM refresh_loan_balance(NEW.loan_id);
+          END IF;
+          RETURN NULL;
+        END
+        $$ LANGUAGE plpgsql
+      `);
+      await this.query('DROP TRIGGER IF EXISTS transactions_loan_balance ON transactions');
+      await this.query(`
+        CREATE TRIGGER transactions_loan_balance
+        AFTER INSERT OR UPDATE OR DELETE ON transactions
+        FOR EACH ROW EXECUTE FUNCTION transactions_refresh_loan_balance()
+      `);
+
+      // Transactions take their loan's currency; a mismatch (e.g. moving an entry to a loan in another currency) is refused
+      await this.query(`
+        CREATE OR REPLACE FUNCTION transactions_set_currency() RETURNS trigger AS $$
+        DECLARE
+          loan_currency VARCHAR(3);
+        BEGIN
+          SELECT currency INTO loan_currency FROM loans WHERE id = NEW.loan_id;
+          IF NEW.currency IS NULL THEN
+            NEW.currency := loan_currency;
+          END IF;
+          IF NEW.currency IS DISTINCT FROM loan_currency THEN
+            RAISE EXCEPTION 'Transaction currency % does not match loan currency %', NEW.currency, loan_currency
+              USING ERRCODE = 'check_violation';
+          END IF;
+          RETURN NEW;
+        END
+        $$ LANGUAGE plpgsql
+      `);
+      await this.query('DROP TRIGGER IF EXISTS transactions_currency ON transactions');
+      await this.query(`
+        CREATE TRIGGER transactions_currency
+        BEFORE INSERT OR UPDATE OF loan_id, currency ON transactions
+        FOR EACH ROW EXECUTE FUNCTION transactions_set_currency()
+      `);
+
+      // Backfill loans created before balances were materialized
+      await this.query(`
+        UPDATE loans l
+        SET (total_paid, total_charges, remaining_debt, pending_amount) = (
+          SELECT t.total_paid, t.total_charges, l.amount + t.balance_effect, t.pending_amount
+          FROM loan_balance_totals(l.id) t
+        )
+        WHERE l.remaining_debt IS NULL
+      `);
+
+      // Ledger view: disbursement plus every transaction as a typed entry.
+      // Payments reduce the balance, adjustments are signed, everything else increases it.
+      await this.query(`
+        CREATE OR REPLACE VIEW loan_ledger AS
+        SELECT
+          l.id as loan_id,
+          l.user_id,
+          NULL::uuid as transaction_id,
+          'disbursement'::varchar as entry_type,
+          l.amount,
+          l.amount as balance_effect,
+          l.loan_date as entry_date,
+          l.created_at,
+          'Loan disbursement'::text as description
+        FROM loans l
+        WHERE l.deleted_at IS NULL
+        UNION ALL
+        SELECT
+          t.loan_id,
+          l.user_id,
+          t.id as transaction_id,
+          t.transaction_type as entry_type,
+          t.amount,
+          CASE WHEN t.transaction_type = 'payment' THEN -t.amount ELSE t.amount END as balance_effect,
+          COALESCE(t.transaction_date, t.payment_date::date, t.created_at::date) as entry_date,
+          t.created_at,
+          COALESCE(t.description, t.remark) as description
+        FROM transactions t
+        JOIN loans l ON t.loan_id = l.id
+        WHERE t.deleted_at IS NULL
+        AND l.deleted_at IS NULL
+        AND t.status = 'confirmed'
+      `);
+
+      // Per-loan balance summary, read from the materialized columns on loans
+      await this.query(`
+        CREATE OR REPLACE VIEW loan_balances AS
+        SELECT
+          l.id as loan_id,
+          l.total_paid,
+          l.total_charges,
+          l.remaining_debt,
+          l.pending_amount
+        FROM loans l
+      `);
+
+      // Rows written before updated_at was maintained everywhere
+      await this.query('UPDATE transactions SET updated_at = COALESCE(created_at, now()) WHERE updated_at IS NULL');
+
+      // Bump updated_at on every row change, whatever code path issued the UPDATE
+      await this.query(`
+        CREATE OR REPLACE FUNCTION set_updated_at() RETURNS trigger AS $$
+        BEGIN
+          IF NEW IS DISTINCT FROM OLD THEN
+            NEW.updated_at := CURRENT_TIMESTAMP;
+          END IF;
+          RETURN NEW;
+        END
+        $$ LANGUAGE plpgsql
+      `);
+      await this.query(`
+        DO $$
+        DECLARE
+          target RECORD;
+        BEGIN
+          FOR target IN
+            SELECT c.table_name FROM information_schema.columns c
+            JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+            WHERE c.table_schema = current_schema() AND c.column_name = 'updated_at' AND t.table_type = 'BASE TABLE'
+          LOOP
+            EXECUTE format('DROP TRIGGER IF EXISTS %I ON %I', target.table_name || '_updated_at', target.table_name);
+            EXECUTE format(
+              'CREATE TRIGGER %I BEFORE UPDATE ON %I FOR EACH ROW EXECUTE FUNCTION set_updated_at()',
+              target.table_name || '_updated_at', target.table_name
+            );
+          END LOOP;
+        END
+        $$
+      `);
+
       console.log('Database tables created successfully');
     } catch (error) {
       console.error('Error creating tables:', error);
@@ -85,8 +911,20 @@ class Database {
     }
   }
 
+  /**
+   * Connection pool statistics for the metrics endpoint
+   */
+  getPoolStats(pool = this.pool) {
+    return {
+      max: pool.options.max,
+      total: pool.totalCount,
+      idle: pool.idleCount,
+      waiting: pool.waitingCount
+    };
+  }
+
   async close() {
-    await this.pool.end();
+    await Promise.all([this.pool, this.replicaPool].filter(Boolean).map(pool => pool.end()));
   }
 }
 
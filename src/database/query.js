@@ -0,0 +1,192 @@
+const db = require('./db');
+const { ValidationError } = require('../utils/response');
+const { DATE_FORMAT_HINT, parseDate } = require('../utils/dates');
+
+// Comparison operators for "?name[op]=value" filters
+const OPERATORS = { eq: '=', ne: '<>', gt: '>', gte: '>=', lt: '<', lte: '<=' };
+// Operators each filter type accepts; the first is used for a plain "?name=value"
+const TYPE_OPERATORS = {
+  uuid: ['eq', 'ne', 'in'],
+  enum: ['eq', 'ne', 'in'],
+  string: ['eq', 'ne', 'in'],
+  number: ['eq', 'ne', 'gt', 'gte', 'lt', 'lte'],
+  date: ['eq', 'ne', 'gt', 'gte', 'lt', 'lte'],
+  search: ['contains']
+};
+const ARRAY_CASTS = { uuid: 'uuid[]', enum: 'text[]', string: 'text[]' };
+const UUID_PATTERN = /^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$/i;
+
+/**
+ * ValidationError for a filter parameter
+ */
+function invalidFilter(name, message) {
+  return new ValidationError(`${name} ${message}`, [{ field: name, issue: 'invalid' }]);
+}
+
+/**
+ * Validate and convert one filter value to the type bound as a parameter
+ */
+function coerceFilterValue(name, filter, raw) {
+  const value = String(raw).trim();
+  switch (filter.type) {
+    case 'uuid':
+      if (!UUID_PATTERN.test(value)) {
+        throw invalidFilter(name, 'must be a UUID');
+      }
+      return value;
+    case 'enum':
+      if (!filter.values.includes(value)) {
+        throw invalidFilter(name, `must be one of: ${filter.values.join(', ')}`);
+      }
+      return value;
+    case 'number':
+      if (value === '' || !Number.isFinite(Number(value))) {
+        throw invalidFilter(name, 'must be a number');
+      }
+      return Number(value);
+    case 'date': {
+      const date = parseDate(value);
+      if (!date) {
+        throw invalidFilter(name, `must be a valid date (${DATE_FORMAT_HINT})`);
+      }
+      return date;
+    }
+    case 'search':
+      // Match the text literally: escape LIKE wildcards typed by the user
+      return `%${value.replace(/[\\%_]/g, '\\$&')}%`;
+    default:
+      return value;
+  }
+}
+
+/**
+ * Parameterized SELECT builder: "?" in SQL fragments become numbered placeholders ($1, $2, ...)
+ * bound in order, so filters can be added conditionally without placeholder arithmetic.
+ */
+class SelectQuery {
+  constructor(sql, ...values) {
+    this.params = [];
+    this.text = this.bind(sql, values);
+    this.suffix = '';
+  }
+
+  /**
+   * Replace each "?" in a fragment with the placeholder for the next value
+   */
+  bind(fragment, values) {
+    const pending = [...values];
+    if ((fragment.match(/\?/g) || []).length !== pending.length) {
+      throw new Error(`Expected ${pending.length} placeholders in: ${fragment}`);
+    }
+    return fragment.replace(/\?/g, () => {
+      this.params.push(pending.shift());
+      return `$${this.params.length}`;
+    });
+  }
+
+  /**
+   * Append an AND condition
+   */
+  where(condition, ...values) {
+    this.text += ` AND ${this.bind(condition, values)}`;
+    return this;
+  }
+
+  /**
+   * Append an AND condition only when test is truthy
+   */
+  whereIf(test, condition, ...values) {
+    return test ? this.where(condition, ...values) : this;
+  }
+
+  /**
+   * Append whitelisted, typed filters from a query string.
+   * filters maps parameter name to { column, type, op, values }: "?name=value" compares with op
+   * (default: the type's first operator), "?name[gte]=value" picks another operator allowed for the type,
+   * unless op is fixed. A search filter's column may be a list, matched with OR.
+   * Values are validated per type and always bound as parameters; bad input throws ValidationError.
+   */
+  filter(filters, query) {
+    for (const [name, filter] of Object.entries(filters)) {
+      const input = query[name];
+      if (input === undefined || input === '') {
+        continue;
+      }
+
+      const allowed = TYPE_OPERATORS[filter.type];
+      let conditions;
+      if (Array.isArray(input)) {
+        conditions = [['in', input.join(',')]];
+      } else if (typeof input === 'object') {
+        if (filter.op) {
+          throw invalidFilter(name, 'does not take an operator');
+        }
+        conditions = Object.entries(input);
+      } else {
+        conditions = [[filter.op || allowed[0], input]];
+      }
+
+      for (const [op, raw] of conditions) {
+        if (!allowed.includes(op)) {
+          throw invalidFilter(name, `supports operators: ${allowed.join(', ')}`);
+        }
+
+        if (op === 'in') {
+          const values = String(raw).split(',').filter(value => value.trim() !== '');
+          this.where(`${filter.column} = ANY(?::${ARRAY_CASTS[filter.type]})`, values.map(value => coerceFilterValue(name, filter, value)));
+        } else if (op === 'contains') {
+          const columns = [].concat(filter.column);
+          const pattern = coerceFilterValue(name, filter, raw);
+          this.where(`(${columns.map(column => `${column} ILIKE ?`).join(' OR ')})`, ...columns.map(() => pattern));
+        } else {
+          this.where(`${filter.column} ${OPERATORS[op]} ?`, coerceFilterValue(name, filter, raw));
+        }
+      }
+    }
+    return this;
+  }
+
+  /**
+   * ORDER BY from a "?sort=-amount,created_at" parameter (a leading "-" sorts descending),
+   * limited to the sortable map of name to column; fallback is the default order and final tiebreaker
+   */
+  sort(sortable, value, fallback) {
+    const terms = String(value || '').split(',').map(term => term.trim()).filter(Boolean).map(term => {
+      const descending = term.startsWith('-');
+      const name = descending ? term.slice(1) : term;
+      if (!sortable[name]) {
+        throw new ValidationError(`Cannot sort by ${name}. Sortable fields: ${Object.keys(sortable).join(', ')}`, [
+          { field: 'sort', issue: 'invalid' }
+        ]);
+      }
+      return `${sortable[name]} ${descending ? 'DESC' : 'ASC'} NULLS LAST`;
+    });
+    return this.orderBy([...terms, fallback].join(', '));
+  }
+
+  orderBy(sql) {
+    this.suffix += ` ORDER BY ${sql}`;
+    return this;
+  }
+
+  limit(count) {
+    this.suffix += ` LIMIT ${this.bind('?', [count])}`;
+    return this;
+  }
+
+  offset(count) {
+    this.suffix += ` OFFSET ${this.bind('?', [count])}`;
+    return this;
+  }
+
+  /**
+   * Execute with a transaction client, or on the read replica (when configured) by default
+   */
+  run(client = db.reader) {
+    return client.query(this.text + this.suffix, this.params);
+  }
+}
+
+module.exports = {
+  SelectQuery
+};
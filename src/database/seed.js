@@ -0,0 +1,148 @@
+require('dotenv').config();
+const config = require('../config');
+const db = require('./db');
+const loanService = require('../services/loan');
+const { hashPassword } = require('../utils/hash');
+
+const DEMO_USERNAME = config.demo.username;
+const DEMO_PASSWORD = config.demo.password;
+
+// Demo portfolio: one loan per borrower, covering every status the dashboard reports on.
+// Dates are days relative to today; payments are [daysAgo, amount].
+const DEMO_LOANS = [
+  {
+    borrower: { name: 'สมชาย ใจดี', phone: '0812345678', address: 'กรุงเทพมหานคร' },
+    amount: 50000, interestRate: 12, interestType: 'reducing', termMonths: 12,
+    loanDaysAgo: 150, dueInDays: 215,
+    plan: { amount: 4500, frequency: 'monthly' },
+    payments: [[120, 4500], [90, 4500], [60, 4500], [30, 4500]]
+  },
+  {
+    borrower: { name: 'สุดา แสนสุข', phone: '0898765432', email: 'suda@example.com' },
+    amount: 20000, interestRate: 5, interestType: 'flat', termMonths: 6,
+    loanDaysAgo: 200, dueInDays: -20,
+    payments: [[170, 3500], [140, 3500], [110, 3500]]
+  },
+  {
+    borrower: { name: 'ประยุทธ มั่นคง', phone: '0861112222' },
+    amount: 8000, interestRate: 0, interestType: 'reducing', termMonths: null,
+    loanDaysAgo: 90, dueInDays: -10,
+    payments: [[60, 3000], [30, 3000], [5, 2000]]
+  },
+  {
+    borrower: { name: 'มาลี พรหมมา', phone: '0834445555', address: 'เชียงใหม่' },
+    amount: 15000, interestRate: 10, interestType: 'reducing', termMonths: 10,
+    loanDaysAgo: 45, dueInDays: 255,
+    plan: { amount: 400, frequency: 'weekly' },
+    payments: [[38, 400], [31, 400], [24, 400], [17, 400], [10, 400]],
+    pending: [3, 400]
+  },
+  {
+    borrower: { name: 'วิชัย รุ่งเรือง' },
+    direction: 'borrowed',
+    amount: 30000, interestRate: 3, interestType: 'flat', termMonths: 12,
+    loanDaysAgo: 60, dueInDays: 305,
+    payments: [[30, 2575]]
+  }
+];
+
+/**
+ * ISO date for a day offset from today (negative = past)
+ */
+function dayOffset(days) {
+  const date = new Date();
+  date.setUTCDate(date.getUTCDate() + days);
+  return date.toISOString().slice(0, 10);
+}
+
+/**
+ * Create the demo user with borrowers, loans, payment plans and payments.
+ * Does nothing when the demo user already exists.
+ */
+async function seed() {
+  const existing = await db.query('SELECT id FROM users WHERE username = $1', [DEMO_USERNAME]);
+  if (existing.rows.length > 0) {
+    console.log(`Demo user "${DEMO_USERNAME}" already exists, skipping seed`);
+    return;
+  }
+
+  const passwordHash = await hashPassword(DEMO_PASSWORD);
+
+  await db.transaction(async client => {
+    const userResult = await client.query(
+      `INSERT INTO users (username, password_hash, full_name, email)
+       VALUES ($1, $2, 'Demo User', 'demo@example.com')
+       RETURNING id`,
+      [DEMO_USERNAME, passwordHash]
+    );
+    const userId = userResult.rows[0].id;
+
+    for (const loan of DEMO_LOANS) {
+      const { borrower } = loan;
+      const borrowerResult = await client.query(
+        `INSERT INTO borrowers (user_id, name, phone, email, address)
+         VALUES ($1, $2, $3, $4, $5)
+         RETURNING id`,
+        [userId, borrower.name, borrower.phone || null, borrower.email || null, borrower.address || null]
+      );
+
+      const loanResult = await client.query(
+        `INSERT INTO loans (user_id, borrower_id, borrower_name, borrower_phone, borrower_address, amount,
+           interest_rate, interest_type, term_months, direction, loan_date, due_date, notes)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, 'Demo data')
+         RETURNING id`,
+        [userId, borrowerResult.rows[0].id, borrower.name, borrower.phone || null, borrower.address || null, loan.amount,
+          loan.interestRate, loan.interestType, loan.termMonths, loan.direction || 'lent',
+          dayOffset(-loan.loanDaysAgo), dayOffset(loan.dueInDays)]
+      );
+      const loanId = loanResult.rows[0].id;
+
+      if (loan.plan) {
+        // Next due date continues the schedule from the loan date
+        const periodDays = loan.plan.frequency === 'weekly' ? 7 : 30;
+        const elapsedPeriods = Math.floor(loan.loanDaysAgo / periodDays);
+        await client.query(
+          `INSERT INTO payment_plans (loan_id, user_id, amount, frequency, start_date, next_due_date, end_date)
+           VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+          [loanId, userId, loan.plan.amount, loan.plan.frequency, dayOffset(-loan.loanDaysAgo + periodDays),
+            dayOffset(-loan.loanDaysAgo + (elapsedPeriods + 1) * periodDays), dayOffset(loan.dueInDays)]
+        );
+      }
+
+      for (const [daysAgo, amount] of loan.payments) {
+        await client.query(
+          `INSERT INTO transactions (loan_id, user_id, amount, transaction_type, transaction_date, description, status, confirmed_at)
+           VALUES ($1, $2, $3, 'payment', $4, 'Demo payment', 'confirmed', $4::date)`,
+          [loanId, userId, amount, dayOffset(-daysAgo)]
+        );
+      }
+
+      if (loan.pending) {
+        const [daysAgo, amount] = loan.pending;
+        await client.query(
+          `INSERT INTO transactions (loan_id, user_id, amount, transaction_type, transaction_date, description, status)
+           VALUES ($1, $2, $3, 'payment', $4, 'Demo payment awaiting confirmation', 'pending')`,
+          [loanId, userId, amount, dayOffset(-daysAgo)]
+        );
+      }
+
+      await loanService.syncLoanStatus(client, loanId);
+    }
+  });
+
+  console.log(`Seeded demo user "${DEMO_USERNAME}" (password: ${DEMO_PASSWORD}) with ${DEMO_LOANS.length} loans`);
+}
+
+if (require.main === module) {
+  db.createTables()
+    .then(seed)
+    .then(() => db.close())
+    .catch(error => {
+      console.error('Seed error:', error);
+      process.exit(1);
+    });
+}
+
+module.exports = {
+  seed
+};
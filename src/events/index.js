@@ -0,0 +1,142 @@
+const { EventEmitter } = require('events');
+const db = require('../database/db');
+
+// Postgres channel carrying account activity; NOTIFY is only delivered once the writing transaction commits
+const EVENT_CHANNEL = 'account_events';
+const STREAMED_EVENT = /^(loan|transaction)\./;
+const RECONNECT_DELAY_MS = 5000;
+
+/**
+ * Account activity stream
+ *
+ * Events are published through Postgres NOTIFY so every API instance sees them,
+ * then fanned out in-process to the subscribers of the event's user.
+ */
+class EventStream {
+  constructor() {
+    this.emitter = new EventEmitter();
+    this.emitter.setMaxListeners(0);
+    this.client = null;
+    this.connecting = null;
+    this.closed = false;
+    this.closeListeners = new Set();
+  }
+
+  /**
+   * Check whether an event is streamed to clients
+   */
+  isStreamed(event) {
+    return STREAMED_EVENT.test(event);
+  }
+
+  /**
+   * Publish an event (pass a transaction client to deliver only if it commits)
+   */
+  async publish(event, { userId, payload = {} }, client = db) {
+    if (!this.isStreamed(event)) {
+      return;
+    }
+
+    try {
+      await client.query('SELECT pg_notify($1, $2)', [
+        EVENT_CHANNEL,
+        JSON.stringify({ event, userId, payload, occurredAt: new Date().toISOString() })
+      ]);
+    } catch (error) {
+      // Live updates are best effort and must never break the triggering request
+      console.error(`Event publish error (${event}):`, error);
+    }
+  }
+
+  /**
+   * Subscribe to a user's events; returns an unsubscribe function
+   */
+  async subscribe(userId, listener) {
+    await this.listen();
+    this.emitter.on(userId, listener);
+    return () => this.emitter.off(userId, listener);
+  }
+
+  /**
+   * Register a callback for when the stream shuts down; returns an unregister function
+   */
+  onClose(listener) {
+    this.closeListeners.add(listener);
+    return () => this.closeListeners.delete(listener);
+  }
+
+  /**
+   * Open the shared LISTEN connection (once per process)
+   */
+  listen() {
+    if (this.closed) {
+      return Promise.reject(new Error('Event stream is closed'));
+    }
+    if (this.client) {
+      return Promise.resolve();
+    }
+
+    if (!this.connecting) {
+      this.connecting = (async () => {
+        const client = await db.pool.connect();
+        client.on('notification', message => this.dispatch(message));
+        client.on('error', error => this.reconnect(client, error));
+        await client.query(`LISTEN ${EVENT_CHANNEL}`);
+        this.client = client;
+      })().finally(() => {
+        this.connecting = null;
+      });
+    }
+
+    return this.connecting;
+  }
+
+  /**
+   * Drop a broken LISTEN connection and reopen it while anyone is subscribed
+   */
+  reconnect(client, error) {
+    console.error('Event stream connection error:', error);
+    client.release(error);
+    this.client = null;
+
+    setTimeout(() => {
+      if (!this.closed && this.emitter.eventNames().length > 0) {
+        this.listen().catch(err => console.error('Event stream reconnect error:', err));
+      }
+    }, RECONNECT_DELAY_MS);
+  }
+
+  /**
+   * End every subscriber's stream and release the LISTEN connection (on shutdown; no reconnects afterwards)
+   */
+  async close() {
+    this.closed = true;
+    this.closeListeners.forEach(listener => listener());
+    this.closeListeners.clear();
+    this.emitter.removeAllListeners();
+
+    await (this.connecting || Promise.resolve()).catch(() => {});
+    if (this.client) {
+      this.client.release(true);
+      this.client = null;
+    }
+  }
+
+  /**
+   * Forward a NOTIFY message to the subscribers of its user
+   */
+  dispatch(message) {
+    if (message.channel !== EVENT_CHANNEL) {
+      return;
+    }
+
+    try {
+      const { userId, ...event } = JSON.parse(message.payload);
+      this.emitter.emit(userId, event);
+    } catch (error) {
+      console.error('Event stream dispatch error:', error);
+    }
+  }
+}
+
+module.exports = new EventStream();
@@ -0,0 +1,173 @@
+const http2 = require('http2');
+const { authMiddleware } = require('../middleware/auth');
+const { encodeMessage, decodeMessage } = require('./protobuf');
+const { SERVICES } = require('./services');
+const { createContext } = require('../utils/handlerContext');
+const cache = require('../cache');
+
+// Largest request message accepted (gRPC's default max receive size)
+const MAX_MESSAGE_BYTES = 4 * 1024 * 1024;
+
+// gRPC status codes
+const GRPC_STATUS = {
+  OK: 0,
+  INVALID_ARGUMENT: 3,
+  NOT_FOUND: 5,
+  ALREADY_EXISTS: 6,
+  PERMISSION_DENIED: 7,
+  RESOURCE_EXHAUSTED: 8,
+  UNIMPLEMENTED: 12,
+  INTERNAL: 13,
+  UNAVAILABLE: 14,
+  UNAUTHENTICATED: 16
+};
+
+/**
+ * Map an HTTP status returned by a handler to a gRPC status code
+ */
+function toGrpcStatus(httpStatus) {
+  if (httpStatus < 400) return GRPC_STATUS.OK;
+  switch (httpStatus) {
+    case 400: return GRPC_STATUS.INVALID_ARGUMENT;
+    case 401: return GRPC_STATUS.UNAUTHENTICATED;
+    case 403: return GRPC_STATUS.PERMISSION_DENIED;
+    case 404: return GRPC_STATUS.NOT_FOUND;
+    case 409: return GRPC_STATUS.ALREADY_EXISTS;
+    case 429: return GRPC_STATUS.RESOURCE_EXHAUSTED;
+    case 503: return GRPC_STATUS.UNAVAILABLE;
+    default: return GRPC_STATUS.INTERNAL;
+  }
+}
+
+/**
+ * Authenticate a call using the same middleware as the REST API; returns { user } or { status, message }
+ */
+async function authenticate(headers) {
+  const { req, res, done } = createContext({ headers: { authorization: headers.authorization } });
+  const authenticated = new Promise(resolve => authMiddleware(req, res, () => resolve({ user: req.user })));
+  const result = await Promise.race([authenticated, done]);
+
+  if (result.user) {
+    return result;
+  }
+  return { status: result.status, message: result.payload.error.message };
+}
+
+/**
+ * Wrap a message in gRPC length-prefixed framing (uncompressed)
+ */
+function frame(message) {
+  const header = Buffer.alloc(5);
+  header.writeUInt8(0, 0);
+  header.writeUInt32BE(message.length, 1);
+  return Buffer.concat([header, message]);
+}
+
+/**
+ * Send a trailers-only error response
+ */
+function sendError(stream, code, message) {
+  stream.respond({
+    ':status': 200,
+    'content-type': 'application/grpc',
+    'grpc-status': String(code),
+    'grpc-message': encodeURIComponent(message)
+  }, { endStream: true });
+}
+
+/**
+ * Handle a unary gRPC call
+ */
+async function handleCall(stream, headers, body) {
+  const [, service, methodName] = (headers[':path'] || '').split('/');
+  const method = SERVICES[service] && SERVICES[service][methodName];
+
+  if (!method) {
+    return sendError(stream, GRPC_STATUS.UNIMPLEMENTED, `Unknown method ${headers[':path']}`);
+  }
+
+  if (body.length < 5 || body.readUInt8(0) !== 0) {
+    return sendError(stream, GRPC_STATUS.INTERNAL, 'Compressed or malformed messages are not supported');
+  }
+
+  const auth = await authenticate(headers);
+  if (!auth.user) {
+    return sendError(stream, toGrpcStatus(auth.status), auth.message);
+  }
+
+  let message;
+  try {
+    message = decodeMessage(method.request, body.slice(5, 5 + body.readUInt32BE(1)));
+  } catch (error) {
+    return sendError(stream, GRPC_STATUS.INVALID_ARGUMENT, `Invalid ${method.request}: ${error.message}`);
+  }
+
+  const { req, res, done } = createContext({ headers, user: auth.user, ...method.toRequest(message) });
+  method.handler[method.method](req, res);
+  const { status, payload } = await done;
+
+  if (status >= 400) {
+    return sendError(stream, toGrpcStatus(status), payload.error.message);
+  }
+
+  if (!/^(List|Get)/.test(methodName)) {
+    await cache.invalidateUser(auth.user.id);
+  }
+
+  stream.respond({ ':status': 200, 'content-type': 'application/grpc' }, { waitForTrailers: true });
+  stream.on('wantTrailers', () => stream.sendTrailers({ 'grpc-status': String(GRPC_STATUS.OK) }));
+  stream.end(frame(encodeMessage(method.response, payload.data)));
+}
+
+/**
+ * Create an HTTP/2 (h2c) server speaking gRPC for the services in proto/loan_money.proto
+ */
+function createGrpcServer() {
+  const server = http2.createServer();
+
+  // Mirror http.Server so shutdown can drain sessions: GOAWAY lets open calls finish, destroy cuts them
+  const sessions = new Set();
+  server.on('session', session => {
+    sessions.add(session);
+    session.on('close', () => sessions.delete(session));
+  });
+  server.closeIdleConnections = () => sessions.forEach(session => session.close());
+  server.closeAllConnections = () => sessions.forEach(session => session.destroy());
+
+  server.on('stream', (stream, headers) => {
+    const chunks = [];
+    let received = 0;
+    let rejected = false;
+    stream.on('data', chunk => {
+      if (rejected) {
+        return;
+      }
+      received += chunk.length;
+      // 5 bytes of framing on top of the message
+      if (received > MAX_MESSAGE_BYTES + 5) {
+        rejected = true;
+        chunks.length = 0;
+        sendError(stream, GRPC_STATUS.RESOURCE_EXHAUSTED, `Message larger than ${MAX_MESSAGE_BYTES} bytes`);
+        return;
+      }
+      chunks.push(chunk);
+    });
+    stream.on('end', () => {
+      if (rejected) {
+        return;
+      }
+      handleCall(stream, headers, Buffer.concat(chunks)).catch(error => {
+        console.error('gRPC call error:', error);
+        if (!stream.headersSent) {
+          sendError(stream, GRPC_STATUS.INTERNAL, 'Internal error');
+        }
+      });
+    });
+  });
+
+  return server;
+}
+
+module.exports = {
+  createGrpcServer
+};
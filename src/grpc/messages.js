@@ -0,0 +1,133 @@
+// Message definitions mirroring proto/loan_money.proto: [field number, name, type, repeated]
+const MESSAGES = {
+  Pagination: [
+    [1, 'page', 'int32'],
+    [2, 'limit', 'int32'],
+    [3, 'total', 'int32']
+  ],
+  DateRange: [
+    [1, 'from', 'string'],
+    [2, 'to', 'string'],
+    [3, 'preset', 'string']
+  ],
+  Loan: [
+    [1, 'id', 'string'],
+    [2, 'borrower_id', 'string'],
+    [3, 'borrower_name', 'string'],
+    [4, 'borrower_phone', 'string'],
+    [5, 'amount', 'double'],
+    [6, 'interest_rate', 'double'],
+    [7, 'interest_type', 'string'],
+    [8, 'term_months', 'int32'],
+    [9, 'direction', 'string'],
+    [10, 'loan_date', 'string'],
+    [11, 'due_date', 'string'],
+    [12, 'status', 'string'],
+    [13, 'notes', 'string'],
+    [14, 'total_paid', 'double'],
+    [15, 'total_charges', 'double'],
+    [16, 'remaining_debt', 'double'],
+    [17, 'created_at', 'string'],
+    [18, 'updated_at', 'string'],
+    [19, 'currency', 'string']
+  ],
+  ListLoansRequest: [
+    [1, 'page', 'int32'],
+    [2, 'limit', 'int32'],
+    [3, 'status', 'string'],
+    [4, 'search', 'string'],
+    [5, 'direction', 'string']
+  ],
+  ListLoansResponse: [
+    [1, 'loans', 'Loan', true],
+    [2, 'pagination', 'Pagination']
+  ],
+  GetLoanRequest: [
+    [1, 'id', 'string']
+  ],
+  CreateLoanRequest: [
+    [1, 'borrower_id', 'string'],
+    [2, 'borrower_name', 'string'],
+    [3, 'borrower_phone', 'string'],
+    [4, 'borrower_address', 'string'],
+    [5, 'amount', 'double'],
+    [6, 'interest_rate', 'double'],
+    [7, 'interest_type', 'string'],
+    [8, 'term_months', 'int32'],
+    [9, 'direction', 'string'],
+    [10, 'loan_date', 'string'],
+    [11, 'due_date', 'string'],
+    [12, 'notes', 'string'],
+    [13, 'currency', 'string']
+  ],
+  Transaction: [
+    [1, 'id', 'string'],
+    [2, 'loan_id', 'string'],
+    [3, 'borrower_name', 'string'],
+    [4, 'amount', 'double'],
+    [5, 'transaction_type', 'string'],
+    [6, 'transaction_date', 'string'],
+    [7, 'description', 'string'],
+    [8, 'status', 'string'],
+    [9, 'created_at', 'string'],
+    [10, 'currency', 'string']
+  ],
+  ListTransactionsRequest: [
+    [1, 'page', 'int32'],
+    [2, 'limit', 'int32'],
+    [3, 'loan_id', 'string'],
+    [4, 'transaction_type', 'string'],
+    [5, 'status', 'string'],
+    [6, 'from', 'string'],
+    [7, 'to', 'string']
+  ],
+  ListTransactionsResponse: [
+    [1, 'transactions', 'Transaction', true],
+    [2, 'pagination', 'Pagination']
+  ],
+  CreateTransactionRequest: [
+    [1, 'loan_id', 'string'],
+    [2, 'amount', 'double'],
+    [3, 'transaction_type', 'string'],
+    [4, 'transaction_date', 'string'],
+    [5, 'description', 'string'],
+    [6, 'status', 'string'],
+    [7, 'currency', 'string']
+  ],
+  GetStatsRequest: [
+    [1, 'range', 'string'],
+    [2, 'from', 'string'],
+    [3, 'to', 'string']
+  ],
+  CurrencyTotals: [
+    [1, 'currency', 'string'],
+    [2, 'total_loans', 'int32'],
+    [3, 'total_amount', 'double'],
+    [4, 'pending_amount', 'double']
+  ],
+  ConsolidatedTotals: [
+    [1, 'currency', 'string'],
+    [2, 'total_amount', 'double'],
+    [3, 'pending_amount', 'double'],
+    [4, 'as_of', 'string'],
+    [5, 'source', 'string']
+  ],
+  DashboardStats: [
+    [1, 'total_loans', 'int32'],
+    [2, 'active_loans', 'int32'],
+    [3, 'total_amount', 'double'],
+    [4, 'total_interest', 'double'],
+    [5, 'overdue_loans', 'int32'],
+    [6, 'missed_payments', 'int32'],
+    [7, 'pending_transactions', 'int32'],
+    [8, 'pending_amount', 'double'],
+    [9, 'range', 'DateRange'],
+    [10, 'currency', 'string'],
+    [11, 'by_currency', 'CurrencyTotals', true],
+    [12, 'consolidated', 'ConsolidatedTotals']
+  ]
+};
+
+module.exports = {
+  MESSAGES
+};
@@ -0,0 +1,176 @@
+const { MESSAGES } = require('./messages');
+
+const WIRE_VARINT = 0;
+const WIRE_FIXED64 = 1;
+const WIRE_LENGTH = 2;
+const WIRE_FIXED32 = 5;
+
+/**
+ * Encode an unsigned or signed integer as a varint (negative int32 uses 10 bytes, per proto3)
+ */
+function encodeVarint(value) {
+  let n = BigInt.asUintN(64, BigInt(Math.trunc(value)));
+  const bytes = [];
+  do {
+    let byte = Number(n & 0x7Fn);
+    n >>= 7n;
+    if (n > 0n) {
+      byte |= 0x80;
+    }
+    bytes.push(byte);
+  } while (n > 0n);
+  return Buffer.from(bytes);
+}
+
+/**
+ * Decode a varint at offset; returns { value, offset }
+ */
+function decodeVarint(buffer, offset) {
+  let result = 0n;
+  let shift = 0n;
+  let byte;
+  do {
+    if (offset >= buffer.length) {
+      throw new Error('Truncated varint');
+    }
+    byte = buffer[offset++];
+    result |= BigInt(byte & 0x7F) << shift;
+    shift += 7n;
+  } while (byte & 0x80);
+  return { value: Number(BigInt.asIntN(64, result)), offset };
+}
+
+/**
+ * Read a field by its proto (snake_case) name, falling back to the camelCase property
+ */
+function readField(object, name) {
+  if (object[name] !== undefined) {
+    return object[name];
+  }
+  return object[name.replace(/_([a-z])/g, (_, letter) => letter.toUpperCase())];
+}
+
+/**
+ * Encode a single field value
+ */
+function encodeValue(number, type, value) {
+  switch (type) {
+    case 'string': {
+      const text = value instanceof Date ? value.toISOString() : String(value);
+      const data = Buffer.from(text, 'utf8');
+      return Buffer.concat([encodeVarint((number << 3) | WIRE_LENGTH), encodeVarint(data.length), data]);
+    }
+    case 'double': {
+      const data = Buffer.alloc(8);
+      data.writeDoubleLE(parseFloat(value) || 0);
+      return Buffer.concat([encodeVarint((number << 3) | WIRE_FIXED64), data]);
+    }
+    case 'int32':
+      return Buffer.concat([encodeVarint((number << 3) | WIRE_VARINT), encodeVarint(parseInt(value) || 0)]);
+    case 'bool':
+      return Buffer.concat([encodeVarint((number << 3) | WIRE_VARINT), encodeVarint(value ? 1 : 0)]);
+    default: {
+      const data = encodeMessage(type, value);
+      return Buffer.concat([encodeVarint((number << 3) | WIRE_LENGTH), encodeVarint(data.length), data]);
+    }
+  }
+}
+
+/**
+ * Encode a plain object as the named protobuf message (null/undefined fields are omitted)
+ */
+function encodeMessage(name, object) {
+  const fields = MESSAGES[name];
+  if (!fields) {
+    throw new Error(`Unknown message: ${name}`);
+  }
+
+  const parts = [];
+  fields.forEach(([number, field, type, repeated]) => {
+    const value = readField(object || {}, field);
+    if (value === null || value === undefined) {
+      return;
+    }
+    (repeated ? value : [value]).forEach(item => parts.push(encodeValue(number, type, item)));
+  });
+  return Buffer.concat(parts);
+}
+
+/**
+ * Throw unless length bytes from offset lie within the buffer
+ */
+function checkBounds(buffer, offset, length) {
+  if (!Number.isSafeInteger(length) || length < 0 || offset + length > buffer.length) {
+    throw new Error('Truncated or invalid field length');
+  }
+}
+
+/**
+ * Decode a buffer as the named protobuf message into a plain object (unknown fields are skipped)
+ */
+function decodeMessage(name, buffer) {
+  const fields = MESSAGES[name];
+  if (!fields) {
+    throw new Error(`Unknown message: ${name}`);
+  }
+
+  const byNumber = new Map(fields.map(field => [field[0], field]));
+  const result = {};
+  let offset = 0;
+
+  while (offset < buffer.length) {
+    const tag = decodeVarint(buffer, offset);
+    offset = tag.offset;
+    const number = tag.value >>> 3;
+    const wireType = tag.value & 0x7;
+    let value;
+
+    if (wireType === WIRE_VARINT) {
+      ({ value, offset } = decodeVarint(buffer, offset));
+    } else if (wireType === WIRE_FIXED64) {
+      checkBounds(buffer, offset, 8);
+      value = buffer.readDoubleLE(offset);
+      offset += 8;
+    } else if (wireType === WIRE_LENGTH) {
+      const length = decodeVarint(buffer, offset);
+      // A negative length would move offset backwards and never finish
+      checkBounds(buffer, length.offset, length.value);
+      value = buffer.slice(length.offset, length.offset + length.value);
+      offset = length.offset + length.value;
+    } else if (wireType === WIRE_FIXED32) {
+      checkBounds(buffer, offset, 4);
+      value = buffer.readFloatLE(offset);
+      offset += 4;
+    } else {
+      throw new Error(`Unsupported wire type ${wireType}`);
+    }
+
+    const field = byNumber.get(number);
+    if (!field) {
+      continue;
+    }
+
+    const [, fieldName, type, repeated] = field;
+    if (type === 'string') {
+      value = value.toString('utf8');
+    } else if (type === 'bool') {
+      value = value !== 0;
+    } else if (!['double', 'int32'].includes(type)) {
+      value = decodeMessage(type, value);
+    }
+
+    if (repeated) {
+      result[fieldName] = result[fieldName] || [];
+      result[fieldName].push(value);
+    } else {
+      result[fieldName] = value;
+    }
+  }
+
+  return result;
+}
+
+module.exports = {
+  encodeMessage,
+  decodeMessage
+};
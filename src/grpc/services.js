@@ -0,0 +1,70 @@
+const loanHandler = require('../handlers/loan');
+const transactionHandler = require('../handlers/transaction');
+const dashboardHandler = require('../handlers/dashboard');
+
+/**
+ * Convert a decoded message's snake_case fields to the camelCase names handlers read
+ */
+function toCamelCase(message) {
+  const result = {};
+  Object.entries(message).forEach(([key, value]) => {
+    result[key.replace(/_([a-z])/g, (_, letter) => letter.toUpperCase())] = value;
+  });
+  return result;
+}
+
+// gRPC methods per service, each backed by the REST handler method it shares logic with
+const SERVICES = {
+  'loanmoney.v1.LoanService': {
+    ListLoans: {
+      request: 'ListLoansRequest',
+      response: 'ListLoansResponse',
+      handler: loanHandler,
+      method: 'getLoans',
+      toRequest: message => ({ query: toCamelCase(message) })
+    },
+    GetLoan: {
+      request: 'GetLoanRequest',
+      response: 'Loan',
+      handler: loanHandler,
+      method: 'getLoan',
+      toRequest: message => ({ params: { id: message.id } })
+    },
+    CreateLoan: {
+      request: 'CreateLoanRequest',
+      response: 'Loan',
+      handler: loanHandler,
+      method: 'createLoan',
+      toRequest: message => ({ body: toCamelCase(message) })
+    }
+  },
+  'loanmoney.v1.TransactionService': {
+    ListTransactions: {
+      request: 'ListTransactionsRequest',
+      response: 'ListTransactionsResponse',
+      handler: transactionHandler,
+      method: 'getTransactions',
+      toRequest: message => ({ query: toCamelCase(message) })
+    },
+    CreateTransaction: {
+      request: 'CreateTransactionRequest',
+      response: 'Transaction',
+      handler: transactionHandler,
+      method: 'createTransaction',
+      toRequest: message => ({ body: toCamelCase(message) })
+    }
+  },
+  'loanmoney.v1.DashboardService': {
+    GetStats: {
+      request: 'GetStatsRequest',
+      response: 'DashboardStats',
+      handler: dashboardHandler,
+      method: 'getDashboardStats',
+      toRequest: message => ({ query: message })
+    }
+  }
+};
+
+module.exports = {
+  SERVICES
+};
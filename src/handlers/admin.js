@@ -0,0 +1,185 @@
+const crypto = require('crypto');
+const db = require('../database/db');
+const config = require('../config');
+const { ConfigError } = require('../config/settings');
+const { reloadConfig } = require('../config/reload');
+const { respondWithError, respondWithJSON, parsePagination, parseIncludeDeleted } = require('../utils/response');
+
+class AdminHandler {
+  /**
+   * Check the ADMIN_TOKEN bearer token (constant-time comparison); admin routes are disabled without it
+   */
+  authorize(req, res) {
+    const token = config.admin.token;
+    if (!token) {
+      respondWithError(res, 503, 'Admin API is not configured', { code: 'NOT_CONFIGURED' });
+      return false;
+    }
+
+    const expected = Buffer.from(`Bearer ${token}`);
+    const actual = Buffer.from(req.headers.authorization || '');
+    if (actual.length !== expected.length || !crypto.timingSafeEqual(actual, expected)) {
+      respondWithError(res, 401, 'Invalid admin token', { code: 'INVALID_TOKEN' });
+      return false;
+    }
+    return true;
+  }
+
+  /**
+   * List user accounts; soft-deleted accounts only with ?include_deleted=true
+   */
+  async getUsers(req, res) {
+    try {
+      if (!this.authorize(req, res)) {
+        return;
+      }
+
+      const { page, limit, offset } = parsePagination(req.query);
+      const includeDeleted = parseIncludeDeleted(req.query);
+
+      const result = await db.query(
+        `SELECT id, username, full_name, email, created_at, updated_at, deleted_at
+         FROM users
+         WHERE $1::boolean OR deleted_at IS NULL
+         ORDER BY created_at DESC
+         LIMIT $2 OFFSET $3`,
+        [includeDeleted, limit, offset]
+      );
+
+      return respondWithJSON(res, 200, {
+        users: result.rows,
+        pagination: { page, limit, total: result.rowCount }
+      });
+
+    } catch (error) {
+      console.error('Get users error:', error);
+      return respondWithError(res, 500, 'Failed to get users', { cause: error });
+    }
+  }
+
+  /**
+   * Instance-wide totals for operators: accounts, active loans, outstanding balances and recent payments
+   */
+  async getMetrics(req, res) {
+    try {
+      if (!this.authorize(req, res)) {
+        return;
+      }
+
+      const [users, loans, payments] = await Promise.all([
+        db.reader.query('SELECT COUNT(*) as total FROM users WHERE deleted_at IS NULL'),
+        db.reader.query(
+          `SELECT
+             COUNT(*) as active_loans,
+             COALESCE(SUM(COALESCE(l.remaining_debt, l.amount)) FILTER (WHERE l.direction = 'lent'), 0) as outstanding_lent,
+             COALESCE(SUM(COALESCE(l.remaining_debt, l.amount)) FILTER (WHERE l.direction = 'borrowed'), 0) as outstanding_borrowed
+           FROM loans l
+           JOIN users u ON u.id = l.user_id
+           WHERE l.status = 'active' AND l.deleted_at IS NULL AND u.deleted_at IS NULL`
+        ),
+        db.reader.query(
+          `SELECT COUNT(*) as count, COALESCE(SUM(t.amount), 0) as amount
+           FROM transactions t
+           JOIN loans l ON l.id = t.loan_id
+           WHERE t.transaction_type = 'payment' AND t.status = 'confirmed'
+           AND t.deleted_at IS NULL AND l.deleted_at IS NULL
+           AND t.created_at >= now() - INTERVAL '24 hours'`
+        )
+      ]);
+
+      const loanTotals = loans.rows[0];
+      const outstandingLent = parseFloat(loanTotals.outstanding_lent);
+      const outstandingBorrowed = parseFloat(loanTotals.outstanding_borrowed);
+
+      return respondWithJSON(res, 200, {
+        users: parseInt(users.rows[0].total),
+        active_loans: parseInt(loanTotals.active_loans),
+        outstanding: {
+          total: outstandingLent + outstandingBorrowed,
+          lent: outstandingLent,
+          borrowed: outstandingBorrowed
+        },
+        payments_last_24h: {
+          count: parseInt(payments.rows[0].count),
+          amount: parseFloat(payments.rows[0].amount)
+        },
+        generated_at: new Date().toISOString()
+      });
+
+    } catch (error) {
+      console.error('Get admin metrics error:', error);
+      return respondWithError(res, 500, 'Failed to get metrics', { cause: error });
+    }
+  }
+
+  /**
+   * Restore a soft-deleted account together with the loans deleted along with it
+   */
+  async restoreUser(req, res) {
+    try {
+      if (!this.authorize(req, res)) {
+        return;
+      }
+
+      const { id } = req.params;
+
+      const user = await db.transaction(async client => {
+        // Loans deleted individually before the account keep their own deleted_at and stay deleted
+        await client.query(
+          `UPDATE loans SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+           WHERE user_id = $1 AND deleted_at = (SELECT deleted_at FROM users WHERE id = $1)`,
+          [id]
+        );
+
+        const result = await client.query(
+          `UPDATE users SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+           WHERE id = $1 AND deleted_at IS NOT NULL
+           RETURNING id, username, full_name, email, created_at, updated_at`,
+          [id]
+        );
+        return result.rows[0] || null;
+      });
+
+      if (!user) {
+        return respondWithError(res, 404, 'Deleted user not found');
+      }
+
+      return respondWithJSON(res, 200, user);
+
+    } catch (error) {
+      console.error('Restore user error:', error);
+      return respondWithError(res, 500, 'Failed to restore user', { cause: error });
+    }
+  }
+
+  /**
+   * Re-read the configuration (as on SIGHUP) and apply reloadable settings; an invalid one is rejected whole
+   */
+  async reloadConfig(req, res) {
+    try {
+      if (!this.authorize(req, res)) {
+        return;
+      }
+
+      const { changed, restartRequired } = reloadConfig();
+      console.log(`Config reloaded via admin API (${changed.length > 0 ? `changed ${changed.join(', ')}` : 'no changes'})`);
+
+      return respondWithJSON(res, 200, {
+        changed,
+        restart_required: restartRequired
+      });
+
+    } catch (error) {
+      if (error instanceof ConfigError) {
+        return respondWithError(res, 422, 'Configuration is invalid; current settings kept', {
+          code: 'INVALID_CONFIG',
+          details: error.issues.map(issue => ({ issue }))
+        });
+      }
+      console.error('Reload config error:', error);
+      return respondWithError(res, 500, 'Failed to reload configuration', { cause: error });
+    }
+  }
+}
+
+module.exports = new AdminHandler();
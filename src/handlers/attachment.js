@@ -0,0 +1,123 @@
+const path = require('path');
+const { v4: uuidv4 } = require('uuid');
+const db = require('../database/db');
+const storage = require('../storage');
+const { respondWithError, respondWithJSON } = require('../utils/response');
+const { getUserFromContext } = require('../middleware/auth');
+
+const ALLOWED_CONTENT_TYPES = ['image/jpeg', 'image/png', 'image/webp', 'image/heic', 'application/pdf'];
+const MAX_ATTACHMENT_SIZE = 5 * 1024 * 1024;
+
+class AttachmentHandler {
+  /**
+   * Upload attachments (transfer slips) for a transaction
+   */
+  async uploadAttachments(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+      const files = req.files || [];
+
+      if (files.length === 0) {
+        return respondWithError(res, 400, 'At least one file is required');
+      }
+
+      for (const file of files) {
+        if (!ALLOWED_CONTENT_TYPES.includes(file.contentType)) {
+          return respondWithError(res, 400, `File type must be one of: ${ALLOWED_CONTENT_TYPES.join(', ')}`);
+        }
+        if (file.size > MAX_ATTACHMENT_SIZE) {
+          return respondWithError(res, 400, `File ${file.fileName} exceeds the 5MB limit`);
+        }
+      }
+
+      const transactionCheck = await db.query(
+        'SELECT id FROM transactions WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+        [id, user.id]
+      );
+
+      if (transactionCheck.rows.length === 0) {
+        return respondWithError(res, 404, 'Transaction not found');
+      }
+
+      const attachments = [];
+      for (const file of files) {
+        const storageKey = `${user.id}/${id}/${uuidv4()}${path.extname(file.fileName).toLowerCase()}`;
+        await storage.save(storageKey, file.buffer, file.contentType);
+
+        const result = await db.query(
+          `INSERT INTO transaction_attachments (transaction_id, user_id, file_name, content_type, size, storage_key)
+           VALUES ($1, $2, $3, $4, $5, $6)
+           RETURNING id, transaction_id, file_name, content_type, size, created_at`,
+          [id, user.id, path.basename(file.fileName), file.contentType, file.size, storageKey]
+        );
+        attachments.push(result.rows[0]);
+      }
+
+      return respondWithJSON(res, 201, attachments);
+
+    } catch (error) {
+      console.error('Upload attachments error:', error);
+      return respondWithError(res, 500, 'Failed to upload attachments', { cause: error });
+    }
+  }
+
+  /**
+   * Download attachment file
+   */
+  async getAttachment(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const result = await db.query(
+        'SELECT * FROM transaction_attachments WHERE id = $1 AND user_id = $2',
+        [id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Attachment not found');
+      }
+
+      const attachment = result.rows[0];
+      const content = await storage.get(attachment.storage_key);
+
+      res.setHeader('Content-Type', attachment.content_type);
+      res.setHeader('Content-Disposition', `inline; filename="${encodeURIComponent(attachment.file_name)}"`);
+      return res.status(200).send(content);
+
+    } catch (error) {
+      console.error('Get attachment error:', error);
+      return respondWithError(res, 500, 'Failed to get attachment', { cause: error });
+    }
+  }
+
+  /**
+   * Delete attachment
+   */
+  async deleteAttachment(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const result = await db.query(
+        'DELETE FROM transaction_attachments WHERE id = $1 AND user_id = $2 RETURNING *',
+        [id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Attachment not found');
+      }
+
+      await storage.delete(result.rows[0].storage_key);
+
+      return respondWithJSON(res, 200, { message: 'Attachment deleted successfully' });
+
+    } catch (error) {
+      console.error('Delete attachment error:', error);
+      return respondWithError(res, 500, 'Failed to delete attachment', { cause: error });
+    }
+  }
+}
+
+module.exports = new AttachmentHandler();
@@ -1,63 +1,43 @@
 const db = require('../database/db');
-const { hashPassword, verifyPassword } = require('../utils/hash');
+const { verifyPassword } = require('../utils/hash');
 const { generateJWT } = require('../utils/jwt');
-const { respondWithError, respondWithJSON, validateRequiredFields } = require('../utils/response');
+const { respondWithError, respondWithJSON, validateRequiredFields, rejectUnknownFields } = require('../utils/response');
+const deviceService = require('../services/device');
+const userService = require('../services/user');
+const { issueSession, clearSession, wantsCookieSession } = require('../middleware/session');
 const { User, AuthResponse } = require('../models');
 
 class AuthHandler {
+  /**
+   * Issue credentials for a signed-in user: a cookie session when requested, otherwise a Bearer JWT
+   */
+  authResponse(req, res, user) {
+    if (wantsCookieSession(req)) {
+      return new AuthResponse({ user, csrfToken: issueSession(res, user) });
+    }
+    return new AuthResponse({ user, token: generateJWT(user.id, user.username) });
+  }
+
   /**
    * Register new user
    */
   async register(req, res) {
     try {
+      rejectUnknownFields(req.body, ['username', 'password', 'fullName']);
       const { username, password, fullName } = req.body;
 
       // Validate required fields
       validateRequiredFields(req.body, ['username', 'password']);
 
-      // Validate password length
-      if (password.length < 6) {
-        return respondWithError(res, 400, 'Password must be at least 6 characters long');
-      }
-
-      // Check if user already exists
-      const existingUser = await db.query(
-        'SELECT id FROM users WHERE username = $1',
-        [username]
-      );
-
-      if (existingUser.rows.length > 0) {
-        return respondWithError(res, 400, 'Username already exists');
-      }
-
-      // Hash password
-      const passwordHash = await hashPassword(password);
-
-      // Create user
-      const result = await db.query(
-        `INSERT INTO users (username, password_hash, full_name)
-         VALUES ($1, $2, $3)
-         RETURNING *`,
-        [username, passwordHash, fullName]
-      );
-
-      const userData = result.rows[0];
-      const user = new User({
-        id: userData.id,
-        username: userData.username,
-        fullName: userData.full_name,
-        createdAt: userData.created_at,
-        updatedAt: userData.updated_at
-      });
+      const user = await userService.createUser({ username, password, fullName });
 
-      // Generate JWT token
-      const token = generateJWT(user.id, user.username);
+      await deviceService.recordLogin(req, user);
 
-      return respondWithJSON(res, 201, new AuthResponse({ user, token }));
+      return respondWithJSON(res, 201, this.authResponse(req, res, user));
 
     } catch (error) {
       console.error('Register error:', error);
-      return respondWithError(res, 500, 'Failed to register user');
+      return respondWithError(res, 500, 'Failed to register user', { cause: error });
     }
   }
 
@@ -66,6 +46,7 @@ class AuthHandler {
    */
   async login(req, res) {
     try {
+      rejectUnknownFields(req.body, ['username', 'password']);
       const { username, password } = req.body;
 
       // Validate required fields
@@ -73,12 +54,12 @@ class AuthHandler {
 
       // Find user by username
       const result = await db.query(
-        'SELECT * FROM users WHERE username = $1',
+        'SELECT * FROM users WHERE username = $1 AND deleted_at IS NULL',
         [username]
       );
 
       if (result.rows.length === 0) {
-        return respondWithError(res, 401, 'Invalid credentials');
+        return respondWithError(res, 401, 'Invalid credentials', { code: 'INVALID_CREDENTIALS' });
       }
 
       const userData = result.rows[0];
@@ -87,7 +68,7 @@ class AuthHandler {
       const isValidPassword = await verifyPassword(password, userData.password_hash);
 
       if (!isValidPassword) {
-        return respondWithError(res, 401, 'Invalid credentials');
+        return respondWithError(res, 401, 'Invalid credentials', { code: 'INVALID_CREDENTIALS' });
       }
 
       const user = new User({
@@ -101,14 +82,44 @@ class AuthHandler {
         updatedAt: userData.updated_at
       });
 
-      // Generate JWT token
-      const token = generateJWT(user.id, user.username);
+      await deviceService.recordLogin(req, user);
 
-      return respondWithJSON(res, 200, new AuthResponse({ user, token }));
+      return respondWithJSON(res, 200, this.authResponse(req, res, user));
 
     } catch (error) {
       console.error('Login error:', error);
-      return respondWithError(res, 500, 'Failed to login');
+      return respondWithError(res, 500, 'Failed to login', { cause: error });
+    }
+  }
+
+  /**
+   * End a cookie session (Bearer tokens are simply discarded by the client)
+   */
+  async logout(req, res) {
+    try {
+      clearSession(res);
+      return respondWithJSON(res, 200, { loggedOut: true });
+
+    } catch (error) {
+      console.error('Logout error:', error);
+      return respondWithError(res, 500, 'Failed to logout', { cause: error });
+    }
+  }
+
+  /**
+   * Current session user and CSRF token, so the web UI can recover its state after a reload
+   */
+  async getSession(req, res) {
+    try {
+      if (!req.session) {
+        return respondWithError(res, 400, 'Not a cookie session', { code: 'NOT_COOKIE_SESSION' });
+      }
+
+      return respondWithJSON(res, 200, new AuthResponse({ user: req.user, csrfToken: req.session.csrfToken }));
+
+    } catch (error) {
+      console.error('Get session error:', error);
+      return respondWithError(res, 500, 'Failed to get session', { cause: error });
     }
   }
 
@@ -128,7 +139,7 @@ class AuthHandler {
       const decoded = validateJWT(token);
 
       const result = await db.query(
-        'SELECT * FROM users WHERE id = $1',
+        'SELECT * FROM users WHERE id = $1 AND deleted_at IS NULL',
         [decoded.userId]
       );
 
@@ -0,0 +1,397 @@
+const db = require('../database/db');
+const { respondWithError, respondWithJSON } = require('../utils/response');
+const { getUserFromContext } = require('../middleware/auth');
+const { PASSPHRASE_HEADER, getRequestPassphrase, encryptArchive, isEncryptedArchive, decryptArchive } = require('../utils/encryption');
+const { isSupportedCurrency, isValidLocale } = require('../utils/currency');
+const { CALENDARS, isValidTimezone } = require('../utils/dates');
+
+const BACKUP_FORMAT = 'loan-money-backup';
+const BACKUP_VERSION = 1;
+const CONFLICT_STRATEGIES = ['skip', 'overwrite', 'fail'];
+
+// Tables in restore order (parents first). parents maps foreign key columns to the table they must belong to.
+// Tables without user_id are owned through their parents.
+const BACKUP_TABLES = [
+  {
+    name: 'borrowers',
+    columns: ['id', 'name', 'phone', 'email', 'line_id', 'address', 'created_at', 'updated_at', 'deleted_at'],
+    parents: {}
+  },
+  {
+    name: 'borrower_notes',
+    columns: ['id', 'borrower_id', 'note_type', 'content', 'promised_amount', 'promised_date', 'occurred_at', 'created_at'],
+    parents: { borrower_id: 'borrowers' }
+  },
+  {
+    name: 'loans',
+    columns: [
+      'id', 'borrower_id', 'borrower_name', 'borrower_phone', 'borrower_address', 'amount', 'interest_rate',
+      'interest_type', 'term_months', 'direction', 'currency', 'loan_date', 'due_date', 'status', 'notes', 'last_accrued_at',
+      'created_at', 'updated_at', 'deleted_at'
+    ],
+    parents: { borrower_id: 'borrowers' }
+  },
+  {
+    name: 'transactions',
+    columns: [
+      'id', 'loan_id', 'amount', 'currency', 'remark', 'payment_date', 'transaction_type', 'transaction_date', 'description',
+      'status', 'confirmed_at', 'created_at', 'updated_at', 'deleted_at'
+    ],
+    parents: { loan_id: 'loans' }
+  },
+  {
+    name: 'payment_plans',
+    columns: ['id', 'loan_id', 'amount', 'frequency', 'start_date', 'next_due_date', 'end_date', 'active', 'created_at', 'updated_at'],
+    parents: { loan_id: 'loans' }
+  },
+  {
+    name: 'expected_payments',
+    columns: ['id', 'plan_id', 'loan_id', 'due_date', 'amount', 'status', 'created_at'],
+    parents: { plan_id: 'payment_plans', loan_id: 'loans' },
+    ownedThrough: 'loan_id'
+  },
+  {
+    name: 'reminder_rules',
+    columns: ['id', 'loan_id', 'rule_type', 'days_before', 'repeat_every_days', 'active', 'created_at', 'updated_at'],
+    parents: { loan_id: 'loans' }
+  },
+  {
+    name: 'saved_reports',
+    columns: ['id', 'name', 'definition', 'schedule', 'next_run_at', 'last_run_at', 'created_at', 'updated_at'],
+    parents: {}
+  },
+  {
+    name: 'webhook_endpoints',
+    columns: ['id', 'url', 'secret', 'events', 'active', 'created_at', 'updated_at'],
+    parents: {}
+  }
+];
+
+const USER_PROFILE_COLUMNS = ['full_name', 'email', 'phone', 'address', 'email_notifications', 'sms_borrower_reminders', 'preferred_currency', 'calendar', 'timezone', 'locale'];
+
+class RestoreConflictError extends Error {}
+
+class BackupHandler {
+  /**
+   * Pick whitelisted columns from a row
+   */
+  pick(row, columns) {
+    const picked = {};
+    columns.forEach(column => {
+      if (row[column] !== undefined) {
+        picked[column] = row[column];
+      }
+    });
+    return picked;
+  }
+
+  /**
+   * Select a table's rows for the user as JSON (dates keep their SQL text form)
+   */
+  async selectTable(client, table, userId) {
+    const ownership = table.ownedThrough
+      ? `${table.ownedThrough} IN (SELECT id FROM loans WHERE user_id = $1)`
+      : 'user_id = $1';
+
+    const result = await client.query(
+      `SELECT to_jsonb(t) as row FROM ${table.name} t WHERE ${ownership} ORDER BY created_at ASC`,
+      [userId]
+    );
+    return result.rows.map(({ row }) => this.pick(row, table.columns));
+  }
+
+  /**
+   * Build a complete JSON archive of the user's data
+   */
+  async buildArchive(userId) {
+    const profileResult = await db.query('SELECT to_jsonb(u) as row FROM users u WHERE id = $1', [userId]);
+
+    const data = {};
+    for (const table of BACKUP_TABLES) {
+      data[table.name] = await this.selectTable(db, table, userId);
+    }
+
+    const preferencesResult = await db.query(
+      'SELECT event, channel, enabled FROM notification_preferences WHERE user_id = $1',
+      [userId]
+    );
+    data.notification_preferences = preferencesResult.rows;
+
+    return {
+      format: BACKUP_FORMAT,
+      version: BACKUP_VERSION,
+      exportedAt: new Date(),
+      // Attachment files and integration tokens are not included
+      profile: this.pick(profileResult.rows[0].row, USER_PROFILE_COLUMNS),
+      data
+    };
+  }
+
+  /**
+   * Export a complete JSON archive of the user's data (encrypted when a passphrase is given)
+   */
+  async exportBackup(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { passphrase, error } = getRequestPassphrase(req);
+      if (error) {
+        return respondWithError(res, 400, error);
+      }
+
+      const archive = await this.buildArchive(user.id);
+      const fileName = `loan-money-backup-${new Date().toISOString().slice(0, 10)}.json`;
+
+      if (passphrase) {
+        res.setHeader('Content-Type', 'application/octet-stream');
+        res.setHeader('Content-Disposition', `attachment; filename="${fileName}.enc"`);
+        return res.status(200).send(encryptArchive(Buffer.from(JSON.stringify(archive)), passphrase));
+      }
+
+      res.setHeader('Content-Disposition', `attachment; filename="${fileName}"`);
+      return respondWithJSON(res, 200, archive);
+
+    } catch (error) {
+      console.error('Export backup error:', error);
+      return respondWithError(res, 500, 'Failed to export backup', { cause: error });
+    }
+  }
+
+  /**
+   * Get IDs of a table's rows owned by the user
+   */
+  async getOwnedIds(client, tableName, userId) {
+    const result = await client.query(`SELECT id FROM ${tableName} WHERE user_id = $1`, [userId]);
+    return new Set(result.rows.map(row => row.id));
+  }
+
+  /**
+   * Restore one row; returns 'inserted', 'updated' or 'skipped'
+   */
+  async restoreRow(client, table, row, userId, strategy, ownedLoanIds) {
+    const values = this.pick(row, table.columns);
+    if (!table.ownedThrough) {
+      values.user_id = userId;
+    }
+
+    const columns = Object.keys(values);
+    const params = columns.map(column => values[column]);
+    const placeholders = columns.map((_, index) => `$${index + 1}`);
+
+    const inserted = await client.query(
+      `INSERT INTO ${table.name} (${columns.join(', ')}) VALUES (${placeholders.join(', ')})
+       ON CONFLICT (id) DO NOTHING
+       RETURNING id`,
+      params
+    );
+
+    if (inserted.rows.length > 0) {
+      return 'inserted';
+    }
+
+    if (strategy === 'fail') {
+      throw new RestoreConflictError(`${table.name} ${row.id} already exists`);
+    }
+
+    if (strategy === 'skip') {
+      return 'skipped';
+    }
+
+    // Overwrite only rows that already belong to this user
+    const updates = columns.filter(column => column !== 'id' && column !== 'user_id');
+    const idIndex = columns.indexOf('id') + 1;
+    let ownership;
+    if (table.ownedThrough) {
+      params.push([...ownedLoanIds]);
+      ownership = `${table.ownedThrough} = ANY($${params.length}::uuid[])`;
+    } else {
+      params.push(userId);
+      ownership = `user_id = $${params.length}`;
+    }
+
+    const updated = await client.query(
+      `UPDATE ${table.name}
+       SET ${updates.map(column => `${column} = $${columns.indexOf(column) + 1}`).join(', ')}
+       WHERE id = $${idIndex} AND ${ownership}
+       RETURNING id`,
+      params
+    );
+
+    return updated.rows.length > 0 ? 'updated' : 'skipped';
+  }
+
+  /**
+   * Whether archived profile settings hold values the profile endpoint would accept
+   */
+  isValidProfile(values) {
+    const set = column => values[column] !== undefined && values[column] !== null;
+    return (!set('preferred_currency') || isSupportedCurrency(values.preferred_currency)) &&
+      (!set('calendar') || CALENDARS.includes(values.calendar)) &&
+      (!set('timezone') || isValidTimezone(values.timezone)) &&
+      (!set('locale') || isValidLocale(values.locale)) &&
+      ['email_notifications', 'sms_borrower_reminders'].every(column => !set(column) || typeof values[column] === 'boolean');
+  }
+
+  /**
+   * Restore archived profile settings onto the user; returns 'updated', 'skipped' or 'rejected'.
+   * The account always exists, so each setting it already has is a conflict: skip only fills
+   * settings without a value, overwrite replaces them, and fail aborts when a set value differs.
+   */
+  async restoreProfile(client, profile, userId, strategy) {
+    const values = this.pick(profile, USER_PROFILE_COLUMNS);
+    if (!this.isValidProfile(values)) {
+      return 'rejected';
+    }
+
+    const currentResult = await client.query(`SELECT ${USER_PROFILE_COLUMNS.join(', ')} FROM users WHERE id = $1`, [userId]);
+    const current = currentResult.rows[0];
+    const changed = Object.keys(values).filter(column => values[column] !== current[column]);
+
+    const conflicting = changed.filter(column => current[column] !== null);
+    if (strategy === 'fail' && conflicting.length > 0) {
+      throw new RestoreConflictError(`profile ${conflicting.join(', ')} already set`);
+    }
+
+    const columns = strategy === 'overwrite' ? changed : changed.filter(column => current[column] === null);
+    if (columns.length === 0) {
+      return 'skipped';
+    }
+
+    await client.query(
+      `UPDATE users
+       SET ${columns.map((column, index) => `${column} = $${index + 1}`).join(', ')}, updated_at = CURRENT_TIMESTAMP
+       WHERE id = $${columns.length + 1}`,
+      [...columns.map(column => values[column]), userId]
+    );
+    return 'updated';
+  }
+
+  /**
+   * Restore a backup archive into the user's account.
+   * strategy: skip (keep existing rows), overwrite (replace existing rows) or fail (abort on any conflict)
+   */
+  async restoreBackup(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const strategy = req.query.strategy || 'skip';
+
+      // Encrypted archives arrive as application/octet-stream
+      let archive = req.body;
+      if (Buffer.isBuffer(req.body)) {
+        if (!isEncryptedArchive(req.body)) {
+          return respondWithError(res, 400, 'Body must be a loan-money backup archive');
+        }
+
+        const passphrase = req.get(PASSPHRASE_HEADER);
+        if (!passphrase) {
+          return respondWithError(res, 400, `${PASSPHRASE_HEADER} header is required for encrypted archives`);
+        }
+
+        try {
+          archive = JSON.parse(decryptArchive(req.body, passphrase).toString('utf8'));
+        } catch (decryptError) {
+          return respondWithError(res, 400, 'Could not decrypt archive: incorrect passphrase or corrupted file');
+        }
+      }
+
+      if (!CONFLICT_STRATEGIES.includes(strategy)) {
+        return respondWithError(res, 400, `Strategy must be one of: ${CONFLICT_STRATEGIES.join(', ')}`);
+      }
+
+      if (!archive || archive.format !== BACKUP_FORMAT || !archive.data || typeof archive.data !== 'object') {
+        return respondWithError(res, 400, 'Body must be a loan-money backup archive');
+      }
+
+      if (archive.version > BACKUP_VERSION) {
+        return respondWithError(res, 400, `Backup version ${archive.version} is newer than supported version ${BACKUP_VERSION}`);
+      }
+
+      const summary = {};
+      try {
+        await db.transaction(async client => {
+
+          const ownedIds = {};
+          for (const table of BACKUP_TABLES) {
+            const rows = Array.isArray(archive.data[table.name]) ? archive.data[table.name] : [];
+            const counts = { inserted: 0, updated: 0, skipped: 0, rejected: 0 };
+
+            for (const row of rows) {
+              // Every referenced parent must belong to this user
+              const orphaned = !row || typeof row.id !== 'string' || Object.entries(table.parents).some(([column, parent]) => (
+                row[column] !== null && row[column] !== undefined && !ownedIds[parent].has(row[column])
+              ));
+
+              if (orphaned) {
+                counts.rejected++;
+                continue;
+              }
+
+              await client.query('SAVEPOINT restore_row');
+              try {
+                const outcome = await this.restoreRow(client, table, row, user.id, strategy, ownedIds.loans);
+                counts[outcome]++;
+                await client.query('RELEASE SAVEPOINT restore_row');
+              } catch (rowError) {
+                if (rowError instanceof RestoreConflictError) {
+                  throw rowError;
+                }
+                // Invalid row (e.g. bad value or constraint violation): skip it, keep the rest
+                await client.query('ROLLBACK TO SAVEPOINT restore_row');
+                counts.rejected++;
+              }
+            }
+
+            if (!table.ownedThrough) {
+              ownedIds[table.name] = await this.getOwnedIds(client, table.name, user.id);
+            }
+            summary[table.name] = counts;
+          }
+
+          if (archive.profile && typeof archive.profile === 'object') {
+            const counts = { inserted: 0, updated: 0, skipped: 0, rejected: 0 };
+            await client.query('SAVEPOINT restore_row');
+            try {
+              counts[await this.restoreProfile(client, archive.profile, user.id, strategy)]++;
+              await client.query('RELEASE SAVEPOINT restore_row');
+            } catch (profileError) {
+              if (profileError instanceof RestoreConflictError) {
+                throw profileError;
+              }
+              // e.g. an email already used by another account
+              await client.query('ROLLBACK TO SAVEPOINT restore_row');
+              counts.rejected++;
+            }
+            summary.profile = counts;
+          }
+
+          const preferences = Array.isArray(archive.data.notification_preferences) ? archive.data.notification_preferences : [];
+          for (const preference of preferences) {
+            if (!preference.event || !preference.channel || typeof preference.enabled !== 'boolean') {
+              continue;
+            }
+            await client.query(
+              `INSERT INTO notification_preferences (user_id, event, channel, enabled)
+               VALUES ($1, $2, $3, $4)
+               ON CONFLICT (user_id, event, channel) DO ${strategy === 'overwrite'
+                 ? 'UPDATE SET enabled = EXCLUDED.enabled, updated_at = CURRENT_TIMESTAMP'
+                 : 'NOTHING'}`,
+              [user.id, preference.event, preference.channel, preference.enabled]
+            );
+          }
+        });
+      } catch (error) {
+        if (error instanceof RestoreConflictError) {
+          return respondWithError(res, 409, `Restore aborted: ${error.message}`, { code: 'RESTORE_CONFLICT' });
+        }
+        throw error;
+      }
+
+      return respondWithJSON(res, 200, { strategy, tables: summary });
+
+    } catch (error) {
+      console.error('Restore backup error:', error);
+      return respondWithError(res, 500, 'Failed to restore backup', { cause: error });
+    }
+  }
+}
+
+module.exports = new BackupHandler();
@@ -0,0 +1,162 @@
+const { URL } = require('url');
+const db = require('../database/db');
+const config = require('../config');
+const { authMiddleware } = require('../middleware/auth');
+const { cacheMiddleware } = require('../middleware/cache');
+const { respondWithError, respondWithJSON, rejectUnknownFields } = require('../utils/response');
+const { createContext } = require('../utils/handlerContext');
+const { ErrorResponse } = require('../models');
+
+const MAX_BATCH_OPERATIONS = config.limits.batchOperations;
+const BATCH_METHODS = ['GET', 'POST', 'PUT', 'PATCH', 'DELETE'];
+// Routes that cannot run as a sub-request (recursion, long-lived streams)
+const EXCLUDED_PATHS = ['/api/v1/batch', '/api/v1/events'];
+// Routes that check the user's password: batching them would bypass the per-request auth rate limit
+const CREDENTIAL_ROUTES = ['PATCH /api/v1/change-password', 'DELETE /api/v1/profile'];
+// Route middleware that is safe to skip: the batch request is already authenticated and caching is an optimization
+const SKIPPED_MIDDLEWARE = [cacheMiddleware];
+
+class BatchHandler {
+  /**
+   * Find the protected JSON route handling method + path; returns { handler, params, path } or null
+   */
+  matchRoute(app, method, path) {
+    for (const layer of app._router.stack) {
+      if (!layer.route || !layer.route.methods[method.toLowerCase()] || !layer.match(path)) {
+        continue;
+      }
+
+      const handlers = layer.route.stack
+        .map(routeLayer => routeLayer.handle)
+        .filter(handle => !SKIPPED_MIDDLEWARE.includes(handle));
+      // Only routes behind auth alone: body parsers for uploads and public routes are not batchable
+      if (handlers.length !== 2 || handlers[0] !== authMiddleware) {
+        return null;
+      }
+      return { handler: handlers[1], params: { ...layer.params }, path: layer.route.path };
+    }
+    return null;
+  }
+
+  /**
+   * Validate one sub-request; returns an error message or null
+   */
+  validateOperation(operation) {
+    if (!operation || typeof operation !== 'object') {
+      return 'Each operation must be an object';
+    }
+    if (!BATCH_METHODS.includes(String(operation.method).toUpperCase())) {
+      return `method must be one of: ${BATCH_METHODS.join(', ')}`;
+    }
+    if (typeof operation.path !== 'string' || !operation.path.startsWith('/api/')) {
+      return 'path must be an API path such as /api/v1/loans';
+    }
+    return null;
+  }
+
+  /**
+   * Build an error result for an operation that was not executed
+   */
+  errorResult(req, index, status, message, code = null) {
+    return {
+      status,
+      body: { error: new ErrorResponse({ status, code, message, requestId: `${req.id}:${index}` }) }
+    };
+  }
+
+  /**
+   * Run one sub-request through its route handler as the batch's user
+   */
+  async executeOperation(req, operation, index) {
+    const method = operation.method.toUpperCase();
+    const url = new URL(operation.path, 'http://batch.local');
+
+    if (EXCLUDED_PATHS.includes(url.pathname)) {
+      return this.errorResult(req, index, 400, `${url.pathname} cannot be called in a batch`, 'NOT_BATCHABLE');
+    }
+
+    const route = this.matchRoute(req.app, method, url.pathname);
+    if (!route) {
+      return this.errorResult(req, index, 404, `No batchable route for ${method} ${url.pathname}`, 'ROUTE_NOT_FOUND');
+    }
+
+    // Checked against the matched route so case or trailing-slash variants of the path are caught too
+    if (CREDENTIAL_ROUTES.includes(`${method} ${route.path}`)) {
+      return this.errorResult(req, index, 400, `${route.path} cannot be called in a batch`, 'NOT_BATCHABLE');
+    }
+
+    const { req: subRequest, res, done } = createContext({
+      id: `${req.id}:${index}`,
+      method,
+      path: url.pathname,
+      headers: req.headers,
+      params: route.params,
+      query: Object.fromEntries(url.searchParams),
+      body: operation.body || {},
+      user: req.user
+    });
+    subRequest.app = req.app;
+
+    route.handler(subRequest, res);
+    const { status, headers, payload } = await done;
+
+    // Binary responses (exports, PDFs) are returned base64 encoded
+    if (Buffer.isBuffer(payload)) {
+      return { status, headers, body: payload.toString('base64'), encoding: 'base64' };
+    }
+    return { status, headers, body: payload };
+  }
+
+  /**
+   * Execute several API calls in one request: { operations: [{ method, path, body }], transaction }
+   * With transaction: true everything commits together and the batch stops at the first failure.
+   */
+  async executeBatch(req, res) {
+    try {
+      rejectUnknownFields(req.body, ['operations', 'transaction']);
+      const { operations } = req.body;
+      const transaction = req.body.transaction === true;
+
+      if (!Array.isArray(operations) || operations.length === 0) {
+        return respondWithError(res, 400, 'operations must be a non-empty array');
+      }
+
+      if (operations.length > MAX_BATCH_OPERATIONS) {
+        return respondWithError(res, 400, `A batch can contain at most ${MAX_BATCH_OPERATIONS} operations`);
+      }
+
+      const details = operations
+        .map((operation, index) => ({ index, issue: this.validateOperation(operation) }))
+        .filter(detail => detail.issue);
+      if (details.length > 0) {
+        return respondWithError(res, 400, 'Invalid batch operations', { details });
+      }
+
+      const run = async () => {
+        const results = [];
+        for (const [index, operation] of operations.entries()) {
+          if (transaction && results.some(result => result.status >= 400)) {
+            results.push(this.errorResult(req, index, 424, 'Skipped after an earlier operation failed', 'FAILED_DEPENDENCY'));
+            continue;
+          }
+          results.push(await this.executeOperation(req, operation, index));
+        }
+        return { results, rollback: transaction && results.some(result => result.status >= 400) };
+      };
+
+      const { results, rollback } = transaction ? await db.transaction(run) : await run();
+
+      return respondWithJSON(res, 200, {
+        transaction,
+        committed: transaction ? !rollback : null,
+        results
+      });
+
+    } catch (error) {
+      console.error('Execute batch error:', error);
+      return respondWithError(res, 500, 'Failed to execute batch', { cause: error });
+    }
+  }
+}
+
+module.exports = new BatchHandler();
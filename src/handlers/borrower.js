@@ -0,0 +1,848 @@
+const db = require('../database/db');
+const { SelectQuery } = require('../database/query');
+const { respondWithError, respondWithJSON, validateRequiredFields, rejectUnknownFields, parsePagination } = require('../utils/response');
+const { getUserFromContext } = require('../middleware/auth');
+const { Borrower } = require('../models');
+const { DEFAULT_CURRENCY, roundCurrency, formatMoney, userLocale } = require('../utils/currency');
+const { calculateRiskScore } = require('../utils/risk');
+const { recordAudit } = require('../utils/audit');
+const { parseCSVRecords } = require('../utils/csv');
+const { parseVCards } = require('../utils/vcard');
+const { generateTextPDF } = require('../utils/pdf');
+const { DATE_FORMAT_HINT, parseDate, formatDate, formatDateFields, userCalendar, userToday } = require('../utils/dates');
+
+const EMAIL_PATTERN = /^[^\s@]+@[^\s@]+\.[^\s@]+$/;
+const NOTE_TYPES = ['note', 'call', 'visit', 'message', 'promise'];
+const MAX_IMPORT_ROWS = 5000;
+
+// Columns PATCH /borrowers/:id may change; omitted fields keep their value and null clears them
+const BORROWER_UPDATE_FIELDS = {
+  name: 'name',
+  phone: 'phone',
+  email: 'email',
+  lineId: 'line_id',
+  address: 'address'
+};
+
+// Query-string filters and sort fields accepted by the borrower list
+const BORROWER_FILTERS = {
+  search: { column: ['name', 'phone', 'email'], type: 'search' }
+};
+const BORROWER_SORTS = {
+  name: 'name',
+  created_at: 'created_at',
+  updated_at: 'updated_at'
+};
+
+/**
+ * Normalize phone number for duplicate detection (digits only, Thai +66 -> 0)
+ */
+function normalizePhone(phone) {
+  if (!phone) {
+    return null;
+  }
+  const digits = phone.replace(/\D/g, '');
+  return digits.startsWith('66') && digits.length === 11 ? `0${digits.slice(2)}` : digits || null;
+}
+
+/**
+ * Normalize name for duplicate detection
+ */
+function normalizeName(name) {
+  return name ? name.trim().toLowerCase().replace(/\s+/g, ' ') : null;
+}
+
+class BorrowerHandler {
+  /**
+   * Map database row to Borrower model
+   */
+  toBorrower(row) {
+    return new Borrower({
+      id: row.id,
+      userId: row.user_id,
+      name: row.name,
+      phone: row.phone,
+      email: row.email,
+      lineId: row.line_id,
+      address: row.address,
+      createdAt: row.created_at,
+      updatedAt: row.updated_at
+    });
+  }
+
+  /**
+   * Find borrower by name for user, creating one if it doesn't exist
+   */
+  async findOrCreateBorrower(client, userId, { name, phone = null, address = null }) {
+    const existing = await client.query(
+      `SELECT * FROM borrowers
+       WHERE user_id = $1 AND name = $2 AND deleted_at IS NULL
+       ORDER BY created_at
+       LIMIT 1`,
+      [userId, name]
+    );
+
+    if (existing.rows.length > 0) {
+      return existing.rows[0];
+    }
+
+    const result = await client.query(
+      `INSERT INTO borrowers (user_id, name, phone, address)
+       VALUES ($1, $2, $3, $4)
+       RETURNING *`,
+      [userId, name, phone, address]
+    );
+
+    return result.rows[0];
+  }
+
+  /**
+   * Compute reliability score from loan and expected-payment history
+   */
+  async getRiskScore(client, user, borrowerId) {
+    // Loans: paid loans are judged by their last payment date, open loans by today
+    const loansResult = await client.query(
+      `SELECT
+         COUNT(*) as loan_count,
+         COUNT(*) FILTER (WHERE status = 'defaulted') as defaulted_count,
+         COUNT(*) FILTER (WHERE due_date IS NOT NULL AND settled_at <= due_date) as on_time_count,
+         COUNT(*) FILTER (WHERE due_date IS NOT NULL AND settled_at > due_date) as late_count,
+         COALESCE(SUM(GREATEST(settled_at - due_date, 0)) FILTER (WHERE due_date IS NOT NULL AND settled_at IS NOT NULL), 0) as total_days_late
+       FROM (
+         SELECT l.status, l.due_date,
+           CASE
+             WHEN l.status = 'paid' THEN (
+               SELECT MAX(COALESCE(t.transaction_date, t.created_at::date))
+               FROM transactions t
+               WHERE t.loan_id = l.id AND t.deleted_at IS NULL AND t.transaction_type = 'payment'
+             )
+             WHEN l.due_date < $3::date THEN $3::date
+           END as settled_at
+         FROM loans l
+         WHERE l.borrower_id = $1 AND l.user_id = $2 AND l.deleted_at IS NULL
+       ) history`,
+      [borrowerId, user.id, userToday(user)]
+    );
+
+    const expectedResult = await client.query(
+      `SELECT
+         COUNT(*) FILTER (WHERE ep.status = 'paid') as paid_count,
+         COUNT(*) FILTER (WHERE ep.status = 'missed') as missed_count
+       FROM expected_payments ep
+       JOIN loans l ON ep.loan_id = l.id
+       WHERE l.borrower_id = $1 AND l.user_id = $2 AND l.deleted_at IS NULL`,
+      [borrowerId, user.id]
+    );
+
+    const loans = loansResult.rows[0];
+    const expected = expectedResult.rows[0];
+
+    return calculateRiskScore({
+      onTimeCount: parseInt(loans.on_time_count) + parseInt(expected.paid_count),
+      lateCount: parseInt(loans.late_count) + parseInt(expected.missed_count),
+      totalDaysLate: parseInt(loans.total_days_late),
+      defaultedCount: parseInt(loans.defaulted_count),
+      loanCount: parseInt(loans.loan_count)
+    });
+  }
+
+  /**
+   * Get all borrowers for user
+   */
+  async getBorrowers(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { page, limit, offset } = parsePagination(req.query);
+
+      const result = await new SelectQuery('SELECT * FROM borrowers WHERE user_id = ? AND deleted_at IS NULL', user.id)
+        .filter(BORROWER_FILTERS, req.query)
+        .sort(BORROWER_SORTS, req.query.sort, 'name ASC, id ASC')
+        .limit(limit)
+        .offset(offset)
+        .run();
+
+      return respondWithJSON(res, 200, {
+        borrowers: result.rows.map(row => this.toBorrower(row)),
+        pagination: { page, limit, total: result.rowCount }
+      });
+
+    } catch (error) {
+      console.error('Get borrowers error:', error);
+      return respondWithError(res, 500, 'Failed to get borrowers', { cause: error });
+    }
+  }
+
+  /**
+   * Create new borrower
+   */
+  async createBorrower(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['name', 'phone', 'email', 'lineId', 'address']);
+      const { name, phone, email, lineId, address } = req.body;
+
+      validateRequiredFields(req.body, ['name']);
+
+      if (email && !EMAIL_PATTERN.test(email)) {
+        return respondWithError(res, 400, 'Email is not valid');
+      }
+
+      const result = await db.query(
+        `INSERT INTO borrowers (user_id, name, phone, email, line_id, address)
+         VALUES ($1, $2, $3, $4, $5, $6)
+         RETURNING *`,
+        [user.id, name, phone, email, lineId, address]
+      );
+
+      return respondWithJSON(res, 201, this.toBorrower(result.rows[0]));
+
+    } catch (error) {
+      console.error('Create borrower error:', error);
+      return respondWithError(res, 500, 'Failed to create borrower', { cause: error });
+    }
+  }
+
+  /**
+   * Get specific borrower
+   */
+  async getBorrower(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const result = await db.query(
+        'SELECT * FROM borrowers WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+        [id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Borrower not found');
+      }
+
+      return respondWithJSON(res, 200, this.toBorrower(result.rows[0]));
+
+    } catch (error) {
+      console.error('Get borrower error:', error);
+      return respondWithError(res, 500, 'Failed to get borrower', { cause: error });
+    }
+  }
+
+  /**
+   * Get aggregate summary of all loans for a borrower, with amounts per currency
+   */
+  async getBorrowerSummary(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const borrowerResult = await db.query(
+        'SELECT * FROM borrowers WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+        [id, user.id]
+      );
+
+      if (borrowerResult.rows.length === 0) {
+        return respondWithError(res, 404, 'Borrower not found');
+      }
+
+      const loansResult = await db.query(
+        `SELECT l.*,
+           COALESCE(lb.total_paid, 0) as total_paid,
+           COALESCE(lb.remaining_debt, l.amount) as remaining_debt,
+           (l.status = 'overdue' OR (l.status = 'active' AND l.due_date < $3::date)) as is_overdue
+         FROM loans l
+         LEFT JOIN loan_balances lb ON lb.loan_id = l.id
+         WHERE l.borrower_id = $1 AND l.user_id = $2 AND l.deleted_at IS NULL
+         ORDER BY l.loan_date DESC`,
+        [id, user.id, userToday(user)]
+      );
+
+      const loans = loansResult.rows;
+      const openLoans = loans.filter(loan => loan.status !== 'paid');
+      const overdueLoans = loans.filter(loan => loan.is_overdue);
+
+      // Amounts per currency; the top-level amounts are only given when the loans share one currency
+      const byCurrency = [...new Set(loans.map(loan => loan.currency))].sort().map(currency => {
+        const sum = (rows, field) => roundCurrency(rows
+          .filter(row => row.currency === currency)
+          .reduce((total, row) => total + parseFloat(row[field]), 0), currency);
+        return {
+          currency,
+          totalLent: sum(loans, 'amount'),
+          totalRepaid: sum(loans, 'total_paid'),
+          totalOutstanding: sum(openLoans, 'remaining_debt'),
+          overdueAmount: sum(overdueLoans, 'remaining_debt')
+        };
+      });
+      const single = byCurrency.length <= 1
+        ? byCurrency[0] || { currency: DEFAULT_CURRENCY, totalLent: 0, totalRepaid: 0, totalOutstanding: 0, overdueAmount: 0 }
+        : null;
+
+      return respondWithJSON(res, 200, {
+        borrower: this.toBorrower(borrowerResult.rows[0]),
+        loans,
+        totalLoans: loans.length,
+        activeLoans: openLoans.length,
+        currency: single ? single.currency : null,
+        totalLent: single ? single.totalLent : null,
+        totalRepaid: single ? single.totalRepaid : null,
+        totalOutstanding: single ? single.totalOutstanding : null,
+        overdueLoans: overdueLoans.length,
+        overdueAmount: single ? single.overdueAmount : null,
+        byCurrency,
+        risk: await this.getRiskScore(db, user, id)
+      });
+
+    } catch (error) {
+      console.error('Get borrower summary error:', error);
+      return respondWithError(res, 500, 'Failed to get borrower summary', { cause: error });
+    }
+  }
+
+  /**
+   * Get borrower reliability score
+   */
+  async getBorrowerScore(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const borrowerCheck = await db.query(
+        'SELECT id FROM borrowers WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+        [id, user.id]
+      );
+
+      if (borrowerCheck.rows.length === 0) {
+        return respondWithError(res, 404, 'Borrower not found');
+      }
+
+      return respondWithJSON(res, 200, await this.getRiskScore(db, user, id));
+
+    } catch (error) {
+      console.error('Get borrower score error:', error);
+      return respondWithError(res, 500, 'Failed to get borrower score', { cause: error });
+    }
+  }
+
+  /**
+   * Merge a duplicate borrower into this (primary) borrower
+   */
+  async mergeBorrower(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['duplicateId']);
+      const { id } = req.params;
+      const { duplicateId } = req.body;
+
+      validateRequiredFields(req.body, ['duplicateId']);
+
+      if (duplicateId === id) {
+        return respondWithError(res, 400, 'Cannot merge a borrower into itself');
+      }
+
+      const { error, merged } = await db.transaction(async client => {
+        const borrowersResult = await client.query(
+          `SELECT * FROM borrowers
+           WHERE id = ANY($1::uuid[]) AND user_id = $2 AND deleted_at IS NULL
+           FOR UPDATE`,
+          [[id, duplicateId], user.id]
+        );
+
+        const primary = borrowersResult.rows.find(row => row.id === id);
+        const duplicate = borrowersResult.rows.find(row => row.id === duplicateId);
+
+        if (!primary || !duplicate) {
+          return { error: [404, 'Borrower not found'] };
+        }
+
+        // Move loans (and with them, their transactions) to the primary borrower
+        const loansResult = await client.query(
+          `UPDATE loans SET borrower_id = $1, borrower_name = $2, updated_at = CURRENT_TIMESTAMP
+           WHERE borrower_id = $3 AND user_id = $4
+           RETURNING id`,
+          [primary.id, primary.name, duplicate.id, user.id]
+        );
+
+        const notesResult = await client.query(
+          'UPDATE borrower_notes SET borrower_id = $1 WHERE borrower_id = $2 AND user_id = $3',
+          [primary.id, duplicate.id, user.id]
+        );
+
+        // Fill in contact details missing on the primary record
+        const mergedResult = await client.query(
+          `UPDATE borrowers
+           SET phone = COALESCE(phone, $1), email = COALESCE(email, $2),
+               line_id = COALESCE(line_id, $3), address = COALESCE(address, $4),
+               updated_at = CURRENT_TIMESTAMP
+           WHERE id = $5
+           RETURNING *`,
+          [duplicate.phone, duplicate.email, duplicate.line_id, duplicate.address, primary.id]
+        );
+
+        await client.query(
+          'UPDATE borrowers SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $1',
+          [duplicate.id]
+        );
+
+        await recordAudit(client, {
+          userId: user.id,
+          action: 'borrower.merged',
+          entityType: 'borrower',
+          entityId: primary.id,
+          details: {
+            duplicateId: duplicate.id,
+            duplicateName: duplicate.name,
+            movedLoanIds: loansResult.rows.map(row => row.id),
+            movedNotes: notesResult.rowCount
+          }
+        });
+
+        return {
+          merged: {
+            borrower: this.toBorrower(mergedResult.rows[0]),
+            movedLoans: loansResult.rowCount,
+            movedNotes: notesResult.rowCount
+          }
+        };
+      });
+
+      if (error) {
+        return respondWithError(res, ...error);
+      }
+
+      return respondWithJSON(res, 200, merged);
+
+    } catch (error) {
+      console.error('Merge borrower error:', error);
+      return respondWithError(res, 500, 'Failed to merge borrowers', { cause: error });
+    }
+  }
+
+  /**
+   * Get borrower notes (newest first)
+   */
+  async getBorrowerNotes(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+      const { page, limit, offset } = parsePagination(req.query);
+
+      const borrowerCheck = await db.query(
+        'SELECT id FROM borrowers WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+        [id, user.id]
+      );
+
+      if (borrowerCheck.rows.length === 0) {
+        return respondWithError(res, 404, 'Borrower not found');
+      }
+
+      const countResult = await db.query(
+        'SELECT COUNT(*) as count FROM borrower_notes WHERE borrower_id = $1',
+        [id]
+      );
+
+      const result = await db.query(
+        `SELECT * FROM borrower_notes
+         WHERE borrower_id = $1
+         ORDER BY occurred_at DESC, created_at DESC
+         LIMIT $2 OFFSET $3`,
+        [id, limit, offset]
+      );
+
+      return respondWithJSON(res, 200, {
+        notes: result.rows,
+        pagination: { page, limit, total: parseInt(countResult.rows[0].count) }
+      });
+
+    } catch (error) {
+      console.error('Get borrower notes error:', error);
+      return respondWithError(res, 500, 'Failed to get borrower notes', { cause: error });
+    }
+  }
+
+  /**
+   * Add a note to a borrower
+   */
+  async createBorrowerNote(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['content', 'noteType', 'promisedAmount', 'promisedDate', 'occurredAt']);
+      const { id } = req.params;
+      const { content, promisedAmount, promisedDate, occurredAt } = req.body;
+      const noteType = req.body.noteType || 'note';
+
+      validateRequiredFields(req.body, ['content']);
+
+      if (!NOTE_TYPES.includes(noteType)) {
+        return respondWithError(res, 400, `Note type must be one of: ${NOTE_TYPES.join(', ')}`);
+      }
+
+      if (promisedAmount !== undefined && promisedAmount <= 0) {
+        return respondWithError(res, 400, 'Promised amount must be greater than 0');
+      }
+
+      const borrowerCheck = await db.query(
+        'SELECT id FROM borrowers WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+        [id, user.id]
+      );
+
+      if (borrowerCheck.rows.length === 0) {
+        return respondWithError(res, 404, 'Borrower not found');
+      }
+
+      const result = await db.query(
+        `INSERT INTO borrower_notes (borrower_id, user_id, note_type, content, promised_amount, promised_date, occurred_at)
+         VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, CURRENT_TIMESTAMP))
+         RETURNING *`,
+        [id, user.id, noteType, content, promisedAmount, promisedDate, occurredAt]
+      );
+
+      return respondWithJSON(res, 201, result.rows[0]);
+
+    } catch (error) {
+      console.error('Create borrower note error:', error);
+      return respondWithError(res, 500, 'Failed to create borrower note', { cause: error });
+    }
+  }
+
+  /**
+   * Delete a borrower note
+   */
+  async deleteBorrowerNote(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id, noteId } = req.params;
+
+      const result = await db.query(
+        'DELETE FROM borrower_notes WHERE id = $1 AND borrower_id = $2 AND user_id = $3 RETURNING *',
+        [noteId, id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Note not found');
+      }
+
+      return respondWithJSON(res, 200, { message: 'Note deleted successfully' });
+
+    } catch (error) {
+      console.error('Delete borrower note error:', error);
+      return respondWithError(res, 500, 'Failed to delete borrower note', { cause: error });
+    }
+  }
+
+  /**
+   * Parse uploaded contacts from CSV or vCard body
+   */
+  parseImportContacts(body, contentType) {
+    if (/vcard/i.test(contentType) || /^\s*BEGIN:VCARD/i.test(body)) {
+      return parseVCards(body).map(contact => ({
+        name: contact.name,
+        phone: contact.phones[0] || null,
+        email: contact.emails[0] || null,
+        lineId: contact.lineId,
+        address: contact.address
+      }));
+    }
+
+    return parseCSVRecords(body).records.map(record => ({
+      name: record.name || record.full_name || null,
+      phone: record.phone || record.mobile || null,
+      email: record.email || null,
+      lineId: record.line_id || null,
+      address: record.address || null
+    }));
+  }
+
+  /**
+   * Import borrowers from CSV or vCard (supports dry run)
+   */
+  async importBorrowers(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const dryRun = req.query.dry_run === 'true';
+
+      if (typeof req.body !== 'string' || req.body.trim() === '') {
+        return respondWithError(res, 400, 'CSV or vCard body is required (Content-Type: text/csv or text/vcard)');
+      }
+
+      const contacts = this.parseImportContacts(req.body, req.headers['content-type']);
+
+      if (contacts.length > MAX_IMPORT_ROWS) {
+        return respondWithError(res, 400, `Import is limited to ${MAX_IMPORT_ROWS} rows`);
+      }
+
+      const existingResult = await db.query(
+        'SELECT id, name, phone FROM borrowers WHERE user_id = $1 AND deleted_at IS NULL',
+        [user.id]
+      );
+
+      const byPhone = new Map();
+      const byName = new Map();
+      existingResult.rows.forEach(row => {
+        if (normalizePhone(row.phone)) {
+          byPhone.set(normalizePhone(row.phone), row.id);
+        }
+        byName.set(normalizeName(row.name), row.id);
+      });
+
+      const rows = contacts.map((contact, index) => {
+        const row = { row: index + 1, ...contact, status: 'new', duplicateOf: null, errors: [] };
+        const phoneKey = normalizePhone(contact.phone);
+        const nameKey = normalizeName(contact.name);
+
+        if (!contact.name) {
+          row.errors.push('name is required');
+        }
+        if (contact.email && !EMAIL_PATTERN.test(contact.email)) {
+          row.errors.push('email is not valid');
+        }
+
+        if (row.errors.length > 0) {
+          row.status = 'invalid';
+          return row;
+        }
+
+        const duplicateOf = (phoneKey && byPhone.get(phoneKey)) || byName.get(nameKey);
+        if (duplicateOf) {
+          row.status = 'duplicate';
+          row.duplicateOf = duplicateOf;
+          return row;
+        }
+
+        // Track within-file duplicates as well
+        const marker = `row:${row.row}`;
+        if (phoneKey) {
+          byPhone.set(phoneKey, marker);
+        }
+        byName.set(nameKey, marker);
+        return row;
+      });
+
+      const newRows = rows.filter(row => row.status === 'new');
+
+      let imported = 0;
+      if (!dryRun && newRows.length > 0) {
+        await db.transaction(async client => {
+          for (const row of newRows) {
+            await client.query(
+              `INSERT INTO borrowers (user_id, name, phone, email, line_id, address)
+               VALUES ($1, $2, $3, $4, $5, $6)`,
+              [user.id, row.name, row.phone, row.email, row.lineId, row.address]
+            );
+          }
+        });
+        imported = newRows.length;
+      }
+
+      return respondWithJSON(res, dryRun ? 200 : 201, {
+        dryRun,
+        totalRows: rows.length,
+        newRows: newRows.length,
+        duplicateRows: rows.filter(row => row.status === 'duplicate').length,
+        invalidRows: rows.filter(row => row.status === 'invalid').length,
+        imported,
+        rows
+      });
+
+    } catch (error) {
+      console.error('Import borrowers error:', error);
+      return respondWithError(res, 500, 'Failed to import borrowers', { cause: error });
+    }
+  }
+
+  /**
+   * Build consolidated statement data for a borrower
+   */
+  async buildStatement(userId, borrower, from, to) {
+    const loansResult = await db.query(
+      `SELECT l.id, l.currency, l.amount, l.loan_date, l.due_date, l.status,
+         COALESCE(SUM(ll.balance_effect) FILTER (WHERE $3::date IS NOT NULL AND ll.entry_date < $3::date), 0) as opening_balance,
+         COALESCE(SUM(ll.balance_effect) FILTER (WHERE ll.entry_date <= $4::date), 0) as closing_balance
+       FROM loans l
+       LEFT JOIN loan_ledger ll ON ll.loan_id = l.id
+       WHERE l.borrower_id = $1 AND l.user_id = $2 AND l.deleted_at IS NULL AND l.loan_date <= $4::date
+       GROUP BY l.id
+       ORDER BY l.loan_date ASC`,
+      [borrower.id, userId, from, to]
+    );
+
+    const entriesResult = await db.query(
+      `SELECT ll.loan_id, ll.entry_type, ll.amount, ll.balance_effect, ll.entry_date, ll.description
+       FROM loan_ledger ll
+       JOIN loans l ON ll.loan_id = l.id
+       WHERE l.borrower_id = $1 AND l.user_id = $2
+       AND ($3::date IS NULL OR ll.entry_date >= $3::date)
+       AND ll.entry_date <= $4::date
+       ORDER BY ll.entry_date ASC, (ll.entry_type = 'disbursement') DESC, ll.created_at ASC`,
+      [borrower.id, userId, from, to]
+    );
+
+    const loans = loansResult.rows.map(loan => ({
+      ...loan,
+      opening_balance: roundCurrency(loan.opening_balance, loan.currency),
+      closing_balance: roundCurrency(loan.closing_balance, loan.currency),
+      entries: entriesResult.rows.filter(entry => entry.loan_id === loan.id)
+    }));
+
+    // Amounts per currency; the top-level totals are only given when the loans share one currency
+    const byCurrency = [...new Set(loans.map(loan => loan.currency))].sort().map(currency => {
+      const currencyLoans = loans.filter(loan => loan.currency === currency);
+      const payments = currencyLoans.flatMap(loan => loan.entries.filter(entry => entry.entry_type === 'payment'));
+      const sum = (rows, field) => roundCurrency(rows.reduce((total, row) => total + parseFloat(row[field]), 0), currency);
+      return {
+        currency,
+        totalPaid: sum(payments, 'amount'),
+        openingBalance: sum(currencyLoans, 'opening_balance'),
+        closingBalance: sum(currencyLoans, 'closing_balance')
+      };
+    });
+    const single = byCurrency.length <= 1
+      ? byCurrency[0] || { currency: DEFAULT_CURRENCY, totalPaid: 0, openingBalance: 0, closingBalance: 0 }
+      : null;
+
+    return {
+      borrower: this.toBorrower(borrower),
+      period: { from, to },
+      currency: single ? single.currency : null,
+      loans,
+      totalPaid: single ? single.totalPaid : null,
+      openingBalance: single ? single.openingBalance : null,
+      closingBalance: single ? single.closingBalance : null,
+      byCurrency,
+      generatedAt: new Date().toISOString()
+    };
+  }
+
+  /**
+   * Render statement data as PDF lines, with dates in the given calendar and amounts in the given locale.
+   * Amounts carry the currency code rather than its symbol, which the PDF's standard fonts lack.
+   */
+  renderStatementPDF(statement, calendar = 'gregorian', locale = null) {
+    const money = (amount, currency) => formatMoney(amount, currency, { locale, display: 'code' });
+    const lines = [
+      { text: 'Loan Statement', bold: true },
+      `Borrower: ${statement.borrower.name}`,
+      `Period: ${formatDate(statement.period.from, calendar) || 'Beginning'} to ${formatDate(statement.period.to, calendar)}`,
+      `Generated: ${statement.generatedAt}`,
+      ''
+    ];
+
+    statement.loans.forEach(loan => {
+      lines.push({ text: `Loan ${loan.id} (${formatDate(loan.loan_date, calendar)}) - ${loan.status}`, bold: true });
+      lines.push(`Opening balance: ${money(loan.opening_balance, loan.currency)}`);
+      loan.entries.forEach(entry => {
+        lines.push(`  ${formatDate(entry.entry_date, calendar)}  ${entry.entry_type.padEnd(12)} ${money(entry.amount, loan.currency).padStart(18)}  ${entry.description || ''}`);
+      });
+      lines.push(`Closing balance: ${money(loan.closing_balance, loan.currency)}`);
+      lines.push('');
+    });
+
+    // One pair of totals per currency (zero totals without loans); different currencies are never added up
+    (statement.byCurrency.length > 0 ? statement.byCurrency : [statement]).forEach(totals => {
+      lines.push({ text: `Total paid in period: ${money(totals.totalPaid, totals.currency)}`, bold: true });
+      lines.push({ text: `Total closing balance: ${money(totals.closingBalance, totals.currency)}`, bold: true });
+    });
+
+    return generateTextPDF(lines);
+  }
+
+  /**
+   * Get consolidated borrower statement as JSON or PDF
+   */
+  async getBorrowerStatement(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+      const from = req.query.from ? parseDate(req.query.from) : null;
+      const to = req.query.to ? parseDate(req.query.to) : userToday(user);
+      const format = req.query.format || (req.accepts(['json', 'pdf']) === 'pdf' ? 'pdf' : 'json');
+      const calendar = userCalendar(user);
+
+      if ((req.query.from && !from) || !to) {
+        return respondWithError(res, 400, `from and to must be valid dates (${DATE_FORMAT_HINT})`);
+      }
+
+      if (!['json', 'pdf'].includes(format)) {
+        return respondWithError(res, 400, 'Format must be one of: json, pdf');
+      }
+
+      const borrowerResult = await db.query(
+        'SELECT * FROM borrowers WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+        [id, user.id]
+      );
+
+      if (borrowerResult.rows.length === 0) {
+        return respondWithError(res, 404, 'Borrower not found');
+      }
+
+      const statement = await this.buildStatement(user.id, borrowerResult.rows[0], from, to);
+
+      if (format === 'pdf') {
+        res.setHeader('Content-Type', 'application/pdf');
+        res.setHeader('Content-Disposition', `attachment; filename="statement-${id}-${to}.pdf"`);
+        return res.status(200).send(this.renderStatementPDF(statement, calendar, userLocale(user)));
+      }
+
+      return respondWithJSON(res, 200, {
+        ...statement,
+        period: { from: formatDate(statement.period.from, calendar), to: formatDate(statement.period.to, calendar) },
+        loans: statement.loans.map(loan => ({ ...formatDateFields(loan, calendar), entries: formatDateFields(loan.entries, calendar) }))
+      });
+
+    } catch (error) {
+      console.error('Get borrower statement error:', error);
+      return respondWithError(res, 500, 'Failed to generate borrower statement', { cause: error });
+    }
+  }
+
+  /**
+   * Update borrower contact details
+   */
+  async updateBorrower(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, Object.keys(BORROWER_UPDATE_FIELDS));
+      const { id } = req.params;
+      const { name, email } = req.body;
+
+      if ('name' in req.body && !name) {
+        return respondWithError(res, 400, 'Name cannot be empty');
+      }
+
+      if (email && !EMAIL_PATTERN.test(email)) {
+        return respondWithError(res, 400, 'Email is not valid');
+      }
+
+      const fields = Object.keys(BORROWER_UPDATE_FIELDS).filter(field => field in req.body);
+      const setClause = fields.map((field, index) => `${BORROWER_UPDATE_FIELDS[field]} = $${index + 1}, `).join('');
+      const values = fields.map(field => req.body[field]);
+
+      const result = await db.query(
+        `UPDATE borrowers
+         SET ${setClause}updated_at = CURRENT_TIMESTAMP
+         WHERE id = $${fields.length + 1} AND user_id = $${fields.length + 2} AND deleted_at IS NULL
+         RETURNING *`,
+        [...values, id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Borrower not found');
+      }
+
+      // Keep denormalized borrower name on loans in sync
+      if (name) {
+        await db.query(
+          'UPDATE loans SET borrower_name = $1 WHERE borrower_id = $2 AND user_id = $3',
+          [name, id, user.id]
+        );
+      }
+
+      return respondWithJSON(res, 200, this.toBorrower(result.rows[0]));
+
+    } catch (error) {
+      console.error('Update borrower error:', error);
+      return respondWithError(res, 500, 'Failed to update borrower', { cause: error });
+    }
+  }
+}
+
+module.exports = new BorrowerHandler();
@@ -0,0 +1,92 @@
+const { respondWithError, respondWithJSON, validateRequiredFields, rejectUnknownFields } = require('../utils/response');
+const { roundCurrency } = require('../utils/currency');
+const {
+  INTEREST_TYPES,
+  PAYMENT_FREQUENCIES,
+  getPeriodCount,
+  buildAmortizationSchedule
+} = require('../utils/interest');
+
+const MAX_TERM_MONTHS = 600;
+// The endpoint is public, so keep schedules small (600 months daily would be 18250 rows)
+const MAX_SCHEDULE_PERIODS = 1000;
+
+class CalculatorHandler {
+  /**
+   * Calculate amortization preview without persisting anything
+   */
+  async calculate(req, res) {
+    try {
+      rejectUnknownFields(req.body, ['principal', 'interestRate', 'termMonths', 'frequency', 'interestType']);
+      validateRequiredFields(req.body, ['principal', 'termMonths']);
+
+      const principal = Number(req.body.principal);
+      const interestRate = Number(req.body.interestRate || 0);
+      const termMonths = Number(req.body.termMonths);
+      const frequency = req.body.frequency || 'monthly';
+      const interestType = req.body.interestType || 'reducing';
+
+      if (!Number.isFinite(principal)) {
+        return respondWithError(res, 400, 'Principal must be a number');
+      }
+
+      if (principal <= 0) {
+        return respondWithError(res, 400, 'Principal must be greater than 0');
+      }
+
+      if (!Number.isFinite(interestRate)) {
+        return respondWithError(res, 400, 'Interest rate must be a number');
+      }
+
+      if (interestRate < 0) {
+        return respondWithError(res, 400, 'Interest rate cannot be negative');
+      }
+
+      if (!Number.isInteger(termMonths) || termMonths <= 0 || termMonths > MAX_TERM_MONTHS) {
+        return respondWithError(res, 400, `Term in months must be an integer between 1 and ${MAX_TERM_MONTHS}`);
+      }
+
+      if (!Object.keys(PAYMENT_FREQUENCIES).includes(frequency)) {
+        return respondWithError(res, 400, `Frequency must be one of: ${Object.keys(PAYMENT_FREQUENCIES).join(', ')}`);
+      }
+
+      if (!INTEREST_TYPES.includes(interestType)) {
+        return respondWithError(res, 400, `Interest type must be one of: ${INTEREST_TYPES.join(', ')}`);
+      }
+
+      const periods = getPeriodCount(termMonths, frequency);
+      if (periods > MAX_SCHEDULE_PERIODS) {
+        return respondWithError(res, 400, `A ${termMonths}-month ${frequency} schedule has ${periods} payments; at most ${MAX_SCHEDULE_PERIODS} are supported`);
+      }
+
+      const schedule = buildAmortizationSchedule({
+        principal,
+        annualRate: interestRate,
+        termMonths,
+        interestType,
+        frequency
+      });
+
+      const totalPayment = roundCurrency(schedule.reduce((sum, row) => sum + row.payment, 0));
+      const totalInterest = roundCurrency(schedule.reduce((sum, row) => sum + row.interest, 0));
+
+      return respondWithJSON(res, 200, {
+        principal,
+        interestRate,
+        interestType,
+        termMonths,
+        frequency,
+        installment: schedule[0].payment,
+        totalPayment,
+        totalInterest,
+        schedule
+      });
+
+    } catch (error) {
+      console.error('Calculator error:', error);
+      return respondWithError(res, 500, 'Failed to calculate loan', { cause: error });
+    }
+  }
+}
+
+module.exports = new CalculatorHandler();
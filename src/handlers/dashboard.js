@@ -1,53 +1,232 @@
+const config = require('../config');
 const db = require('../database/db');
 const { respondWithError, respondWithJSON, parsePagination } = require('../utils/response');
 const { getUserFromContext } = require('../middleware/auth');
 const { DashboardStats } = require('../models');
+const { parseDateRange, dateRangeCondition } = require('../utils/dateRange');
+const { frequencyIntervalSQL } = require('../utils/interest');
+const { DEFAULT_CURRENCY, roundCurrency } = require('../utils/currency');
+const exchangeRates = require('../rates');
+const { parseDate, userToday } = require('../utils/dates');
+
+const MAX_PROJECTION_MONTHS = 24;
+const MAX_TOP_BORROWERS = 100;
+const MAX_TREND_MONTHS = 36;
+const PAYMENT_GRACE_DAYS = config.loans.paymentGraceDays;
+const LOAN_DEFAULT_AFTER_DAYS = config.loans.defaultAfterDays;
+
+// Days without any payment after which a new loan counts as stalled
+const STALL_DAYS_WITHOUT_PAYMENT = 30;
+const DAY_MS = 24 * 60 * 60 * 1000;
+
+// Default preset and maximum span in days per stats granularity
+const STATS_GRANULARITIES = {
+  day: { defaultPreset: 'last_30_days', maxDays: 92 },
+  week: { defaultPreset: 'this_quarter', maxDays: 731 },
+  month: { defaultPreset: 'last_12_months', maxDays: null }
+};
+
+/**
+ * Single currency of per-currency totals, or null when they span several (so amounts cannot be summed)
+ */
+function singleCurrency(byCurrency) {
+  if (byCurrency.length > 1) {
+    return null;
+  }
+  return byCurrency.length === 1 ? byCurrency[0].currency : DEFAULT_CURRENCY;
+}
+
+/**
+ * Percentage of part in total rounded to 2 decimals (null when total is 0)
+ */
+function toRate(part, total) {
+  return total > 0 ? Math.round((part / total) * 10000) / 100 : null;
+}
+
+// Date column each dashboard metric is filtered on; "today" is always the user's local date (userToday)
+const LOAN_DATE = 'loan_date';
+const TRANSACTION_DATE = 'COALESCE(transaction_date, created_at::date)';
 
 class DashboardHandler {
+  /**
+   * Per-currency totals converted into the user's preferred currency, or null when a rate is unavailable
+   */
+  async consolidate(user, byCurrency, fields) {
+    try {
+      return await exchangeRates.consolidate(byCurrency, fields, user.preferredCurrency || DEFAULT_CURRENCY);
+    } catch (error) {
+      console.warn('Consolidated total unavailable:', error.message);
+      return null;
+    }
+  }
+
+  /**
+   * Run a query scoped to the user, with the range applied to the given date column
+   */
+  queryInRange(sql, column, range, userId, extraParams = []) {
+    const params = [userId, ...extraParams];
+    const condition = dateRangeCondition(column, range, params);
+    return db.reader.query(sql.replace('{{range}}', condition), params);
+  }
+
+  /**
+   * Load dashboard statistics in a single round trip.
+   * Loans are filtered by loan date, transactions by transaction date and overdue/missed items by due date.
+   * Only lent loans (money owed to the user) are counted; getNetPosition covers borrowed ones.
+   * Amounts are broken down per currency; the top-level totals are only set when there is a single currency,
+   * and consolidated gives them converted into the user's preferred currency.
+   */
+  async loadDashboardStats(user, range) {
+    const params = [user.id, userToday(user)];
+    const loanRange = dateRangeCondition(LOAN_DATE, range, params);
+    const dueRange = dateRangeCondition('due_date', range, params);
+    const missedRange = dateRangeCondition('ep.due_date', range, params);
+    const pendingRange = dateRangeCondition(TRANSACTION_DATE, range, params);
+
+    const result = await db.reader.query(
+      `WITH loan_stats AS (
+         SELECT COUNT(*) as total_loans,
+                COUNT(*) FILTER (WHERE status = 'active') as active_loans,
+                COALESCE(SUM(amount), 0) as total_amount
+         FROM loans
+         WHERE user_id = $1 AND deleted_at IS NULL AND direction = 'lent' ${loanRange}
+       ),
+       overdue_stats AS (
+         SELECT COUNT(*) as overdue_loans
+         FROM loans
+         WHERE user_id = $1 AND deleted_at IS NULL AND direction = 'lent' AND due_date < $2::date AND status = 'active' ${dueRange}
+       ),
+       missed_stats AS (
+         SELECT COUNT(*) as missed_payments
+         FROM expected_payments ep
+         JOIN loans l ON ep.loan_id = l.id
+         WHERE l.user_id = $1 AND l.deleted_at IS NULL AND l.direction = 'lent' AND ep.status = 'missed' ${missedRange}
+       ),
+       pending_stats AS (
+         SELECT COUNT(*) as pending_transactions
+         FROM transactions
+         WHERE user_id = $1 AND status = 'pending' AND deleted_at IS NULL
+         AND loan_id IN (SELECT id FROM loans WHERE user_id = $1 AND deleted_at IS NULL AND direction = 'lent') ${pendingRange}
+       ),
+       currency_loans AS (
+         SELECT currency, COUNT(*) as total_loans, SUM(amount) as total_amount
+         FROM loans
+         WHERE user_id = $1 AND deleted_at IS NULL AND direction = 'lent' ${loanRange}
+         GROUP BY currency
+       ),
+       currency_pending AS (
+         SELECT currency, SUM(amount) as pending_amount
+         FROM transactions
+         WHERE user_id = $1 AND status = 'pending' AND deleted_at IS NULL
+         AND loan_id IN (SELECT id FROM loans WHERE user_id = $1 AND deleted_at IS NULL AND direction = 'lent') ${pendingRange}
+         GROUP BY currency
+       ),
+       currency_stats AS (
+         SELECT COALESCE(json_agg(json_build_object(
+                  'currency', currency,
+                  'total_loans', COALESCE(cl.total_loans, 0),
+                  'total_amount', COALESCE(cl.total_amount, 0),
+                  'pending_amount', COALESCE(cp.pending_amount, 0)
+                ) ORDER BY currency), '[]') as by_currency
+         FROM currency_loans cl
+         FULL JOIN currency_pending cp USING (currency)
+       )
+       SELECT * FROM loan_stats, overdue_stats, missed_stats, pending_stats, currency_stats`,
+      params
+    );
+    const row = result.rows[0];
+    const byCurrency = row.by_currency.map(totals => ({
+      currency: totals.currency,
+      totalLoans: parseInt(totals.total_loans),
+      totalAmount: parseFloat(totals.total_amount),
+      pendingAmount: parseFloat(totals.pending_amount)
+    }));
+    const currency = singleCurrency(byCurrency);
+    const sum = field => (currency ? byCurrency.reduce((total, totals) => total + totals[field], 0) : null);
+
+    return new DashboardStats({
+      totalLoans: parseInt(row.total_loans),
+      activeLoans: parseInt(row.active_loans),
+      totalAmount: sum('totalAmount'),
+      totalInterest: currency ? 0 : null, // Calculate based on business logic
+      overdueLoans: parseInt(row.overdue_loans),
+      missedPayments: parseInt(row.missed_payments),
+      pendingTransactions: parseInt(row.pending_transactions),
+      pendingAmount: sum('pendingAmount'),
+      currency,
+      byCurrency,
+      consolidated: await this.consolidate(user, byCurrency, ['totalAmount', 'pendingAmount']),
+      range: { from: range.from, to: range.to, preset: range.preset }
+    });
+  }
+
+  /**
+   * Load loan counts and amounts per day, week or month (granularity must be whitelisted by the caller)
+   */
+  async loadPeriodStats(userId, granularity, range) {
+    const result = await this.queryInRange(
+      `SELECT 
+         DATE_TRUNC('${granularity}', loan_date) as period,
+         COUNT(*) as loans_count,
+         COALESCE(SUM(amount), 0) as total_amount
+       FROM loans 
+       WHERE user_id = $1 AND deleted_at IS NULL {{range}}
+       GROUP BY DATE_TRUNC('${granularity}', loan_date)
+       ORDER BY period DESC`,
+      LOAN_DATE, range, userId
+    );
+
+    // Keep the "month" key for existing monthly chart clients
+    return result.rows.map(row => (
+      granularity === 'month' ? { month: row.period, ...row } : row
+    ));
+  }
+
   /**
    * Get dashboard statistics
    */
   async getDashboardStats(req, res) {
     try {
       const user = getUserFromContext(req);
+      const range = parseDateRange(req.query, 'all', userToday(user));
 
-      // Get total loans count
-      const totalLoansResult = await db.query(
-        'SELECT COUNT(*) as count FROM loans WHERE user_id = $1',
-        [user.id]
-      );
+      if (range.error) {
+        return respondWithError(res, 400, range.error);
+      }
 
-      // Get active loans count
-      const activeLoansResult = await db.query(
-        'SELECT COUNT(*) as count FROM loans WHERE user_id = $1 AND status = $2',
-        [user.id, 'active']
-      );
+      const stats = await this.loadDashboardStats(user, range);
 
-      // Get total amount
-      const totalAmountResult = await db.query(
-        'SELECT COALESCE(SUM(amount), 0) as total FROM loans WHERE user_id = $1',
-        [user.id]
-      );
+      return respondWithJSON(res, 200, stats);
 
-      // Get overdue loans count
-      const overdueLoansResult = await db.query(
-        'SELECT COUNT(*) as count FROM loans WHERE user_id = $1 AND due_date < CURRENT_DATE AND status = $2',
-        [user.id, 'active']
-      );
+    } catch (error) {
+      console.error('Dashboard stats error:', error);
+      return respondWithError(res, 500, 'Failed to get dashboard statistics', { cause: error });
+    }
+  }
 
-      const stats = new DashboardStats({
-        totalLoans: parseInt(totalLoansResult.rows[0].count),
-        activeLoans: parseInt(activeLoansResult.rows[0].count),
-        totalAmount: parseFloat(totalAmountResult.rows[0].total),
-        totalInterest: 0, // Calculate based on business logic
-        overdueLoans: parseInt(overdueLoansResult.rows[0].count)
-      });
+  /**
+   * Get the combined dashboard payload (statistics and monthly trend) in one request
+   */
+  async getDashboard(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const range = parseDateRange(req.query, 'all', userToday(user));
+      const trendRange = parseDateRange(req.query, STATS_GRANULARITIES.month.defaultPreset, userToday(user));
 
-      return respondWithJSON(res, 200, stats);
+      if (range.error) {
+        return respondWithError(res, 400, range.error);
+      }
+
+      const [stats, monthly] = await Promise.all([
+        this.loadDashboardStats(user, range),
+        this.loadPeriodStats(user.id, 'month', trendRange)
+      ]);
+
+      return respondWithJSON(res, 200, { stats, monthly });
 
     } catch (error) {
-      console.error('Dashboard stats error:', error);
-      return respondWithError(res, 500, 'Failed to get dashboard statistics');
+      console.error('Dashboard error:', error);
+      return respondWithError(res, 500, 'Failed to get dashboard', { cause: error });
     }
   }
 
@@ -58,100 +237,797 @@ class DashboardHandler {
     try {
       const user = getUserFromContext(req);
       const { limit } = parsePagination(req.query);
+      const range = parseDateRange(req.query, 'all', userToday(user));
 
-      const result = await db.query(
+      if (range.error) {
+        return respondWithError(res, 400, range.error);
+      }
+
+      const params = [user.id];
+      const condition = dateRangeCondition('COALESCE(t.transaction_date, t.created_at::date)', range, params);
+      params.push(limit || 10);
+
+      const result = await db.reader.query(
         `SELECT t.*, l.borrower_name, l.amount as loan_amount
          FROM transactions t
          JOIN loans l ON t.loan_id = l.id
-         WHERE t.user_id = $1
+         WHERE t.user_id = $1 AND t.deleted_at IS NULL AND l.deleted_at IS NULL ${condition}
          ORDER BY t.created_at DESC
-         LIMIT $2`,
-        [user.id, limit || 10]
+         LIMIT $${params.length}`,
+        params
       );
 
       return respondWithJSON(res, 200, result.rows);
 
     } catch (error) {
       console.error('Recent transactions error:', error);
-      return respondWithError(res, 500, 'Failed to get recent transactions');
+      return respondWithError(res, 500, 'Failed to get recent transactions', { cause: error });
     }
   }
 
   /**
-   * Get loan summary
+   * Get loan summary per status and currency
    */
   async getLoanSummary(req, res) {
     try {
       const user = getUserFromContext(req);
+      const range = parseDateRange(req.query, 'all', userToday(user));
 
-      const result = await db.query(
+      if (range.error) {
+        return respondWithError(res, 400, range.error);
+      }
+
+      const result = await this.queryInRange(
         `SELECT 
            status,
+           currency,
            COUNT(*) as count,
            COALESCE(SUM(amount), 0) as total_amount
          FROM loans 
-         WHERE user_id = $1 
-         GROUP BY status`,
-        [user.id]
+         WHERE user_id = $1 AND deleted_at IS NULL {{range}}
+         GROUP BY status, currency
+         ORDER BY status, currency`,
+        LOAN_DATE, range, user.id
       );
 
       return respondWithJSON(res, 200, result.rows);
 
     } catch (error) {
       console.error('Loan summary error:', error);
-      return respondWithError(res, 500, 'Failed to get loan summary');
+      return respondWithError(res, 500, 'Failed to get loan summary', { cause: error });
     }
   }
 
   /**
-   * Get monthly statistics
+   * Get loan statistics grouped by day, week or month (defaults to monthly for the last 12 months)
    */
   async getMonthlyStats(req, res) {
     try {
       const user = getUserFromContext(req);
+      const granularity = req.query.granularity || 'month';
+      const settings = STATS_GRANULARITIES[granularity];
 
-      const result = await db.query(
-        `SELECT 
-           DATE_TRUNC('month', loan_date) as month,
-           COUNT(*) as loans_count,
-           COALESCE(SUM(amount), 0) as total_amount
-         FROM loans 
-         WHERE user_id = $1 
-         GROUP BY DATE_TRUNC('month', loan_date)
-         ORDER BY month DESC
-         LIMIT 12`,
-        [user.id]
-      );
+      if (!settings) {
+        return respondWithError(res, 400, `Granularity must be one of: ${Object.keys(STATS_GRANULARITIES).join(', ')}`);
+      }
 
-      return respondWithJSON(res, 200, result.rows);
+      const range = parseDateRange(req.query, settings.defaultPreset, userToday(user));
+
+      if (range.error) {
+        return respondWithError(res, 400, range.error);
+      }
+
+      if (settings.maxDays) {
+        const from = range.from ? new Date(range.from) : null;
+        const to = range.to ? new Date(range.to) : new Date();
+        if (!from || (to - from) / (24 * 60 * 60 * 1000) > settings.maxDays) {
+          return respondWithError(res, 400, `${granularity} granularity requires a range of at most ${settings.maxDays} days`);
+        }
+      }
+
+      // granularity is whitelisted above, so it is safe to inline
+      return respondWithJSON(res, 200, await this.loadPeriodStats(user.id, granularity, range));
 
     } catch (error) {
       console.error('Monthly stats error:', error);
-      return respondWithError(res, 500, 'Failed to get monthly statistics');
+      return respondWithError(res, 500, 'Failed to get monthly statistics', { cause: error });
     }
   }
 
   /**
-   * Get overdue loans
+   * Get overdue lent loans
    */
   async getOverdueLoans(req, res) {
     try {
       const user = getUserFromContext(req);
+      const range = parseDateRange(req.query, 'all', userToday(user));
 
-      const result = await db.query(
+      if (range.error) {
+        return respondWithError(res, 400, range.error);
+      }
+
+      const result = await this.queryInRange(
         `SELECT * FROM loans 
-         WHERE user_id = $1 
-         AND due_date < CURRENT_DATE 
-         AND status = 'active'
+         WHERE user_id = $1 AND deleted_at IS NULL AND direction = 'lent'
+         AND due_date < $2::date
+         AND status = 'active' {{range}}
          ORDER BY due_date ASC`,
-        [user.id]
+        'due_date', range, user.id, [userToday(user)]
       );
 
       return respondWithJSON(res, 200, result.rows);
 
     } catch (error) {
       console.error('Overdue loans error:', error);
-      return respondWithError(res, 500, 'Failed to get overdue loans');
+      return respondWithError(res, 500, 'Failed to get overdue loans', { cause: error });
+    }
+  }
+
+  /**
+   * Get missed expected payments on lent loans
+   */
+  async getMissedPayments(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const range = parseDateRange(req.query, 'all', userToday(user));
+
+      if (range.error) {
+        return respondWithError(res, 400, range.error);
+      }
+
+      const result = await this.queryInRange(
+        `SELECT ep.*, l.borrower_name, pp.frequency
+         FROM expected_payments ep
+         JOIN loans l ON ep.loan_id = l.id
+         JOIN payment_plans pp ON ep.plan_id = pp.id
+         WHERE l.user_id = $1 AND l.deleted_at IS NULL AND l.direction = 'lent'
+         AND ep.status = 'missed'
+         AND l.status = 'active' {{range}}
+         ORDER BY ep.due_date ASC`,
+        'ep.due_date', range, user.id
+      );
+
+      return respondWithJSON(res, 200, result.rows);
+
+    } catch (error) {
+      console.error('Missed payments error:', error);
+      return respondWithError(res, 500, 'Failed to get missed payments', { cause: error });
+    }
+  }
+
+  /**
+   * Project expected incoming payments per month from payment plans and due dates.
+   * Loans with an active plan are projected from its schedule, others from their due date;
+   * every loan is capped at its remaining debt. Only lent loans are projected (payments to the user).
+   * Projections are per currency; the top-level
+   * months and totals are only given when there is a single currency.
+   */
+  async getCashFlowProjection(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const months = req.query.months ? parseInt(req.query.months) : 6;
+
+      if (!Number.isInteger(months) || months < 1 || months > MAX_PROJECTION_MONTHS) {
+        return respondWithError(res, 400, `months must be an integer between 1 and ${MAX_PROJECTION_MONTHS}`);
+      }
+
+      // Months are counted from the user's local today
+      const today = userToday(user);
+      const [year, month] = today.split('-').map(Number);
+      const horizonEnd = new Date(Date.UTC(year, month - 1 + months, 0)).toISOString().slice(0, 10);
+
+      const loansResult = await db.reader.query(
+        `SELECT l.id, l.currency, l.due_date, COALESCE(lb.remaining_debt, l.amount) as remaining_debt,
+           EXISTS (SELECT 1 FROM payment_plans pp WHERE pp.loan_id = l.id AND pp.active = true) as has_plan
+         FROM loans l
+         LEFT JOIN loan_balances lb ON lb.loan_id = l.id
+         WHERE l.user_id = $1 AND l.deleted_at IS NULL AND l.status = 'active' AND l.direction = 'lent'`,
+        [user.id]
+      );
+
+      const plansResult = await db.reader.query(
+        `SELECT pp.loan_id, occurrence::date as due_date, pp.amount
+         FROM payment_plans pp
+         JOIN loans l ON l.id = pp.loan_id
+         CROSS JOIN LATERAL generate_series(
+           pp.next_due_date::timestamp,
+           LEAST(COALESCE(pp.end_date, $2::date), $2::date)::timestamp,
+           ${frequencyIntervalSQL('pp.frequency')}
+         ) as occurrence
+         WHERE l.user_id = $1 AND l.deleted_at IS NULL AND l.status = 'active' AND l.direction = 'lent' AND pp.active = true
+         ORDER BY pp.loan_id, occurrence`,
+        [user.id, horizonEnd]
+      );
+
+      // Monthly buckets and overdue/unscheduled amounts per currency
+      const currencies = new Map();
+      const forCurrency = currency => {
+        if (!currencies.has(currency)) {
+          const buckets = new Map();
+          for (let i = 0; i < months; i++) {
+            const bucketMonth = new Date(Date.UTC(year, month - 1 + i, 1)).toISOString().slice(0, 7);
+            buckets.set(bucketMonth, { month: bucketMonth, expected: 0, fromPlans: 0, fromDueDates: 0, loans: new Set() });
+          }
+          currencies.set(currency, { currency, buckets, overdue: 0, unscheduled: 0 });
+        }
+        return currencies.get(currency);
+      };
+
+      const loanCurrency = new Map(loansResult.rows.map(loan => [loan.id, loan.currency]));
+      const remaining = new Map(loansResult.rows.map(loan => [loan.id, parseFloat(loan.remaining_debt)]));
+      const addToBucket = (date, loanId, amount, source) => {
+        // pg parses DATE columns to local midnight, so read them with local getters (parseDate)
+        const bucket = forCurrency(loanCurrency.get(loanId)).buckets.get(parseDate(date).slice(0, 7));
+        if (!bucket || amount <= 0) {
+          return;
+        }
+        bucket.expected += amount;
+        bucket[source] += amount;
+        bucket.loans.add(loanId);
+      };
+
+      for (const occurrence of plansResult.rows) {
+        const left = remaining.get(occurrence.loan_id) || 0;
+        const amount = Math.min(parseFloat(occurrence.amount), left);
+        remaining.set(occurrence.loan_id, left - amount);
+        addToBucket(occurrence.due_date, occurrence.loan_id, amount, 'fromPlans');
+      }
+
+      for (const loan of loansResult.rows.filter(row => !row.has_plan)) {
+        const amount = remaining.get(loan.id);
+        const totals = forCurrency(loan.currency);
+        if (!loan.due_date) {
+          totals.unscheduled += amount;
+        } else if (parseDate(loan.due_date) < today) {
+          totals.overdue += amount;
+        } else {
+          addToBucket(loan.due_date, loan.id, amount, 'fromDueDates');
+        }
+      }
+
+      const summarize = totals => {
+        const projection = [...totals.buckets.values()].map(bucket => ({
+          month: bucket.month,
+          expected: roundCurrency(bucket.expected, totals.currency),
+          fromPlans: roundCurrency(bucket.fromPlans, totals.currency),
+          fromDueDates: roundCurrency(bucket.fromDueDates, totals.currency),
+          loansCount: bucket.loans.size
+        }));
+        return {
+          currency: totals.currency,
+          months: projection,
+          totalExpected: roundCurrency(projection.reduce((sum, bucket) => sum + bucket.expected, 0), totals.currency),
+          overdue: roundCurrency(totals.overdue, totals.currency),
+          unscheduled: roundCurrency(totals.unscheduled, totals.currency)
+        };
+      };
+      const byCurrency = [...currencies.values()]
+        .sort((a, b) => a.currency.localeCompare(b.currency))
+        .map(summarize);
+      const currency = singleCurrency(byCurrency);
+      // Without loans the projection is all zero months in the default currency
+      const single = byCurrency[0] || summarize(forCurrency(DEFAULT_CURRENCY));
+
+      return respondWithJSON(res, 200, {
+        currency,
+        months: currency ? single.months : [],
+        totalExpected: currency ? single.totalExpected : null,
+        overdue: currency ? single.overdue : null,
+        unscheduled: currency ? single.unscheduled : null,
+        byCurrency
+      });
+
+    } catch (error) {
+      console.error('Cash flow projection error:', error);
+      return respondWithError(res, 500, 'Failed to get cash flow projection', { cause: error });
+    }
+  }
+
+  /**
+   * Get borrowers ranked by outstanding debt, one row per borrower and currency (amounts are never summed across currencies).
+   * Only lent loans count: a lender the user borrowed from owes them nothing.
+   */
+  async getTopBorrowers(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const limit = req.query.limit ? parseInt(req.query.limit) : 10;
+
+      if (!Number.isInteger(limit) || limit < 1 || limit > MAX_TOP_BORROWERS) {
+        return respondWithError(res, 400, `limit must be an integer between 1 and ${MAX_TOP_BORROWERS}`);
+      }
+
+      const result = await db.reader.query(
+        `SELECT
+           b.id,
+           b.name,
+           b.phone,
+           l.currency,
+           COUNT(l.id) as loans_count,
+           COUNT(l.id) FILTER (WHERE l.status = 'active') as active_loans_count,
+           COALESCE(SUM(COALESCE(lb.remaining_debt, l.amount)) FILTER (WHERE l.status = 'active'), 0) as outstanding,
+           COALESCE(SUM(COALESCE(lb.remaining_debt, l.amount))
+             FILTER (WHERE l.status = 'active' AND l.due_date < $3::date), 0) as overdue,
+           COALESCE(SUM(l.amount), 0) as lifetime_lent
+         FROM borrowers b
+         JOIN loans l ON l.borrower_id = b.id
+         LEFT JOIN loan_balances lb ON lb.loan_id = l.id
+         WHERE b.user_id = $1 AND b.deleted_at IS NULL AND l.deleted_at IS NULL AND l.direction = 'lent'
+         GROUP BY b.id, l.currency
+         HAVING COUNT(l.id) FILTER (WHERE l.status = 'active') > 0
+         ORDER BY outstanding DESC, overdue DESC, b.name ASC
+         LIMIT $2`,
+        [user.id, limit, userToday(user)]
+      );
+
+      return respondWithJSON(res, 200, result.rows.map(row => ({
+        id: row.id,
+        name: row.name,
+        phone: row.phone,
+        currency: row.currency,
+        loansCount: parseInt(row.loans_count),
+        activeLoansCount: parseInt(row.active_loans_count),
+        outstanding: parseFloat(row.outstanding),
+        overdue: parseFloat(row.overdue),
+        lifetimeLent: parseFloat(row.lifetime_lent)
+      })));
+
+    } catch (error) {
+      console.error('Top borrowers error:', error);
+      return respondWithError(res, 500, 'Failed to get top borrowers', { cause: error });
+    }
+  }
+
+  /**
+   * Get collection metrics per due month: on-time repayment rate, average days to repay and default rate.
+   * Only lent loans whose due date has passed are counted; defaults are loans marked defaulted or unpaid past LOAN_DEFAULT_AFTER_DAYS.
+   */
+  async getCollectionMetrics(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const range = parseDateRange(req.query, 'last_12_months', userToday(user));
+
+      if (range.error) {
+        return respondWithError(res, 400, range.error);
+      }
+
+      const result = await this.queryInRange(
+        `WITH outcomes AS (
+           SELECT l.loan_date, l.due_date, l.status,
+             CASE WHEN l.status = 'paid' THEN (
+               SELECT MAX(ll.entry_date) FROM loan_ledger ll
+               WHERE ll.loan_id = l.id AND ll.entry_type = 'payment'
+             ) END as repaid_on
+           FROM loans l
+           WHERE l.user_id = $1 AND l.deleted_at IS NULL AND l.direction = 'lent'
+           AND l.due_date IS NOT NULL AND l.due_date < $4::date {{range}}
+         )
+         SELECT
+           DATE_TRUNC('month', due_date) as month,
+           COUNT(*) as matured_loans,
+           COUNT(*) FILTER (WHERE repaid_on IS NOT NULL) as repaid_loans,
+           COUNT(*) FILTER (WHERE repaid_on <= due_date + $2::int) as on_time_loans,
+           AVG(repaid_on - loan_date) FILTER (WHERE repaid_on IS NOT NULL) as avg_days_to_repay,
+           COUNT(*) FILTER (
+             WHERE status = 'defaulted' OR (status <> 'paid' AND $4::date - due_date > $3::int)
+           ) as defaulted_loans
+         FROM outcomes
+         GROUP BY DATE_TRUNC('month', due_date)
+         ORDER BY month DESC`,
+        'l.due_date', range, user.id, [PAYMENT_GRACE_DAYS, LOAN_DEFAULT_AFTER_DAYS, userToday(user)]
+      );
+
+      const months = result.rows.map(row => {
+        const matured = parseInt(row.matured_loans);
+        return {
+          month: row.month,
+          maturedLoans: matured,
+          repaidLoans: parseInt(row.repaid_loans),
+          onTimeRate: toRate(parseInt(row.on_time_loans), matured),
+          avgDaysToRepay: row.avg_days_to_repay !== null ? Math.round(parseFloat(row.avg_days_to_repay) * 10) / 10 : null,
+          defaultRate: toRate(parseInt(row.defaulted_loans), matured)
+        };
+      });
+
+      const totals = result.rows.reduce((sum, row) => ({
+        matured: sum.matured + parseInt(row.matured_loans),
+        onTime: sum.onTime + parseInt(row.on_time_loans),
+        defaulted: sum.defaulted + parseInt(row.defaulted_loans)
+      }), { matured: 0, onTime: 0, defaulted: 0 });
+
+      return respondWithJSON(res, 200, {
+        months,
+        overall: {
+          maturedLoans: totals.matured,
+          onTimeRate: toRate(totals.onTime, totals.matured),
+          defaultRate: toRate(totals.defaulted, totals.matured)
+        },
+        graceDays: PAYMENT_GRACE_DAYS,
+        defaultAfterDays: LOAN_DEFAULT_AFTER_DAYS
+      });
+
+    } catch (error) {
+      console.error('Collection metrics error:', error);
+      return respondWithError(res, 500, 'Failed to get collection metrics', { cause: error });
+    }
+  }
+
+  /**
+   * Get income report separating principal recovered from interest and fee income (defaults to year to date).
+   * Payments are applied to outstanding interest and fees first, then to principal.
+   * Only lent loans count: interest the user pays on borrowed money is not income.
+   * Figures are per currency; the top-level ones are only given when there is a single currency.
+   */
+  async getIncomeReport(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const range = parseDateRange(req.query, 'ytd', userToday(user));
+
+      if (range.error) {
+        return respondWithError(res, 400, range.error);
+      }
+
+      const collectedResult = await this.queryInRange(
+        `WITH entries AS (
+           SELECT l.currency, ll.entry_type, ll.amount, ll.entry_date,
+             SUM(CASE WHEN ll.entry_type IN ('interest', 'fee') THEN ll.amount ELSE 0 END) OVER ledger as charges_to_date,
+             SUM(CASE WHEN ll.entry_type = 'payment' THEN ll.amount ELSE 0 END) OVER ledger as paid_to_date
+           FROM loan_ledger ll
+           JOIN loans l ON l.id = ll.loan_id
+           WHERE ll.user_id = $1 AND l.direction = 'lent'
+           WINDOW ledger AS (
+             PARTITION BY ll.loan_id
+             ORDER BY ll.entry_date, (ll.entry_type = 'disbursement') DESC, ll.created_at
+             ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+           )
+         ), payments AS (
+           SELECT currency, entry_date, amount,
+             LEAST(amount, GREATEST(charges_to_date - (paid_to_date - amount), 0)) as income_portion
+           FROM entries
+           WHERE entry_type = 'payment'
+         )
+         SELECT
+           currency,
+           DATE_TRUNC('month', entry_date) as month,
+           COALESCE(SUM(amount), 0) as collected,
+           COALESCE(SUM(income_portion), 0) as income,
+           COALESCE(SUM(amount - income_portion), 0) as principal
+         FROM payments
+         WHERE true {{range}}
+         GROUP BY currency, DATE_TRUNC('month', entry_date)
+         ORDER BY currency, month ASC`,
+        'entry_date', range, user.id
+      );
+
+      const accruedResult = await this.queryInRange(
+        `SELECT l.currency,
+           COALESCE(SUM(ll.amount) FILTER (WHERE ll.entry_type = 'interest'), 0) as interest,
+           COALESCE(SUM(ll.amount) FILTER (WHERE ll.entry_type = 'fee'), 0) as fees
+         FROM loan_ledger ll
+         JOIN loans l ON l.id = ll.loan_id
+         WHERE ll.user_id = $1 AND l.direction = 'lent' {{range}}
+         GROUP BY l.currency`,
+        'll.entry_date', range, user.id
+      );
+
+      const currencies = [...new Set([
+        ...collectedResult.rows.map(row => row.currency),
+        ...accruedResult.rows.map(row => row.currency)
+      ])].sort();
+
+      const byCurrency = currencies.map(currency => {
+        const months = collectedResult.rows.filter(row => row.currency === currency).map(row => ({
+          month: row.month,
+          collected: parseFloat(row.collected),
+          principalRecovered: parseFloat(row.principal),
+          incomeCollected: parseFloat(row.income)
+        }));
+        const sum = field => roundCurrency(months.reduce((total, month) => total + month[field], 0), currency);
+        const accrued = accruedResult.rows.find(row => row.currency === currency) || { interest: 0, fees: 0 };
+
+        return {
+          currency,
+          collected: sum('collected'),
+          principalRecovered: sum('principalRecovered'),
+          incomeCollected: sum('incomeCollected'),
+          accrued: {
+            interest: parseFloat(accrued.interest),
+            fees: parseFloat(accrued.fees),
+            total: roundCurrency(parseFloat(accrued.interest) + parseFloat(accrued.fees), currency)
+          },
+          months
+        };
+      });
+      const currency = singleCurrency(byCurrency);
+      const single = byCurrency[0] || {
+        collected: 0, principalRecovered: 0, incomeCollected: 0, accrued: { interest: 0, fees: 0, total: 0 }, months: []
+      };
+
+      return respondWithJSON(res, 200, {
+        range: { from: range.from, to: range.to, preset: range.preset },
+        currency,
+        collected: currency ? single.collected : null,
+        principalRecovered: currency ? single.principalRecovered : null,
+        incomeCollected: currency ? single.incomeCollected : null,
+        accrued: currency ? single.accrued : null,
+        months: currency ? single.months : [],
+        byCurrency
+      });
+
+    } catch (error) {
+      console.error('Income report error:', error);
+      return respondWithError(res, 500, 'Failed to get income report', { cause: error });
+    }
+  }
+
+  /**
+   * Get repayment velocity per active lent loan and portfolio-wide.
+   * A loan is stalling when the time since its last payment exceeds twice its average gap between payments.
+   */
+  async getRepaymentVelocity(req, res) {
+    try {
+      const user = getUserFromContext(req);
+
+      const result = await db.reader.query(
+        `WITH payments AS (
+           SELECT ll.loan_id, ll.amount,
+             ll.entry_date - LAG(ll.entry_date, 1, l.loan_date) OVER (
+               PARTITION BY ll.loan_id ORDER BY ll.entry_date, ll.created_at
+             ) as gap_days,
+             ll.entry_date
+           FROM loan_ledger ll
+           JOIN loans l ON l.id = ll.loan_id
+           WHERE ll.user_id = $1 AND ll.entry_type = 'payment' AND l.status = 'active' AND l.direction = 'lent'
+         )
+         SELECT l.id, l.borrower_name, l.loan_date, l.due_date,
+           COALESCE(lb.remaining_debt, l.amount) as remaining_debt,
+           COUNT(p.loan_id) as payments_count,
+           MAX(p.entry_date) as last_payment_date,
+           AVG(p.gap_days) as avg_gap_days,
+           AVG(p.amount) as avg_payment,
+           SUM(p.gap_days) as total_gap_days
+         FROM loans l
+         LEFT JOIN loan_balances lb ON lb.loan_id = l.id
+         LEFT JOIN payments p ON p.loan_id = l.id
+         WHERE l.user_id = $1 AND l.deleted_at IS NULL AND l.status = 'active' AND l.direction = 'lent'
+         GROUP BY l.id, lb.remaining_debt`,
+        [user.id]
+      );
+
+      // Local midnight of the user's today, comparable with pg DATE values (parsed at local midnight)
+      const today = new Date(`${userToday(user)}T00:00:00`);
+      const loans = result.rows.map(row => {
+        const paymentsCount = parseInt(row.payments_count);
+        const remainingDebt = parseFloat(row.remaining_debt);
+        const avgGapDays = row.avg_gap_days !== null ? parseFloat(row.avg_gap_days) : null;
+        const avgPayment = row.avg_payment !== null ? parseFloat(row.avg_payment) : null;
+        const lastActivity = row.last_payment_date || row.loan_date;
+        const daysSinceLastPayment = Math.round((today - new Date(lastActivity)) / DAY_MS);
+
+        let projectedCompletionDate = null;
+        if (avgGapDays > 0 && avgPayment > 0 && remainingDebt > 0) {
+          const periodsLeft = Math.ceil(remainingDebt / avgPayment);
+          projectedCompletionDate = parseDate(new Date(new Date(lastActivity).getTime() + periodsLeft * avgGapDays * DAY_MS));
+        }
+
+        const stalling = paymentsCount === 0
+          ? daysSinceLastPayment > STALL_DAYS_WITHOUT_PAYMENT
+          : daysSinceLastPayment > avgGapDays * 2;
+
+        return {
+          loanId: row.id,
+          borrowerName: row.borrower_name,
+          dueDate: row.due_date,
+          remainingDebt,
+          paymentsCount,
+          lastPaymentDate: row.last_payment_date,
+          daysSinceLastPayment,
+          avgDaysBetweenPayments: avgGapDays !== null ? Math.round(avgGapDays * 10) / 10 : null,
+          avgPayment: avgPayment !== null ? roundCurrency(avgPayment) : null,
+          projectedCompletionDate,
+          behindSchedule: Boolean(projectedCompletionDate && row.due_date &&
+            projectedCompletionDate > parseDate(row.due_date)),
+          stalling,
+          totalGapDays: row.total_gap_days !== null ? parseFloat(row.total_gap_days) : 0
+        };
+      });
+
+      // Stalling loans first, then those waiting longest for a payment
+      loans.sort((a, b) => (b.stalling - a.stalling) || (b.daysSinceLastPayment - a.daysSinceLastPayment));
+
+      const totalPayments = loans.reduce((sum, loan) => sum + loan.paymentsCount, 0);
+      const totalGapDays = loans.reduce((sum, loan) => sum + loan.totalGapDays, 0);
+      const projectedDates = loans.map(loan => loan.projectedCompletionDate).filter(Boolean).sort();
+
+      return respondWithJSON(res, 200, {
+        portfolio: {
+          activeLoans: loans.length,
+          stallingLoans: loans.filter(loan => loan.stalling).length,
+          behindScheduleLoans: loans.filter(loan => loan.behindSchedule).length,
+          avgDaysBetweenPayments: totalPayments > 0 ? Math.round((totalGapDays / totalPayments) * 10) / 10 : null,
+          projectedCompletionDate: projectedDates.length > 0 ? projectedDates[projectedDates.length - 1] : null
+        },
+        loans: loans.map(({ totalGapDays: _omit, ...loan }) => loan)
+      });
+
+    } catch (error) {
+      console.error('Repayment velocity error:', error);
+      return respondWithError(res, 500, 'Failed to get repayment velocity', { cause: error });
+    }
+  }
+
+  /**
+   * Compare amounts scheduled by payment plans on lent loans to date with payments actually received, per borrower and currency.
+   * Totals are per currency; the top-level ones are only given when there is a single currency.
+   */
+  async getExpectedVsActual(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const today = userToday(user);
+
+      const result = await db.reader.query(
+        `WITH scheduled AS (
+           SELECT ep.loan_id, SUM(ep.amount) as expected, MIN(pp.start_date) as start_date
+           FROM expected_payments ep
+           JOIN payment_plans pp ON pp.id = ep.plan_id
+           WHERE pp.user_id = $1 AND ep.due_date <= $2::date
+           GROUP BY ep.loan_id
+         ), actual AS (
+           SELECT ll.loan_id, SUM(ll.amount) as collected
+           FROM loan_ledger ll
+           JOIN scheduled s ON s.loan_id = ll.loan_id
+           WHERE ll.entry_type = 'payment'
+           AND ll.entry_date >= s.start_date AND ll.entry_date <= $2::date
+           GROUP BY ll.loan_id
+         )
+         SELECT l.id as loan_id, l.borrower_id, COALESCE(b.name, l.borrower_name) as borrower_name,
+           l.currency, l.status, s.expected, COALESCE(a.collected, 0) as collected
+         FROM scheduled s
+         JOIN loans l ON l.id = s.loan_id AND l.deleted_at IS NULL AND l.direction = 'lent'
+         LEFT JOIN borrowers b ON b.id = l.borrower_id
+         LEFT JOIN actual a ON a.loan_id = s.loan_id
+         ORDER BY borrower_name ASC`,
+        [user.id, today]
+      );
+
+      const borrowers = new Map();
+      for (const row of result.rows) {
+        const key = `${row.borrower_id || row.borrower_name}:${row.currency}`;
+        if (!borrowers.has(key)) {
+          borrowers.set(key, {
+            borrowerId: row.borrower_id,
+            borrowerName: row.borrower_name,
+            currency: row.currency,
+            expected: 0,
+            collected: 0,
+            shortfall: 0,
+            loans: []
+          });
+        }
+
+        const expected = parseFloat(row.expected);
+        const collected = parseFloat(row.collected);
+        const shortfall = roundCurrency(Math.max(expected - collected, 0), row.currency);
+        const borrower = borrowers.get(key);
+
+        borrower.expected = roundCurrency(borrower.expected + expected, row.currency);
+        borrower.collected = roundCurrency(borrower.collected + collected, row.currency);
+        borrower.shortfall = roundCurrency(borrower.shortfall + shortfall, row.currency);
+        borrower.loans.push({ loanId: row.loan_id, status: row.status, expected, collected, shortfall });
+      }
+
+      const rows = [...borrowers.values()].sort((a, b) => b.shortfall - a.shortfall);
+      const byCurrency = [...new Set(rows.map(row => row.currency))].sort().map(currency => {
+        const currencyRows = rows.filter(row => row.currency === currency);
+        const total = field => roundCurrency(currencyRows.reduce((sum, row) => sum + row[field], 0), currency);
+        return {
+          currency,
+          expected: total('expected'),
+          collected: total('collected'),
+          shortfall: total('shortfall'),
+          collectionRate: toRate(total('collected'), total('expected'))
+        };
+      });
+      const currency = singleCurrency(byCurrency);
+      const single = byCurrency[0] || { expected: 0, collected: 0, shortfall: 0, collectionRate: null };
+
+      return respondWithJSON(res, 200, {
+        asOf: today,
+        currency,
+        expected: currency ? single.expected : null,
+        collected: currency ? single.collected : null,
+        shortfall: currency ? single.shortfall : null,
+        collectionRate: currency ? single.collectionRate : null,
+        byCurrency,
+        borrowers: rows
+      });
+
+    } catch (error) {
+      console.error('Expected vs actual error:', error);
+      return respondWithError(res, 500, 'Failed to get expected vs actual collections', { cause: error });
+    }
+  }
+
+  /**
+   * Get net position: what the user is owed (lent) vs what they owe (borrowed), with a month-end trend.
+   * Positions are per currency; the top-level figures and trend are only given when there is a single currency,
+   * and consolidated gives the position converted into the user's preferred currency.
+   */
+  async getNetPosition(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const months = req.query.months ? parseInt(req.query.months) : 12;
+
+      if (!Number.isInteger(months) || months < 1 || months > MAX_TREND_MONTHS) {
+        return respondWithError(res, 400, `months must be an integer between 1 and ${MAX_TREND_MONTHS}`);
+      }
+
+      const currentResult = await db.reader.query(
+        `SELECT l.currency,
+           COALESCE(SUM(COALESCE(lb.remaining_debt, l.amount)) FILTER (WHERE l.direction = 'lent'), 0) as owed_to_me,
+           COALESCE(SUM(COALESCE(lb.remaining_debt, l.amount)) FILTER (WHERE l.direction = 'borrowed'), 0) as i_owe
+         FROM loans l
+         LEFT JOIN loan_balances lb ON lb.loan_id = l.id
+         WHERE l.user_id = $1 AND l.deleted_at IS NULL AND l.status <> 'paid'
+         GROUP BY l.currency
+         ORDER BY l.currency`,
+        [user.id]
+      );
+
+      const byCurrency = currentResult.rows.map(row => {
+        const owedToMe = parseFloat(row.owed_to_me);
+        const iOwe = parseFloat(row.i_owe);
+        return { currency: row.currency, owedToMe, iOwe, netPosition: roundCurrency(owedToMe - iOwe) };
+      });
+      const currency = singleCurrency(byCurrency);
+      const consolidated = await this.consolidate(user, byCurrency, ['owedToMe', 'iOwe', 'netPosition']);
+
+      if (!currency) {
+        return respondWithJSON(res, 200, {
+          currency, owedToMe: null, iOwe: null, netPosition: null, byCurrency, consolidated, trend: []
+        });
+      }
+
+      const trendResult = await db.reader.query(
+        `WITH months AS (
+           SELECT (month_start + INTERVAL '1 month' - INTERVAL '1 day')::date as month_end
+           FROM generate_series(
+             DATE_TRUNC('month', $3::date) - ($2::int - 1) * INTERVAL '1 month',
+             DATE_TRUNC('month', $3::date),
+             INTERVAL '1 month'
+           ) as month_start
+         )
+         SELECT m.month_end,
+           COALESCE(SUM(ll.balance_effect) FILTER (WHERE l.direction = 'lent'), 0) as owed_to_me,
+           COALESCE(SUM(ll.balance_effect) FILTER (WHERE l.direction = 'borrowed'), 0) as i_owe
+         FROM months m
+         LEFT JOIN loan_ledger ll ON ll.user_id = $1 AND ll.entry_date <= m.month_end
+         LEFT JOIN loans l ON l.id = ll.loan_id
+         GROUP BY m.month_end
+         ORDER BY m.month_end ASC`,
+        [user.id, months, userToday(user)]
+      );
+
+      const owedToMe = byCurrency.length > 0 ? byCurrency[0].owedToMe : 0;
+      const iOwe = byCurrency.length > 0 ? byCurrency[0].iOwe : 0;
+
+      return respondWithJSON(res, 200, {
+        currency,
+        owedToMe,
+        iOwe,
+        netPosition: roundCurrency(owedToMe - iOwe),
+        byCurrency,
+        consolidated,
+        trend: trendResult.rows.map(row => ({
+          month: parseDate(row.month_end).slice(0, 7),
+          owedToMe: parseFloat(row.owed_to_me),
+          iOwe: parseFloat(row.i_owe),
+          netPosition: roundCurrency(parseFloat(row.owed_to_me) - parseFloat(row.i_owe))
+        }))
+      });
+
+    } catch (error) {
+      console.error('Net position error:', error);
+      return respondWithError(res, 500, 'Failed to get net position', { cause: error });
     }
   }
 }
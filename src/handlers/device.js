@@ -0,0 +1,107 @@
+const db = require('../database/db');
+const { respondWithError, respondWithJSON, rejectUnknownFields } = require('../utils/response');
+const { getUserFromContext } = require('../middleware/auth');
+const { UserDevice } = require('../models');
+const deviceService = require('../services/device');
+
+class DeviceHandler {
+  /**
+   * Map database row to UserDevice model
+   */
+  toDevice(row) {
+    return new UserDevice({
+      id: row.id,
+      userAgent: row.user_agent,
+      lastIp: row.last_ip,
+      lastCountry: row.last_country,
+      countries: row.countries,
+      trusted: row.trusted,
+      firstSeenAt: row.first_seen_at,
+      lastSeenAt: row.last_seen_at
+    });
+  }
+
+  /**
+   * List devices the user has signed in from, flagging the one making this request
+   */
+  async getDevices(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const fingerprint = deviceService.fingerprint(req);
+
+      const result = await db.query(
+        'SELECT * FROM user_devices WHERE user_id = $1 ORDER BY last_seen_at DESC',
+        [user.id]
+      );
+
+      return respondWithJSON(res, 200, result.rows.map(row => ({
+        ...this.toDevice(row),
+        current: row.fingerprint === fingerprint
+      })));
+
+    } catch (error) {
+      console.error('Get devices error:', error);
+      return respondWithError(res, 500, 'Failed to get devices', { cause: error });
+    }
+  }
+
+  /**
+   * Mark a device as trusted (no more new-login alerts from it) or untrusted
+   */
+  async updateDevice(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['trusted']);
+      const { id } = req.params;
+      const { trusted } = req.body;
+
+      if (typeof trusted !== 'boolean') {
+        return respondWithError(res, 400, 'Trusted must be a boolean');
+      }
+
+      const result = await db.query(
+        `UPDATE user_devices SET trusted = $1, updated_at = CURRENT_TIMESTAMP
+         WHERE id = $2 AND user_id = $3
+         RETURNING *`,
+        [trusted, id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Device not found');
+      }
+
+      return respondWithJSON(res, 200, this.toDevice(result.rows[0]));
+
+    } catch (error) {
+      console.error('Update device error:', error);
+      return respondWithError(res, 500, 'Failed to update device', { cause: error });
+    }
+  }
+
+  /**
+   * Forget a device; its next sign-in alerts again
+   */
+  async deleteDevice(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const result = await db.query(
+        'DELETE FROM user_devices WHERE id = $1 AND user_id = $2 RETURNING id',
+        [id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Device not found');
+      }
+
+      return respondWithJSON(res, 200, { message: 'Device removed successfully' });
+
+    } catch (error) {
+      console.error('Delete device error:', error);
+      return respondWithError(res, 500, 'Failed to delete device', { cause: error });
+    }
+  }
+}
+
+module.exports = new DeviceHandler();
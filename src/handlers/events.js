@@ -0,0 +1,54 @@
+const config = require('../config');
+const { getUserFromContext } = require('../middleware/auth');
+const { respondWithError } = require('../utils/response');
+const eventStream = require('../events');
+
+// Comment lines keep proxies and load balancers from closing idle streams
+const HEARTBEAT_INTERVAL_MS = config.events.heartbeatMs;
+const RETRY_MS = 5000;
+
+class EventsHandler {
+  /**
+   * Stream the user's loan and transaction events as Server-Sent Events
+   */
+  async streamEvents(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      let eventId = 0;
+
+      const unsubscribe = await eventStream.subscribe(user.id, ({ event, payload, occurredAt }) => {
+        eventId++;
+        res.write(`id: ${eventId}\nevent: ${event}\ndata: ${JSON.stringify({ ...payload, occurredAt })}\n\n`);
+      });
+
+      res.status(200).set({
+        'Content-Type': 'text/event-stream',
+        'Cache-Control': 'no-cache',
+        Connection: 'keep-alive',
+        // Disable response buffering in nginx
+        'X-Accel-Buffering': 'no'
+      });
+      res.flushHeaders();
+      res.write(`retry: ${RETRY_MS}\n: connected\n\n`);
+
+      const heartbeat = setInterval(() => res.write(': heartbeat\n\n'), HEARTBEAT_INTERVAL_MS);
+      // On shutdown the client reconnects (after the retry delay) to another instance
+      const stopOnClose = eventStream.onClose(() => res.end());
+
+      req.on('close', () => {
+        clearInterval(heartbeat);
+        unsubscribe();
+        stopOnClose();
+      });
+
+    } catch (error) {
+      console.error('Stream events error:', error);
+      if (!res.headersSent) {
+        return respondWithError(res, 500, 'Failed to open event stream');
+      }
+      res.end();
+    }
+  }
+}
+
+module.exports = new EventsHandler();
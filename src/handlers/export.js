@@ -0,0 +1,483 @@
+const config = require('../config');
+const db = require('../database/db');
+const storage = require('../storage');
+const { respondWithError, respondWithJSON, rejectUnknownFields, ValidationError } = require('../utils/response');
+const { getUserFromContext } = require('../middleware/auth');
+const { ExportJob } = require('../models');
+const { signPath, verifySignedPath } = require('../utils/signedUrl');
+const { getRequestPassphrase, deriveKey, encryptWithKey } = require('../utils/encryption');
+const { generateWorkbook } = require('../utils/xlsx');
+const { generateQIF, generateOFX } = require('../utils/accounting');
+const { parseDateRange, dateRangeCondition } = require('../utils/dateRange');
+const { DEFAULT_CURRENCY, CURRENCIES, isSupportedCurrency } = require('../utils/currency');
+const backupHandler = require('./backup');
+
+const DOWNLOAD_URL_TTL_MINUTES = config.jobs.exportDownloadTtlMinutes;
+
+// Content type per export format
+const EXPORT_FORMATS = {
+  xlsx: 'application/vnd.openxmlformats-officedocument.spreadsheetml.sheet',
+  qif: 'application/qif; charset=utf-8',
+  ofx: 'application/x-ofx; charset=utf-8',
+  backup: 'application/json; charset=utf-8'
+};
+
+const LOAN_COLUMNS = [
+  { header: 'Borrower', key: 'borrower_name', width: 25 },
+  { header: 'Direction', key: 'direction', width: 10 },
+  { header: 'Currency', key: 'currency', width: 9 },
+  { header: 'Amount', key: 'amount', type: 'currency', currencyKey: 'currency', width: 15 },
+  { header: 'Interest Rate (%)', key: 'interest_rate', type: 'number', width: 16 },
+  { header: 'Interest Type', key: 'interest_type', width: 14 },
+  { header: 'Loan Date', key: 'loan_date', type: 'date', width: 12 },
+  { header: 'Due Date', key: 'due_date', type: 'date', width: 12 },
+  { header: 'Status', key: 'status', width: 10 },
+  { header: 'Total Paid', key: 'total_paid', type: 'currency', currencyKey: 'currency', width: 15 },
+  { header: 'Charges', key: 'total_charges', type: 'currency', currencyKey: 'currency', width: 15 },
+  { header: 'Remaining Debt', key: 'remaining_debt', type: 'currency', currencyKey: 'currency', width: 16 },
+  { header: 'Notes', key: 'notes', width: 30 },
+  { header: 'Loan ID', key: 'id', width: 38 }
+];
+
+const TRANSACTION_COLUMNS = [
+  { header: 'Date', key: 'transaction_date', type: 'date', width: 12 },
+  { header: 'Borrower', key: 'borrower_name', width: 25 },
+  { header: 'Type', key: 'transaction_type', width: 12 },
+  { header: 'Currency', key: 'currency', width: 9 },
+  { header: 'Amount', key: 'amount', type: 'currency', currencyKey: 'currency', width: 15 },
+  { header: 'Status', key: 'status', width: 10 },
+  { header: 'Description', key: 'description', width: 35 },
+  { header: 'Transaction ID', key: 'id', width: 38 },
+  { header: 'Loan ID', key: 'loan_id', width: 38 }
+];
+
+const SUMMARY_COLUMNS = [
+  { header: 'Metric', key: 'metric', width: 30 },
+  { header: 'Currency', key: 'currency', width: 9 },
+  { header: 'Value', key: 'value', type: 'number', width: 18 },
+  { header: 'Amount', key: 'amount', type: 'currency', currencyKey: 'currency', width: 18 }
+];
+
+/**
+ * Check an optional currency filter for cash exports; returns an error message or null
+ */
+function validateCurrencyFilter(currency) {
+  return currency && !isSupportedCurrency(currency)
+    ? `Currency must be one of: ${Object.keys(CURRENCIES).join(', ')}`
+    : null;
+}
+
+class ExportHandler {
+  /**
+   * Build an XLSX workbook of loans, transactions and a summary for user
+   */
+  async buildWorkbook(userId) {
+    const loansResult = await db.query(
+      `SELECT l.*, COALESCE(lb.total_paid, 0) as total_paid, COALESCE(lb.total_charges, 0) as total_charges,
+         COALESCE(lb.remaining_debt, l.amount) as remaining_debt
+       FROM loans l
+       LEFT JOIN loan_balances lb ON lb.loan_id = l.id
+       WHERE l.user_id = $1 AND l.deleted_at IS NULL
+       ORDER BY l.loan_date ASC`,
+      [userId]
+    );
+
+    const transactionsResult = await db.query(
+      `SELECT t.id, t.loan_id, l.borrower_name, t.transaction_type, t.currency, t.amount, t.status,
+         COALESCE(t.transaction_date, t.created_at::date) as transaction_date,
+         COALESCE(t.description, t.remark) as description
+       FROM transactions t
+       JOIN loans l ON t.loan_id = l.id
+       WHERE t.user_id = $1 AND t.deleted_at IS NULL AND l.deleted_at IS NULL
+       ORDER BY COALESCE(t.transaction_date, t.created_at::date) ASC, t.created_at ASC`,
+      [userId]
+    );
+
+    const loans = loansResult.rows;
+    const transactions = transactionsResult.rows;
+    const sumBy = (rows, field) => rows.reduce((sum, row) => sum + parseFloat(row[field] || 0), 0);
+
+    // One block of summary rows per currency; amounts in different currencies are never added together
+    const currencies = [...new Set(loans.map(loan => loan.currency))].sort();
+    const summary = (currencies.length > 0 ? currencies : [DEFAULT_CURRENCY]).flatMap(currency => {
+      const currencyLoans = loans.filter(loan => loan.currency === currency);
+      const activeLoans = currencyLoans.filter(loan => loan.status === 'active');
+      const confirmed = transactions.filter(t => t.status === 'confirmed' && t.currency === currency);
+      const payments = confirmed.filter(t => t.transaction_type === 'payment');
+      const charges = confirmed.filter(t => ['interest', 'fee'].includes(t.transaction_type));
+
+      return [
+        { metric: 'Total loans', currency, value: currencyLoans.length, amount: sumBy(currencyLoans, 'amount') },
+        { metric: 'Active loans', currency, value: activeLoans.length, amount: sumBy(activeLoans, 'remaining_debt') },
+        { metric: 'Paid loans', currency, value: currencyLoans.filter(loan => loan.status === 'paid').length },
+        {
+          metric: 'Overdue loans',
+          currency,
+          value: activeLoans.filter(loan => loan.due_date && new Date(loan.due_date) < new Date()).length
+        },
+        { metric: 'Payments received', currency, value: payments.length, amount: sumBy(payments, 'amount') },
+        { metric: 'Interest and fees charged', currency, value: charges.length, amount: sumBy(charges, 'amount') },
+        { metric: 'Outstanding debt', currency, amount: sumBy(activeLoans, 'remaining_debt') }
+      ];
+    });
+    summary.push({ metric: `Generated ${new Date().toISOString().slice(0, 10)}` });
+
+    return generateWorkbook([
+      { name: 'Loans', columns: LOAN_COLUMNS, rows: loans },
+      { name: 'Transactions', columns: TRANSACTION_COLUMNS, rows: transactions },
+      { name: 'Summary', columns: SUMMARY_COLUMNS, rows: summary }
+    ]);
+  }
+
+  /**
+   * Generate an export file for user; returns { content, contentType, fileName }
+   */
+  async generateExport(userId, format, params = {}) {
+    const date = new Date().toISOString().slice(0, 10);
+
+    switch (format) {
+      case 'xlsx':
+        return {
+          content: await this.buildWorkbook(userId),
+          contentType: EXPORT_FORMATS.xlsx,
+          fileName: `loan-money-${date}.xlsx`
+        };
+      case 'qif': {
+        const { currency, entries } = await this.getCashEntries(userId, params.range, params.currency);
+        return {
+          content: Buffer.from(generateQIF(entries, { currency })),
+          contentType: EXPORT_FORMATS.qif,
+          fileName: `loan-money-${date}.qif`
+        };
+      }
+      case 'ofx': {
+        const { currency, entries } = await this.getCashEntries(userId, params.range, params.currency);
+        return {
+          content: Buffer.from(generateOFX(entries, {
+            accountId: userId,
+            from: params.range.from,
+            to: params.range.to,
+            currency
+          })),
+          contentType: EXPORT_FORMATS.ofx,
+          fileName: `loan-money-${date}.ofx`
+        };
+      }
+      case 'backup':
+        return {
+          content: Buffer.from(JSON.stringify(await backupHandler.buildArchive(userId))),
+          contentType: EXPORT_FORMATS.backup,
+          fileName: `loan-money-backup-${date}.json`
+        };
+      default:
+        throw new Error(`Unsupported export format: ${format}`);
+    }
+  }
+
+  /**
+   * Encrypt a generated export file with a derived key
+   */
+  encryptExport(file, key) {
+    return {
+      content: encryptWithKey(file.content, key),
+      contentType: 'application/octet-stream',
+      fileName: `${file.fileName}.enc`
+    };
+  }
+
+  /**
+   * Generate an export and send it, encrypted when the request carries a passphrase
+   */
+  async respondWithExport(req, res, userId, format, params) {
+    const { passphrase, error } = getRequestPassphrase(req);
+    if (error) {
+      return respondWithError(res, 400, error);
+    }
+
+    const file = await this.generateExport(userId, format, params);
+    return this.sendExport(res, passphrase ? this.encryptExport(file, deriveKey(passphrase)) : file);
+  }
+
+  /**
+   * Send a generated export file as an attachment
+   */
+  sendExport(res, file) {
+    res.setHeader('Content-Type', file.contentType);
+    res.setHeader('Content-Disposition', `attachment; filename="${file.fileName}"`);
+    return res.status(200).send(file.content);
+  }
+
+  /**
+   * Export loans, transactions and a summary as an XLSX workbook
+   */
+  async exportWorkbook(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      return this.respondWithExport(req, res, user.id, 'xlsx');
+
+    } catch (error) {
+      console.error('Export workbook error:', error);
+      return respondWithError(res, 500, 'Failed to export workbook', { cause: error });
+    }
+  }
+
+  /**
+   * Get cash movements (disbursements and confirmed payments) signed from the user's point of view.
+   * Interest, fees and adjustments are not cash and are left out.
+   * A QIF or OFX file holds one currency, so entries are limited to currency when given and
+   * refused when they span several; returns { currency, entries }.
+   */
+  async getCashEntries(userId, range, currency = null) {
+    const params = [userId];
+    const rangeCondition = dateRangeCondition('ll.entry_date', range, params);
+    if (currency) {
+      params.push(currency);
+    }
+    const result = await db.query(
+      `SELECT COALESCE(ll.transaction_id, ll.loan_id) as id, ll.loan_id, ll.entry_type, ll.amount,
+         to_char(ll.entry_date, 'YYYY-MM-DD') as entry_date, ll.description, l.borrower_name, l.direction, l.currency
+       FROM loan_ledger ll
+       JOIN loans l ON l.id = ll.loan_id
+       WHERE ll.user_id = $1
+         AND l.deleted_at IS NULL
+         AND ll.entry_type IN ('disbursement', 'payment')
+         ${rangeCondition}
+         ${currency ? `AND l.currency = $${params.length}` : ''}
+       ORDER BY ll.entry_date ASC, ll.created_at ASC`,
+      params
+    );
+
+    const currencies = [...new Set(result.rows.map(row => row.currency))].sort();
+    if (currencies.length > 1) {
+      throw new ValidationError(`Entries span several currencies (${currencies.join(', ')}); choose one with currency`, [
+        { field: 'currency', issue: 'required_for_mixed_currencies', currencies }
+      ]);
+    }
+
+    const entries = result.rows.map(row => {
+      // Lending pays money out and gets repaid; borrowing is the reverse
+      const moneyIn = (row.entry_type === 'payment') === (row.direction !== 'borrowed');
+      return {
+        id: row.id,
+        date: row.entry_date,
+        amount: moneyIn ? parseFloat(row.amount) : -parseFloat(row.amount),
+        payee: row.borrower_name,
+        memo: row.description,
+        category: row.entry_type === 'payment' ? 'Loans:Repayment' : 'Loans:Disbursement'
+      };
+    });
+    return { currency: currency || currencies[0] || DEFAULT_CURRENCY, entries };
+  }
+
+  /**
+   * Export cash movements as QIF
+   */
+  async exportQIF(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const range = parseDateRange(req.query);
+      if (range.error) {
+        return respondWithError(res, 400, range.error);
+      }
+
+      const currency = req.query.currency || null;
+      const currencyError = validateCurrencyFilter(currency);
+      if (currencyError) {
+        return respondWithError(res, 400, currencyError);
+      }
+
+      return await this.respondWithExport(req, res, user.id, 'qif', { range, currency });
+
+    } catch (error) {
+      console.error('Export QIF error:', error);
+      return respondWithError(res, 500, 'Failed to export QIF', { cause: error });
+    }
+  }
+
+  /**
+   * Export cash movements as an OFX bank statement
+   */
+  async exportOFX(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const range = parseDateRange(req.query);
+      if (range.error) {
+        return respondWithError(res, 400, range.error);
+      }
+
+      const currency = req.query.currency || null;
+      const currencyError = validateCurrencyFilter(currency);
+      if (currencyError) {
+        return respondWithError(res, 400, currencyError);
+      }
+
+      return await this.respondWithExport(req, res, user.id, 'ofx', { range, currency });
+
+    } catch (error) {
+      console.error('Export OFX error:', error);
+      return respondWithError(res, 500, 'Failed to export OFX', { cause: error });
+    }
+  }
+
+  /**
+   * Map database row to ExportJob model, with a signed download URL once completed
+   */
+  toExportJob(req, row) {
+    let downloadUrl = null;
+    if (row.status === 'completed') {
+      const ttl = Date.now() + DOWNLOAD_URL_TTL_MINUTES * 60 * 1000;
+      const expiresAt = Math.min(ttl, new Date(row.expires_at).getTime());
+      downloadUrl = `${config.server.publicUrl || `${req.protocol}://${req.get('host')}`}${signPath(`/api/v1/exports/${row.id}/download`, expiresAt)}`;
+    }
+
+    return new ExportJob({
+      id: row.id,
+      userId: row.user_id,
+      format: row.format,
+      params: row.params,
+      encrypted: row.encrypted,
+      status: row.status,
+      fileName: row.file_name,
+      size: row.size,
+      error: row.error,
+      createdAt: row.created_at,
+      startedAt: row.started_at,
+      completedAt: row.completed_at,
+      expiresAt: row.expires_at,
+      downloadUrl
+    });
+  }
+
+  /**
+   * Queue an export to be generated in the background
+   */
+  async createExportJob(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['format', 'range', 'from', 'to', 'currency']);
+      const { format } = req.body;
+      const currency = req.body.currency || null;
+
+      if (!EXPORT_FORMATS[format]) {
+        return respondWithError(res, 400, `Format must be one of: ${Object.keys(EXPORT_FORMATS).join(', ')}`);
+      }
+
+      const currencyError = validateCurrencyFilter(currency);
+      if (currencyError) {
+        return respondWithError(res, 400, currencyError);
+      }
+
+      // Resolve the date range now so the export covers what was requested
+      const range = parseDateRange(req.body);
+      if (range.error) {
+        return respondWithError(res, 400, range.error);
+      }
+
+      const { passphrase, error: passphraseError } = getRequestPassphrase(req);
+      if (passphraseError) {
+        return respondWithError(res, 400, passphraseError);
+      }
+
+      // Only the derived key is kept, and only until the worker has encrypted the file
+      let encryptionKey = null;
+      if (passphrase) {
+        const { salt, key, iv } = deriveKey(passphrase);
+        encryptionKey = [salt, key, iv].map(part => part.toString('hex')).join(':');
+      }
+
+      const result = await db.query(
+        `INSERT INTO export_jobs (user_id, format, params, encrypted, encryption_key)
+         VALUES ($1, $2, $3, $4, $5)
+         RETURNING *`,
+        [user.id, format, JSON.stringify({ range, currency }), encryptionKey !== null, encryptionKey]
+      );
+
+      return respondWithJSON(res, 202, this.toExportJob(req, result.rows[0]));
+
+    } catch (error) {
+      console.error('Create export job error:', error);
+      return respondWithError(res, 500, 'Failed to create export job', { cause: error });
+    }
+  }
+
+  /**
+   * Get recent export jobs for user
+   */
+  async getExportJobs(req, res) {
+    try {
+      const user = getUserFromContext(req);
+
+      const result = await db.query(
+        'SELECT * FROM export_jobs WHERE user_id = $1 ORDER BY created_at DESC LIMIT 50',
+        [user.id]
+      );
+
+      return respondWithJSON(res, 200, result.rows.map(row => this.toExportJob(req, row)));
+
+    } catch (error) {
+      console.error('Get export jobs error:', error);
+      return respondWithError(res, 500, 'Failed to get export jobs', { cause: error });
+    }
+  }
+
+  /**
+   * Get export job status (includes a signed download URL once completed)
+   */
+  async getExportJob(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const result = await db.query(
+        'SELECT * FROM export_jobs WHERE id = $1 AND user_id = $2',
+        [id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Export not found');
+      }
+
+      return respondWithJSON(res, 200, this.toExportJob(req, result.rows[0]));
+
+    } catch (error) {
+      console.error('Get export job error:', error);
+      return respondWithError(res, 500, 'Failed to get export job', { cause: error });
+    }
+  }
+
+  /**
+   * Download a completed export using a signed URL (no auth header required)
+   */
+  async downloadExport(req, res) {
+    try {
+      const { id } = req.params;
+      const { expires, signature } = req.query;
+
+      if (!verifySignedPath(`/api/v1/exports/${id}/download`, expires, signature)) {
+        return respondWithError(res, 403, 'Download link is invalid or has expired', { code: 'INVALID_DOWNLOAD_LINK' });
+      }
+
+      const result = await db.query(
+        "SELECT * FROM export_jobs WHERE id = $1 AND status = 'completed' AND expires_at > CURRENT_TIMESTAMP",
+        [id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Export not found');
+      }
+
+      const job = result.rows[0];
+      return this.sendExport(res, {
+        content: await storage.get(job.storage_key),
+        contentType: job.content_type,
+        fileName: job.file_name
+      });
+
+    } catch (error) {
+      console.error('Download export error:', error);
+      return respondWithError(res, 500, 'Failed to download export', { cause: error });
+    }
+  }
+}
+
+module.exports = new ExportHandler();
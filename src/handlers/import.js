@@ -0,0 +1,185 @@
+const path = require('path');
+const { v4: uuidv4 } = require('uuid');
+const db = require('../database/db');
+const storage = require('../storage');
+const { respondWithError, respondWithJSON } = require('../utils/response');
+const { getUserFromContext } = require('../middleware/auth');
+const { normalizeHeader } = require('../utils/csv');
+const { IMPORT_TARGETS, readImportRows, readImportFile, suggestMapping } = require('../utils/imports');
+const loanHandler = require('./loan');
+const transactionHandler = require('./transaction');
+
+const IMPORT_HANDLERS = {
+  loans: loanHandler,
+  transactions: transactionHandler
+};
+const SAMPLE_ROWS = 10;
+const UPLOAD_RETENTION_HOURS = 24;
+const MAX_UPLOAD_SIZE = 10 * 1024 * 1024;
+
+class ImportHandler {
+  /**
+   * Remove the user's uploads that were never imported
+   */
+  async removeStaleUploads(userId) {
+    const result = await db.query(
+      `DELETE FROM import_uploads
+       WHERE user_id = $1 AND created_at < CURRENT_TIMESTAMP - $2::interval
+       RETURNING storage_key`,
+      [userId, `${UPLOAD_RETENTION_HOURS} hours`]
+    );
+
+    for (const row of result.rows) {
+      await storage.delete(row.storage_key);
+    }
+  }
+
+  /**
+   * Upload a CSV or XLSX spreadsheet and preview its columns, sample rows and a suggested mapping
+   */
+  async previewImport(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const target = req.body.target || 'loans';
+      const file = (req.files || []).find(f => f.fieldName === 'file') || (req.files || [])[0];
+
+      if (!IMPORT_TARGETS[target]) {
+        return respondWithError(res, 400, `Target must be one of: ${Object.keys(IMPORT_TARGETS).join(', ')}`);
+      }
+
+      if (!file || file.size === 0) {
+        return respondWithError(res, 400, 'Spreadsheet file is required (multipart field "file")');
+      }
+
+      if (file.size > MAX_UPLOAD_SIZE) {
+        return respondWithError(res, 400, `File ${file.fileName} exceeds the 10MB limit`);
+      }
+
+      let rows;
+      try {
+        rows = readImportRows(file);
+      } catch (parseError) {
+        return respondWithError(res, 400, `Could not read spreadsheet: ${parseError.message}`);
+      }
+
+      if (rows.length === 0) {
+        return respondWithError(res, 400, 'Spreadsheet is empty');
+      }
+
+      const headers = rows[0].map(normalizeHeader);
+      const dataRows = rows.slice(1);
+      const columns = rows[0].map((label, index) => ({
+        header: label.trim(),
+        key: headers[index],
+        samples: dataRows.slice(0, 5).map(row => (row[index] || '').trim())
+      }));
+
+      await this.removeStaleUploads(user.id);
+
+      const storageKey = `imports/${user.id}/${uuidv4()}${path.extname(file.fileName).toLowerCase()}`;
+      await storage.save(storageKey, file.buffer, file.contentType);
+
+      const result = await db.query(
+        `INSERT INTO import_uploads (user_id, target, file_name, content_type, storage_key, row_count)
+         VALUES ($1, $2, $3, $4, $5, $6)
+         RETURNING id, created_at`,
+        [user.id, target, path.basename(file.fileName), file.contentType, storageKey, dataRows.length]
+      );
+
+      return respondWithJSON(res, 201, {
+        uploadId: result.rows[0].id,
+        target,
+        fileName: path.basename(file.fileName),
+        rowCount: dataRows.length,
+        columns,
+        sampleRows: dataRows.slice(0, SAMPLE_ROWS),
+        fields: Object.keys(IMPORT_TARGETS[target].fields),
+        requiredFields: IMPORT_TARGETS[target].required,
+        suggestedMapping: suggestMapping(headers, target),
+        expiresAt: new Date(new Date(result.rows[0].created_at).getTime() + UPLOAD_RETENTION_HOURS * 60 * 60 * 1000)
+      });
+
+    } catch (error) {
+      console.error('Preview import error:', error);
+      return respondWithError(res, 500, 'Failed to preview import', { cause: error });
+    }
+  }
+
+  /**
+   * Run an import of a previewed upload with a { field: header } mapping (supports dry run)
+   */
+  async runImport(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+      const { mapping } = req.body;
+      const dryRun = req.query.dry_run === 'true';
+
+      if (!mapping || typeof mapping !== 'object') {
+        return respondWithError(res, 400, 'mapping object is required');
+      }
+
+      const uploadResult = await db.query(
+        'SELECT * FROM import_uploads WHERE id = $1 AND user_id = $2',
+        [id, user.id]
+      );
+
+      if (uploadResult.rows.length === 0) {
+        return respondWithError(res, 404, 'Import upload not found');
+      }
+
+      const upload = uploadResult.rows[0];
+      const table = readImportFile({
+        fileName: upload.file_name,
+        contentType: upload.content_type,
+        buffer: await storage.get(upload.storage_key)
+      });
+
+      const result = await IMPORT_HANDLERS[upload.target].runImport(user.id, table, mapping, dryRun);
+      if (result.error) {
+        return respondWithError(res, 400, result.error);
+      }
+
+      // Upload is no longer needed once its rows are imported
+      if (!dryRun) {
+        await db.query('DELETE FROM import_uploads WHERE id = $1', [upload.id]);
+        await storage.delete(upload.storage_key);
+      }
+
+      return respondWithJSON(res, dryRun ? 200 : 201, { target: upload.target, ...result });
+
+    } catch (error) {
+      console.error('Run import error:', error);
+      return respondWithError(res, 500, 'Failed to run import', { cause: error });
+    }
+  }
+
+  /**
+   * Discard a previewed upload without importing
+   */
+  async deleteUpload(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const result = await db.query(
+        'DELETE FROM import_uploads WHERE id = $1 AND user_id = $2 RETURNING storage_key',
+        [id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Import upload not found');
+      }
+
+      await storage.delete(result.rows[0].storage_key);
+
+      return respondWithJSON(res, 200, { message: 'Import upload deleted successfully' });
+
+    } catch (error) {
+      console.error('Delete import upload error:', error);
+      return respondWithError(res, 500, 'Failed to delete import upload', { cause: error });
+    }
+  }
+}
+
+module.exports = new ImportHandler();
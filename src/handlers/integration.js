@@ -0,0 +1,346 @@
+const config = require('../config');
+const db = require('../database/db');
+const { respondWithError, respondWithJSON, validateRequiredFields, rejectUnknownFields } = require('../utils/response');
+const { getUserFromContext } = require('../middleware/auth');
+const crypto = require('crypto');
+const { signToken, verifyToken } = require('../utils/jwt');
+const { sendTelegramMessage } = require('../utils/telegram');
+
+const LINE_AUTHORIZE_URL = 'https://notify-bot.line.me/oauth/authorize';
+const LINE_TOKEN_URL = 'https://notify-bot.line.me/oauth/token';
+const LINE_STATUS_URL = 'https://notify-api.line.me/api/status';
+const LINE_REVOKE_URL = 'https://notify-api.line.me/api/revoke';
+const TELEGRAM_LINK_CODE_TTL_MINUTES = 15;
+
+class IntegrationHandler {
+  /**
+   * Store (or replace) an integration token for user
+   */
+  async saveIntegration(userId, provider, accessToken, metadata = null) {
+    await db.query(
+      `INSERT INTO user_integrations (user_id, provider, access_token, metadata)
+       VALUES ($1, $2, $3, $4)
+       ON CONFLICT (user_id, provider)
+       DO UPDATE SET access_token = EXCLUDED.access_token, metadata = EXCLUDED.metadata, updated_at = CURRENT_TIMESTAMP`,
+      [userId, provider, accessToken, metadata ? JSON.stringify(metadata) : null]
+    );
+  }
+
+  /**
+   * Get LINE integration status
+   */
+  async getLineIntegration(req, res) {
+    try {
+      const user = getUserFromContext(req);
+
+      const result = await db.query(
+        `SELECT metadata, created_at, updated_at FROM user_integrations
+         WHERE user_id = $1 AND provider = 'line'`,
+        [user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithJSON(res, 200, { connected: false });
+      }
+
+      return respondWithJSON(res, 200, {
+        connected: true,
+        target: result.rows[0].metadata ? result.rows[0].metadata.target : null,
+        connectedAt: result.rows[0].created_at,
+        updatedAt: result.rows[0].updated_at
+      });
+
+    } catch (error) {
+      console.error('Get LINE integration error:', error);
+      return respondWithError(res, 500, 'Failed to get LINE integration', { cause: error });
+    }
+  }
+
+  /**
+   * Start LINE Notify OAuth flow and return the authorization URL
+   */
+  async authorizeLine(req, res) {
+    try {
+      const user = getUserFromContext(req);
+
+      const line = config.integrations.line;
+      if (!line.clientId || !line.redirectUri) {
+        return respondWithError(res, 503, 'LINE Notify is not configured', { code: 'NOT_CONFIGURED' });
+      }
+
+      const params = new URLSearchParams({
+        response_type: 'code',
+        client_id: line.clientId,
+        redirect_uri: line.redirectUri,
+        scope: 'notify',
+        state: signToken('line_oauth', user.id)
+      });
+
+      return respondWithJSON(res, 200, { authorizeUrl: `${LINE_AUTHORIZE_URL}?${params}` });
+
+    } catch (error) {
+      console.error('Authorize LINE error:', error);
+      return respondWithError(res, 500, 'Failed to start LINE authorization', { cause: error });
+    }
+  }
+
+  /**
+   * Fetch the LINE Notify target name (user or group) for a token
+   */
+  async fetchLineTarget(accessToken) {
+    const response = await fetch(LINE_STATUS_URL, {
+      headers: { 'Authorization': `Bearer ${accessToken}` }
+    });
+
+    if (!response.ok) {
+      throw new Error(`LINE Notify status responded with ${response.status}`);
+    }
+
+    const status = await response.json();
+    return status.target || null;
+  }
+
+  /**
+   * Handle LINE Notify OAuth callback (public, identified by signed state)
+   */
+  async lineCallback(req, res) {
+    try {
+      const { code, state, error } = req.query;
+      const frontendUrl = config.server.frontendUrl;
+
+      if (error || !code || !state) {
+        return res.redirect(`${frontendUrl}/profile.html?line=cancelled`);
+      }
+
+      let userId;
+      try {
+        userId = verifyToken(state, 'line_oauth');
+      } catch (stateError) {
+        return respondWithError(res, 400, 'Invalid or expired state');
+      }
+
+      const response = await fetch(LINE_TOKEN_URL, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/x-www-form-urlencoded' },
+        body: new URLSearchParams({
+          grant_type: 'authorization_code',
+          code,
+          redirect_uri: config.integrations.line.redirectUri,
+          client_id: config.integrations.line.clientId,
+          client_secret: config.integrations.line.clientSecret
+        })
+      });
+
+      if (!response.ok) {
+        console.error('LINE token exchange failed:', response.status, await response.text());
+        return res.redirect(`${frontendUrl}/profile.html?line=failed`);
+      }
+
+      const { access_token: accessToken } = await response.json();
+      const target = await this.fetchLineTarget(accessToken).catch(() => null);
+      await this.saveIntegration(userId, 'line', accessToken, { target });
+
+      return res.redirect(`${frontendUrl}/profile.html?line=connected`);
+
+    } catch (error) {
+      console.error('LINE callback error:', error);
+      return respondWithError(res, 500, 'Failed to connect LINE', { cause: error });
+    }
+  }
+
+  /**
+   * Connect LINE Notify with a personal access token
+   */
+  async connectLineToken(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['token']);
+      const { token } = req.body;
+
+      validateRequiredFields(req.body, ['token']);
+
+      let target;
+      try {
+        target = await this.fetchLineTarget(token);
+      } catch (statusError) {
+        return respondWithError(res, 400, 'LINE Notify token is not valid');
+      }
+
+      await this.saveIntegration(user.id, 'line', token, { target });
+
+      return respondWithJSON(res, 200, { connected: true, target });
+
+    } catch (error) {
+      console.error('Connect LINE token error:', error);
+      return respondWithError(res, 500, 'Failed to connect LINE', { cause: error });
+    }
+  }
+
+  /**
+   * Disconnect LINE Notify (revokes the token)
+   */
+  async disconnectLine(req, res) {
+    try {
+      const user = getUserFromContext(req);
+
+      const result = await db.query(
+        `DELETE FROM user_integrations WHERE user_id = $1 AND provider = 'line' RETURNING access_token`,
+        [user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'LINE is not connected');
+      }
+
+      // Best effort: the token is gone locally even if LINE is unreachable
+      await fetch(LINE_REVOKE_URL, {
+        method: 'POST',
+        headers: { 'Authorization': `Bearer ${result.rows[0].access_token}` }
+      }).catch(revokeError => console.error('LINE revoke error:', revokeError));
+
+      return respondWithJSON(res, 200, { message: 'LINE disconnected successfully' });
+
+    } catch (error) {
+      console.error('Disconnect LINE error:', error);
+      return respondWithError(res, 500, 'Failed to disconnect LINE', { cause: error });
+    }
+  }
+
+  /**
+   * Get Telegram integration status
+   */
+  async getTelegramIntegration(req, res) {
+    try {
+      const user = getUserFromContext(req);
+
+      const result = await db.query(
+        `SELECT metadata, created_at, updated_at FROM user_integrations
+         WHERE user_id = $1 AND provider = 'telegram'`,
+        [user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithJSON(res, 200, { connected: false });
+      }
+
+      return respondWithJSON(res, 200, {
+        connected: true,
+        username: result.rows[0].metadata ? result.rows[0].metadata.username : null,
+        connectedAt: result.rows[0].created_at,
+        updatedAt: result.rows[0].updated_at
+      });
+
+    } catch (error) {
+      console.error('Get Telegram integration error:', error);
+      return respondWithError(res, 500, 'Failed to get Telegram integration', { cause: error });
+    }
+  }
+
+  /**
+   * Create a one-time deep link the user opens to start the bot and link their chat
+   */
+  async linkTelegram(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { botToken, botUsername } = config.integrations.telegram;
+
+      if (!botToken || !botUsername) {
+        return respondWithError(res, 503, 'Telegram bot is not configured', { code: 'NOT_CONFIGURED' });
+      }
+
+      // Telegram start parameters allow up to 64 URL-safe characters
+      const code = crypto.randomBytes(24).toString('base64url');
+
+      const result = await db.query(
+        `INSERT INTO integration_link_codes (code, user_id, provider, expires_at)
+         VALUES ($1, $2, 'telegram', CURRENT_TIMESTAMP + ($3 || ' minutes')::interval)
+         RETURNING expires_at`,
+        [code, user.id, TELEGRAM_LINK_CODE_TTL_MINUTES]
+      );
+
+      return respondWithJSON(res, 200, {
+        deepLink: `https://t.me/${botUsername}?start=${code}`,
+        expiresAt: result.rows[0].expires_at
+      });
+
+    } catch (error) {
+      console.error('Link Telegram error:', error);
+      return respondWithError(res, 500, 'Failed to create Telegram link', { cause: error });
+    }
+  }
+
+  /**
+   * Telegram bot webhook: handles "/start <code>" sent after opening the deep link
+   */
+  async telegramWebhook(req, res) {
+    try {
+      const secret = config.integrations.telegram.webhookSecret;
+      if (!secret || req.get('X-Telegram-Bot-Api-Secret-Token') !== secret) {
+        return respondWithError(res, 401, 'Invalid webhook secret', { code: 'INVALID_WEBHOOK_SECRET' });
+      }
+
+      const message = req.body && req.body.message;
+      const match = message && typeof message.text === 'string' && message.text.match(/^\/start\s+(\S+)/);
+
+      // Acknowledge every update so Telegram doesn't redeliver it
+      if (!match) {
+        return respondWithJSON(res, 200, { ok: true });
+      }
+
+      const codeResult = await db.query(
+        `DELETE FROM integration_link_codes
+         WHERE code = $1 AND provider = 'telegram' AND expires_at > CURRENT_TIMESTAMP
+         RETURNING user_id`,
+        [match[1]]
+      );
+
+      const chatId = String(message.chat.id);
+
+      if (codeResult.rows.length === 0) {
+        await sendTelegramMessage(chatId, 'This link has expired. Please create a new one from your profile.')
+          .catch(sendError => console.error('Telegram reply error:', sendError));
+        return respondWithJSON(res, 200, { ok: true });
+      }
+
+      // access_token holds the chat ID for Telegram; the bot token stays server-side
+      await this.saveIntegration(codeResult.rows[0].user_id, 'telegram', chatId, {
+        username: message.chat.username || (message.from && message.from.username) || null
+      });
+
+      await sendTelegramMessage(chatId, 'Your loan-money account is now connected. You will receive reminders here.')
+        .catch(sendError => console.error('Telegram reply error:', sendError));
+
+      return respondWithJSON(res, 200, { ok: true });
+
+    } catch (error) {
+      console.error('Telegram webhook error:', error);
+      return respondWithError(res, 500, 'Failed to process Telegram update', { cause: error });
+    }
+  }
+
+  /**
+   * Disconnect Telegram chat
+   */
+  async disconnectTelegram(req, res) {
+    try {
+      const user = getUserFromContext(req);
+
+      const result = await db.query(
+        `DELETE FROM user_integrations WHERE user_id = $1 AND provider = 'telegram' RETURNING id`,
+        [user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Telegram is not connected');
+      }
+
+      return respondWithJSON(res, 200, { message: 'Telegram disconnected successfully' });
+
+    } catch (error) {
+      console.error('Disconnect Telegram error:', error);
+      return respondWithError(res, 500, 'Failed to disconnect Telegram', { cause: error });
+    }
+  }
+}
+
+module.exports = new IntegrationHandler();
@@ -1,58 +1,177 @@
 const db = require('../database/db');
-const { respondWithError, respondWithJSON, validateRequiredFields, parsePagination } = require('../utils/response');
+const { SelectQuery } = require('../database/query');
+const { respondWithError, respondWithJSON, validateRequiredFields, rejectUnknownFields, parsePagination, parseIncludeDeleted, parseFields, selectFields, encodeCursor, parseCursorPagination } = require('../utils/response');
 const { getUserFromContext } = require('../middleware/auth');
-const { Loan } = require('../models');
+const { wantsJSONAPI, parseInclude, loanResource, transactionResource, respondWithJSONAPI } = require('../utils/jsonapi');
+const { Loan, LOAN_DIRECTIONS, LOAN_STATUSES } = require('../models');
+const loanService = require('../services/loan');
+const borrowerHandler = require('./borrower');
+const notificationService = require('../notifications');
+const { INTEREST_TYPES, calculateTotalInterest } = require('../utils/interest');
+const { parseCSVRecords, applyColumnMapping } = require('../utils/csv');
+const { IMPORT_TARGETS, readImportFile } = require('../utils/imports');
+const { DEFAULT_CURRENCY, CURRENCIES, isSupportedCurrency } = require('../utils/currency');
+const { parseDate, parseDateFields, formatDateFields, userCalendar } = require('../utils/dates');
+
+const MAX_IMPORT_ROWS = 5000;
+
+// Query-string filters and sort fields accepted by the loan lists
+const LOAN_FILTERS = {
+  status: { column: 'l.status', type: 'enum', values: LOAN_STATUSES },
+  direction: { column: 'l.direction', type: 'enum', values: LOAN_DIRECTIONS },
+  currency: { column: 'l.currency', type: 'enum', values: Object.keys(CURRENCIES) },
+  borrowerId: { column: 'l.borrower_id', type: 'uuid' },
+  search: { column: 'l.borrower_name', type: 'search' },
+  amount: { column: 'l.amount', type: 'number' },
+  loanDate: { column: 'l.loan_date', type: 'date' },
+  dueDate: { column: 'l.due_date', type: 'date' }
+};
+const LOAN_SORTS = {
+  created_at: 'l.created_at',
+  loan_date: 'l.loan_date',
+  due_date: 'l.due_date',
+  amount: 'l.amount',
+  borrower_name: 'l.borrower_name',
+  status: 'l.status',
+  remaining_debt: 'lb.remaining_debt'
+};
+
+/**
+ * Parse an imported number, allowing thousands separators (e.g. "10,000.50")
+ */
+function parseImportNumber(value) {
+  return value === undefined || value === '' ? null : Number(String(value).replace(/,/g, ''));
+}
 
 class LoanHandler {
   /**
    * Get all loans for user
    */
   async getLoans(req, res) {
+    // ?cursor= (empty for the first page) switches to keyset pagination, avoiding OFFSET scans on large accounts
+    if (req.query.cursor !== undefined) {
+      return this.getLoansV2(req, res);
+    }
+
     try {
       const user = getUserFromContext(req);
       const { page, limit, offset } = parsePagination(req.query);
-      const { status, search } = req.query;
-
-      let query = 'SELECT * FROM loans WHERE user_id = $1';
-      let params = [user.id];
-      let paramCount = 1;
+      const fields = parseFields(req.query);
+      const includeDeleted = parseIncludeDeleted(req.query);
+
+      const query = new SelectQuery(`
+        SELECT l.*, lb.total_paid, lb.total_charges, lb.remaining_debt
+        FROM loans l
+        LEFT JOIN loan_balances lb ON lb.loan_id = l.id
+        WHERE l.user_id = ?
+      `, user.id)
+        .whereIf(!includeDeleted, 'l.deleted_at IS NULL')
+        .filter(LOAN_FILTERS, req.query)
+        .sort(LOAN_SORTS, req.query.sort, 'l.created_at DESC, l.id DESC');
 
-      if (status) {
-        paramCount++;
-        query += ` AND status = $${paramCount}`;
-        params.push(status);
+      if (limit) {
+        query.limit(limit);
+        if (offset) {
+          query.offset(offset);
+        }
       }
 
-      if (search) {
-        paramCount++;
-        query += ` AND borrower_name ILIKE $${paramCount}`;
-        params.push(`%${search}%`);
+      const result = await query.run();
+
+      if (wantsJSONAPI(req)) {
+        return respondWithJSONAPI(res, 200, await this.toLoansDocument(user, result.rows, parseInclude(req.query), {
+          pagination: { page, limit, total: result.rowCount }
+        }));
       }
 
-      query += ' ORDER BY created_at DESC';
+      return respondWithJSON(res, 200, {
+        loans: selectFields(formatDateFields(result.rows, userCalendar(user)), fields, result.fields.map(field => field.name)),
+        pagination: { page, limit, total: result.rowCount }
+      });
 
-      if (limit) {
-        paramCount++;
-        query += ` LIMIT $${paramCount}`;
-        params.push(limit);
+    } catch (error) {
+      console.error('Get loans error:', error);
+      return respondWithError(res, 500, 'Failed to get loans', { cause: error });
+    }
+  }
 
-        if (offset) {
-          paramCount++;
-          query += ` OFFSET $${paramCount}`;
-          params.push(offset);
-        }
+  /**
+   * Build a JSON:API document for loans, with dates in the user's calendar; include=transactions embeds
+   * each loan's transactions
+   */
+  async toLoansDocument(user, loans, include, meta = undefined) {
+    const calendar = userCalendar(user);
+    let transactions = null;
+    if (include.includes('transactions')) {
+      const result = await db.query(
+        `SELECT * FROM transactions
+         WHERE user_id = $1 AND loan_id = ANY($2::uuid[]) AND deleted_at IS NULL
+         ORDER BY created_at ASC`,
+        [user.id, loans.map(loan => loan.id)]
+      );
+      transactions = formatDateFields(result.rows, calendar);
+    }
+
+    const document = {
+      data: formatDateFields(loans, calendar).map(loan => loanResource(
+        loan,
+        transactions && transactions.filter(transaction => transaction.loan_id === loan.id)
+      )),
+      meta
+    };
+    if (transactions) {
+      document.included = transactions.map(transactionResource);
+    }
+    return document;
+  }
+
+  /**
+   * Get loans for user with cursor pagination (API v2)
+   */
+  async getLoansV2(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { limit, after, error } = parseCursorPagination(req.query);
+      const fields = parseFields(req.query);
+      const includeDeleted = parseIncludeDeleted(req.query);
+      if (error) {
+        return respondWithError(res, 400, error);
       }
 
-      const result = await db.query(query, params);
+      const query = new SelectQuery(`
+        SELECT l.*, l.created_at::text as cursor_created_at, lb.total_paid, lb.total_charges, lb.remaining_debt
+        FROM loans l
+        LEFT JOIN loan_balances lb ON lb.loan_id = l.id
+        WHERE l.user_id = ?
+      `, user.id)
+        .whereIf(!includeDeleted, 'l.deleted_at IS NULL')
+        .filter(LOAN_FILTERS, req.query)
+        // Keyset condition keeps pages stable while new loans are added
+        .whereIf(after, '(l.created_at, l.id) < (?::timestamptz, ?::uuid)', after && after.createdAt, after && after.id)
+        .orderBy('l.created_at DESC, l.id DESC')
+        .limit(limit + 1);
+
+      const result = await query.run();
+      const rows = result.rows.slice(0, limit);
+      const last = rows[rows.length - 1];
+      const hasMore = result.rows.length > limit;
 
       return respondWithJSON(res, 200, {
-        loans: result.rows,
-        pagination: { page, limit, total: result.rowCount }
+        loans: selectFields(
+          formatDateFields(rows.map(({ cursor_created_at, ...loan }) => loan), userCalendar(user)),
+          fields,
+          result.fields.map(field => field.name).filter(name => name !== 'cursor_created_at')
+        ),
+        pagination: {
+          limit,
+          hasMore,
+          nextCursor: hasMore ? encodeCursor(last.cursor_created_at, last.id) : null
+        }
       });
 
     } catch (error) {
-      console.error('Get loans error:', error);
-      return respondWithError(res, 500, 'Failed to get loans');
+      console.error('Get loans v2 error:', error);
+      return respondWithError(res, 500, 'Failed to get loans', { cause: error });
     }
   }
 
@@ -62,9 +181,23 @@ class LoanHandler {
   async createLoan(req, res) {
     try {
       const user = getUserFromContext(req);
-      const { borrowerName, borrowerPhone, borrowerAddress, amount, interestRate, loanDate, dueDate, notes } = req.body;
+      rejectUnknownFields(req.body, ['borrowerId', 'borrowerName', 'borrowerPhone', 'borrowerAddress', 'amount', 'interestRate', 'interestType', 'termMonths', 'direction', 'currency', 'loanDate', 'dueDate', 'notes']);
+      const { borrowerName, borrowerPhone, borrowerAddress, amount, interestRate, notes } = req.body;
+      const interestType = req.body.interestType || 'reducing';
+      const termMonths = req.body.termMonths || null;
+      const direction = req.body.direction || 'lent';
+      const currency = req.body.currency || DEFAULT_CURRENCY;
+
+      validateRequiredFields(req.body, ['amount', 'interestRate', 'loanDate']);
+
+      const { loanDate, dueDate, error: dateError } = parseDateFields(req.body, ['loanDate', 'dueDate']);
+      if (dateError) {
+        return respondWithError(res, 400, dateError);
+      }
 
-      validateRequiredFields(req.body, ['borrowerName', 'amount', 'interestRate', 'loanDate']);
+      if (!req.body.borrowerId && !borrowerName) {
+        return respondWithError(res, 400, 'Either borrowerId or borrowerName is required');
+      }
 
       if (amount <= 0) {
         return respondWithError(res, 400, 'Amount must be greater than 0');
@@ -74,22 +207,67 @@ class LoanHandler {
         return respondWithError(res, 400, 'Interest rate cannot be negative');
       }
 
+      if (!INTEREST_TYPES.includes(interestType)) {
+        return respondWithError(res, 400, `Interest type must be one of: ${INTEREST_TYPES.join(', ')}`);
+      }
+
+      if (!LOAN_DIRECTIONS.includes(direction)) {
+        return respondWithError(res, 400, `Direction must be one of: ${LOAN_DIRECTIONS.join(', ')}`);
+      }
+
+      // Fixed at creation: payments are recorded in the loan currency
+      if (!isSupportedCurrency(currency)) {
+        return respondWithError(res, 400, `Currency must be one of: ${Object.keys(CURRENCIES).join(', ')}`);
+      }
+
+      if (interestType === 'flat' && !termMonths) {
+        return respondWithError(res, 400, 'Term in months is required for flat-rate loans');
+      }
+
+      if (termMonths !== null && (!Number.isInteger(Number(termMonths)) || termMonths <= 0)) {
+        return respondWithError(res, 400, 'Term in months must be a positive integer');
+      }
+
+      let borrower;
+      if (req.body.borrowerId) {
+        const borrowerResult = await db.query(
+          'SELECT * FROM borrowers WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+          [req.body.borrowerId, user.id]
+        );
+
+        if (borrowerResult.rows.length === 0) {
+          return respondWithError(res, 404, 'Borrower not found');
+        }
+        borrower = borrowerResult.rows[0];
+      } else {
+        borrower = await borrowerHandler.findOrCreateBorrower(db, user.id, {
+          name: borrowerName,
+          phone: borrowerPhone,
+          address: borrowerAddress
+        });
+      }
+
       const result = await db.query(
-        `INSERT INTO loans (user_id, borrower_name, borrower_phone, borrower_address, amount, interest_rate, loan_date, due_date, notes)
-         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        `INSERT INTO loans (user_id, borrower_id, borrower_name, borrower_phone, borrower_address, amount, interest_rate, interest_type, term_months, direction, currency, loan_date, due_date, notes)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
          RETURNING *`,
-        [user.id, borrowerName, borrowerPhone, borrowerAddress, amount, interestRate, loanDate, dueDate, notes]
+        [user.id, borrower.id, borrower.name, borrowerPhone || borrower.phone, borrowerAddress || borrower.address, amount, interestRate, interestType, termMonths, direction, currency, loanDate, dueDate, notes]
       );
 
       const loanData = result.rows[0];
       const loan = new Loan({
         id: loanData.id,
         userId: loanData.user_id,
+        borrowerId: loanData.borrower_id,
         borrowerName: loanData.borrower_name,
         borrowerPhone: loanData.borrower_phone,
         borrowerAddress: loanData.borrower_address,
         amount: loanData.amount,
         interestRate: loanData.interest_rate,
+        interestType: loanData.interest_type,
+        termMonths: loanData.term_months,
+        direction: loanData.direction,
+        currency: loanData.currency,
         loanDate: loanData.loan_date,
         dueDate: loanData.due_date,
         status: loanData.status,
@@ -98,11 +276,242 @@ class LoanHandler {
         updatedAt: loanData.updated_at
       });
 
-      return respondWithJSON(res, 201, loan);
+      loan.borrower = borrowerHandler.toBorrower(borrower);
+
+      // Warn when lending again to a borrower with a poor repayment history
+      const risk = await borrowerHandler.getRiskScore(db, user, borrower.id);
+      loan.borrowerRisk = risk;
+      loan.warnings = risk.rating === 'risky'
+        ? [`Borrower has a risky repayment history (score ${risk.score})`]
+        : [];
+      loan.totalInterest = calculateTotalInterest({
+        principal: loan.amount,
+        annualRate: loan.interestRate,
+        termMonths: loan.termMonths,
+        interestType: loan.interestType
+      });
+
+      await notificationService.emit('loan.created', {
+        userId: user.id,
+        payload: { loanId: loan.id, borrowerName: loan.borrowerName, amount: loan.amount, currency: loan.currency, dueDate: loan.dueDate }
+      });
+
+      return respondWithJSON(res, 201, formatDateFields(loan, userCalendar(user)));
 
     } catch (error) {
       console.error('Create loan error:', error);
-      return respondWithError(res, 500, 'Failed to create loan');
+      return respondWithError(res, 500, 'Failed to create loan', { cause: error });
+    }
+  }
+
+  /**
+   * Validate and normalize a CSV import row
+   */
+  validateImportRow(record) {
+    const errors = [];
+    const amount = parseImportNumber(record.amount);
+    const interestRate = parseImportNumber(record.interest_rate) || 0;
+    const termMonths = parseImportNumber(record.term_months);
+    const paidAmount = parseImportNumber(record.paid_amount) || 0;
+    const interestType = record.interest_type || 'reducing';
+    const direction = record.direction || 'lent';
+    const currency = record.currency ? String(record.currency).trim().toUpperCase() : DEFAULT_CURRENCY;
+    const status = record.status || 'active';
+
+    if (!record.borrower_name) {
+      errors.push('borrower_name is required');
+    }
+
+    if (amount === null || isNaN(amount)) {
+      errors.push('amount must be a number');
+    } else if (amount <= 0) {
+      errors.push('amount must be greater than 0');
+    }
+
+    if (isNaN(interestRate) || interestRate < 0) {
+      errors.push('interest_rate must be a non-negative number');
+    }
+
+    if (!INTEREST_TYPES.includes(interestType)) {
+      errors.push(`interest_type must be one of: ${INTEREST_TYPES.join(', ')}`);
+    }
+
+    if (termMonths !== null && (!Number.isInteger(termMonths) || termMonths <= 0)) {
+      errors.push('term_months must be a positive integer');
+    } else if (interestType === 'flat' && termMonths === null) {
+      errors.push('term_months is required for flat-rate loans');
+    }
+
+    if (!LOAN_DIRECTIONS.includes(direction)) {
+      errors.push(`direction must be one of: ${LOAN_DIRECTIONS.join(', ')}`);
+    }
+
+    if (!isSupportedCurrency(currency)) {
+      errors.push(`currency must be one of: ${Object.keys(CURRENCIES).join(', ')}`);
+    }
+
+    if (!LOAN_STATUSES.includes(status)) {
+      errors.push(`status must be one of: ${LOAN_STATUSES.join(', ')}`);
+    }
+
+    const loanDate = record.loan_date ? parseDate(record.loan_date) : null;
+    const dueDate = record.due_date ? parseDate(record.due_date) : null;
+    if (!record.loan_date) {
+      errors.push('loan_date is required');
+    } else if (!loanDate) {
+      errors.push('loan_date is not a valid date');
+    }
+
+    if (record.due_date && !dueDate) {
+      errors.push('due_date is not a valid date');
+    } else if (dueDate && loanDate && dueDate < loanDate) {
+      errors.push('due_date cannot be before loan_date');
+    }
+
+    if (isNaN(paidAmount) || paidAmount < 0) {
+      errors.push('paid_amount must be a non-negative number');
+    } else if (paidAmount > amount) {
+      errors.push('paid_amount cannot exceed amount');
+    }
+
+    return {
+      errors,
+      value: {
+        borrowerName: record.borrower_name,
+        borrowerPhone: record.borrower_phone || null,
+        borrowerAddress: record.borrower_address || null,
+        amount,
+        interestRate,
+        interestType,
+        termMonths,
+        direction,
+        currency,
+        loanDate,
+        dueDate,
+        status,
+        paidAmount,
+        notes: record.notes || null
+      }
+    };
+  }
+
+  /**
+   * Map, validate and (unless dry run) import loan records in a single transaction.
+   * Returns { error } when the columns or mapping are unusable, otherwise the import summary.
+   */
+  async runImport(userId, { headers, records: rawRecords }, mapping, dryRun) {
+    const { fields, required } = IMPORT_TARGETS.loans;
+    const { records, error: mappingError } = applyColumnMapping(headers, rawRecords, mapping, Object.keys(fields));
+    if (mappingError) {
+      return { error: mappingError };
+    }
+
+    const available = new Set([...headers, ...Object.keys(mapping || {})]);
+    const missingColumns = required.filter(field => !available.has(field));
+    if (missingColumns.length > 0) {
+      return { error: `Missing required columns: ${missingColumns.join(', ')}` };
+    }
+
+    if (records.length > MAX_IMPORT_ROWS) {
+      return { error: `Import is limited to ${MAX_IMPORT_ROWS} rows` };
+    }
+
+    const rows = records.map((record, index) => ({
+      row: index + 2, // Account for header row, 1-based
+      ...this.validateImportRow(record)
+    }));
+
+    const validRows = rows.filter(row => row.errors.length === 0);
+
+    let imported = 0;
+    if (!dryRun && validRows.length > 0) {
+      await db.transaction(async client => {
+        for (const row of validRows) {
+          const { value } = row;
+          const borrower = await borrowerHandler.findOrCreateBorrower(client, userId, {
+            name: value.borrowerName,
+            phone: value.borrowerPhone,
+            address: value.borrowerAddress
+          });
+
+          const loanResult = await client.query(
+            `INSERT INTO loans (user_id, borrower_id, borrower_name, borrower_phone, borrower_address, amount, interest_rate, interest_type, term_months, direction, currency, loan_date, due_date, status, notes)
+             VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+             RETURNING id`,
+            [userId, borrower.id, borrower.name, value.borrowerPhone || borrower.phone, value.borrowerAddress || borrower.address, value.amount, value.interestRate, value.interestType, value.termMonths, value.direction, value.currency, value.loanDate, value.dueDate, value.status, value.notes]
+          );
+          row.loanId = loanResult.rows[0].id;
+
+          // Amount already repaid before migration becomes a single opening payment
+          if (value.paidAmount > 0) {
+            await client.query(
+              `INSERT INTO transactions (loan_id, user_id, amount, transaction_type, transaction_date, description)
+               VALUES ($1, $2, $3, 'payment', CURRENT_DATE, 'Opening balance (imported)')`,
+              [row.loanId, userId, value.paidAmount]
+            );
+            await loanService.syncLoanStatus(client, row.loanId);
+          }
+        }
+      });
+      imported = validRows.length;
+    }
+
+    return {
+      dryRun,
+      totalRows: rows.length,
+      validRows: validRows.length,
+      invalidRows: rows.length - validRows.length,
+      imported,
+      rows: rows.map(({ row, errors, value, loanId }) => ({
+        row,
+        status: errors.length > 0 ? 'invalid' : 'valid',
+        errors,
+        loan: errors.length > 0 ? undefined : value,
+        loanId
+      }))
+    };
+  }
+
+  /**
+   * Import loans from CSV/XLSX (multipart file) or a text/csv body, with optional column mapping and dry run.
+   * Valid rows are committed in a single transaction; invalid rows are reported and skipped.
+   */
+  async importLoans(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const dryRun = req.query.dry_run === 'true';
+
+      const file = (req.files || []).find(f => f.fieldName === 'file') || (req.files || [])[0];
+      if (!file && (typeof req.body !== 'string' || req.body.trim() === '')) {
+        return respondWithError(res, 400, 'CSV file is required (multipart field "file" or Content-Type: text/csv)');
+      }
+
+      let mapping = (file && req.body.mapping) || req.query.mapping;
+      if (typeof mapping === 'string') {
+        try {
+          mapping = JSON.parse(mapping);
+        } catch (parseError) {
+          return respondWithError(res, 400, 'mapping must be valid JSON');
+        }
+      }
+
+      let table;
+      try {
+        table = file ? readImportFile(file) : parseCSVRecords(req.body);
+      } catch (parseError) {
+        return respondWithError(res, 400, `Could not read file: ${parseError.message}`);
+      }
+
+      const result = await this.runImport(user.id, table, mapping, dryRun);
+      if (result.error) {
+        return respondWithError(res, 400, result.error);
+      }
+
+      return respondWithJSON(res, dryRun ? 200 : 201, result);
+
+    } catch (error) {
+      console.error('Import loans error:', error);
+      return respondWithError(res, 500, 'Failed to import loans', { cause: error });
     }
   }
 
@@ -115,7 +524,10 @@ class LoanHandler {
       const { id } = req.params;
 
       const result = await db.query(
-        'SELECT * FROM loans WHERE id = $1 AND user_id = $2',
+        `SELECT l.*, lb.total_paid, lb.total_charges, lb.remaining_debt
+         FROM loans l
+         LEFT JOIN loan_balances lb ON lb.loan_id = l.id
+         WHERE l.id = $1 AND l.user_id = $2 AND l.deleted_at IS NULL`,
         [id, user.id]
       );
 
@@ -123,11 +535,118 @@ class LoanHandler {
         return respondWithError(res, 404, 'Loan not found');
       }
 
-      return respondWithJSON(res, 200, result.rows[0]);
+      const loan = result.rows[0];
+      loan.total_interest = calculateTotalInterest({
+        principal: parseFloat(loan.amount),
+        annualRate: parseFloat(loan.interest_rate),
+        termMonths: loan.term_months,
+        interestType: loan.interest_type
+      });
+
+      if (loan.borrower_id) {
+        const borrowerResult = await db.query('SELECT * FROM borrowers WHERE id = $1', [loan.borrower_id]);
+        loan.borrower = borrowerResult.rows.length > 0 ? borrowerHandler.toBorrower(borrowerResult.rows[0]) : null;
+      }
+
+      if (wantsJSONAPI(req)) {
+        const include = parseInclude(req.query);
+        const document = await this.toLoansDocument(user, [loan], include);
+        document.data = document.data[0];
+        if (include.includes('borrower') && loan.borrower) {
+          const { id: borrowerId, ...attributes } = loan.borrower;
+          document.included = [
+            { type: 'borrowers', id: borrowerId, attributes },
+            ...(document.included || [])
+          ];
+        }
+        return respondWithJSONAPI(res, 200, document);
+      }
+
+      return respondWithJSON(res, 200, formatDateFields(loan, userCalendar(user)));
 
     } catch (error) {
       console.error('Get loan error:', error);
-      return respondWithError(res, 500, 'Failed to get loan');
+      return respondWithError(res, 500, 'Failed to get loan', { cause: error });
+    }
+  }
+
+  /**
+   * Get overdue escalation steps fired for a loan
+   */
+  async getLoanEscalations(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const loanCheck = await db.query(
+        'SELECT id FROM loans WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+        [id, user.id]
+      );
+
+      if (loanCheck.rows.length === 0) {
+        return respondWithError(res, 404, 'Loan not found');
+      }
+
+      const result = await db.query(
+        `SELECT step, due_date, days_overdue, created_at as fired_at
+         FROM loan_escalations
+         WHERE loan_id = $1
+         ORDER BY created_at DESC`,
+        [id]
+      );
+
+      return respondWithJSON(res, 200, result.rows);
+
+    } catch (error) {
+      console.error('Get loan escalations error:', error);
+      return respondWithError(res, 500, 'Failed to get loan escalations', { cause: error });
+    }
+  }
+
+  /**
+   * Get loan ledger with running balance
+   */
+  async getLoanLedger(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const loanCheck = await db.query(
+        'SELECT id FROM loans WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+        [id, user.id]
+      );
+
+      if (loanCheck.rows.length === 0) {
+        return respondWithError(res, 404, 'Loan not found');
+      }
+
+      const result = await db.query(
+        `SELECT
+           transaction_id,
+           entry_type,
+           amount,
+           balance_effect,
+           entry_date,
+           description,
+           created_at,
+           SUM(balance_effect) OVER (
+             ORDER BY entry_date, (entry_type = 'disbursement') DESC, created_at
+             ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+           ) as running_balance
+         FROM loan_ledger
+         WHERE loan_id = $1
+         ORDER BY entry_date, (entry_type = 'disbursement') DESC, created_at`,
+        [id]
+      );
+
+      const entries = result.rows;
+      const balance = entries.length > 0 ? entries[entries.length - 1].running_balance : 0;
+
+      return respondWithJSON(res, 200, { entries: formatDateFields(entries, userCalendar(user)), balance });
+
+    } catch (error) {
+      console.error('Get loan ledger error:', error);
+      return respondWithError(res, 500, 'Failed to get loan ledger', { cause: error });
     }
   }
 
@@ -137,28 +656,57 @@ class LoanHandler {
   async updateLoan(req, res) {
     try {
       const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['borrowerName', 'borrowerPhone', 'borrowerAddress', 'amount', 'interestRate', 'interestType', 'termMonths', 'loanDate', 'dueDate', 'notes']);
       const { id } = req.params;
-      const { borrowerName, borrowerPhone, borrowerAddress, amount, interestRate, loanDate, dueDate, notes } = req.body;
+      const { borrowerName, borrowerPhone, borrowerAddress, amount, interestRate, interestType, termMonths, notes } = req.body;
+
+      if (interestType && !INTEREST_TYPES.includes(interestType)) {
+        return respondWithError(res, 400, `Interest type must be one of: ${INTEREST_TYPES.join(', ')}`);
+      }
+
+      if (termMonths !== undefined && termMonths !== null && (!Number.isInteger(Number(termMonths)) || termMonths <= 0)) {
+        return respondWithError(res, 400, 'Term in months must be a positive integer');
+      }
+
+      const { loanDate, dueDate, error: dateError } = parseDateFields(req.body, ['loanDate', 'dueDate']);
+      if (dateError) {
+        return respondWithError(res, 400, dateError);
+      }
+
+      const current = await db.query(
+        'SELECT interest_type, term_months FROM loans WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+        [id, user.id]
+      );
+
+      if (current.rows.length === 0) {
+        return respondWithError(res, 404, 'Loan not found');
+      }
+
+      // interest_type and term_months are kept when omitted; the resulting pair must still be valid
+      if ((interestType || current.rows[0].interest_type) === 'flat' && !(termMonths || current.rows[0].term_months)) {
+        return respondWithError(res, 400, 'Term in months is required for flat-rate loans');
+      }
 
       const result = await db.query(
         `UPDATE loans 
          SET borrower_name = $1, borrower_phone = $2, borrower_address = $3, 
-             amount = $4, interest_rate = $5, loan_date = $6, due_date = $7, 
-             notes = $8, updated_at = CURRENT_TIMESTAMP
-         WHERE id = $9 AND user_id = $10
+             amount = $4, interest_rate = $5, interest_type = COALESCE($6, interest_type),
+             term_months = COALESCE($7, term_months), loan_date = $8, due_date = $9, 
+             notes = $10, updated_at = CURRENT_TIMESTAMP
+         WHERE id = $11 AND user_id = $12 AND deleted_at IS NULL
          RETURNING *`,
-        [borrowerName, borrowerPhone, borrowerAddress, amount, interestRate, loanDate, dueDate, notes, id, user.id]
+        [borrowerName, borrowerPhone, borrowerAddress, amount, interestRate, interestType, termMonths || null, loanDate, dueDate, notes, id, user.id]
       );
 
       if (result.rows.length === 0) {
         return respondWithError(res, 404, 'Loan not found');
       }
 
-      return respondWithJSON(res, 200, result.rows[0]);
+      return respondWithJSON(res, 200, formatDateFields(result.rows[0], userCalendar(user)));
 
     } catch (error) {
       console.error('Update loan error:', error);
-      return respondWithError(res, 500, 'Failed to update loan');
+      return respondWithError(res, 500, 'Failed to update loan', { cause: error });
     }
   }
 
@@ -170,8 +718,11 @@ class LoanHandler {
       const user = getUserFromContext(req);
       const { id } = req.params;
 
+      // Soft delete; the loan and its transactions come back with restoreLoan
       const result = await db.query(
-        'DELETE FROM loans WHERE id = $1 AND user_id = $2 RETURNING *',
+        `UPDATE loans SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+         WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+         RETURNING id`,
         [id, user.id]
       );
 
@@ -183,7 +734,34 @@ class LoanHandler {
 
     } catch (error) {
       console.error('Delete loan error:', error);
-      return respondWithError(res, 500, 'Failed to delete loan');
+      return respondWithError(res, 500, 'Failed to delete loan', { cause: error });
+    }
+  }
+
+  /**
+   * Restore a soft-deleted loan
+   */
+  async restoreLoan(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const result = await db.query(
+        `UPDATE loans SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+         WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+         RETURNING *`,
+        [id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Deleted loan not found');
+      }
+
+      return respondWithJSON(res, 200, result.rows[0]);
+
+    } catch (error) {
+      console.error('Restore loan error:', error);
+      return respondWithError(res, 500, 'Failed to restore loan', { cause: error });
     }
   }
 
@@ -193,30 +771,22 @@ class LoanHandler {
   async updateLoanStatus(req, res) {
     try {
       const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['status']);
       const { id } = req.params;
       const { status } = req.body;
 
       validateRequiredFields(req.body, ['status']);
 
-      const validStatuses = ['active', 'paid', 'overdue', 'defaulted'];
-      if (!validStatuses.includes(status)) {
-        return respondWithError(res, 400, `Status must be one of: ${validStatuses.join(', ')}`);
-      }
-
-      const result = await db.query(
-        'UPDATE loans SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND user_id = $3 RETURNING *',
-        [status, id, user.id]
-      );
-
-      if (result.rows.length === 0) {
+      const loan = await loanService.updateStatus(user.id, id, status);
+      if (!loan) {
         return respondWithError(res, 404, 'Loan not found');
       }
 
-      return respondWithJSON(res, 200, result.rows[0]);
+      return respondWithJSON(res, 200, loan);
 
     } catch (error) {
       console.error('Update loan status error:', error);
-      return respondWithError(res, 500, 'Failed to update loan status');
+      return respondWithError(res, 500, 'Failed to update loan status', { cause: error });
     }
   }
 }
@@ -0,0 +1,64 @@
+const crypto = require('crypto');
+const config = require('../config');
+const db = require('../database/db');
+const { respondWithError } = require('../utils/response');
+
+/**
+ * Render gauges in the Prometheus text exposition format
+ */
+function renderGauges(gauges) {
+  return gauges.map(({ name, help, value }) => (
+    `# HELP ${name} ${help}\n# TYPE ${name} gauge\n${name} ${value}\n`
+  )).join('');
+}
+
+class MetricsHandler {
+  /**
+   * Check the optional METRICS_TOKEN bearer token (constant-time comparison)
+   */
+  isAuthorized(req) {
+    const token = config.admin.metricsToken;
+    if (!token) {
+      return true;
+    }
+
+    const expected = Buffer.from(`Bearer ${token}`);
+    const actual = Buffer.from(req.headers.authorization || '');
+    return actual.length === expected.length && crypto.timingSafeEqual(actual, expected);
+  }
+
+  /**
+   * Expose process and database pool metrics for Prometheus
+   */
+  async getMetrics(req, res) {
+    try {
+      if (!this.isAuthorized(req)) {
+        return respondWithError(res, 401, 'Invalid metrics token', { code: 'INVALID_TOKEN' });
+      }
+
+      const memory = process.memoryUsage();
+      const pools = [['db_pool', db.pool], ['db_replica_pool', db.replicaPool]].filter(([, pool]) => pool);
+
+      res.type('text/plain; version=0.0.4').send(renderGauges([
+        ...pools.flatMap(([prefix, pool]) => {
+          const stats = db.getPoolStats(pool);
+          return [
+            { name: `${prefix}_max_connections`, help: 'Configured maximum pool size', value: stats.max },
+            { name: `${prefix}_total_connections`, help: 'Open connections in the pool', value: stats.total },
+            { name: `${prefix}_idle_connections`, help: 'Idle connections in the pool', value: stats.idle },
+            { name: `${prefix}_waiting_clients`, help: 'Queries waiting for a free connection', value: stats.waiting }
+          ];
+        }),
+        { name: 'process_uptime_seconds', help: 'Process uptime', value: Math.round(process.uptime()) },
+        { name: 'process_resident_memory_bytes', help: 'Resident memory size', value: memory.rss },
+        { name: 'nodejs_heap_used_bytes', help: 'V8 heap in use', value: memory.heapUsed }
+      ]));
+
+    } catch (error) {
+      console.error('Get metrics error:', error);
+      return respondWithError(res, 500, 'Failed to get metrics', { cause: error });
+    }
+  }
+}
+
+module.exports = new MetricsHandler();
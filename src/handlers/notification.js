@@ -0,0 +1,169 @@
+const config = require('../config');
+const db = require('../database/db');
+const { respondWithError, respondWithJSON, parsePagination, rejectUnknownFields } = require('../utils/response');
+const { getUserFromContext } = require('../middleware/auth');
+
+class NotificationHandler {
+  /**
+   * Get in-app notifications for user
+   */
+  async getNotifications(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { page, limit, offset } = parsePagination(req.query);
+      const unreadOnly = req.query.unread === 'true';
+
+      const result = await db.query(
+        `SELECT id, event, subject, body, payload, read_at, created_at
+         FROM notifications
+         WHERE user_id = $1 AND channel = 'in_app'
+         AND ($2::boolean = false OR read_at IS NULL)
+         ORDER BY created_at DESC
+         LIMIT $3 OFFSET $4`,
+        [user.id, unreadOnly, limit, offset]
+      );
+
+      const unreadResult = await db.query(
+        `SELECT COUNT(*) as count FROM notifications
+         WHERE user_id = $1 AND channel = 'in_app' AND read_at IS NULL`,
+        [user.id]
+      );
+
+      return respondWithJSON(res, 200, {
+        notifications: result.rows,
+        unreadCount: parseInt(unreadResult.rows[0].count),
+        pagination: { page, limit, total: result.rowCount }
+      });
+
+    } catch (error) {
+      console.error('Get notifications error:', error);
+      return respondWithError(res, 500, 'Failed to get notifications', { cause: error });
+    }
+  }
+
+  /**
+   * Mark in-app notification as read
+   */
+  async markAsRead(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const result = await db.query(
+        `UPDATE notifications SET read_at = COALESCE(read_at, CURRENT_TIMESTAMP)
+         WHERE id = $1 AND user_id = $2 AND channel = 'in_app'
+         RETURNING id, read_at`,
+        [id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Notification not found');
+      }
+
+      return respondWithJSON(res, 200, result.rows[0]);
+
+    } catch (error) {
+      console.error('Mark notification read error:', error);
+      return respondWithError(res, 500, 'Failed to mark notification as read', { cause: error });
+    }
+  }
+
+  /**
+   * Mark all in-app notifications as read
+   */
+  async markAllAsRead(req, res) {
+    try {
+      const user = getUserFromContext(req);
+
+      const result = await db.query(
+        `UPDATE notifications SET read_at = CURRENT_TIMESTAMP
+         WHERE user_id = $1 AND channel = 'in_app' AND read_at IS NULL`,
+        [user.id]
+      );
+
+      return respondWithJSON(res, 200, { updated: result.rowCount });
+
+    } catch (error) {
+      console.error('Mark all notifications read error:', error);
+      return respondWithError(res, 500, 'Failed to mark notifications as read', { cause: error });
+    }
+  }
+
+  /**
+   * Get VAPID public key for the browser to subscribe with
+   */
+  async getPushPublicKey(req, res) {
+    const publicKey = config.notifications.webPush.publicKey;
+    if (!publicKey) {
+      return respondWithError(res, 503, 'Web Push is not configured', { code: 'NOT_CONFIGURED' });
+    }
+    return respondWithJSON(res, 200, { publicKey });
+  }
+
+  /**
+   * Register (or refresh) a browser push subscription
+   */
+  async subscribePush(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['endpoint', 'keys']);
+      const { endpoint, keys } = req.body;
+
+      if (!endpoint || !keys || !keys.p256dh || !keys.auth) {
+        return respondWithError(res, 400, 'Subscription must include endpoint, keys.p256dh and keys.auth');
+      }
+
+      if (!/^https:\/\//.test(endpoint)) {
+        return respondWithError(res, 400, 'Subscription endpoint must be an HTTPS URL');
+      }
+
+      const result = await db.query(
+        `INSERT INTO push_subscriptions (user_id, endpoint, p256dh, auth, user_agent)
+         VALUES ($1, $2, $3, $4, $5)
+         ON CONFLICT (endpoint) DO UPDATE
+         SET user_id = EXCLUDED.user_id, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth,
+             user_agent = EXCLUDED.user_agent, updated_at = CURRENT_TIMESTAMP
+         RETURNING id, endpoint, created_at`,
+        [user.id, endpoint, keys.p256dh, keys.auth, req.get('User-Agent') || null]
+      );
+
+      return respondWithJSON(res, 201, result.rows[0]);
+
+    } catch (error) {
+      console.error('Subscribe push error:', error);
+      return respondWithError(res, 500, 'Failed to register push subscription', { cause: error });
+    }
+  }
+
+  /**
+   * Remove a browser push subscription
+   */
+  async unsubscribePush(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['endpoint']);
+      const { endpoint } = req.body;
+
+      if (!endpoint) {
+        return respondWithError(res, 400, 'Endpoint is required');
+      }
+
+      const result = await db.query(
+        'DELETE FROM push_subscriptions WHERE user_id = $1 AND endpoint = $2',
+        [user.id, endpoint]
+      );
+
+      if (result.rowCount === 0) {
+        return respondWithError(res, 404, 'Push subscription not found');
+      }
+
+      return respondWithJSON(res, 200, { message: 'Push subscription removed successfully' });
+
+    } catch (error) {
+      console.error('Unsubscribe push error:', error);
+      return respondWithError(res, 500, 'Failed to remove push subscription', { cause: error });
+    }
+  }
+}
+
+module.exports = new NotificationHandler();
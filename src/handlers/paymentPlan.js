@@ -0,0 +1,160 @@
+const db = require('../database/db');
+const { SelectQuery } = require('../database/query');
+const { respondWithError, respondWithJSON, validateRequiredFields, rejectUnknownFields, parsePagination } = require('../utils/response');
+const { getUserFromContext } = require('../middleware/auth');
+const { PaymentPlan } = require('../models');
+const { FREQUENCY_INTERVALS } = require('../utils/interest');
+
+const EXPECTED_PAYMENT_FILTERS = {
+  status: { column: 'ep.status', type: 'enum', values: ['pending', 'paid', 'missed'] },
+  from: { column: 'ep.due_date', type: 'date', op: 'gte' },
+  to: { column: 'ep.due_date', type: 'date', op: 'lte' }
+};
+
+class PaymentPlanHandler {
+  /**
+   * Map database row to PaymentPlan model
+   */
+  toPaymentPlan(row) {
+    return new PaymentPlan({
+      id: row.id,
+      loanId: row.loan_id,
+      userId: row.user_id,
+      amount: row.amount,
+      frequency: row.frequency,
+      startDate: row.start_date,
+      nextDueDate: row.next_due_date,
+      endDate: row.end_date,
+      active: row.active,
+      createdAt: row.created_at,
+      updatedAt: row.updated_at
+    });
+  }
+
+  /**
+   * Create recurring payment plan for a loan
+   */
+  async createPaymentPlan(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['amount', 'frequency', 'startDate', 'endDate']);
+      const { loanId } = req.params;
+      const { amount, frequency, startDate, endDate } = req.body;
+
+      validateRequiredFields(req.body, ['amount', 'frequency', 'startDate']);
+
+      if (amount <= 0) {
+        return respondWithError(res, 400, 'Amount must be greater than 0');
+      }
+
+      if (!Object.keys(FREQUENCY_INTERVALS).includes(frequency)) {
+        return respondWithError(res, 400, `Frequency must be one of: ${Object.keys(FREQUENCY_INTERVALS).join(', ')}`);
+      }
+
+      if (endDate && new Date(endDate) < new Date(startDate)) {
+        return respondWithError(res, 400, 'End date must be after start date');
+      }
+
+      const loanCheck = await db.query(
+        'SELECT id FROM loans WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+        [loanId, user.id]
+      );
+
+      if (loanCheck.rows.length === 0) {
+        return respondWithError(res, 404, 'Loan not found');
+      }
+
+      const result = await db.query(
+        `INSERT INTO payment_plans (loan_id, user_id, amount, frequency, start_date, next_due_date, end_date)
+         VALUES ($1, $2, $3, $4, $5, $5, $6)
+         RETURNING *`,
+        [loanId, user.id, amount, frequency, startDate, endDate || null]
+      );
+
+      return respondWithJSON(res, 201, this.toPaymentPlan(result.rows[0]));
+
+    } catch (error) {
+      console.error('Create payment plan error:', error);
+      return respondWithError(res, 500, 'Failed to create payment plan', { cause: error });
+    }
+  }
+
+  /**
+   * Get payment plans for a loan
+   */
+  async getPaymentPlans(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { loanId } = req.params;
+
+      const result = await db.query(
+        'SELECT * FROM payment_plans WHERE loan_id = $1 AND user_id = $2 ORDER BY created_at DESC',
+        [loanId, user.id]
+      );
+
+      return respondWithJSON(res, 200, result.rows.map(row => this.toPaymentPlan(row)));
+
+    } catch (error) {
+      console.error('Get payment plans error:', error);
+      return respondWithError(res, 500, 'Failed to get payment plans', { cause: error });
+    }
+  }
+
+  /**
+   * Deactivate payment plan
+   */
+  async deletePaymentPlan(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const result = await db.query(
+        `UPDATE payment_plans SET active = false, updated_at = CURRENT_TIMESTAMP
+         WHERE id = $1 AND user_id = $2
+         RETURNING *`,
+        [id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Payment plan not found');
+      }
+
+      return respondWithJSON(res, 200, { message: 'Payment plan deactivated successfully' });
+
+    } catch (error) {
+      console.error('Delete payment plan error:', error);
+      return respondWithError(res, 500, 'Failed to delete payment plan', { cause: error });
+    }
+  }
+
+  /**
+   * Get expected payments for a loan
+   */
+  async getExpectedPayments(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { loanId } = req.params;
+      const { limit, offset } = parsePagination(req.query);
+
+      const result = await new SelectQuery(`
+        SELECT ep.*
+        FROM expected_payments ep
+        JOIN payment_plans pp ON ep.plan_id = pp.id
+        WHERE ep.loan_id = ? AND pp.user_id = ?
+      `, loanId, user.id)
+        .filter(EXPECTED_PAYMENT_FILTERS, req.query)
+        .orderBy('ep.due_date DESC')
+        .limit(limit)
+        .offset(offset)
+        .run();
+
+      return respondWithJSON(res, 200, result.rows);
+
+    } catch (error) {
+      console.error('Get expected payments error:', error);
+      return respondWithError(res, 500, 'Failed to get expected payments', { cause: error });
+    }
+  }
+}
+
+module.exports = new PaymentPlanHandler();
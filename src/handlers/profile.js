@@ -1,7 +1,12 @@
 const db = require('../database/db');
-const { respondWithError, respondWithJSON, validateRequiredFields } = require('../utils/response');
+const { respondWithError, respondWithJSON, validateRequiredFields, rejectUnknownFields } = require('../utils/response');
 const { getUserFromContext } = require('../middleware/auth');
 const { hashPassword, verifyPassword } = require('../utils/hash');
+const { validatePassword } = require('../utils/password');
+const notificationService = require('../notifications');
+const { NOTIFICATION_EVENTS } = require('../notifications/templates');
+const { CURRENCIES, isSupportedCurrency, isValidLocale } = require('../utils/currency');
+const { CALENDARS, isValidTimezone } = require('../utils/dates');
 
 class ProfileHandler {
   /**
@@ -13,7 +18,7 @@ class ProfileHandler {
       return respondWithJSON(res, 200, user.toJSON());
     } catch (error) {
       console.error('Get profile error:', error);
-      return respondWithError(res, 500, 'Failed to get profile');
+      return respondWithError(res, 500, 'Failed to get profile', { cause: error });
     }
   }
 
@@ -23,14 +28,48 @@ class ProfileHandler {
   async updateProfile(req, res) {
     try {
       const user = getUserFromContext(req);
-      const { fullName, phone, address, email } = req.body;
+      rejectUnknownFields(req.body, ['fullName', 'phone', 'address', 'email', 'emailNotifications', 'smsBorrowerReminders', 'preferredCurrency', 'calendar', 'timezone', 'locale']);
+      const { fullName, phone, address, email, emailNotifications, smsBorrowerReminders, preferredCurrency, calendar, timezone, locale } = req.body;
+
+      if (emailNotifications !== undefined && typeof emailNotifications !== 'boolean') {
+        return respondWithError(res, 400, 'emailNotifications must be a boolean');
+      }
+
+      if (smsBorrowerReminders !== undefined && typeof smsBorrowerReminders !== 'boolean') {
+        return respondWithError(res, 400, 'smsBorrowerReminders must be a boolean');
+      }
+
+      // null clears the preference; omitting it keeps the current one
+      if (preferredCurrency !== undefined && preferredCurrency !== null && !isSupportedCurrency(preferredCurrency)) {
+        return respondWithError(res, 400, `preferredCurrency must be one of: ${Object.keys(CURRENCIES).join(', ')}`);
+      }
+
+      if (calendar !== undefined && calendar !== null && !CALENDARS.includes(calendar)) {
+        return respondWithError(res, 400, `calendar must be one of: ${CALENDARS.join(', ')}`);
+      }
+
+      if (timezone !== undefined && timezone !== null && !isValidTimezone(timezone)) {
+        return respondWithError(res, 400, 'timezone must be an IANA time zone such as Asia/Bangkok');
+      }
+
+      if (locale !== undefined && locale !== null && !isValidLocale(locale)) {
+        return respondWithError(res, 400, 'locale must be a supported language tag such as th-TH');
+      }
 
       const result = await db.query(
         `UPDATE users 
-         SET full_name = $1, phone = $2, address = $3, email = $4, updated_at = CURRENT_TIMESTAMP
-         WHERE id = $5
+         SET full_name = $1, phone = $2, address = $3, email = $4,
+             email_notifications = COALESCE($5, email_notifications),
+             sms_borrower_reminders = COALESCE($6, sms_borrower_reminders),
+             preferred_currency = CASE WHEN $7 THEN $8 ELSE preferred_currency END,
+             calendar = CASE WHEN $9 THEN $10 ELSE calendar END,
+             timezone = CASE WHEN $11 THEN $12 ELSE timezone END,
+             locale = CASE WHEN $13 THEN $14 ELSE locale END, updated_at = CURRENT_TIMESTAMP
+         WHERE id = $15
          RETURNING *`,
-        [fullName, phone, address, email, user.id]
+        [fullName, phone, address, email, emailNotifications, smsBorrowerReminders,
+          preferredCurrency !== undefined, preferredCurrency || null, calendar !== undefined, calendar || null,
+          timezone !== undefined, timezone || null, locale !== undefined, locale || null, user.id]
       );
 
       if (result.rows.length === 0) {
@@ -46,6 +85,12 @@ class ProfileHandler {
         fullName: updatedUserData.full_name,
         phone: updatedUserData.phone,
         address: updatedUserData.address,
+        emailNotifications: updatedUserData.email_notifications,
+        smsBorrowerReminders: updatedUserData.sms_borrower_reminders,
+        preferredCurrency: updatedUserData.preferred_currency,
+        calendar: updatedUserData.calendar,
+        timezone: updatedUserData.timezone,
+        locale: updatedUserData.locale,
         createdAt: updatedUserData.created_at,
         updatedAt: updatedUserData.updated_at
       });
@@ -54,7 +99,7 @@ class ProfileHandler {
 
     } catch (error) {
       console.error('Update profile error:', error);
-      return respondWithError(res, 500, 'Failed to update profile');
+      return respondWithError(res, 500, 'Failed to update profile', { cause: error });
     }
   }
 
@@ -64,14 +109,11 @@ class ProfileHandler {
   async changePassword(req, res) {
     try {
       const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['currentPassword', 'newPassword']);
       const { currentPassword, newPassword } = req.body;
 
       validateRequiredFields(req.body, ['currentPassword', 'newPassword']);
 
-      if (newPassword.length < 6) {
-        return respondWithError(res, 400, 'New password must be at least 6 characters long');
-      }
-
       // Get current password hash
       const result = await db.query(
         'SELECT password_hash FROM users WHERE id = $1',
@@ -88,6 +130,8 @@ class ProfileHandler {
         return respondWithError(res, 400, 'Current password is incorrect');
       }
 
+      await validatePassword(newPassword, 'newPassword');
+
       // Hash new password
       const newPasswordHash = await hashPassword(newPassword);
 
@@ -101,7 +145,125 @@ class ProfileHandler {
 
     } catch (error) {
       console.error('Change password error:', error);
-      return respondWithError(res, 500, 'Failed to change password');
+      return respondWithError(res, 500, 'Failed to change password', { cause: error });
+    }
+  }
+
+  /**
+   * Soft-delete the account; its live loans are deleted with it and come back if the account is restored
+   */
+  async deleteAccount(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['password']);
+      const { password } = req.body;
+
+      validateRequiredFields(req.body, ['password']);
+
+      const result = await db.query(
+        'SELECT password_hash FROM users WHERE id = $1',
+        [user.id]
+      );
+
+      const isValidPassword = await verifyPassword(password, result.rows[0].password_hash);
+      if (!isValidPassword) {
+        return respondWithError(res, 400, 'Password is incorrect');
+      }
+
+      // CURRENT_TIMESTAMP is fixed per transaction, so the loans share the account's deleted_at
+      await db.transaction(async client => {
+        await client.query(
+          'UPDATE users SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $1',
+          [user.id]
+        );
+        await client.query(
+          'UPDATE loans SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND deleted_at IS NULL',
+          [user.id]
+        );
+      });
+
+      return respondWithJSON(res, 200, { message: 'Account deleted successfully' });
+
+    } catch (error) {
+      console.error('Delete account error:', error);
+      return respondWithError(res, 500, 'Failed to delete account', { cause: error });
+    }
+  }
+
+  /**
+   * Get notification preferences per event and channel
+   */
+  async getNotificationPreferences(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const preferences = await notificationService.getPreferences(user.id);
+
+      return respondWithJSON(res, 200, {
+        events: NOTIFICATION_EVENTS,
+        channels: notificationService.getConfigurableChannels(),
+        preferences
+      });
+
+    } catch (error) {
+      console.error('Get notification preferences error:', error);
+      return respondWithError(res, 500, 'Failed to get notification preferences', { cause: error });
+    }
+  }
+
+  /**
+   * Update notification preferences, e.g. { "preferences": { "loan.overdue": { "email": true, "line": false } } }
+   */
+  async updateNotificationPreferences(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['preferences']);
+      const { preferences } = req.body;
+      const channels = notificationService.getConfigurableChannels();
+
+      if (!preferences || typeof preferences !== 'object' || Array.isArray(preferences)) {
+        return respondWithError(res, 400, 'preferences must be an object keyed by event');
+      }
+
+      const updates = [];
+      for (const [event, channelSettings] of Object.entries(preferences)) {
+        if (!NOTIFICATION_EVENTS.includes(event)) {
+          return respondWithError(res, 400, `Unknown event: ${event}`);
+        }
+        if (!channelSettings || typeof channelSettings !== 'object') {
+          return respondWithError(res, 400, `Preferences for ${event} must be an object keyed by channel`);
+        }
+        for (const [channel, enabled] of Object.entries(channelSettings)) {
+          if (!channels.includes(channel)) {
+            return respondWithError(res, 400, `Unknown channel: ${channel}`);
+          }
+          if (typeof enabled !== 'boolean') {
+            return respondWithError(res, 400, `Preference for ${event}/${channel} must be a boolean`);
+          }
+          updates.push([event, channel, enabled]);
+        }
+      }
+
+      await db.transaction(async client => {
+        for (const [event, channel, enabled] of updates) {
+          await client.query(
+            `INSERT INTO notification_preferences (user_id, event, channel, enabled)
+             VALUES ($1, $2, $3, $4)
+             ON CONFLICT (user_id, event, channel)
+             DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = CURRENT_TIMESTAMP`,
+            [user.id, event, channel, enabled]
+          );
+        }
+      });
+
+      return respondWithJSON(res, 200, {
+        events: NOTIFICATION_EVENTS,
+        channels,
+        preferences: await notificationService.getPreferences(user.id)
+      });
+
+    } catch (error) {
+      console.error('Update notification preferences error:', error);
+      return respondWithError(res, 500, 'Failed to update notification preferences', { cause: error });
     }
   }
 }
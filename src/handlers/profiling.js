@@ -0,0 +1,110 @@
+const inspector = require('inspector');
+const v8 = require('v8');
+const { respondWithError } = require('../utils/response');
+const adminHandler = require('./admin');
+
+const DEFAULT_PROFILE_SECONDS = 10;
+const MAX_PROFILE_SECONDS = 60;
+
+/**
+ * Promisified inspector session call
+ */
+function post(session, method, params = {}) {
+  return new Promise((resolve, reject) => {
+    session.post(method, params, (error, result) => (error ? reject(error) : resolve(result)));
+  });
+}
+
+class ProfilingHandler {
+  constructor() {
+    // One capture at a time: profiling itself costs CPU and a heap snapshot pauses the process
+    this.busy = false;
+  }
+
+  /**
+   * Admin auth plus the single-capture guard; returns a release callback, or null when the request was answered
+   */
+  acquire(req, res) {
+    if (!adminHandler.authorize(req, res)) {
+      return null;
+    }
+    if (this.busy) {
+      respondWithError(res, 409, 'Another profile is being captured', { code: 'PROFILE_IN_PROGRESS' });
+      return null;
+    }
+    this.busy = true;
+    return () => {
+      this.busy = false;
+    };
+  }
+
+  /**
+   * Record a CPU profile for ?seconds=N (default 10, max 60); open the .cpuprofile in Chrome DevTools
+   */
+  async getCpuProfile(req, res) {
+    const release = this.acquire(req, res);
+    if (!release) {
+      return;
+    }
+
+    const seconds = req.query.seconds ? Number(req.query.seconds) : DEFAULT_PROFILE_SECONDS;
+    if (!Number.isInteger(seconds) || seconds < 1 || seconds > MAX_PROFILE_SECONDS) {
+      release();
+      return respondWithError(res, 400, `seconds must be an integer between 1 and ${MAX_PROFILE_SECONDS}`);
+    }
+
+    const session = new inspector.Session();
+    try {
+      session.connect();
+      await post(session, 'Profiler.enable');
+      await post(session, 'Profiler.start');
+      await new Promise(resolve => setTimeout(resolve, seconds * 1000));
+      const { profile } = await post(session, 'Profiler.stop');
+
+      res.setHeader('Content-Type', 'application/json');
+      res.setHeader('Content-Disposition', `attachment; filename="cpu-${Date.now()}.cpuprofile"`);
+      res.setHeader('Cache-Control', 'no-store');
+      return res.send(JSON.stringify(profile));
+
+    } catch (error) {
+      console.error('CPU profile error:', error);
+      return respondWithError(res, 500, 'Failed to capture CPU profile', { cause: error });
+    } finally {
+      session.disconnect();
+      release();
+    }
+  }
+
+  /**
+   * Stream a V8 heap snapshot; open the .heapsnapshot in Chrome DevTools (Memory tab)
+   */
+  async getHeapSnapshot(req, res) {
+    const release = this.acquire(req, res);
+    if (!release) {
+      return;
+    }
+
+    try {
+      const snapshot = v8.getHeapSnapshot();
+
+      res.setHeader('Content-Type', 'application/json');
+      res.setHeader('Content-Disposition', `attachment; filename="heap-${Date.now()}.heapsnapshot"`);
+      // no-transform: stream the snapshot as is rather than through the compression middleware
+      res.setHeader('Cache-Control', 'no-store, no-transform');
+      snapshot.on('error', error => {
+        console.error('Heap snapshot stream error:', error);
+        res.destroy(error);
+      });
+      snapshot.on('close', release);
+      res.on('close', () => snapshot.destroy());
+      snapshot.pipe(res);
+
+    } catch (error) {
+      release();
+      console.error('Heap snapshot error:', error);
+      return respondWithError(res, 500, 'Failed to capture heap snapshot', { cause: error });
+    }
+  }
+}
+
+module.exports = new ProfilingHandler();
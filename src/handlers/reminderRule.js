@@ -0,0 +1,199 @@
+const db = require('../database/db');
+const { SelectQuery } = require('../database/query');
+const { respondWithError, respondWithJSON, validateRequiredFields, rejectUnknownFields } = require('../utils/response');
+const { getUserFromContext } = require('../middleware/auth');
+const { ReminderRule } = require('../models');
+
+const RULE_TYPES = ['before_due', 'overdue'];
+const MAX_DAYS = 365;
+
+class ReminderRuleHandler {
+  /**
+   * Map database row to ReminderRule model
+   */
+  toReminderRule(row) {
+    return new ReminderRule({
+      id: row.id,
+      userId: row.user_id,
+      loanId: row.loan_id,
+      ruleType: row.rule_type,
+      daysBefore: row.days_before,
+      repeatEveryDays: row.repeat_every_days,
+      active: row.active,
+      createdAt: row.created_at,
+      updatedAt: row.updated_at
+    });
+  }
+
+  /**
+   * Validate rule settings for a rule type; returns an error message or null
+   */
+  validateRule(ruleType, daysBefore, repeatEveryDays) {
+    const isValidDays = days => Number.isInteger(days) && days > 0 && days <= MAX_DAYS;
+
+    if (ruleType === 'before_due') {
+      if (!Array.isArray(daysBefore) || daysBefore.length === 0 || !daysBefore.every(isValidDays)) {
+        return `daysBefore must be a non-empty array of whole days between 1 and ${MAX_DAYS}`;
+      }
+    }
+
+    if (ruleType === 'overdue' && repeatEveryDays !== null && repeatEveryDays !== undefined && !isValidDays(repeatEveryDays)) {
+      return `repeatEveryDays must be a whole number of days between 1 and ${MAX_DAYS}`;
+    }
+
+    return null;
+  }
+
+  /**
+   * Get reminder rules for the current user
+   */
+  async getReminderRules(req, res) {
+    try {
+      const user = getUserFromContext(req);
+
+      const result = await new SelectQuery('SELECT * FROM reminder_rules WHERE user_id = ?', user.id)
+        .filter({ loan_id: { column: 'loan_id', type: 'uuid' } }, req.query)
+        .orderBy('created_at DESC')
+        .run();
+
+      return respondWithJSON(res, 200, result.rows.map(row => this.toReminderRule(row)));
+
+    } catch (error) {
+      console.error('Get reminder rules error:', error);
+      return respondWithError(res, 500, 'Failed to get reminder rules', { cause: error });
+    }
+  }
+
+  /**
+   * Create reminder rule
+   */
+  async createReminderRule(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['loanId', 'ruleType', 'daysBefore', 'repeatEveryDays']);
+      const { ruleType, daysBefore, repeatEveryDays, loanId } = req.body;
+
+      validateRequiredFields(req.body, ['ruleType']);
+
+      if (!RULE_TYPES.includes(ruleType)) {
+        return respondWithError(res, 400, `Rule type must be one of: ${RULE_TYPES.join(', ')}`);
+      }
+
+      const validationError = this.validateRule(ruleType, daysBefore, repeatEveryDays);
+      if (validationError) {
+        return respondWithError(res, 400, validationError);
+      }
+
+      if (loanId) {
+        const loanCheck = await db.query(
+          'SELECT id FROM loans WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+          [loanId, user.id]
+        );
+
+        if (loanCheck.rows.length === 0) {
+          return respondWithError(res, 404, 'Loan not found');
+        }
+      }
+
+      const result = await db.query(
+        `INSERT INTO reminder_rules (user_id, loan_id, rule_type, days_before, repeat_every_days)
+         VALUES ($1, $2, $3, $4, $5)
+         RETURNING *`,
+        [
+          user.id,
+          loanId || null,
+          ruleType,
+          ruleType === 'before_due' ? [...new Set(daysBefore)] : null,
+          ruleType === 'overdue' ? repeatEveryDays || null : null
+        ]
+      );
+
+      return respondWithJSON(res, 201, this.toReminderRule(result.rows[0]));
+
+    } catch (error) {
+      console.error('Create reminder rule error:', error);
+      return respondWithError(res, 500, 'Failed to create reminder rule', { cause: error });
+    }
+  }
+
+  /**
+   * Update reminder rule settings or toggle it on/off
+   */
+  async updateReminderRule(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['active', 'daysBefore', 'repeatEveryDays']);
+      const { id } = req.params;
+      const { daysBefore, repeatEveryDays, active } = req.body;
+
+      if (active !== undefined && typeof active !== 'boolean') {
+        return respondWithError(res, 400, 'active must be a boolean');
+      }
+
+      const existing = await db.query(
+        'SELECT * FROM reminder_rules WHERE id = $1 AND user_id = $2',
+        [id, user.id]
+      );
+
+      if (existing.rows.length === 0) {
+        return respondWithError(res, 404, 'Reminder rule not found');
+      }
+
+      const rule = existing.rows[0];
+      const nextDaysBefore = daysBefore !== undefined ? daysBefore : rule.days_before;
+      const nextRepeatEveryDays = repeatEveryDays !== undefined ? repeatEveryDays : rule.repeat_every_days;
+
+      const validationError = this.validateRule(rule.rule_type, nextDaysBefore, nextRepeatEveryDays);
+      if (validationError) {
+        return respondWithError(res, 400, validationError);
+      }
+
+      const result = await db.query(
+        `UPDATE reminder_rules
+         SET days_before = $1, repeat_every_days = $2, active = COALESCE($3, active), updated_at = CURRENT_TIMESTAMP
+         WHERE id = $4 AND user_id = $5
+         RETURNING *`,
+        [
+          rule.rule_type === 'before_due' ? [...new Set(nextDaysBefore)] : null,
+          rule.rule_type === 'overdue' ? nextRepeatEveryDays || null : null,
+          active,
+          id,
+          user.id
+        ]
+      );
+
+      return respondWithJSON(res, 200, this.toReminderRule(result.rows[0]));
+
+    } catch (error) {
+      console.error('Update reminder rule error:', error);
+      return respondWithError(res, 500, 'Failed to update reminder rule', { cause: error });
+    }
+  }
+
+  /**
+   * Delete reminder rule
+   */
+  async deleteReminderRule(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const result = await db.query(
+        'DELETE FROM reminder_rules WHERE id = $1 AND user_id = $2 RETURNING id',
+        [id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Reminder rule not found');
+      }
+
+      return respondWithJSON(res, 200, { message: 'Reminder rule deleted successfully' });
+
+    } catch (error) {
+      console.error('Delete reminder rule error:', error);
+      return respondWithError(res, 500, 'Failed to delete reminder rule', { cause: error });
+    }
+  }
+}
+
+module.exports = new ReminderRuleHandler();
@@ -0,0 +1,232 @@
+const db = require('../database/db');
+const { respondWithError, respondWithJSON, validateRequiredFields, rejectUnknownFields } = require('../utils/response');
+const { getUserFromContext } = require('../middleware/auth');
+const { SavedReport } = require('../models');
+const { REPORT_SCHEDULES, validateReportDefinition, runReport } = require('../utils/reports');
+const { formatDate, formatDateFields, userCalendar } = require('../utils/dates');
+
+class ReportHandler {
+  /**
+   * Map database row to SavedReport model
+   */
+  toSavedReport(row) {
+    return new SavedReport({
+      id: row.id,
+      userId: row.user_id,
+      name: row.name,
+      definition: row.definition,
+      schedule: row.schedule,
+      nextRunAt: row.next_run_at,
+      lastRunAt: row.last_run_at,
+      createdAt: row.created_at,
+      updatedAt: row.updated_at
+    });
+  }
+
+  /**
+   * Validate schedule value; returns an error message or null
+   */
+  validateSchedule(schedule) {
+    if (schedule && !REPORT_SCHEDULES[schedule]) {
+      return `Schedule must be one of: ${Object.keys(REPORT_SCHEDULES).join(', ')}`;
+    }
+    return null;
+  }
+
+  /**
+   * Get saved reports for user
+   */
+  async getReports(req, res) {
+    try {
+      const user = getUserFromContext(req);
+
+      const result = await db.query(
+        'SELECT * FROM saved_reports WHERE user_id = $1 ORDER BY created_at DESC',
+        [user.id]
+      );
+
+      return respondWithJSON(res, 200, result.rows.map(row => this.toSavedReport(row)));
+
+    } catch (error) {
+      console.error('Get reports error:', error);
+      return respondWithError(res, 500, 'Failed to get reports', { cause: error });
+    }
+  }
+
+  /**
+   * Save report definition, optionally scheduled for email delivery
+   */
+  async createReport(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['name', 'definition', 'schedule']);
+      const { name, definition, schedule } = req.body;
+
+      validateRequiredFields(req.body, ['name', 'definition']);
+
+      const validationError = validateReportDefinition(definition) || this.validateSchedule(schedule);
+      if (validationError) {
+        return respondWithError(res, 400, validationError);
+      }
+
+      const result = await db.query(
+        `INSERT INTO saved_reports (user_id, name, definition, schedule, next_run_at)
+         VALUES ($1, $2, $3, $4::varchar,
+           CASE WHEN $4::varchar IS NULL THEN NULL ELSE CURRENT_TIMESTAMP + $5::interval END)
+         RETURNING *`,
+        [user.id, name, JSON.stringify(definition), schedule || null, schedule ? REPORT_SCHEDULES[schedule] : null]
+      );
+
+      return respondWithJSON(res, 201, this.toSavedReport(result.rows[0]));
+
+    } catch (error) {
+      console.error('Create report error:', error);
+      return respondWithError(res, 500, 'Failed to create report', { cause: error });
+    }
+  }
+
+  /**
+   * Get saved report by ID
+   */
+  async getReport(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const result = await db.query(
+        'SELECT * FROM saved_reports WHERE id = $1 AND user_id = $2',
+        [id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Report not found');
+      }
+
+      return respondWithJSON(res, 200, this.toSavedReport(result.rows[0]));
+
+    } catch (error) {
+      console.error('Get report error:', error);
+      return respondWithError(res, 500, 'Failed to get report', { cause: error });
+    }
+  }
+
+  /**
+   * Update saved report name, definition or schedule (null schedule disables delivery)
+   */
+  async updateReport(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['name', 'definition', 'schedule']);
+      const { id } = req.params;
+      const { name, definition, schedule } = req.body;
+
+      if (definition !== undefined) {
+        const definitionError = validateReportDefinition(definition);
+        if (definitionError) {
+          return respondWithError(res, 400, definitionError);
+        }
+      }
+
+      const scheduleError = this.validateSchedule(schedule);
+      if (scheduleError) {
+        return respondWithError(res, 400, scheduleError);
+      }
+
+      const result = await db.query(
+        `UPDATE saved_reports
+         SET name = COALESCE($1, name),
+             definition = COALESCE($2, definition),
+             schedule = CASE WHEN $3::boolean THEN $4::varchar ELSE schedule END,
+             next_run_at = CASE
+               WHEN NOT $3::boolean THEN next_run_at
+               WHEN $4::varchar IS NULL THEN NULL
+               ELSE CURRENT_TIMESTAMP + $5::interval
+             END,
+             updated_at = CURRENT_TIMESTAMP
+         WHERE id = $6 AND user_id = $7
+         RETURNING *`,
+        [
+          name,
+          definition !== undefined ? JSON.stringify(definition) : null,
+          schedule !== undefined,
+          schedule || null,
+          schedule ? REPORT_SCHEDULES[schedule] : null,
+          id,
+          user.id
+        ]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Report not found');
+      }
+
+      return respondWithJSON(res, 200, this.toSavedReport(result.rows[0]));
+
+    } catch (error) {
+      console.error('Update report error:', error);
+      return respondWithError(res, 500, 'Failed to update report', { cause: error });
+    }
+  }
+
+  /**
+   * Delete saved report
+   */
+  async deleteReport(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const result = await db.query(
+        'DELETE FROM saved_reports WHERE id = $1 AND user_id = $2 RETURNING id',
+        [id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Report not found');
+      }
+
+      return respondWithJSON(res, 200, { message: 'Report deleted successfully' });
+
+    } catch (error) {
+      console.error('Delete report error:', error);
+      return respondWithError(res, 500, 'Failed to delete report', { cause: error });
+    }
+  }
+
+  /**
+   * Run saved report and return its results
+   */
+  async runSavedReport(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const reportResult = await db.query(
+        'SELECT * FROM saved_reports WHERE id = $1 AND user_id = $2',
+        [id, user.id]
+      );
+
+      if (reportResult.rows.length === 0) {
+        return respondWithError(res, 404, 'Report not found');
+      }
+
+      const report = reportResult.rows[0];
+      const result = await runReport(db.reader, user.id, report.definition);
+
+      const calendar = userCalendar(user);
+      return respondWithJSON(res, 200, {
+        report: this.toSavedReport(report),
+        generatedAt: new Date(),
+        ...result,
+        range: { ...result.range, from: formatDate(result.range.from, calendar), to: formatDate(result.range.to, calendar) },
+        rows: formatDateFields(result.rows, calendar)
+      });
+
+    } catch (error) {
+      console.error('Run report error:', error);
+      return respondWithError(res, 500, 'Failed to run report', { cause: error });
+    }
+  }
+}
+
+module.exports = new ReportHandler();
@@ -1,63 +1,148 @@
 const db = require('../database/db');
-const { respondWithError, respondWithJSON, validateRequiredFields, parsePagination } = require('../utils/response');
+const { SelectQuery } = require('../database/query');
+const { respondWithError, respondWithJSON, validateRequiredFields, rejectUnknownFields, parsePagination, parseIncludeDeleted, parseFields, selectFields, encodeCursor, parseCursorPagination, ValidationError } = require('../utils/response');
 const { getUserFromContext } = require('../middleware/auth');
+const { wantsJSONAPI, transactionResource, respondWithJSONAPI } = require('../utils/jsonapi');
 const { Transaction } = require('../models');
+const { parseCSVRecords, applyColumnMapping } = require('../utils/csv');
+const { IMPORT_TARGETS } = require('../utils/imports');
+const notificationService = require('../notifications');
+const loanService = require('../services/loan');
+const { parseDate, parseDateFields, formatDateFields, userCalendar } = require('../utils/dates');
+const { userLocale } = require('../utils/currency');
+
+const TRANSACTION_TYPES = ['payment', 'interest', 'fee', 'adjustment'];
+const TRANSACTION_STATUSES = ['pending', 'confirmed', 'rejected'];
+const MAX_IMPORT_ROWS = 5000;
+const MAX_BATCH_SIZE = 500;
+
+// Query-string filters and sort fields accepted by the transaction lists
+const TRANSACTION_DATE = 'COALESCE(t.transaction_date, t.created_at::date)';
+const TRANSACTION_FILTERS = {
+  loanId: { column: 't.loan_id', type: 'uuid' },
+  transactionType: { column: 't.transaction_type', type: 'enum', values: TRANSACTION_TYPES },
+  status: { column: 't.status', type: 'enum', values: TRANSACTION_STATUSES },
+  from: { column: TRANSACTION_DATE, type: 'date', op: 'gte' },
+  to: { column: TRANSACTION_DATE, type: 'date', op: 'lte' },
+  min_amount: { column: 't.amount', type: 'number', op: 'gte' },
+  max_amount: { column: 't.amount', type: 'number', op: 'lte' },
+  amount: { column: 't.amount', type: 'number' },
+  search: { column: ['t.description', 'l.borrower_name'], type: 'search' }
+};
+const TRANSACTION_SORTS = {
+  created_at: 't.created_at',
+  transaction_date: TRANSACTION_DATE,
+  amount: 't.amount',
+  borrower_name: 'l.borrower_name'
+};
+
+// Fields that may be changed through batch update (request field -> column)
+const BATCH_UPDATE_FIELDS = {
+  transactionType: 'transaction_type',
+  transactionDate: 'transaction_date',
+  paymentDate: 'payment_date',
+  description: 'description'
+};
 
 class TransactionHandler {
   /**
    * Get all transactions for user
    */
   async getTransactions(req, res) {
+    // ?cursor= (empty for the first page) switches to keyset pagination, avoiding OFFSET scans on large accounts
+    if (req.query.cursor !== undefined) {
+      return this.getTransactionsV2(req, res);
+    }
+
     try {
       const user = getUserFromContext(req);
       const { page, limit, offset } = parsePagination(req.query);
-      const { loanId, transactionType } = req.query;
-
-      let query = `
+      const fields = parseFields(req.query);
+      const includeDeleted = parseIncludeDeleted(req.query);
+      const query = new SelectQuery(`
         SELECT t.*, l.borrower_name, l.amount as loan_amount
         FROM transactions t
         JOIN loans l ON t.loan_id = l.id
-        WHERE t.user_id = $1
-      `;
-      let params = [user.id];
-      let paramCount = 1;
+        WHERE t.user_id = ? AND l.deleted_at IS NULL
+      `, user.id)
+        .whereIf(!includeDeleted, 't.deleted_at IS NULL')
+        .filter(TRANSACTION_FILTERS, req.query)
+        .sort(TRANSACTION_SORTS, req.query.sort, 't.created_at DESC, t.id DESC');
 
-      if (loanId) {
-        paramCount++;
-        query += ` AND t.loan_id = $${paramCount}`;
-        params.push(loanId);
+      if (limit) {
+        query.limit(limit);
+        if (offset) {
+          query.offset(offset);
+        }
       }
 
-      if (transactionType) {
-        paramCount++;
-        query += ` AND t.transaction_type = $${paramCount}`;
-        params.push(transactionType);
+      const result = await query.run();
+
+      if (wantsJSONAPI(req)) {
+        return respondWithJSONAPI(res, 200, {
+          data: formatDateFields(result.rows, userCalendar(user)).map(transactionResource),
+          meta: { pagination: { page, limit, total: result.rowCount } }
+        });
       }
 
-      query += ' ORDER BY t.created_at DESC';
+      return respondWithJSON(res, 200, {
+        transactions: selectFields(formatDateFields(result.rows, userCalendar(user)), fields, result.fields.map(field => field.name)),
+        pagination: { page, limit, total: result.rowCount }
+      });
 
-      if (limit) {
-        paramCount++;
-        query += ` LIMIT $${paramCount}`;
-        params.push(limit);
+    } catch (error) {
+      console.error('Get transactions error:', error);
+      return respondWithError(res, 500, 'Failed to get transactions', { cause: error });
+    }
+  }
 
-        if (offset) {
-          paramCount++;
-          query += ` OFFSET $${paramCount}`;
-          params.push(offset);
-        }
+  /**
+   * Get transactions for user with cursor pagination (API v2)
+   */
+  async getTransactionsV2(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { limit, after, error } = parseCursorPagination(req.query);
+      const fields = parseFields(req.query);
+      const includeDeleted = parseIncludeDeleted(req.query);
+      if (error) {
+        return respondWithError(res, 400, error);
       }
 
-      const result = await db.query(query, params);
+      const query = new SelectQuery(`
+        SELECT t.*, t.created_at::text as cursor_created_at, l.borrower_name, l.amount as loan_amount
+        FROM transactions t
+        JOIN loans l ON t.loan_id = l.id
+        WHERE t.user_id = ? AND l.deleted_at IS NULL
+      `, user.id)
+        .whereIf(!includeDeleted, 't.deleted_at IS NULL')
+        .filter(TRANSACTION_FILTERS, req.query)
+        // Keyset condition keeps pages stable while new transactions are added
+        .whereIf(after, '(t.created_at, t.id) < (?::timestamptz, ?::uuid)', after && after.createdAt, after && after.id)
+        .orderBy('t.created_at DESC, t.id DESC')
+        .limit(limit + 1);
+
+      const result = await query.run();
+      const rows = result.rows.slice(0, limit);
+      const last = rows[rows.length - 1];
+      const hasMore = result.rows.length > limit;
 
       return respondWithJSON(res, 200, {
-        transactions: result.rows,
-        pagination: { page, limit, total: result.rowCount }
+        transactions: selectFields(
+          formatDateFields(rows.map(({ cursor_created_at, ...transaction }) => transaction), userCalendar(user)),
+          fields,
+          result.fields.map(field => field.name).filter(name => name !== 'cursor_created_at')
+        ),
+        pagination: {
+          limit,
+          hasMore,
+          nextCursor: hasMore ? encodeCursor(last.cursor_created_at, last.id) : null
+        }
       });
 
     } catch (error) {
-      console.error('Get transactions error:', error);
-      return respondWithError(res, 500, 'Failed to get transactions');
+      console.error('Get transactions v2 error:', error);
+      return respondWithError(res, 500, 'Failed to get transactions', { cause: error });
     }
   }
 
@@ -67,22 +152,34 @@ class TransactionHandler {
   async createTransaction(req, res) {
     try {
       const user = getUserFromContext(req);
-      const { loanId, amount, transactionType, transactionDate, description } = req.body;
+      rejectUnknownFields(req.body, ['loanId', 'amount', 'currency', 'transactionType', 'transactionDate', 'description', 'status']);
+      // currency is optional; when given it must match the loan's
+      const { loanId, amount, currency, transactionType, description } = req.body;
+      const status = req.body.status || 'confirmed';
 
       validateRequiredFields(req.body, ['loanId', 'amount', 'transactionType', 'transactionDate']);
 
-      if (amount <= 0) {
-        return respondWithError(res, 400, 'Amount must be greater than 0');
+      const { transactionDate, error: dateError } = parseDateFields(req.body, ['transactionDate']);
+      if (dateError) {
+        return respondWithError(res, 400, dateError);
+      }
+
+      if (!['pending', 'confirmed'].includes(status)) {
+        return respondWithError(res, 400, 'Status must be one of: pending, confirmed');
+      }
+
+      if (!TRANSACTION_TYPES.includes(transactionType)) {
+        return respondWithError(res, 400, `Transaction type must be one of: ${TRANSACTION_TYPES.join(', ')}`);
       }
 
-      const validTypes = ['payment', 'interest', 'fee', 'adjustment'];
-      if (!validTypes.includes(transactionType)) {
-        return respondWithError(res, 400, `Transaction type must be one of: ${validTypes.join(', ')}`);
+      // Adjustments are signed; every other entry type must be positive
+      if (transactionType !== 'adjustment' && amount <= 0) {
+        return respondWithError(res, 400, 'Amount must be greater than 0');
       }
 
       // Verify loan belongs to user
       const loanCheck = await db.query(
-        'SELECT id FROM loans WHERE id = $1 AND user_id = $2',
+        'SELECT id, borrower_name FROM loans WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
         [loanId, user.id]
       );
 
@@ -90,31 +187,199 @@ class TransactionHandler {
         return respondWithError(res, 404, 'Loan not found');
       }
 
-      const result = await db.query(
-        `INSERT INTO transactions (loan_id, user_id, amount, transaction_type, transaction_date, description)
-         VALUES ($1, $2, $3, $4, $5, $6)
-         RETURNING *`,
-        [loanId, user.id, amount, transactionType, transactionDate, description]
-      );
+      const transactionData = await db.transaction(async client => {
+        await loanService.validatePayment(client, { loanId, transactionType, amount, currency, locale: userLocale(user) });
+
+        const result = await client.query(
+          `INSERT INTO transactions (loan_id, user_id, amount, transaction_type, transaction_date, description, status, confirmed_at)
+           VALUES ($1, $2, $3, $4, $5, $6, $7, CASE WHEN $7 = 'confirmed' THEN CURRENT_TIMESTAMP END)
+           RETURNING *`,
+          [loanId, user.id, amount, transactionType, transactionDate, description, status]
+        );
+
+        await notificationService.emit('transaction.created', {
+          userId: user.id,
+          payload: {
+            transactionId: result.rows[0].id,
+            loanId,
+            borrowerName: loanCheck.rows[0].borrower_name,
+            amount: parseFloat(amount),
+            currency: result.rows[0].currency,
+            transactionType,
+            status
+          }
+        }, client);
+
+        await loanService.syncLoanStatus(client, loanId);
+        return result.rows[0];
+      });
 
-      const transactionData = result.rows[0];
       const transaction = new Transaction({
         id: transactionData.id,
         loanId: transactionData.loan_id,
         userId: transactionData.user_id,
         amount: transactionData.amount,
+        currency: transactionData.currency,
         transactionType: transactionData.transaction_type,
         transactionDate: transactionData.transaction_date,
         description: transactionData.description,
+        status: transactionData.status,
+        confirmedAt: transactionData.confirmed_at,
         createdAt: transactionData.created_at,
         updatedAt: transactionData.updated_at
       });
 
-      return respondWithJSON(res, 201, transaction);
+      return respondWithJSON(res, 201, formatDateFields(transaction, userCalendar(user)));
 
     } catch (error) {
       console.error('Create transaction error:', error);
-      return respondWithError(res, 500, 'Failed to create transaction');
+      return respondWithError(res, 500, 'Failed to create transaction', { cause: error });
+    }
+  }
+
+  /**
+   * Validate a single imported CSV row
+   */
+  validateImportRow(record, loanIds) {
+    const errors = [];
+    const amount = parseFloat(record.amount);
+    const transactionType = record.transaction_type || 'payment';
+    const rawDate = record.transaction_date || record.payment_date;
+    const transactionDate = rawDate ? parseDate(rawDate) : null;
+
+    if (!record.loan_id) {
+      errors.push('loan_id is required');
+    } else if (!loanIds.has(record.loan_id)) {
+      errors.push('Loan not found');
+    }
+
+    if (!TRANSACTION_TYPES.includes(transactionType)) {
+      errors.push(`transaction_type must be one of: ${TRANSACTION_TYPES.join(', ')}`);
+    }
+
+    if (isNaN(amount)) {
+      errors.push('amount must be a number');
+    } else if (transactionType !== 'adjustment' && amount <= 0) {
+      errors.push('amount must be greater than 0');
+    }
+
+    if (!rawDate) {
+      errors.push('transaction_date is required');
+    } else if (!transactionDate) {
+      errors.push('transaction_date is not a valid date');
+    }
+
+    return {
+      errors,
+      value: {
+        loanId: record.loan_id,
+        amount,
+        transactionType,
+        transactionDate,
+        description: record.description || null
+      }
+    };
+  }
+
+  /**
+   * Map, validate and (unless dry run) import transaction records in a single transaction.
+   * Returns { error } when the columns or mapping are unusable, otherwise the import summary.
+   */
+  async runImport(userId, { headers, records: rawRecords }, mapping, dryRun) {
+    const { fields, required } = IMPORT_TARGETS.transactions;
+    const { records, error: mappingError } = applyColumnMapping(headers, rawRecords, mapping, Object.keys(fields));
+    if (mappingError) {
+      return { error: mappingError };
+    }
+
+    const available = new Set([...headers, ...Object.keys(mapping || {})]);
+    const missingHeaders = required.filter(header => !available.has(header));
+    if (missingHeaders.length > 0) {
+      return { error: `Missing required columns: ${missingHeaders.join(', ')}` };
+    }
+
+    if (records.length > MAX_IMPORT_ROWS) {
+      return { error: `Import is limited to ${MAX_IMPORT_ROWS} rows` };
+    }
+
+    const loansResult = await db.query('SELECT id FROM loans WHERE user_id = $1 AND deleted_at IS NULL', [userId]);
+    const loanIds = new Set(loansResult.rows.map(row => row.id));
+
+    const rows = records.map((record, index) => ({
+      row: index + 2, // Account for header row, 1-based
+      ...this.validateImportRow(record, loanIds)
+    }));
+
+    // Each row is checked against the balance left by the rows before it; a dry run rolls the inserts back
+    const checkedRows = rows.filter(row => row.errors.length === 0);
+    if (checkedRows.length > 0) {
+      await db.transaction(async client => {
+        for (const row of checkedRows) {
+          const { value } = row;
+          try {
+            await loanService.validatePayment(client, { loanId: value.loanId, transactionType: value.transactionType, amount: value.amount });
+          } catch (error) {
+            if (!(error instanceof ValidationError)) {
+              throw error;
+            }
+            row.errors.push(error.message);
+            continue;
+          }
+
+          await client.query(
+            `INSERT INTO transactions (loan_id, user_id, amount, transaction_type, transaction_date, description)
+             VALUES ($1, $2, $3, $4, $5, $6)`,
+            [value.loanId, userId, value.amount, value.transactionType, value.transactionDate, value.description]
+          );
+        }
+
+        if (dryRun) {
+          return { rollback: true };
+        }
+        const importedLoanIds = checkedRows.filter(row => row.errors.length === 0).map(({ value }) => value.loanId);
+        for (const loanId of new Set(importedLoanIds)) {
+          await loanService.syncLoanStatus(client, loanId);
+        }
+        return null;
+      });
+    }
+
+    const validRows = rows.filter(row => row.errors.length === 0);
+    const invalidRows = rows.filter(row => row.errors.length > 0);
+    const imported = dryRun ? 0 : validRows.length;
+
+    return {
+      dryRun,
+      totalRows: rows.length,
+      validRows: validRows.length,
+      invalidRows: invalidRows.length,
+      imported,
+      errors: invalidRows.map(({ row, errors }) => ({ row, errors }))
+    };
+  }
+
+  /**
+   * Import transactions from CSV (supports dry run)
+   */
+  async importTransactions(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const dryRun = req.query.dry_run === 'true';
+
+      if (typeof req.body !== 'string' || req.body.trim() === '') {
+        return respondWithError(res, 400, 'CSV body is required (Content-Type: text/csv)');
+      }
+
+      const result = await this.runImport(user.id, parseCSVRecords(req.body), null, dryRun);
+      if (result.error) {
+        return respondWithError(res, 400, result.error);
+      }
+
+      return respondWithJSON(res, dryRun ? 200 : 201, result);
+
+    } catch (error) {
+      console.error('Import transactions error:', error);
+      return respondWithError(res, 500, 'Failed to import transactions', { cause: error });
     }
   }
 
@@ -130,7 +395,7 @@ class TransactionHandler {
         `SELECT t.*, l.borrower_name, l.amount as loan_amount
          FROM transactions t
          JOIN loans l ON t.loan_id = l.id
-         WHERE t.id = $1 AND t.user_id = $2`,
+         WHERE t.id = $1 AND t.user_id = $2 AND t.deleted_at IS NULL AND l.deleted_at IS NULL`,
         [id, user.id]
       );
 
@@ -138,11 +403,26 @@ class TransactionHandler {
         return respondWithError(res, 404, 'Transaction not found');
       }
 
-      return respondWithJSON(res, 200, result.rows[0]);
+      const attachmentsResult = await db.query(
+        `SELECT id, file_name, content_type, size, created_at
+         FROM transaction_attachments
+         WHERE transaction_id = $1
+         ORDER BY created_at ASC`,
+        [id]
+      );
+
+      const transaction = result.rows[0];
+      transaction.attachments = attachmentsResult.rows;
+
+      if (wantsJSONAPI(req)) {
+        return respondWithJSONAPI(res, 200, { data: transactionResource(formatDateFields(transaction, userCalendar(user))) });
+      }
+
+      return respondWithJSON(res, 200, formatDateFields(transaction, userCalendar(user)));
 
     } catch (error) {
       console.error('Get transaction error:', error);
-      return respondWithError(res, 500, 'Failed to get transaction');
+      return respondWithError(res, 500, 'Failed to get transaction', { cause: error });
     }
   }
 
@@ -152,33 +432,352 @@ class TransactionHandler {
   async updateTransaction(req, res) {
     try {
       const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['amount', 'transactionType', 'transactionDate', 'description']);
       const { id } = req.params;
-      const { amount, transactionType, transactionDate, description } = req.body;
+      const { amount, transactionType, description } = req.body;
 
-      // Check if transaction exists and belongs to user
-      const existingTransaction = await db.query(
-        'SELECT * FROM transactions WHERE id = $1 AND user_id = $2',
-        [id, user.id]
-      );
+      const { transactionDate, error: dateError } = parseDateFields(req.body, ['transactionDate']);
+      if (dateError) {
+        return respondWithError(res, 400, dateError);
+      }
 
-      if (existingTransaction.rows.length === 0) {
+      const transaction = await db.transaction(async client => {
+        // Check if transaction exists and belongs to user
+        const existingTransaction = await client.query(
+          'SELECT * FROM transactions WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE',
+          [id, user.id]
+        );
+
+        if (existingTransaction.rows.length === 0) {
+          return null;
+        }
+
+        const existing = existingTransaction.rows[0];
+        if (existing.status === 'confirmed') {
+          await loanService.validatePayment(client, {
+            loanId: existing.loan_id,
+            transactionType,
+            amount,
+            replacing: existing,
+            locale: userLocale(user)
+          });
+        }
+
+        const result = await client.query(
+          `UPDATE transactions 
+           SET amount = $1, transaction_type = $2, transaction_date = $3, 
+               description = $4, updated_at = CURRENT_TIMESTAMP
+           WHERE id = $5 AND user_id = $6
+           RETURNING *`,
+          [amount, transactionType, transactionDate, description, id, user.id]
+        );
+
+        await loanService.syncLoanStatus(client, result.rows[0].loan_id);
+        return result.rows[0];
+      });
+
+      if (!transaction) {
         return respondWithError(res, 404, 'Transaction not found');
       }
 
-      const result = await db.query(
-        `UPDATE transactions 
-         SET amount = $1, transaction_type = $2, transaction_date = $3, 
-             description = $4, updated_at = CURRENT_TIMESTAMP
-         WHERE id = $5 AND user_id = $6
-         RETURNING *`,
-        [amount, transactionType, transactionDate, description, id, user.id]
-      );
-
-      return respondWithJSON(res, 200, result.rows[0]);
+      return respondWithJSON(res, 200, formatDateFields(transaction, userCalendar(user)));
 
     } catch (error) {
       console.error('Update transaction error:', error);
-      return respondWithError(res, 500, 'Failed to update transaction');
+      return respondWithError(res, 500, 'Failed to update transaction', { cause: error });
+    }
+  }
+
+  /**
+   * Confirm or reject a pending transaction
+   */
+  async updateTransactionStatus(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['status']);
+      const { id } = req.params;
+      const { status } = req.body;
+
+      validateRequiredFields(req.body, ['status']);
+
+      if (!['confirmed', 'rejected'].includes(status)) {
+        return respondWithError(res, 400, 'Status must be one of: confirmed, rejected');
+      }
+
+      const transaction = await db.transaction(async client => {
+        const pending = await client.query(
+          "SELECT loan_id, amount, transaction_type FROM transactions WHERE id = $1 AND user_id = $2 AND status = 'pending' AND deleted_at IS NULL FOR UPDATE",
+          [id, user.id]
+        );
+
+        if (pending.rows.length === 0) {
+          return null;
+        }
+
+        if (status === 'confirmed') {
+          const { loan_id: loanId, amount, transaction_type: transactionType } = pending.rows[0];
+          await loanService.validatePayment(client, { loanId, transactionType, amount, locale: userLocale(user) });
+        }
+
+        const result = await client.query(
+          `UPDATE transactions
+           SET status = $1,
+               confirmed_at = CASE WHEN $1 = 'confirmed' THEN CURRENT_TIMESTAMP END,
+               updated_at = CURRENT_TIMESTAMP
+           WHERE id = $2 AND user_id = $3
+           RETURNING *`,
+          [status, id, user.id]
+        );
+
+        await loanService.syncLoanStatus(client, result.rows[0].loan_id);
+        return result.rows[0];
+      });
+
+      if (!transaction) {
+        return respondWithError(res, 404, 'Pending transaction not found');
+      }
+
+      return respondWithJSON(res, 200, formatDateFields(transaction, userCalendar(user)));
+
+    } catch (error) {
+      console.error('Update transaction status error:', error);
+      return respondWithError(res, 500, 'Failed to update transaction status', { cause: error });
+    }
+  }
+
+  /**
+   * Move transaction to another loan of the same user
+   */
+  async moveTransaction(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['targetLoanId']);
+      const { id } = req.params;
+      const { targetLoanId } = req.body;
+
+      validateRequiredFields(req.body, ['targetLoanId']);
+
+      const { error, transaction } = await db.transaction(async client => {
+        const existing = await client.query(
+          'SELECT * FROM transactions WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE',
+          [id, user.id]
+        );
+
+        if (existing.rows.length === 0) {
+          return { error: [404, 'Transaction not found'] };
+        }
+
+        const sourceLoanId = existing.rows[0].loan_id;
+        if (sourceLoanId === targetLoanId) {
+          return { error: [400, 'Transaction already belongs to this loan'] };
+        }
+
+        const targetLoan = await client.query(
+          'SELECT id, currency FROM loans WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+          [targetLoanId, user.id]
+        );
+
+        if (targetLoan.rows.length === 0) {
+          return { error: [404, 'Target loan not found'] };
+        }
+
+        // Amounts are not converted, so an entry can only move between loans in the same currency;
+        // a confirmed payment must also fit the target loan's remaining debt
+        const { transaction_type: transactionType, amount, currency, status } = existing.rows[0];
+        if (status === 'confirmed') {
+          await loanService.validatePayment(client, { loanId: targetLoanId, transactionType, amount, currency, locale: userLocale(user) });
+        } else {
+          loanService.validateCurrency(targetLoan.rows[0], currency);
+        }
+
+        const result = await client.query(
+          `UPDATE transactions SET loan_id = $1, updated_at = CURRENT_TIMESTAMP
+           WHERE id = $2
+           RETURNING *`,
+          [targetLoanId, id]
+        );
+
+        await loanService.syncLoanStatus(client, sourceLoanId);
+        await loanService.syncLoanStatus(client, targetLoanId);
+
+        return { transaction: result.rows[0] };
+      });
+
+      if (error) {
+        return respondWithError(res, ...error);
+      }
+
+      return respondWithJSON(res, 200, formatDateFields(transaction, userCalendar(user)));
+
+    } catch (error) {
+      console.error('Move transaction error:', error);
+      return respondWithError(res, 500, 'Failed to move transaction', { cause: error });
+    }
+  }
+
+  /**
+   * Validate batch request IDs
+   */
+  validateBatchIds(ids) {
+    if (!Array.isArray(ids) || ids.length === 0) {
+      return 'ids must be a non-empty array';
+    }
+    if (ids.length > MAX_BATCH_SIZE) {
+      return `Batch is limited to ${MAX_BATCH_SIZE} items`;
+    }
+    if (new Set(ids).size !== ids.length) {
+      return 'ids must not contain duplicates';
+    }
+    return null;
+  }
+
+  /**
+   * Run a batch operation atomically; rolls back if any item fails.
+   * An operation returns the updated row, null when the item is not found, or throws ValidationError.
+   */
+  async runBatch(res, ids, operation) {
+    const { rollback, results } = await db.transaction(async client => {
+      const results = [];
+      const affectedLoanIds = new Set();
+      for (const id of ids) {
+        let row;
+        try {
+          row = await operation(client, id);
+        } catch (error) {
+          if (!(error instanceof ValidationError)) {
+            throw error;
+          }
+          results.push({ id, success: false, error: error.message });
+          continue;
+        }
+        if (row) {
+          affectedLoanIds.add(row.loan_id);
+          results.push({ id, success: true });
+        } else {
+          results.push({ id, success: false, error: 'Transaction not found' });
+        }
+      }
+
+      if (results.some(result => !result.success)) {
+        return { rollback: true, results };
+      }
+
+      for (const loanId of affectedLoanIds) {
+        await loanService.syncLoanStatus(client, loanId);
+      }
+      return { results };
+    });
+
+    if (rollback) {
+      const failed = results.filter(result => !result.success).length;
+      return respondWithJSON(res, 400, { committed: false, succeeded: 0, failed, results });
+    }
+    return respondWithJSON(res, 200, { committed: true, succeeded: results.length, failed: 0, results });
+  }
+
+  /**
+   * Batch update transactions
+   */
+  async batchUpdateTransactions(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['ids', 'changes']);
+      const { ids, changes } = req.body;
+
+      const idsError = this.validateBatchIds(ids);
+      if (idsError) {
+        return respondWithError(res, 400, idsError);
+      }
+
+      if (!changes || typeof changes !== 'object') {
+        return respondWithError(res, 400, 'changes object is required');
+      }
+
+      const unknownFields = Object.keys(changes).filter(field => !BATCH_UPDATE_FIELDS[field]);
+      if (unknownFields.length > 0) {
+        return respondWithError(res, 400, `Unsupported fields: ${unknownFields.join(', ')}`);
+      }
+
+      const fields = Object.keys(changes);
+      if (fields.length === 0) {
+        return respondWithError(res, 400, 'At least one field to change is required');
+      }
+
+      if (changes.transactionType && !TRANSACTION_TYPES.includes(changes.transactionType)) {
+        return respondWithError(res, 400, `Transaction type must be one of: ${TRANSACTION_TYPES.join(', ')}`);
+      }
+
+      const dates = parseDateFields(changes, ['transactionDate', 'paymentDate']);
+      if (dates.error) {
+        return respondWithError(res, 400, dates.error);
+      }
+
+      const setClause = fields.map((field, index) => `${BATCH_UPDATE_FIELDS[field]} = $${index + 1}`).join(', ');
+      const values = fields.map(field => (field in dates ? dates[field] : changes[field]));
+
+      return await this.runBatch(res, ids, async (client, id) => {
+        if (changes.transactionType) {
+          // Switching between payment and charge types moves the balance by twice the amount
+          const existing = await client.query(
+            'SELECT * FROM transactions WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE',
+            [id, user.id]
+          );
+          const row = existing.rows[0];
+          if (!row) {
+            return null;
+          }
+          await loanService.validatePayment(client, {
+            loanId: row.loan_id,
+            transactionType: changes.transactionType,
+            amount: row.amount,
+            replacing: row,
+            locale: userLocale(user)
+          });
+        }
+
+        const result = await client.query(
+          `UPDATE transactions
+           SET ${setClause}, updated_at = CURRENT_TIMESTAMP
+           WHERE id = $${fields.length + 1} AND user_id = $${fields.length + 2} AND deleted_at IS NULL
+           RETURNING loan_id`,
+          [...values, id, user.id]
+        );
+        return result.rows[0];
+      });
+
+    } catch (error) {
+      console.error('Batch update transactions error:', error);
+      return respondWithError(res, 500, 'Failed to batch update transactions', { cause: error });
+    }
+  }
+
+  /**
+   * Batch delete transactions
+   */
+  async batchDeleteTransactions(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['ids']);
+      const { ids } = req.body;
+
+      const idsError = this.validateBatchIds(ids);
+      if (idsError) {
+        return respondWithError(res, 400, idsError);
+      }
+
+      return await this.runBatch(res, ids, async (client, id) => {
+        const result = await client.query(
+          `UPDATE transactions SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+           WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+           RETURNING loan_id`,
+          [id, user.id]
+        );
+        return result.rows[0];
+      });
+
+    } catch (error) {
+      console.error('Batch delete transactions error:', error);
+      return respondWithError(res, 500, 'Failed to batch delete transactions', { cause: error });
     }
   }
 
@@ -190,12 +789,23 @@ class TransactionHandler {
       const user = getUserFromContext(req);
       const { id } = req.params;
 
-      const result = await db.query(
-        'DELETE FROM transactions WHERE id = $1 AND user_id = $2 RETURNING *',
-        [id, user.id]
-      );
+      const deleted = await db.transaction(async client => {
+        const result = await client.query(
+          `UPDATE transactions SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+           WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+           RETURNING *`,
+          [id, user.id]
+        );
 
-      if (result.rows.length === 0) {
+        if (result.rows.length === 0) {
+          return null;
+        }
+
+        await loanService.syncLoanStatus(client, result.rows[0].loan_id);
+        return result.rows[0];
+      });
+
+      if (!deleted) {
         return respondWithError(res, 404, 'Transaction not found');
       }
 
@@ -203,7 +813,56 @@ class TransactionHandler {
 
     } catch (error) {
       console.error('Delete transaction error:', error);
-      return respondWithError(res, 500, 'Failed to delete transaction');
+      return respondWithError(res, 500, 'Failed to delete transaction', { cause: error });
+    }
+  }
+
+  /**
+   * Restore a soft-deleted transaction (its loan must not be deleted)
+   */
+  async restoreTransaction(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const transaction = await db.transaction(async client => {
+        const existing = await client.query(
+          `SELECT t.* FROM transactions t
+           JOIN loans l ON l.id = t.loan_id
+           WHERE t.id = $1 AND t.user_id = $2 AND t.deleted_at IS NOT NULL AND l.deleted_at IS NULL
+           FOR UPDATE OF t`,
+          [id, user.id]
+        );
+
+        if (existing.rows.length === 0) {
+          return null;
+        }
+
+        const { loan_id: loanId, amount, transaction_type: transactionType, status } = existing.rows[0];
+        if (status === 'confirmed') {
+          await loanService.validatePayment(client, { loanId, transactionType, amount, locale: userLocale(user) });
+        }
+
+        const result = await client.query(
+          `UPDATE transactions SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP
+           WHERE id = $1
+           RETURNING *`,
+          [id]
+        );
+
+        await loanService.syncLoanStatus(client, loanId);
+        return result.rows[0];
+      });
+
+      if (!transaction) {
+        return respondWithError(res, 404, 'Deleted transaction not found');
+      }
+
+      return respondWithJSON(res, 200, formatDateFields(transaction, userCalendar(user)));
+
+    } catch (error) {
+      console.error('Restore transaction error:', error);
+      return respondWithError(res, 500, 'Failed to restore transaction', { cause: error });
     }
   }
 
@@ -218,7 +877,7 @@ class TransactionHandler {
 
       // Verify loan belongs to user
       const loanCheck = await db.query(
-        'SELECT id FROM loans WHERE id = $1 AND user_id = $2',
+        'SELECT id FROM loans WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
         [loanId, user.id]
       );
 
@@ -226,7 +885,26 @@ class TransactionHandler {
         return respondWithError(res, 404, 'Loan not found');
       }
 
-      let query = 'SELECT * FROM transactions WHERE loan_id = $1 ORDER BY created_at DESC';
+      // balance_after: loan amount plus confirmed balance effects up to and including each row
+      let query = `
+        SELECT t.*,
+          CASE WHEN t.status = 'confirmed' THEN
+            l.amount + SUM(
+              CASE
+                WHEN t.status <> 'confirmed' THEN 0
+                WHEN t.transaction_type = 'payment' THEN -t.amount
+                ELSE t.amount
+              END
+            ) OVER (
+              ORDER BY COALESCE(t.transaction_date, t.created_at::date), t.created_at
+              ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+            )
+          END as balance_after
+        FROM transactions t
+        JOIN loans l ON t.loan_id = l.id
+        WHERE t.loan_id = $1 AND t.deleted_at IS NULL
+        ORDER BY t.created_at DESC
+      `;
       let params = [loanId];
 
       if (limit) {
@@ -242,13 +920,13 @@ class TransactionHandler {
       const result = await db.query(query, params);
 
       return respondWithJSON(res, 200, {
-        transactions: result.rows,
+        transactions: formatDateFields(result.rows, userCalendar(user)),
         pagination: { page, limit, total: result.rowCount }
       });
 
     } catch (error) {
       console.error('Get transactions by loan error:', error);
-      return respondWithError(res, 500, 'Failed to get loan transactions');
+      return respondWithError(res, 500, 'Failed to get loan transactions', { cause: error });
     }
   }
 }
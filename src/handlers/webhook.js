@@ -0,0 +1,254 @@
+const crypto = require('crypto');
+const config = require('../config');
+const db = require('../database/db');
+const { respondWithError, respondWithJSON, validateRequiredFields, rejectUnknownFields, parsePagination } = require('../utils/response');
+const { getUserFromContext } = require('../middleware/auth');
+const { WebhookEndpoint } = require('../models');
+const { WEBHOOK_EVENTS } = require('../notifications/channels/webhook');
+const { blockedHostReason } = require('../utils/publicAddress');
+
+class WebhookHandler {
+  /**
+   * Map database row to WebhookEndpoint model (secret is only included on create)
+   */
+  toWebhook(row, { includeSecret = false } = {}) {
+    const webhook = new WebhookEndpoint({
+      id: row.id,
+      userId: row.user_id,
+      url: row.url,
+      events: row.events,
+      active: row.active,
+      createdAt: row.created_at,
+      updatedAt: row.updated_at
+    });
+    if (includeSecret) {
+      webhook.secret = row.secret;
+    }
+    return webhook;
+  }
+
+  /**
+   * Validate webhook URL and event filter; returns an error message or null
+   */
+  validateWebhook(url, events) {
+    if (url !== undefined) {
+      let parsed;
+      try {
+        parsed = new URL(url);
+      } catch (error) {
+        return 'URL is not valid';
+      }
+      if (config.server.production && parsed.protocol !== 'https:') {
+        return 'URL must use https';
+      }
+      if (!['https:', 'http:'].includes(parsed.protocol)) {
+        return 'URL must use http or https';
+      }
+      // Hostnames are also checked against their resolved addresses on every delivery
+      const blocked = blockedHostReason(parsed);
+      if (blocked) {
+        return blocked;
+      }
+    }
+
+    if (events !== undefined) {
+      if (!Array.isArray(events) || events.length === 0) {
+        return 'Events must be a non-empty array';
+      }
+      const invalid = events.filter(event => !WEBHOOK_EVENTS.includes(event));
+      if (invalid.length > 0) {
+        return `Unsupported events: ${invalid.join(', ')}. Supported: ${WEBHOOK_EVENTS.join(', ')}`;
+      }
+    }
+
+    return null;
+  }
+
+  /**
+   * Get webhook endpoints for user
+   */
+  async getWebhooks(req, res) {
+    try {
+      const user = getUserFromContext(req);
+
+      const result = await db.query(
+        'SELECT * FROM webhook_endpoints WHERE user_id = $1 ORDER BY created_at DESC',
+        [user.id]
+      );
+
+      return respondWithJSON(res, 200, result.rows.map(row => this.toWebhook(row)));
+
+    } catch (error) {
+      console.error('Get webhooks error:', error);
+      return respondWithError(res, 500, 'Failed to get webhooks', { cause: error });
+    }
+  }
+
+  /**
+   * Create webhook endpoint; a signing secret is generated when not provided
+   */
+  async createWebhook(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['url', 'events', 'secret']);
+      const { url, secret } = req.body;
+      const events = req.body.events || WEBHOOK_EVENTS;
+
+      validateRequiredFields(req.body, ['url']);
+
+      const validationError = this.validateWebhook(url, events);
+      if (validationError) {
+        return respondWithError(res, 400, validationError);
+      }
+
+      const result = await db.query(
+        `INSERT INTO webhook_endpoints (user_id, url, secret, events)
+         VALUES ($1, $2, $3, $4)
+         RETURNING *`,
+        [user.id, url, secret || crypto.randomBytes(32).toString('hex'), [...new Set(events)]]
+      );
+
+      return respondWithJSON(res, 201, this.toWebhook(result.rows[0], { includeSecret: true }));
+
+    } catch (error) {
+      console.error('Create webhook error:', error);
+      return respondWithError(res, 500, 'Failed to create webhook', { cause: error });
+    }
+  }
+
+  /**
+   * Update webhook URL, event filter or active flag
+   */
+  async updateWebhook(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      rejectUnknownFields(req.body, ['url', 'events', 'active']);
+      const { id } = req.params;
+      const { url, events, active } = req.body;
+
+      const validationError = this.validateWebhook(url, events);
+      if (validationError) {
+        return respondWithError(res, 400, validationError);
+      }
+
+      if (active !== undefined && typeof active !== 'boolean') {
+        return respondWithError(res, 400, 'active must be a boolean');
+      }
+
+      const result = await db.query(
+        `UPDATE webhook_endpoints
+         SET url = COALESCE($1, url), events = COALESCE($2, events), active = COALESCE($3, active),
+             updated_at = CURRENT_TIMESTAMP
+         WHERE id = $4 AND user_id = $5
+         RETURNING *`,
+        [url, events ? [...new Set(events)] : null, active, id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Webhook not found');
+      }
+
+      return respondWithJSON(res, 200, this.toWebhook(result.rows[0]));
+
+    } catch (error) {
+      console.error('Update webhook error:', error);
+      return respondWithError(res, 500, 'Failed to update webhook', { cause: error });
+    }
+  }
+
+  /**
+   * Delete webhook endpoint
+   */
+  async deleteWebhook(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+
+      const result = await db.query(
+        'DELETE FROM webhook_endpoints WHERE id = $1 AND user_id = $2 RETURNING id',
+        [id, user.id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Webhook not found');
+      }
+
+      return respondWithJSON(res, 200, { message: 'Webhook deleted successfully' });
+
+    } catch (error) {
+      console.error('Delete webhook error:', error);
+      return respondWithError(res, 500, 'Failed to delete webhook', { cause: error });
+    }
+  }
+
+  /**
+   * Get delivery log for a webhook endpoint
+   */
+  async getWebhookDeliveries(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id } = req.params;
+      const { page, limit, offset } = parsePagination(req.query);
+
+      const webhookCheck = await db.query(
+        'SELECT id FROM webhook_endpoints WHERE id = $1 AND user_id = $2',
+        [id, user.id]
+      );
+
+      if (webhookCheck.rows.length === 0) {
+        return respondWithError(res, 404, 'Webhook not found');
+      }
+
+      const result = await db.query(
+        `SELECT wd.id, wd.notification_id, wd.event, wd.attempt, wd.status_code, wd.response_body,
+                wd.error, wd.duration_ms, wd.created_at, n.status as delivery_status
+         FROM webhook_deliveries wd
+         LEFT JOIN notifications n ON n.id = wd.notification_id
+         WHERE wd.webhook_id = $1
+         ORDER BY wd.created_at DESC
+         LIMIT $2 OFFSET $3`,
+        [id, limit, offset]
+      );
+
+      return respondWithJSON(res, 200, {
+        deliveries: result.rows,
+        pagination: { page, limit, total: result.rowCount }
+      });
+
+    } catch (error) {
+      console.error('Get webhook deliveries error:', error);
+      return respondWithError(res, 500, 'Failed to get webhook deliveries', { cause: error });
+    }
+  }
+
+  /**
+   * Queue a failed or delivered webhook notification for another attempt
+   */
+  async redeliverWebhook(req, res) {
+    try {
+      const user = getUserFromContext(req);
+      const { id, notificationId } = req.params;
+
+      const result = await db.query(
+        `UPDATE notifications
+         SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, last_error = NULL
+         WHERE id = $1 AND user_id = $2 AND channel = 'webhook' AND recipient = $3
+         AND status IN ('sent', 'failed')
+         RETURNING id, status`,
+        [notificationId, user.id, id]
+      );
+
+      if (result.rows.length === 0) {
+        return respondWithError(res, 404, 'Delivery not found or already queued');
+      }
+
+      return respondWithJSON(res, 200, result.rows[0]);
+
+    } catch (error) {
+      console.error('Redeliver webhook error:', error);
+      return respondWithError(res, 500, 'Failed to queue webhook redelivery', { cause: error });
+    }
+  }
+}
+
+module.exports = new WebhookHandler();
@@ -1,39 +1,120 @@
 require('dotenv').config();
+// Validated settings; an invalid environment stops startup here with every problem listed
+const config = require('./config');
+const http = require('http');
 const express = require('express');
-const cors = require('cors');
 const db = require('./database/db');
+const cache = require('./cache');
+const eventStream = require('./events');
 const authHandler = require('./handlers/auth');
 const profileHandler = require('./handlers/profile');
 const dashboardHandler = require('./handlers/dashboard');
 const loanHandler = require('./handlers/loan');
 const transactionHandler = require('./handlers/transaction');
+const calculatorHandler = require('./handlers/calculator');
+const paymentPlanHandler = require('./handlers/paymentPlan');
+const reminderRuleHandler = require('./handlers/reminderRule');
+const attachmentHandler = require('./handlers/attachment');
+const borrowerHandler = require('./handlers/borrower');
+const notificationHandler = require('./handlers/notification');
+const webhookHandler = require('./handlers/webhook');
+const reportHandler = require('./handlers/report');
+const exportHandler = require('./handlers/export');
+const backupHandler = require('./handlers/backup');
+const importHandler = require('./handlers/import');
+const integrationHandler = require('./handlers/integration');
+const eventsHandler = require('./handlers/events');
+const batchHandler = require('./handlers/batch');
+const metricsHandler = require('./handlers/metrics');
+const adminHandler = require('./handlers/admin');
+const profilingHandler = require('./handlers/profiling');
+const deviceHandler = require('./handlers/device');
+const scheduler = require('./jobs/scheduler');
+const interestAccrualJob = require('./jobs/interestAccrual');
+const paymentPlanJob = require('./jobs/paymentPlans');
+const notificationDeliveryJob = require('./jobs/notificationDelivery');
+const reminderJob = require('./jobs/reminders');
+const escalationJob = require('./jobs/escalations');
+const savedReportJob = require('./jobs/savedReports');
+const exportJobWorker = require('./jobs/exports');
 const { authMiddleware } = require('./middleware/auth');
-const { respondWithJSON, logAPICall } = require('./utils/response');
+const { etagMiddleware } = require('./middleware/etag');
+const { compressionMiddleware } = require('./middleware/compression');
+const { queryTimeoutMiddleware } = require('./middleware/timeout');
+const { cacheMiddleware, invalidateOnWrite } = require('./middleware/cache');
+const { createCorsMiddleware } = require('./middleware/cors');
+const { recoverAsyncHandlers, recoveryMiddleware, reportUnhandledRejections } = require('./middleware/recovery');
+const { requestIdMiddleware, prefixLogsWithRequestId } = require('./middleware/requestId');
+const { accessLogMiddleware } = require('./middleware/accessLog');
+const { rateLimit, methodRateLimit } = require('./middleware/rateLimit');
+const { createStaticMiddleware, spaFallback } = require('./middleware/static');
+const { respondWithError, respondWithJSON } = require('./utils/response');
+const { multipartBody } = require('./utils/multipart');
+const { buildOpenAPISpec } = require('./utils/openapi');
+const { isShuttingDown, drainingMiddleware, handleShutdownSignals } = require('./utils/shutdown');
+const { applyLogLevel } = require('./utils/logLevel');
+const { handleReloadSignal } = require('./config/reload');
+const { createGrpcServer } = require('./grpc');
+
+// Async handler failures reach the error middleware; stray rejections are reported
+recoverAsyncHandlers();
+reportUnhandledRejections();
+applyLogLevel();
+prefixLogsWithRequestId();
 
 const app = express();
-const PORT = process.env.PORT || 3000;
+const PORT = config.server.port;
+const GRPC_PORT = config.server.grpcPort;
+const JOB_INTERVAL_MS = config.jobs.intervalMs;
+const NOTIFICATION_INTERVAL_MS = config.jobs.notificationIntervalMs;
+const EXPORT_INTERVAL_MS = config.jobs.exportIntervalMs;
+
+// Behind a load balancer, req.ip (used by the rate limiter) must come from X-Forwarded-For
+if (config.server.trustProxy) {
+  app.set('trust proxy', /^\d+$/.test(config.server.trustProxy) ? parseInt(config.server.trustProxy) : config.server.trustProxy);
+}
 
 // Middleware
-app.use(cors({
-  origin: true, // Allow all origins including file://
-  credentials: true,
-  methods: ['GET', 'POST', 'PUT', 'DELETE', 'PATCH', 'OPTIONS'],
-  allowedHeaders: ['Content-Type', 'Authorization', 'X-Requested-With']
-}));
-app.use(express.json());
+app.use(requestIdMiddleware);
+app.use(drainingMiddleware);
+app.use(createCorsMiddleware());
+
+// Response compression (Brotli/gzip via Accept-Encoding)
+app.use(compressionMiddleware);
+
+
+// Per-IP rate limits: reads and writes across the API, plus a strict policy on credential endpoints
+app.use('/api', methodRateLimit());
+app.use(['/api/v1/register', '/api/v1/login', '/api/v1/change-password', '/api/v1/admin'], rateLimit('auth'));
+// JSON bodies are capped (JSON_BODY_LIMIT) so oversized payloads are refused before reaching handlers;
+// backup restore archives get their own, larger limit
+const jsonBody = express.json({ limit: config.limits.jsonBody });
+const backupBody = express.json({ limit: config.limits.backupSize });
+const encryptedBackupBody = express.raw({ type: 'application/octet-stream', limit: config.limits.backupSize });
+app.use((req, res, next) => (req.path === '/api/v1/restore' ? backupBody : jsonBody)(req, res, next));
 app.use(express.urlencoded({ extended: true }));
 
-// Logging middleware
-app.use((req, res, next) => {
-  res.on('finish', () => {
-    const userId = req.user ? req.user.id : 'anonymous';
-    logAPICall(req.method, req.path, userId, res.statusCode);
-  });
-  next();
-});
+// Raw CSV/vCard body parser for import endpoints
+const csvBody = express.text({ type: ['text/csv', 'text/plain', 'text/vcard', 'text/x-vcard'], limit: '5mb' });
+
+// Cancel a request's queries when it times out or the client goes away
+app.use(queryTimeoutMiddleware);
+
+// Structured access logs (sampled, with credentials redacted)
+app.use(accessLogMiddleware);
+
+// Any successful write drops the user's cached dashboard responses
+app.use(invalidateOnWrite);
+
+// Conditional GET (ETag / If-None-Match) for resources the dashboard polls
+app.use(['/api/v1/loans', '/api/v2/loans', '/api/v1/dashboard'], etagMiddleware);
 
 // Health check endpoint
 app.get('/health', (req, res) => {
+  // Draining instances fail the check so load balancers stop routing to them
+  if (isShuttingDown()) {
+    return respondWithError(res, 503, 'Server is shutting down', { code: 'SHUTTING_DOWN' });
+  }
   respondWithJSON(res, 200, { 
     status: 'healthy',
     timestamp: new Date().toISOString(),
@@ -41,9 +122,38 @@ app.get('/health', (req, res) => {
   });
 });
 
+// Prometheus metrics (protected by METRICS_TOKEN when set)
+app.get('/metrics', metricsHandler.getMetrics.bind(metricsHandler));
+
+// Account administration (requires ADMIN_TOKEN)
+app.get('/api/v1/admin/users', adminHandler.getUsers.bind(adminHandler));
+app.get('/api/v1/admin/metrics', adminHandler.getMetrics.bind(adminHandler));
+app.post('/api/v1/admin/users/:id/restore', adminHandler.restoreUser.bind(adminHandler));
+app.post('/api/v1/admin/config/reload', adminHandler.reloadConfig.bind(adminHandler));
+
+// CPU profiles and heap snapshots for diagnosing a misbehaving instance (requires ADMIN_TOKEN)
+app.get('/api/v1/admin/debug/cpu-profile', profilingHandler.getCpuProfile.bind(profilingHandler));
+app.get('/api/v1/admin/debug/heap-snapshot', profilingHandler.getHeapSnapshot.bind(profilingHandler));
+
+// API documentation (public); the spec is built from the registered routes on first request,
+// the viewer is a static page in the web frontend (no third-party assets)
+let openAPISpec = null;
+app.get('/api/v1/openapi.json', (req, res) => {
+  openAPISpec = openAPISpec || buildOpenAPISpec(app);
+  res.json(openAPISpec);
+});
+app.get('/api/v1/docs', (req, res) => {
+  res.redirect('/static/api-docs.html');
+});
+
 // Auth routes (public) - NO AUTH REQUIRED
 app.post('/api/v1/register', authHandler.register.bind(authHandler));
 app.post('/api/v1/login', authHandler.login.bind(authHandler));
+app.post('/api/v1/logout', authHandler.logout.bind(authHandler));
+app.get('/api/v1/session', authMiddleware, authHandler.getSession.bind(authHandler));
+
+// Loan calculator (public)
+app.post('/api/v1/calculator', calculatorHandler.calculate.bind(calculatorHandler));
 
 // Apply auth middleware only to protected routes
 // Don't use app.use('/api/v1', authMiddleware) as it affects register/login too
@@ -51,52 +161,183 @@ app.post('/api/v1/login', authHandler.login.bind(authHandler));
 // Profile management endpoints (protected)
 app.get('/api/v1/profile', authMiddleware, profileHandler.getProfile.bind(profileHandler));
 app.patch('/api/v1/profile', authMiddleware, profileHandler.updateProfile.bind(profileHandler));
+app.delete('/api/v1/profile', authMiddleware, profileHandler.deleteAccount.bind(profileHandler));
 app.patch('/api/v1/change-password', authMiddleware, profileHandler.changePassword.bind(profileHandler));
+app.get('/api/v1/profile/notifications', authMiddleware, profileHandler.getNotificationPreferences.bind(profileHandler));
+app.patch('/api/v1/profile/notifications', authMiddleware, profileHandler.updateNotificationPreferences.bind(profileHandler));
+
+// Sign-in devices (new device/country logins trigger an auth.new_login notification)
+app.get('/api/v1/profile/devices', authMiddleware, deviceHandler.getDevices.bind(deviceHandler));
+app.patch('/api/v1/profile/devices/:id', authMiddleware, deviceHandler.updateDevice.bind(deviceHandler));
+app.delete('/api/v1/profile/devices/:id', authMiddleware, deviceHandler.deleteDevice.bind(deviceHandler));
+
+// Integration endpoints (protected, except OAuth callback which is verified by signed state)
+app.get('/api/v1/profile/integrations/line', authMiddleware, integrationHandler.getLineIntegration.bind(integrationHandler));
+app.post('/api/v1/profile/integrations/line', authMiddleware, integrationHandler.connectLineToken.bind(integrationHandler));
+app.delete('/api/v1/profile/integrations/line', authMiddleware, integrationHandler.disconnectLine.bind(integrationHandler));
+app.post('/api/v1/profile/integrations/line/authorize', authMiddleware, integrationHandler.authorizeLine.bind(integrationHandler));
+app.get('/api/v1/profile/integrations/line/callback', integrationHandler.lineCallback.bind(integrationHandler));
+app.get('/api/v1/profile/integrations/telegram', authMiddleware, integrationHandler.getTelegramIntegration.bind(integrationHandler));
+app.post('/api/v1/profile/integrations/telegram/link', authMiddleware, integrationHandler.linkTelegram.bind(integrationHandler));
+app.delete('/api/v1/profile/integrations/telegram', authMiddleware, integrationHandler.disconnectTelegram.bind(integrationHandler));
+app.post('/api/v1/integrations/telegram/webhook', integrationHandler.telegramWebhook.bind(integrationHandler));
 
 // Dashboard endpoints (protected)
-app.get('/api/v1/dashboard/stats', authMiddleware, dashboardHandler.getDashboardStats.bind(dashboardHandler));
-app.get('/api/v1/dashboard/recent-transactions', authMiddleware, dashboardHandler.getRecentTransactions.bind(dashboardHandler));
-app.get('/api/v1/dashboard/loan-summary', authMiddleware, dashboardHandler.getLoanSummary.bind(dashboardHandler));
-app.get('/api/v1/dashboard/monthly-stats', authMiddleware, dashboardHandler.getMonthlyStats.bind(dashboardHandler));
-app.get('/api/v1/dashboard/overdue-loans', authMiddleware, dashboardHandler.getOverdueLoans.bind(dashboardHandler));
+app.get('/api/v1/dashboard', authMiddleware, cacheMiddleware, dashboardHandler.getDashboard.bind(dashboardHandler));
+app.get('/api/v1/dashboard/stats', authMiddleware, cacheMiddleware, dashboardHandler.getDashboardStats.bind(dashboardHandler));
+app.get('/api/v1/dashboard/recent-transactions', authMiddleware, cacheMiddleware, dashboardHandler.getRecentTransactions.bind(dashboardHandler));
+app.get('/api/v1/dashboard/loan-summary', authMiddleware, cacheMiddleware, dashboardHandler.getLoanSummary.bind(dashboardHandler));
+app.get('/api/v1/dashboard/monthly-stats', authMiddleware, cacheMiddleware, dashboardHandler.getMonthlyStats.bind(dashboardHandler));
+app.get('/api/v1/dashboard/overdue-loans', authMiddleware, cacheMiddleware, dashboardHandler.getOverdueLoans.bind(dashboardHandler));
+app.get('/api/v1/dashboard/missed-payments', authMiddleware, cacheMiddleware, dashboardHandler.getMissedPayments.bind(dashboardHandler));
+app.get('/api/v1/dashboard/projection', authMiddleware, cacheMiddleware, dashboardHandler.getCashFlowProjection.bind(dashboardHandler));
+app.get('/api/v1/dashboard/top-borrowers', authMiddleware, cacheMiddleware, dashboardHandler.getTopBorrowers.bind(dashboardHandler));
+app.get('/api/v1/dashboard/collections', authMiddleware, cacheMiddleware, dashboardHandler.getCollectionMetrics.bind(dashboardHandler));
+app.get('/api/v1/dashboard/income', authMiddleware, cacheMiddleware, dashboardHandler.getIncomeReport.bind(dashboardHandler));
+app.get('/api/v1/dashboard/velocity', authMiddleware, cacheMiddleware, dashboardHandler.getRepaymentVelocity.bind(dashboardHandler));
+app.get('/api/v1/dashboard/expected-vs-actual', authMiddleware, cacheMiddleware, dashboardHandler.getExpectedVsActual.bind(dashboardHandler));
+app.get('/api/v1/dashboard/net-position', authMiddleware, cacheMiddleware, dashboardHandler.getNetPosition.bind(dashboardHandler));
+
+// Live activity stream (Server-Sent Events)
+app.get('/api/v1/events', authMiddleware, eventsHandler.streamEvents.bind(eventsHandler));
+
+// Saved report routes
+app.get('/api/v1/reports', authMiddleware, reportHandler.getReports.bind(reportHandler));
+app.post('/api/v1/reports', authMiddleware, reportHandler.createReport.bind(reportHandler));
+app.get('/api/v1/reports/:id', authMiddleware, reportHandler.getReport.bind(reportHandler));
+app.patch('/api/v1/reports/:id', authMiddleware, reportHandler.updateReport.bind(reportHandler));
+app.delete('/api/v1/reports/:id', authMiddleware, reportHandler.deleteReport.bind(reportHandler));
+app.get('/api/v1/reports/:id/run', authMiddleware, reportHandler.runSavedReport.bind(reportHandler));
+
+// Export routes
+app.get('/api/v1/export/xlsx', authMiddleware, exportHandler.exportWorkbook.bind(exportHandler));
+app.get('/api/v1/export/qif', authMiddleware, exportHandler.exportQIF.bind(exportHandler));
+app.get('/api/v1/export/ofx', authMiddleware, exportHandler.exportOFX.bind(exportHandler));
+app.get('/api/v1/exports', authMiddleware, exportHandler.getExportJobs.bind(exportHandler));
+app.post('/api/v1/exports', authMiddleware, exportHandler.createExportJob.bind(exportHandler));
+app.get('/api/v1/exports/:id', authMiddleware, exportHandler.getExportJob.bind(exportHandler));
+app.get('/api/v1/exports/:id/download', exportHandler.downloadExport.bind(exportHandler));
+
+// Spreadsheet import routes (upload and preview, then run with a column mapping)
+app.post('/api/v1/imports/preview', authMiddleware, multipartBody(), importHandler.previewImport.bind(importHandler));
+app.post('/api/v1/imports/:id/run', authMiddleware, importHandler.runImport.bind(importHandler));
+app.delete('/api/v1/imports/:id', authMiddleware, importHandler.deleteUpload.bind(importHandler));
+
+// Backup and restore routes
+app.get('/api/v1/backup', authMiddleware, backupHandler.exportBackup.bind(backupHandler));
+app.post('/api/v1/restore', authMiddleware, encryptedBackupBody, backupHandler.restoreBackup.bind(backupHandler));
 
 // Loan management endpoints (protected)
 app.get('/api/v1/loans', authMiddleware, loanHandler.getLoans.bind(loanHandler));
 app.post('/api/v1/loans', authMiddleware, loanHandler.createLoan.bind(loanHandler));
+app.post('/api/v1/loans/import', authMiddleware, multipartBody(), csvBody, loanHandler.importLoans.bind(loanHandler));
 app.get('/api/v1/loans/:id', authMiddleware, loanHandler.getLoan.bind(loanHandler));
 app.patch('/api/v1/loans/:id', authMiddleware, loanHandler.updateLoan.bind(loanHandler));
 app.delete('/api/v1/loans/:id', authMiddleware, loanHandler.deleteLoan.bind(loanHandler));
+app.post('/api/v1/loans/:id/restore', authMiddleware, loanHandler.restoreLoan.bind(loanHandler));
+app.get('/api/v1/loans/:id/ledger', authMiddleware, loanHandler.getLoanLedger.bind(loanHandler));
+app.get('/api/v1/loans/:id/escalations', authMiddleware, loanHandler.getLoanEscalations.bind(loanHandler));
 app.patch('/api/v1/loans/:id/status', authMiddleware, loanHandler.updateLoanStatus.bind(loanHandler));
 
+// Borrower management endpoints (protected)
+app.get('/api/v1/borrowers', authMiddleware, borrowerHandler.getBorrowers.bind(borrowerHandler));
+app.post('/api/v1/borrowers', authMiddleware, borrowerHandler.createBorrower.bind(borrowerHandler));
+app.post('/api/v1/borrowers/import', authMiddleware, csvBody, borrowerHandler.importBorrowers.bind(borrowerHandler));
+app.get('/api/v1/borrowers/:id', authMiddleware, borrowerHandler.getBorrower.bind(borrowerHandler));
+app.patch('/api/v1/borrowers/:id', authMiddleware, borrowerHandler.updateBorrower.bind(borrowerHandler));
+app.get('/api/v1/borrowers/:id/summary', authMiddleware, borrowerHandler.getBorrowerSummary.bind(borrowerHandler));
+app.get('/api/v1/borrowers/:id/score', authMiddleware, borrowerHandler.getBorrowerScore.bind(borrowerHandler));
+app.get('/api/v1/borrowers/:id/statement', authMiddleware, borrowerHandler.getBorrowerStatement.bind(borrowerHandler));
+app.post('/api/v1/borrowers/:id/merge', authMiddleware, borrowerHandler.mergeBorrower.bind(borrowerHandler));
+app.get('/api/v1/borrowers/:id/notes', authMiddleware, borrowerHandler.getBorrowerNotes.bind(borrowerHandler));
+app.post('/api/v1/borrowers/:id/notes', authMiddleware, borrowerHandler.createBorrowerNote.bind(borrowerHandler));
+app.delete('/api/v1/borrowers/:id/notes/:noteId', authMiddleware, borrowerHandler.deleteBorrowerNote.bind(borrowerHandler));
+
 // Transaction management endpoints (protected)
 app.get('/api/v1/transactions', authMiddleware, transactionHandler.getTransactions.bind(transactionHandler));
 app.post('/api/v1/transactions', authMiddleware, transactionHandler.createTransaction.bind(transactionHandler));
+app.post('/api/v1/transactions/import', authMiddleware, csvBody, transactionHandler.importTransactions.bind(transactionHandler));
+app.patch('/api/v1/transactions/batch', authMiddleware, transactionHandler.batchUpdateTransactions.bind(transactionHandler));
+app.delete('/api/v1/transactions/batch', authMiddleware, transactionHandler.batchDeleteTransactions.bind(transactionHandler));
 app.get('/api/v1/transactions/:id', authMiddleware, transactionHandler.getTransaction.bind(transactionHandler));
 app.patch('/api/v1/transactions/:id', authMiddleware, transactionHandler.updateTransaction.bind(transactionHandler));
 app.delete('/api/v1/transactions/:id', authMiddleware, transactionHandler.deleteTransaction.bind(transactionHandler));
+app.post('/api/v1/transactions/:id/restore', authMiddleware, transactionHandler.restoreTransaction.bind(transactionHandler));
+app.post('/api/v1/transactions/:id/move', authMiddleware, transactionHandler.moveTransaction.bind(transactionHandler));
+app.patch('/api/v1/transactions/:id/status', authMiddleware, transactionHandler.updateTransactionStatus.bind(transactionHandler));
 app.get('/api/v1/loans/:loanId/transactions', authMiddleware, transactionHandler.getTransactionsByLoan.bind(transactionHandler));
 
+// Attachment endpoints (protected)
+app.post('/api/v1/transactions/:id/attachments', authMiddleware, multipartBody(), attachmentHandler.uploadAttachments.bind(attachmentHandler));
+app.get('/api/v1/attachments/:id', authMiddleware, attachmentHandler.getAttachment.bind(attachmentHandler));
+app.delete('/api/v1/attachments/:id', authMiddleware, attachmentHandler.deleteAttachment.bind(attachmentHandler));
+
+// Payment plan endpoints (protected)
+app.get('/api/v1/loans/:loanId/payment-plans', authMiddleware, paymentPlanHandler.getPaymentPlans.bind(paymentPlanHandler));
+app.post('/api/v1/loans/:loanId/payment-plans', authMiddleware, paymentPlanHandler.createPaymentPlan.bind(paymentPlanHandler));
+app.get('/api/v1/loans/:loanId/expected-payments', authMiddleware, paymentPlanHandler.getExpectedPayments.bind(paymentPlanHandler));
+app.delete('/api/v1/payment-plans/:id', authMiddleware, paymentPlanHandler.deletePaymentPlan.bind(paymentPlanHandler));
+
+// Reminder rule routes
+app.get('/api/v1/reminder-rules', authMiddleware, reminderRuleHandler.getReminderRules.bind(reminderRuleHandler));
+app.post('/api/v1/reminder-rules', authMiddleware, reminderRuleHandler.createReminderRule.bind(reminderRuleHandler));
+app.patch('/api/v1/reminder-rules/:id', authMiddleware, reminderRuleHandler.updateReminderRule.bind(reminderRuleHandler));
+app.delete('/api/v1/reminder-rules/:id', authMiddleware, reminderRuleHandler.deleteReminderRule.bind(reminderRuleHandler));
+
+// Notification endpoints (protected)
+app.get('/api/v1/notifications', authMiddleware, notificationHandler.getNotifications.bind(notificationHandler));
+app.post('/api/v1/notifications/read-all', authMiddleware, notificationHandler.markAllAsRead.bind(notificationHandler));
+app.patch('/api/v1/notifications/:id/read', authMiddleware, notificationHandler.markAsRead.bind(notificationHandler));
+app.get('/api/v1/notifications/push/public-key', authMiddleware, notificationHandler.getPushPublicKey.bind(notificationHandler));
+app.post('/api/v1/notifications/push/subscriptions', authMiddleware, notificationHandler.subscribePush.bind(notificationHandler));
+app.delete('/api/v1/notifications/push/subscriptions', authMiddleware, notificationHandler.unsubscribePush.bind(notificationHandler));
+
+// Webhook routes
+app.get('/api/v1/webhooks', authMiddleware, webhookHandler.getWebhooks.bind(webhookHandler));
+app.post('/api/v1/webhooks', authMiddleware, webhookHandler.createWebhook.bind(webhookHandler));
+app.patch('/api/v1/webhooks/:id', authMiddleware, webhookHandler.updateWebhook.bind(webhookHandler));
+app.delete('/api/v1/webhooks/:id', authMiddleware, webhookHandler.deleteWebhook.bind(webhookHandler));
+app.get('/api/v1/webhooks/:id/deliveries', authMiddleware, webhookHandler.getWebhookDeliveries.bind(webhookHandler));
+app.post('/api/v1/webhooks/:id/deliveries/:notificationId/redeliver', authMiddleware, webhookHandler.redeliverWebhook.bind(webhookHandler));
+
+// Batch endpoint: several protected API calls in one round trip, optionally in one transaction
+app.post('/api/v1/batch', authMiddleware, batchHandler.executeBatch.bind(batchHandler));
+
+// API v2: list endpoints with cursor pagination (?after=<nextCursor>)
+app.get('/api/v2/loans', authMiddleware, loanHandler.getLoansV2.bind(loanHandler));
+app.get('/api/v2/transactions', authMiddleware, transactionHandler.getTransactionsV2.bind(transactionHandler));
+
+// Web frontend from STATIC_DIR only, at / and /static, with index.html for client-side routes
+const staticFiles = createStaticMiddleware();
+app.use('/static', staticFiles);
+app.use(staticFiles);
+app.use(spaFallback);
+
 // Error handling middleware
-app.use((error, req, res, next) => {
-  console.error('Unhandled error:', error);
-  res.status(500).json({
-    error: {
-      message: 'Internal server error',
-      status: 500
-    }
-  });
-});
+app.use(recoveryMiddleware);
 
 // 404 handler
 app.use('*', (req, res) => {
-  res.status(404).json({
-    error: {
-      message: 'Route not found',
-      status: 404
-    }
-  });
+  respondWithError(res, 404, 'Route not found', { code: 'ROUTE_NOT_FOUND' });
 });
 
+/**
+ * HTTP server with timeouts and a header size cap, so slow or stalled clients and hung handlers
+ * cannot hold connections forever (on Vercel the platform enforces its own limits)
+ */
+function createHttpServer() {
+  const server = http.createServer({
+    maxHeaderSize: config.server.maxHeaderBytes,
+    // How often header/request deadlines are checked (Node's default of 30s would stretch a 10s deadline to 40s)
+    connectionsCheckingInterval: 1000
+  }, app);
+  server.headersTimeout = config.server.headersTimeoutMs;
+  server.requestTimeout = config.server.requestTimeoutMs;
+  server.keepAliveTimeout = config.server.keepAliveTimeoutMs;
+  server.setTimeout(config.server.socketTimeoutMs);
+  return server;
+}
+
 // Initialize database and start server
 async function startServer() {
   try {
@@ -104,9 +345,42 @@ async function startServer() {
     await db.createTables();
     console.log('Database initialized successfully');
 
-    app.listen(PORT, () => {
+    // Start background jobs
+    scheduler.register('interest-accrual', JOB_INTERVAL_MS, () => interestAccrualJob.run());
+    scheduler.register('payment-plans', JOB_INTERVAL_MS, () => paymentPlanJob.run());
+    scheduler.register('reminders', JOB_INTERVAL_MS, () => reminderJob.run());
+    scheduler.register('overdue-escalations', JOB_INTERVAL_MS, () => escalationJob.run());
+    scheduler.register('saved-reports', JOB_INTERVAL_MS, () => savedReportJob.run());
+    scheduler.register('notification-delivery', NOTIFICATION_INTERVAL_MS, () => notificationDeliveryJob.run());
+    scheduler.register('exports', EXPORT_INTERVAL_MS, () => exportJobWorker.run());
+    scheduler.start();
+
+    const servers = [createHttpServer().listen(PORT, () => {
       console.log(`Server running on port ${PORT}`);
       console.log(`Health check: http://localhost:${PORT}/health`);
+    })];
+
+    // gRPC API on its own port (disabled unless GRPC_PORT is set)
+    if (GRPC_PORT) {
+      servers.push(createGrpcServer().listen(GRPC_PORT, () => {
+        console.log(`gRPC server running on port ${GRPC_PORT}`);
+      }));
+    }
+
+    // SIGHUP re-reads the environment and .env and applies reloadable settings
+    handleReloadSignal();
+
+    // Drain requests, event streams and running jobs before releasing the cache and database connections
+    handleShutdownSignals({
+      servers,
+      drain: [
+        { name: 'event streams', run: () => eventStream.close() },
+        { name: 'background jobs', run: () => scheduler.stop() }
+      ],
+      cleanup: [
+        { name: 'cache', run: () => cache.close() },
+        { name: 'database', run: () => db.close() }
+      ]
     });
   } catch (error) {
     console.error('Failed to start server:', error);
@@ -114,9 +388,9 @@ async function startServer() {
   }
 }
 
-// For Vercel serverless deployment
-if (process.env.VERCEL) {
-  module.exports = app;
-} else {
+module.exports = { app, startServer };
+
+// `node src/index.js` serves directly; Vercel loads the app through api/index.js and the CLI calls startServer
+if (require.main === module && !config.server.vercel) {
   startServer();
 }
\ No newline at end of file
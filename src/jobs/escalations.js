@@ -0,0 +1,120 @@
+const config = require('../config');
+const db = require('../database/db');
+const notificationService = require('../notifications');
+const { parseDate, localTodaySQL } = require('../utils/dates');
+
+const ESCALATION_STEP_NAMES = ['gentle', 'firm', 'final'];
+
+/**
+ * Pair escalation step offsets (days overdue) with the step names
+ */
+function parseEscalationSteps(days) {
+  return ESCALATION_STEP_NAMES
+    .map((step, index) => ({ step, daysOverdue: days[index] }))
+    .filter(step => Number.isInteger(step.daysOverdue) && step.daysOverdue > 0);
+}
+
+class EscalationJob {
+  /**
+   * Read per run so a config reload takes effect on the next pass
+   */
+  get steps() {
+    return parseEscalationSteps(config.reminders.escalationDays);
+  }
+
+  /**
+   * Find overdue active lent loans, their days overdue and the latest step already fired for the current due date
+   */
+  async findOverdueLoans() {
+    const result = await db.query(
+      `SELECT l.id, l.user_id, l.borrower_name, l.due_date,
+         t.today - l.due_date as days_overdue,
+         COALESCE(lb.remaining_debt, l.amount) as remaining_debt,
+         ARRAY(
+           SELECT le.step FROM loan_escalations le
+           WHERE le.loan_id = l.id AND le.due_date = l.due_date
+         ) as fired_steps
+       FROM loans l
+       JOIN users u ON u.id = l.user_id
+       CROSS JOIN LATERAL (SELECT ${localTodaySQL('u.timezone')} as today) t
+       LEFT JOIN loan_balances lb ON lb.loan_id = l.id
+       WHERE l.status = 'active' AND l.deleted_at IS NULL AND l.direction = 'lent'
+       AND l.due_date IS NOT NULL AND l.due_date < t.today`
+    );
+    return result.rows;
+  }
+
+  /**
+   * Record a fired step; returns false if another worker already fired it
+   */
+  async recordStep(client, loan, step) {
+    const result = await client.query(
+      `INSERT INTO loan_escalations (loan_id, due_date, step, days_overdue)
+       VALUES ($1, $2, $3, $4)
+       ON CONFLICT (loan_id, due_date, step) DO NOTHING
+       RETURNING id`,
+      [loan.id, loan.due_date, step.step, loan.days_overdue]
+    );
+    return result.rows.length > 0;
+  }
+
+  /**
+   * Fire the latest reached step for each overdue loan.
+   * Earlier steps that were never sent (e.g. after downtime) are skipped rather than sent all at once.
+   */
+  async run() {
+    const steps = this.steps;
+    if (steps.length === 0) {
+      return;
+    }
+
+    let fired = 0;
+    const loans = await this.findOverdueLoans();
+
+    for (const loan of loans) {
+      const reached = steps.filter(step => loan.days_overdue >= step.daysOverdue);
+      if (reached.length === 0) {
+        continue;
+      }
+
+      const step = reached[reached.length - 1];
+      const alreadyFired = steps
+        .slice(steps.indexOf(step))
+        .some(later => loan.fired_steps.includes(later.step));
+      if (alreadyFired) {
+        continue;
+      }
+
+      try {
+        const recorded = await db.transaction(async client => {
+          if (!(await this.recordStep(client, loan, step))) {
+            return false;
+          }
+          await notificationService.emit(`loan.escalation.${step.step}`, {
+            userId: loan.user_id,
+            payload: {
+              loanId: loan.id,
+              borrowerName: loan.borrower_name,
+              dueDate: parseDate(loan.due_date),
+              daysOverdue: loan.days_overdue,
+              step: step.step,
+              remainingDebt: parseFloat(loan.remaining_debt)
+            }
+          }, client);
+          return true;
+        });
+        if (recorded) {
+          fired++;
+        }
+      } catch (error) {
+        console.error(`Escalation for loan ${loan.id} failed:`, error);
+      }
+    }
+
+    if (fired > 0) {
+      console.log(`Overdue escalations: ${fired} steps fired`);
+    }
+  }
+}
+
+module.exports = new EscalationJob();
@@ -0,0 +1,97 @@
+const config = require('../config');
+const db = require('../database/db');
+const storage = require('../storage');
+const exportHandler = require('../handlers/export');
+
+const EXPORT_BATCH_SIZE = config.jobs.exportBatchSize;
+const EXPORT_RETENTION_HOURS = config.jobs.exportRetentionHours;
+
+class ExportJobWorker {
+  /**
+   * Claim pending export jobs (and ones stuck processing after a crash) so other workers skip them
+   */
+  async claimPendingJobs() {
+    const result = await db.query(
+      `UPDATE export_jobs
+       SET status = 'processing', started_at = CURRENT_TIMESTAMP
+       WHERE id IN (
+         SELECT id FROM export_jobs
+         WHERE status = 'pending'
+            OR (status = 'processing' AND started_at < CURRENT_TIMESTAMP - INTERVAL '1 hour')
+         ORDER BY created_at ASC
+         LIMIT $1
+         FOR UPDATE SKIP LOCKED
+       )
+       RETURNING *`,
+      [EXPORT_BATCH_SIZE]
+    );
+    return result.rows;
+  }
+
+  /**
+   * Generate one export file into storage
+   */
+  async processJob(job) {
+    try {
+      let file = await exportHandler.generateExport(job.user_id, job.format, job.params);
+      if (job.encryption_key) {
+        const [salt, key, iv] = job.encryption_key.split(':').map(part => Buffer.from(part, 'hex'));
+        file = exportHandler.encryptExport(file, { salt, key, iv });
+      }
+
+      const storageKey = `exports/${job.user_id}/${job.id}/${file.fileName}`;
+      await storage.save(storageKey, file.content, file.contentType);
+
+      await db.query(
+        `UPDATE export_jobs
+         SET status = 'completed', storage_key = $1, file_name = $2, content_type = $3, size = $4,
+             encryption_key = NULL, completed_at = CURRENT_TIMESTAMP, expires_at = CURRENT_TIMESTAMP + $5::interval
+         WHERE id = $6`,
+        [storageKey, file.fileName, file.contentType, file.content.length, `${EXPORT_RETENTION_HOURS} hours`, job.id]
+      );
+    } catch (error) {
+      console.error(`Export job ${job.id} failed:`, error);
+      await db.query(
+        `UPDATE export_jobs
+         SET status = 'failed', error = $1, encryption_key = NULL, completed_at = CURRENT_TIMESTAMP
+         WHERE id = $2`,
+        [error.message, job.id]
+      );
+    }
+  }
+
+  /**
+   * Remove expired export files and mark their jobs expired
+   */
+  async removeExpired() {
+    const result = await db.query(
+      `UPDATE export_jobs
+       SET status = 'expired'
+       WHERE status = 'completed' AND expires_at <= CURRENT_TIMESTAMP
+       RETURNING storage_key`
+    );
+
+    for (const row of result.rows) {
+      await storage.delete(row.storage_key);
+    }
+  }
+
+  /**
+   * Generate pending exports and clean up expired files
+   */
+  async run() {
+    const jobs = await this.claimPendingJobs();
+
+    for (const job of jobs) {
+      await this.processJob(job);
+    }
+
+    await this.removeExpired();
+
+    if (jobs.length > 0) {
+      console.log(`Exports: ${jobs.length} export jobs processed`);
+    }
+  }
+}
+
+module.exports = new ExportJobWorker();
@@ -0,0 +1,103 @@
+const config = require('../config');
+const db = require('../database/db');
+const { roundCurrency } = require('../utils/currency');
+const { calculateFlatInterest } = require('../utils/interest');
+
+class InterestAccrualJob {
+  constructor() {
+    this.period = config.loans.interestAccrualPeriod;
+  }
+
+  /**
+   * Find open (active or overdue) interest-bearing loans that are due for accrual
+   */
+  async findDueLoans() {
+    const dueCondition = this.period === 'monthly'
+      ? `COALESCE(l.last_accrued_at, l.loan_date) + INTERVAL '1 month' <= CURRENT_DATE`
+      : `COALESCE(l.last_accrued_at, l.loan_date) < CURRENT_DATE`;
+
+    const result = await db.query(
+      `SELECT
+         l.id, l.user_id, l.amount, l.currency, l.interest_rate, l.interest_type, l.term_months,
+         CURRENT_DATE - COALESCE(l.last_accrued_at, l.loan_date) as days,
+         COALESCE(lb.total_paid, 0) as total_paid,
+         COALESCE((
+           SELECT SUM(t.amount) FROM transactions t
+           WHERE t.loan_id = l.id AND t.transaction_type = 'interest'
+           AND t.status <> 'rejected' AND t.deleted_at IS NULL
+         ), 0) as interest_posted
+       FROM loans l
+       LEFT JOIN loan_balances lb ON lb.loan_id = l.id
+       WHERE l.status IN ('active', 'overdue')
+       AND l.deleted_at IS NULL
+       AND l.interest_rate > 0
+       AND ${dueCondition}`
+    );
+
+    return result.rows;
+  }
+
+  /**
+   * Calculate accrued interest for a loan over the given number of days.
+   * Flat-rate interest stops at the total for the term, less the interest already posted.
+   */
+  calculateAccrual(loan) {
+    const principal = parseFloat(loan.amount);
+    const rate = parseFloat(loan.interest_rate);
+
+    if (loan.interest_type === 'flat') {
+      const accrued = principal * (rate / 100) / 365 * loan.days;
+      if (!loan.term_months) {
+        return roundCurrency(accrued, loan.currency);
+      }
+      const remaining = calculateFlatInterest(principal, rate, loan.term_months, loan.currency) - parseFloat(loan.interest_posted);
+      return roundCurrency(Math.max(Math.min(accrued, remaining), 0), loan.currency);
+    }
+
+    const base = Math.max(principal - parseFloat(loan.total_paid), 0);
+    return roundCurrency(base * (rate / 100) / 365 * loan.days, loan.currency);
+  }
+
+  /**
+   * Post an interest entry and advance the loan's accrual date atomically
+   */
+  async accrueLoan(loan) {
+    const amount = this.calculateAccrual(loan);
+
+    await db.transaction(async client => {
+      if (amount > 0) {
+        await client.query(
+          `INSERT INTO transactions (loan_id, user_id, amount, transaction_type, transaction_date, description)
+           VALUES ($1, $2, $3, 'interest', CURRENT_DATE, $4)`,
+          [loan.id, loan.user_id, amount, `Interest accrual (${loan.days} days)`]
+        );
+      }
+
+      await client.query(
+        'UPDATE loans SET last_accrued_at = CURRENT_DATE WHERE id = $1',
+        [loan.id]
+      );
+    });
+  }
+
+  /**
+   * Run interest accrual for all due loans
+   */
+  async run() {
+    const loans = await this.findDueLoans();
+
+    for (const loan of loans) {
+      try {
+        await this.accrueLoan(loan);
+      } catch (error) {
+        console.error(`Interest accrual error for loan ${loan.id}:`, error);
+      }
+    }
+
+    if (loans.length > 0) {
+      console.log(`Interest accrual posted for ${loans.length} loans`);
+    }
+  }
+}
+
+module.exports = new InterestAccrualJob();
@@ -0,0 +1,113 @@
+const config = require('../config');
+const db = require('../database/db');
+
+// First key of the two-key advisory lock, so job locks cannot collide with other advisory lock users
+const LOCK_NAMESPACE = 0x4c4d4a42;
+
+/**
+ * Cluster-wide job locks on Postgres session advisory locks, so a job runs on one instance at a time.
+ * All locks live on one dedicated connection rather than one per running job, so long jobs do not
+ * hold up pool connections; if that connection drops, Postgres releases its locks and another
+ * instance can take over. Needs a session-level connection (not a transaction-mode pooler such as
+ * PgBouncer in transaction mode); JOB_DISTRIBUTED_LOCKS=false turns locking off for single instances.
+ */
+class JobLock {
+  constructor() {
+    this.client = null;
+    this.connecting = null;
+    this.onConnectionError = error => {
+      console.error('Job lock connection lost; its locks were released:', error.message);
+      this.discard(error);
+    };
+  }
+
+  /**
+   * The lock connection, checked out on first use and replaced after it fails
+   */
+  async session() {
+    if (this.client) {
+      return this.client;
+    }
+    if (!this.connecting) {
+      this.connecting = db.pool.connect()
+        .then(client => {
+          client.on('error', this.onConnectionError);
+          this.client = client;
+          return client;
+        })
+        .finally(() => {
+          this.connecting = null;
+        });
+    }
+    return this.connecting;
+  }
+
+  /**
+   * Destroy the lock connection after an error (its locks go with it)
+   */
+  discard(error) {
+    const client = this.client;
+    if (client) {
+      this.client = null;
+      client.removeListener('error', this.onConnectionError);
+      client.release(error);
+    }
+  }
+
+  /**
+   * Take the lock for a job without waiting; false when another instance holds it
+   */
+  async tryAcquire(name) {
+    if (!config.jobs.distributedLocks) {
+      return true;
+    }
+
+    const client = await this.session();
+    try {
+      const result = await client.query('SELECT pg_try_advisory_lock($1, hashtext($2)) AS locked', [LOCK_NAMESPACE, name]);
+      return result.rows[0].locked;
+    } catch (error) {
+      this.discard(error);
+      throw error;
+    }
+  }
+
+  /**
+   * Release a job lock taken by tryAcquire
+   */
+  async release(name) {
+    if (!config.jobs.distributedLocks || !this.client) {
+      return;
+    }
+
+    try {
+      await this.client.query('SELECT pg_advisory_unlock($1, hashtext($2))', [LOCK_NAMESPACE, name]);
+    } catch (error) {
+      // Dropping the connection is the only way left to release the lock
+      console.error(`Job lock release error for ${name}:`, error.message);
+      this.discard(error);
+    }
+  }
+
+  /**
+   * Return the lock connection to the pool, releasing every lock still held
+   */
+  async close() {
+    const client = this.client;
+    if (!client) {
+      return;
+    }
+
+    try {
+      await client.query('SELECT pg_advisory_unlock_all()');
+      this.client = null;
+      client.removeListener('error', this.onConnectionError);
+      client.release();
+    } catch (error) {
+      console.error('Job lock close error:', error.message);
+      this.discard(error);
+    }
+  }
+}
+
+module.exports = new JobLock();
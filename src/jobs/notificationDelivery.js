@@ -0,0 +1,89 @@
+const config = require('../config');
+const db = require('../database/db');
+const notificationService = require('../notifications');
+
+const BATCH_SIZE = 50;
+
+class NotificationDeliveryJob {
+  constructor() {
+    this.maxAttempts = config.jobs.notificationMaxAttempts;
+    this.baseDelaySeconds = config.jobs.notificationRetryDelaySeconds;
+  }
+
+  /**
+   * Claim a batch of due notifications so concurrent workers don't double-send
+   */
+  async claimBatch() {
+    const result = await db.query(
+      `UPDATE notifications
+       SET status = 'sending', attempts = attempts + 1
+       WHERE id IN (
+         SELECT id FROM notifications
+         WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+         ORDER BY next_attempt_at ASC
+         LIMIT $1
+         FOR UPDATE SKIP LOCKED
+       )
+       RETURNING *`,
+      [BATCH_SIZE]
+    );
+
+    return result.rows;
+  }
+
+  /**
+   * Deliver a single notification, scheduling a retry with exponential backoff on failure
+   */
+  async deliver(notification) {
+    const channel = notificationService.getChannel(notification.channel);
+
+    try {
+      if (!channel) {
+        throw new Error(`Channel ${notification.channel} is not registered`);
+      }
+
+      await channel.send(notification);
+
+      await db.query(
+        `UPDATE notifications SET status = 'sent', sent_at = CURRENT_TIMESTAMP, last_error = NULL
+         WHERE id = $1`,
+        [notification.id]
+      );
+    } catch (error) {
+      const failed = notification.attempts >= this.maxAttempts;
+      const delaySeconds = this.baseDelaySeconds * Math.pow(2, notification.attempts - 1);
+
+      await db.query(
+        `UPDATE notifications
+         SET status = $1, last_error = $2,
+             next_attempt_at = CURRENT_TIMESTAMP + ($3 || ' seconds')::interval
+         WHERE id = $4`,
+        [failed ? 'failed' : 'pending', error.message, delaySeconds, notification.id]
+      );
+
+      console.error(`Notification ${notification.id} delivery via ${notification.channel} failed:`, error.message);
+    }
+  }
+
+  /**
+   * Deliver all due notifications
+   */
+  async run() {
+    // Recover notifications left in 'sending' by a crashed worker
+    await db.query(
+      `UPDATE notifications SET status = 'pending'
+       WHERE status = 'sending' AND next_attempt_at < CURRENT_TIMESTAMP - INTERVAL '10 minutes'`
+    );
+
+    let batch = await this.claimBatch();
+
+    while (batch.length > 0) {
+      for (const notification of batch) {
+        await this.deliver(notification);
+      }
+      batch = await this.claimBatch();
+    }
+  }
+}
+
+module.exports = new NotificationDeliveryJob();
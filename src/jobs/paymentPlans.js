@@ -0,0 +1,95 @@
+const config = require('../config');
+const db = require('../database/db');
+const { frequencyIntervalSQL } = require('../utils/interest');
+const { localTodaySQL } = require('../utils/dates');
+
+const MAX_CATCH_UP_PERIODS = 366;
+
+// SQL expression mapping plan frequency to its period interval
+const INTERVAL_SQL = frequencyIntervalSQL('pp.frequency');
+
+class PaymentPlanJob {
+  constructor() {
+    this.graceDays = config.loans.paymentGraceDays;
+  }
+
+  /**
+   * Create expected payment entries for plans that reached their next due date (in the owner's time zone)
+   */
+  async generateExpectedPayments() {
+    let generated = 0;
+
+    // Loop to catch up plans that missed several periods (e.g. after downtime)
+    for (let i = 0; i < MAX_CATCH_UP_PERIODS; i++) {
+      const result = await db.query(
+        `WITH due AS (
+           SELECT pp.id, pp.loan_id, pp.amount, pp.next_due_date
+           FROM payment_plans pp
+           JOIN loans l ON l.id = pp.loan_id
+           JOIN users u ON u.id = l.user_id
+           WHERE pp.active = true
+           AND l.status = 'active'
+           AND l.deleted_at IS NULL
+           AND pp.next_due_date <= ${localTodaySQL('u.timezone')}
+           AND (pp.end_date IS NULL OR pp.next_due_date <= pp.end_date)
+         ), inserted AS (
+           INSERT INTO expected_payments (plan_id, loan_id, due_date, amount)
+           SELECT id, loan_id, next_due_date, amount FROM due
+           ON CONFLICT (plan_id, due_date) DO NOTHING
+         )
+         UPDATE payment_plans pp
+         SET next_due_date = (pp.next_due_date + ${INTERVAL_SQL})::date, updated_at = CURRENT_TIMESTAMP
+         FROM due
+         WHERE pp.id = due.id`
+      );
+
+      if (result.rowCount === 0) {
+        break;
+      }
+      generated += result.rowCount;
+    }
+
+    return generated;
+  }
+
+  /**
+   * Mark pending expected payments past the grace period (in the owner's time zone) as paid or missed
+   */
+  async reconcileExpectedPayments() {
+    const result = await db.query(
+      `UPDATE expected_payments ep
+       SET status = CASE WHEN COALESCE((
+         SELECT SUM(t.amount) FROM transactions t
+         WHERE t.loan_id = ep.loan_id
+         AND t.deleted_at IS NULL
+         AND t.transaction_type = 'payment'
+         AND t.status = 'confirmed'
+         AND COALESCE(t.transaction_date, t.created_at::date) > (ep.due_date - ${INTERVAL_SQL})::date
+         AND COALESCE(t.transaction_date, t.created_at::date) <= ep.due_date + $1::int
+       ), 0) >= ep.amount THEN 'paid' ELSE 'missed' END
+       FROM payment_plans pp
+       JOIN loans l ON l.id = pp.loan_id
+       JOIN users u ON u.id = l.user_id
+       WHERE pp.id = ep.plan_id
+       AND ep.status = 'pending'
+       AND ep.due_date + $1::int < ${localTodaySQL('u.timezone')}`,
+      [this.graceDays]
+    );
+
+    return result.rowCount;
+  }
+
+  /**
+   * Run payment plan processing
+   */
+  async run() {
+    const generated = await this.generateExpectedPayments();
+    const reconciled = await this.reconcileExpectedPayments();
+
+    if (generated > 0 || reconciled > 0) {
+      console.log(`Payment plans: ${generated} expected payments created, ${reconciled} reconciled`);
+    }
+  }
+}
+
+module.exports = new PaymentPlanJob();
@@ -0,0 +1,165 @@
+const config = require('../config');
+const db = require('../database/db');
+const notificationService = require('../notifications');
+const { parseDate, localTodaySQL } = require('../utils/dates');
+
+class ReminderJob {
+  /**
+   * Read per run so a config reload takes effect on the next pass
+   */
+  get daysBefore() {
+    return config.reminders.daysBefore;
+  }
+
+  /**
+   * Record that a reminder was sent; returns false if it was already sent
+   */
+  async claimReminder(loanId, reminderKey) {
+    const result = await db.query(
+      `INSERT INTO loan_reminders (loan_id, reminder_key)
+       VALUES ($1, $2)
+       ON CONFLICT (loan_id, reminder_key) DO NOTHING
+       RETURNING id`,
+      [loanId, reminderKey]
+    );
+    return result.rows.length > 0;
+  }
+
+  /**
+   * Build notification payload for a loan row
+   */
+  buildPayload(loan) {
+    return {
+      loanId: loan.id,
+      borrowerName: loan.borrower_name,
+      dueDate: parseDate(loan.due_date),
+      daysUntilDue: loan.days_until_due,
+      daysOverdue: Math.max(-loan.days_until_due, 0),
+      remainingDebt: parseFloat(loan.remaining_debt)
+    };
+  }
+
+  /**
+   * Find active lent loans with a due date and their remaining balance (the user is not reminded of their own debts).
+   * Loans covered by user-defined reminder rules are skipped unless withRules is set,
+   * in which case one row is returned per matching rule.
+   * Conditions compare against t.today, the date in the loan owner's time zone.
+   */
+  async findLoans(condition, params, { withRules = false, ruleType = null } = {}) {
+    const ruleJoin = withRules
+      ? `JOIN reminder_rules r ON r.user_id = l.user_id AND r.active = true
+           AND (r.loan_id IS NULL OR r.loan_id = l.id) AND r.rule_type = '${ruleType}'`
+      : '';
+    const ruleCondition = withRules
+      ? ''
+      : `AND NOT EXISTS (
+           SELECT 1 FROM reminder_rules r WHERE r.user_id = l.user_id AND r.active = true
+           AND (r.loan_id IS NULL OR r.loan_id = l.id)
+         )`;
+
+    const result = await db.query(
+      `SELECT l.id, l.user_id, l.borrower_name, l.due_date,
+         l.due_date - t.today as days_until_due,
+         COALESCE(lb.remaining_debt, l.amount) as remaining_debt
+         ${withRules ? ', r.id as rule_id, r.repeat_every_days' : ''}
+       FROM loans l
+       JOIN users u ON u.id = l.user_id
+       CROSS JOIN LATERAL (SELECT ${localTodaySQL('u.timezone')} as today) t
+       LEFT JOIN loan_balances lb ON lb.loan_id = l.id
+       ${ruleJoin}
+       WHERE l.status = 'active' AND l.deleted_at IS NULL AND l.direction = 'lent' AND l.due_date IS NOT NULL
+       AND ${condition} ${ruleCondition}`,
+      params
+    );
+    return result.rows;
+  }
+
+  /**
+   * Claim and emit a reminder for a loan
+   */
+  async remind(event, loan, key) {
+    if (!(await this.claimReminder(loan.id, key))) {
+      return false;
+    }
+    await notificationService.emit(event, { userId: loan.user_id, payload: this.buildPayload(loan) });
+    return true;
+  }
+
+  /**
+   * Send reminders N days before due date
+   */
+  async sendDueSoonReminders() {
+    let sent = 0;
+
+    if (this.daysBefore.length > 0) {
+      const loans = await this.findLoans('l.due_date - t.today = ANY($1::int[])', [this.daysBefore]);
+      for (const loan of loans) {
+        const key = `due_soon:${loan.days_until_due}:${parseDate(loan.due_date)}`;
+        if (await this.remind('loan.due_soon', loan, key)) {
+          sent++;
+        }
+      }
+    }
+
+    const ruleLoans = await this.findLoans(
+      'l.due_date - t.today = ANY(r.days_before)',
+      [],
+      { withRules: true, ruleType: 'before_due' }
+    );
+    for (const loan of ruleLoans) {
+      // Keyed by day rather than rule so overlapping rules don't send twice
+      const key = `due_soon:${loan.days_until_due}:${parseDate(loan.due_date)}`;
+      if (await this.remind('loan.due_soon', loan, key)) {
+        sent++;
+      }
+    }
+
+    return sent;
+  }
+
+  /**
+   * Send a reminder once a loan slips overdue, repeating per overdue rules
+   */
+  async sendOverdueReminders() {
+    let sent = 0;
+
+    const loans = await this.findLoans('l.due_date < t.today', []);
+    for (const loan of loans) {
+      const key = `overdue:${parseDate(loan.due_date)}`;
+      if (await this.remind('loan.overdue', loan, key)) {
+        sent++;
+      }
+    }
+
+    const ruleLoans = await this.findLoans(
+      'l.due_date < t.today',
+      [],
+      { withRules: true, ruleType: 'overdue' }
+    );
+    for (const loan of ruleLoans) {
+      // Period index since the loan went overdue; a missed run catches up on the next one
+      const daysOverdue = -loan.days_until_due;
+      const period = loan.repeat_every_days ? Math.floor((daysOverdue - 1) / loan.repeat_every_days) : 0;
+      const key = `overdue:${parseDate(loan.due_date)}:${loan.rule_id}:${period}`;
+      if (await this.remind('loan.overdue', loan, key)) {
+        sent++;
+      }
+    }
+
+    return sent;
+  }
+
+  /**
+   * Run reminder processing
+   */
+  async run() {
+    const dueSoon = await this.sendDueSoonReminders();
+    const overdue = await this.sendOverdueReminders();
+
+    if (dueSoon > 0 || overdue > 0) {
+      console.log(`Reminders queued: ${dueSoon} due soon, ${overdue} overdue`);
+    }
+  }
+}
+
+module.exports = new ReminderJob();
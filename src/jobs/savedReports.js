@@ -0,0 +1,60 @@
+const db = require('../database/db');
+const notificationService = require('../notifications');
+const { REPORT_SCHEDULES, runReport, formatReportText } = require('../utils/reports');
+const { userCalendar } = require('../utils/dates');
+
+// SQL expression mapping report schedule to its interval
+const SCHEDULE_INTERVAL_SQL = `CASE schedule ${Object.entries(REPORT_SCHEDULES)
+  .map(([schedule, interval]) => `WHEN '${schedule}' THEN INTERVAL '${interval}'`)
+  .join(' ')} END`;
+
+class SavedReportJob {
+  /**
+   * Claim scheduled reports that are due, advancing their next run so other workers skip them
+   */
+  async claimDueReports() {
+    const result = await db.query(
+      `UPDATE saved_reports
+       SET next_run_at = GREATEST(next_run_at + ${SCHEDULE_INTERVAL_SQL}, CURRENT_TIMESTAMP),
+           last_run_at = CURRENT_TIMESTAMP
+       WHERE id IN (
+         SELECT id FROM saved_reports
+         WHERE schedule IS NOT NULL AND next_run_at <= CURRENT_TIMESTAMP
+         FOR UPDATE SKIP LOCKED
+       )
+       RETURNING *, (SELECT calendar FROM users WHERE users.id = saved_reports.user_id) as user_calendar,
+         (SELECT locale FROM users WHERE users.id = saved_reports.user_id) as user_locale`
+    );
+    return result.rows;
+  }
+
+  /**
+   * Run scheduled reports and queue them for email delivery
+   */
+  async run() {
+    const reports = await this.claimDueReports();
+
+    for (const report of reports) {
+      try {
+        const result = await runReport(db, report.user_id, report.definition);
+        await notificationService.emit('report.scheduled', {
+          userId: report.user_id,
+          channels: ['email'],
+          payload: {
+            reportId: report.id,
+            reportName: report.name,
+            summary: formatReportText(report.definition, result, userCalendar({ calendar: report.user_calendar }), report.user_locale)
+          }
+        });
+      } catch (error) {
+        console.error(`Scheduled report ${report.id} failed:`, error);
+      }
+    }
+
+    if (reports.length > 0) {
+      console.log(`Saved reports: ${reports.length} scheduled reports queued`);
+    }
+  }
+}
+
+module.exports = new SavedReportJob();
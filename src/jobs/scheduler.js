@@ -0,0 +1,68 @@
+const jobLock = require('./lock');
+
+class Scheduler {
+  constructor() {
+    this.jobs = [];
+    this.timers = [];
+  }
+
+  /**
+   * Register a job to run at a fixed interval
+   */
+  register(name, intervalMs, handler) {
+    this.jobs.push({ name, intervalMs, handler, running: null });
+  }
+
+  /**
+   * Run a single job, skipping if the previous run is still in progress here
+   * or the job is running on another instance (see lock.js)
+   */
+  async runJob(job) {
+    if (job.running) {
+      return;
+    }
+
+    job.running = (async () => {
+      let locked = false;
+      try {
+        locked = await jobLock.tryAcquire(job.name);
+        if (!locked) {
+          console.debug(`Job ${job.name} is running on another instance, skipping`);
+          return;
+        }
+        await job.handler();
+      } catch (error) {
+        console.error(`Job ${job.name} error:`, error);
+      } finally {
+        if (locked) {
+          await jobLock.release(job.name);
+        }
+      }
+    })();
+    await job.running;
+    job.running = null;
+  }
+
+  /**
+   * Start all registered jobs
+   */
+  start() {
+    this.jobs.forEach(job => {
+      this.runJob(job);
+      this.timers.push(setInterval(() => this.runJob(job), job.intervalMs));
+      console.log(`Scheduled job ${job.name} every ${job.intervalMs}ms`);
+    });
+  }
+
+  /**
+   * Stop scheduling jobs and resolve once the runs in progress finish and their locks are released
+   */
+  async stop() {
+    this.timers.forEach(timer => clearInterval(timer));
+    this.timers = [];
+    await Promise.all(this.jobs.map(job => job.running).filter(Boolean));
+    await jobLock.close();
+  }
+}
+
+module.exports = new Scheduler();
@@ -0,0 +1,123 @@
+const config = require('../config');
+const { logAPICall } = require('../utils/response');
+
+// Format (json or text), sample rate and verbosity are read from config.logging per request so a reload applies
+// Header, query and body keys whose values never reach the logs
+const REDACTED_KEYS = /authorization|cookie|csrf|passw(or)?d|passphrase|secret|token|signature|api[-_]?key/i;
+const REDACTED = '[REDACTED]';
+
+/**
+ * Copy of a value with credential-looking keys replaced, at any depth
+ */
+function redact(value) {
+  if (Array.isArray(value)) {
+    return value.map(redact);
+  }
+  if (value && typeof value === 'object') {
+    return Object.fromEntries(Object.entries(value).map(([key, nested]) => [
+      key,
+      REDACTED_KEYS.test(key) ? REDACTED : redact(nested)
+    ]));
+  }
+  return value;
+}
+
+/**
+ * Whether to log a request; successful ones are sampled at ACCESS_LOG_SAMPLE_RATE
+ */
+function shouldLog(status, sampleRate) {
+  return status >= 400 || sampleRate >= 1 || Math.random() < sampleRate;
+}
+
+/**
+ * Count the bytes actually written to the response body (Content-Length is absent for streamed responses)
+ */
+function countBytes(res) {
+  const counter = { bytes: 0 };
+  const { write, end } = res;
+  const add = (chunk, encoding) => {
+    if (chunk && typeof chunk !== 'function') {
+      counter.bytes += Buffer.isBuffer(chunk) ? chunk.length : Buffer.byteLength(chunk, typeof encoding === 'string' ? encoding : 'utf8');
+    }
+  };
+
+  res.write = function countedWrite(chunk, encoding, ...rest) {
+    add(chunk, encoding);
+    return write.call(this, chunk, encoding, ...rest);
+  };
+  res.end = function countedEnd(chunk, encoding, ...rest) {
+    add(chunk, encoding);
+    return end.call(this, chunk, encoding, ...rest);
+  };
+  return counter;
+}
+
+/**
+ * Structured access log entry for a finished (or aborted) request
+ */
+function buildEntry(req, res, startedAt, bytes, aborted) {
+  const { accessLogSampleRate, accessLogVerbose } = config.logging;
+  const entry = {
+    time: new Date().toISOString(),
+    level: res.statusCode >= 500 ? 'error' : res.statusCode >= 400 ? 'warn' : 'info',
+    msg: 'request',
+    request_id: req.id,
+    method: req.method,
+    path: req.originalUrl.split('?')[0],
+    route: req.route ? `${req.baseUrl || ''}${req.route.path}` : undefined,
+    query: Object.keys(req.query || {}).length > 0 ? redact(req.query) : undefined,
+    status: res.statusCode,
+    latency_ms: Number(process.hrtime.bigint() - startedAt) / 1e6,
+    bytes,
+    user_id: req.user ? req.user.id : null,
+    ip: req.ip,
+    user_agent: req.get('User-Agent') || undefined,
+    aborted: aborted || undefined,
+    sample_rate: res.statusCode < 400 && accessLogSampleRate < 1 ? accessLogSampleRate : undefined
+  };
+
+  // Request headers, and the body of failed requests, for debugging
+  if (accessLogVerbose) {
+    entry.headers = redact(req.headers);
+    if (res.statusCode >= 400 && req.body && typeof req.body === 'object' && !Buffer.isBuffer(req.body)) {
+      entry.body = redact(req.body);
+    }
+  }
+  return entry;
+}
+
+/**
+ * Emit one access log line per request with user, status, latency and response size
+ */
+function accessLogMiddleware(req, res, next) {
+  const startedAt = process.hrtime.bigint();
+  const counter = countBytes(res);
+  let logged = false;
+
+  const log = aborted => {
+    if (logged) {
+      return;
+    }
+    logged = true;
+    if (!shouldLog(res.statusCode, config.logging.accessLogSampleRate)) {
+      return;
+    }
+
+    // text is the legacy one-line format; json is one object per line
+    if (config.logging.accessLogFormat === 'text') {
+      logAPICall(req.method, req.path, req.user ? req.user.id : 'anonymous', res.statusCode);
+      return;
+    }
+    // Written directly: the entry already carries request_id, so skip the console prefix
+    process.stdout.write(`${JSON.stringify(buildEntry(req, res, startedAt, counter.bytes, aborted))}\n`);
+  };
+
+  res.on('finish', () => log(false));
+  res.on('close', () => log(!res.writableFinished));
+  next();
+}
+
+module.exports = {
+  accessLogMiddleware,
+  redact
+};
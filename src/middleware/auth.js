@@ -1,30 +1,36 @@
 const { validateJWT, extractTokenFromHeader } = require('../utils/jwt');
 const { respondWithError } = require('../utils/response');
+const { readSessionToken, isValidCsrf } = require('./session');
 const db = require('../database/db');
 const { User } = require('../models');
 
 /**
- * Authentication middleware
+ * Authentication middleware: a Bearer token, or else the session cookie (which requires CSRF on writes)
  */
 async function authMiddleware(req, res, next) {
   try {
     const authHeader = req.headers.authorization;
-    
-    if (!authHeader) {
-      return respondWithError(res, 401, 'Authorization header required');
+    const sessionToken = authHeader ? null : readSessionToken(req);
+
+    if (!authHeader && !sessionToken) {
+      return respondWithError(res, 401, 'Authorization header required', { code: 'AUTH_REQUIRED' });
     }
 
-    const token = extractTokenFromHeader(authHeader);
+    const token = authHeader ? extractTokenFromHeader(authHeader) : sessionToken;
     const decoded = validateJWT(token);
 
+    if (sessionToken && !isValidCsrf(req, decoded)) {
+      return respondWithError(res, 403, 'Missing or invalid CSRF token', { code: 'CSRF_INVALID' });
+    }
+
     // Verify user still exists in database
     const result = await db.query(
-      'SELECT * FROM users WHERE id = $1',
+      'SELECT * FROM users WHERE id = $1 AND deleted_at IS NULL',
       [decoded.userId]
     );
 
     if (result.rows.length === 0) {
-      return respondWithError(res, 401, 'User not found');
+      return respondWithError(res, 401, 'User not found', { code: 'USER_NOT_FOUND' });
     }
 
     // Attach user to request
@@ -36,14 +42,21 @@ async function authMiddleware(req, res, next) {
       fullName: userData.full_name,
       phone: userData.phone,
       address: userData.address,
+      emailNotifications: userData.email_notifications,
+      smsBorrowerReminders: userData.sms_borrower_reminders,
+      preferredCurrency: userData.preferred_currency,
+      calendar: userData.calendar,
+      timezone: userData.timezone,
+      locale: userData.locale,
       createdAt: userData.created_at,
       updatedAt: userData.updated_at
     });
+    req.session = sessionToken ? { csrfToken: decoded.csrf } : null;
 
     next();
   } catch (error) {
     console.error('Auth middleware error:', error);
-    return respondWithError(res, 401, 'Invalid or expired token');
+    return respondWithError(res, 401, 'Invalid or expired token', { code: 'INVALID_TOKEN' });
   }
 }
 
@@ -0,0 +1,60 @@
+const config = require('../config');
+const cache = require('../cache');
+
+const CACHE_TTL_SECONDS = config.cache.dashboardTtlSeconds;
+
+/**
+ * Serve GET responses from the user's cache (keyed by URL) and cache successful JSON responses.
+ * Runs after authMiddleware so the key is per user.
+ */
+async function cacheMiddleware(req, res, next) {
+  if (req.method !== 'GET' || !req.user) {
+    return next();
+  }
+
+  const key = `response:${req.originalUrl}`;
+  // Captured before the handler reads the database, so a write landing meanwhile orphans the entry
+  const version = await cache.getVersion(req.user.id);
+  const cached = await cache.get(req.user.id, key, version);
+  if (cached) {
+    res.setHeader('X-Cache', 'HIT');
+    return res.status(cached.status).json(cached.body);
+  }
+
+  res.setHeader('X-Cache', 'MISS');
+  const json = res.json.bind(res);
+  res.json = body => {
+    if (res.statusCode === 200) {
+      cache.set(req.user.id, key, { status: res.statusCode, body }, CACHE_TTL_SECONDS, version);
+    }
+    return json(body);
+  };
+
+  next();
+}
+
+/**
+ * Invalidate the user's cached responses when a write succeeds, before the response is sent
+ * so a client refreshing right after never reads stale data
+ */
+function invalidateOnWrite(req, res, next) {
+  if (req.method === 'GET' || req.method === 'HEAD' || req.method === 'OPTIONS') {
+    return next();
+  }
+
+  const json = res.json.bind(res);
+  res.json = body => {
+    if (!req.user || res.statusCode >= 400) {
+      return json(body);
+    }
+    cache.invalidateUser(req.user.id).then(() => json(body));
+    return res;
+  };
+
+  next();
+}
+
+module.exports = {
+  cacheMiddleware,
+  invalidateOnWrite
+};
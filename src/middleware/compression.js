@@ -0,0 +1,117 @@
+const zlib = require('zlib');
+const config = require('../config');
+
+// Small bodies are not worth the CPU and the encoding overhead
+const COMPRESSION_THRESHOLD_BYTES = config.server.compressionThresholdBytes;
+// Text-like types only; XLSX, ZIP archives, PDFs and images are already compressed
+const COMPRESSIBLE_TYPES = /^(text\/(?!event-stream)|application\/(json|vnd\.api\+json|javascript|xml|x-ofx|qif)|image\/svg\+xml)/i;
+
+/**
+ * Pick the preferred supported encoding from Accept-Encoding (brotli over gzip, honoring q=0)
+ */
+function negotiateEncoding(header) {
+  const accepted = {};
+  String(header || '').split(',').forEach(part => {
+    const [name, ...params] = part.trim().toLowerCase().split(';');
+    const q = params.map(param => param.trim()).find(param => param.startsWith('q='));
+    accepted[name] = q ? parseFloat(q.slice(2)) : 1;
+  });
+
+  const quality = name => (name in accepted ? accepted[name] : (accepted['*'] || 0));
+  return ['br', 'gzip'].find(name => quality(name) > 0) || null;
+}
+
+/**
+ * Create the compression stream for an encoding
+ */
+function createEncoder(encoding) {
+  if (encoding === 'br') {
+    return zlib.createBrotliCompress({ params: { [zlib.constants.BROTLI_PARAM_QUALITY]: 4 } });
+  }
+  return zlib.createGzip();
+}
+
+/**
+ * Compress text and JSON responses with Brotli or gzip, negotiated via Accept-Encoding
+ */
+function compressionMiddleware(req, res, next) {
+  const encoding = negotiateEncoding(req.headers['accept-encoding']);
+  res.vary('Accept-Encoding');
+
+  if (!encoding || req.method === 'HEAD') {
+    return next();
+  }
+
+  const write = res.write.bind(res);
+  const end = res.end.bind(res);
+  let encoder = null;
+  let decided = false;
+
+  // Decided on the first write, once status and headers are final
+  const start = () => {
+    decided = true;
+    const type = String(res.getHeader('Content-Type') || '');
+    const length = res.getHeader('Content-Length');
+
+    if (res.headersSent || !COMPRESSIBLE_TYPES.test(type) ||
+        res.getHeader('Content-Encoding') ||
+        /no-transform/.test(res.getHeader('Cache-Control') || '') ||
+        res.statusCode === 204 || res.statusCode === 304 ||
+        (length !== undefined && parseInt(length) < COMPRESSION_THRESHOLD_BYTES)) {
+      return;
+    }
+
+    res.setHeader('Content-Encoding', encoding);
+    res.removeHeader('Content-Length');
+    encoder = createEncoder(encoding);
+    // Backpressure both ways, so piped streams (static files, exports) neither stall nor buffer without bound
+    encoder.on('data', chunk => {
+      if (!write(chunk)) {
+        encoder.pause();
+        res.once('drain', () => encoder.resume());
+      }
+    });
+    encoder.on('drain', () => res.emit('drain'));
+    encoder.on('end', () => end());
+  };
+
+  res.write = (chunk, chunkEncoding, callback) => {
+    if (!decided) {
+      start();
+    }
+    if (!encoder) {
+      return write(chunk, chunkEncoding, callback);
+    }
+    return encoder.write(typeof chunk === 'string' ? Buffer.from(chunk, chunkEncoding) : chunk, callback);
+  };
+
+  res.end = (chunk, chunkEncoding, callback) => {
+    if (typeof chunk === 'function') {
+      [callback, chunk] = [chunk, null];
+    } else if (typeof chunkEncoding === 'function') {
+      [callback, chunkEncoding] = [chunkEncoding, undefined];
+    }
+    if (!decided) {
+      start();
+    }
+    if (!encoder) {
+      return end(chunk, chunkEncoding, callback);
+    }
+
+    if (callback) {
+      res.once('finish', callback);
+    }
+    if (chunk) {
+      encoder.end(typeof chunk === 'string' ? Buffer.from(chunk, chunkEncoding) : chunk);
+    } else {
+      encoder.end();
+    }
+    return res;
+  };
+
+  next();
+}
+
+module.exports = {
+  compressionMiddleware
+};
@@ -0,0 +1,73 @@
+const cors = require('cors');
+const config = require('../config');
+const { onReload } = require('../config/reload');
+
+const DEFAULT_METHODS = ['GET', 'POST', 'PUT', 'DELETE', 'PATCH', 'OPTIONS'];
+const DEFAULT_HEADERS = ['Content-Type', 'Authorization', 'X-Requested-With', 'X-Export-Passphrase', 'X-Request-Id', 'If-None-Match', 'X-CSRF-Token'];
+const EXPOSED_HEADERS = [
+  'Content-Disposition', 'X-Request-Id', 'ETag',
+  'RateLimit-Limit', 'RateLimit-Remaining', 'RateLimit-Reset', 'Retry-After'
+];
+
+/**
+ * Turn an allowed origin into a matcher; "*" inside an entry matches one subdomain label
+ * (https://*.example.com), "null" matches file:// pages
+ */
+function originMatcher(entry) {
+  if (!entry.includes('*')) {
+    return origin => origin === entry;
+  }
+  const pattern = entry.split('*').map(part => part.replace(/[.+?^${}()|[\]\\]/g, '\\$&')).join('[^./]+');
+  const regex = new RegExp(`^${pattern}$`);
+  return origin => regex.test(origin);
+}
+
+/**
+ * Resolve the origin option from CORS_ALLOWED_ORIGINS.
+ * Unset: reflect any origin in development, same-origin only (no CORS headers) in production.
+ */
+function resolveOrigin(origins) {
+  if (!origins) {
+    return !config.server.production;
+  }
+  if (origins.includes('*')) {
+    return '*';
+  }
+
+  const matchers = origins.map(originMatcher);
+  return (origin, callback) => callback(null, Boolean(origin) && matchers.some(matches => matches(origin)));
+}
+
+/**
+ * CORS options from CORS_ALLOWED_ORIGINS, CORS_ALLOWED_METHODS and CORS_ALLOWED_HEADERS
+ */
+function buildOptions() {
+  const origin = resolveOrigin(config.cors.allowedOrigins);
+
+  return {
+    origin,
+    // A literal "*" cannot be combined with credentials, so credentials are only sent to listed origins
+    credentials: origin !== '*',
+    methods: config.cors.allowedMethods || DEFAULT_METHODS,
+    allowedHeaders: config.cors.allowedHeaders || DEFAULT_HEADERS,
+    exposedHeaders: EXPOSED_HEADERS
+  };
+}
+
+/**
+ * Build the CORS middleware; its options are rebuilt when a config reload changes them
+ */
+function createCorsMiddleware() {
+  let options = buildOptions();
+  onReload(changed => {
+    if (changed.some(name => name.startsWith('CORS_'))) {
+      options = buildOptions();
+    }
+  });
+
+  return cors((req, callback) => callback(null, options));
+}
+
+module.exports = {
+  createCorsMiddleware
+};
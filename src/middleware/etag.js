@@ -0,0 +1,48 @@
+const crypto = require('crypto');
+
+/**
+ * Check an If-None-Match header against an entity tag (weak comparison, "*" matches anything)
+ */
+function matchesETag(header, etag) {
+  if (!header) {
+    return false;
+  }
+
+  const strip = tag => tag.trim().replace(/^W\//, '');
+  return header.split(',').some(tag => tag.trim() === '*' || strip(tag) === strip(etag));
+}
+
+/**
+ * Conditional GET middleware: tags JSON responses with a content hash and answers 304 when the client's copy is current
+ */
+function etagMiddleware(req, res, next) {
+  if (req.method !== 'GET' && req.method !== 'HEAD') {
+    return next();
+  }
+
+  // Responses are per user: browsers may keep them but must revalidate, shared caches must not
+  res.setHeader('Cache-Control', 'private, no-cache');
+  res.setHeader('Vary', 'Authorization');
+
+  const json = res.json.bind(res);
+  res.json = body => {
+    if (res.statusCode !== 200) {
+      return json(body);
+    }
+
+    const hash = crypto.createHash('sha1').update(JSON.stringify(body)).digest('base64url');
+    const etag = `"${hash}"`;
+    res.setHeader('ETag', etag);
+
+    if (matchesETag(req.get('If-None-Match'), etag)) {
+      return res.status(304).end();
+    }
+    return json(body);
+  };
+
+  next();
+}
+
+module.exports = {
+  etagMiddleware
+};
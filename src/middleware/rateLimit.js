@@ -0,0 +1,57 @@
+const cache = require('../cache');
+const config = require('../config');
+const { respondWithError } = require('../utils/response');
+
+/**
+ * Fixed-window rate limiter keyed by client IP, counting in the shared cache
+ * (Redis when configured, so the limit holds across instances).
+ * Sends RateLimit-Limit/Remaining/Reset on every response and Retry-After with the 429.
+ * Requests are allowed through when the counter backend is unavailable.
+ */
+function rateLimit(name) {
+  return async (req, res, next) => {
+    // Credential endpoints are brute-force targets; reads are cheap and polled by the dashboard.
+    // Looked up per request so a config reload applies; { limit, windowSeconds } or null when off.
+    const policy = config.limits.rateLimit[name];
+    if (!policy) {
+      return next();
+    }
+
+    const { limit, windowSeconds } = policy;
+    const now = Math.floor(Date.now() / 1000);
+    const windowStart = now - (now % windowSeconds);
+    const reset = windowStart + windowSeconds - now;
+
+    const count = await cache.increment(`ratelimit:${name}:${req.ip}:${windowStart}`, windowSeconds);
+    if (count === null) {
+      return next();
+    }
+
+    res.setHeader('RateLimit-Limit', limit);
+    res.setHeader('RateLimit-Remaining', Math.max(limit - count, 0));
+    res.setHeader('RateLimit-Reset', reset);
+
+    if (count > limit) {
+      res.setHeader('Retry-After', reset);
+      return respondWithError(res, 429, 'Too many requests, please try again later', { code: 'RATE_LIMITED' });
+    }
+
+    next();
+  };
+}
+
+/**
+ * Apply the read or write policy by HTTP method
+ */
+function methodRateLimit() {
+  const read = rateLimit('read');
+  const write = rateLimit('write');
+  return (req, res, next) => (
+    req.method === 'GET' || req.method === 'HEAD' || req.method === 'OPTIONS' ? read : write
+  )(req, res, next);
+}
+
+module.exports = {
+  rateLimit,
+  methodRateLimit
+};
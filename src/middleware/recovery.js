@@ -0,0 +1,72 @@
+const Layer = require('express/lib/router/layer');
+const { respondWithError } = require('../utils/response');
+const { captureException } = require('../utils/sentry');
+
+/**
+ * Make Express 4 forward rejected promises from async middleware and handlers to the error handler,
+ * instead of leaving the request hanging with an unhandled rejection (Express 5 does this natively)
+ */
+function recoverAsyncHandlers() {
+  Layer.prototype.handle_request = function handle(req, res, next) {
+    const fn = this.handle;
+    if (fn.length > 3) {
+      return next();
+    }
+
+    try {
+      const result = fn(req, res, next);
+      if (result && typeof result.catch === 'function') {
+        result.catch(error => next(error || new Error('Handler rejected without a reason')));
+      }
+    } catch (error) {
+      next(error);
+    }
+  };
+}
+
+/**
+ * Final error handler: body parser failures become client errors, anything else a 500
+ * carrying the request ID (reported to error tracking by respondWithError)
+ */
+function recoveryMiddleware(error, req, res, next) {
+  if (error.type === 'entity.too.large') {
+    return respondWithError(res, 413, `Request body exceeds the ${error.limit} byte limit`);
+  }
+  if (error.type === 'entity.parse.failed') {
+    return respondWithError(res, 400, 'Request body is not valid JSON', {
+      code: 'INVALID_JSON',
+      details: [{ field: 'body', issue: 'invalid_json', reason: error.message }]
+    });
+  }
+  if (error.type === 'request.aborted' || error.type === 'request.size.invalid') {
+    return respondWithError(res, 400, 'Request body was incomplete');
+  }
+  if (error.type === 'encoding.unsupported' || error.type === 'charset.unsupported') {
+    return respondWithError(res, 415, error.message, { code: 'UNSUPPORTED_MEDIA_TYPE' });
+  }
+
+  console.error('Unhandled error:', error);
+
+  // A response already in flight can only be aborted
+  if (res.headersSent) {
+    captureException(error, { req });
+    return res.destroy(error);
+  }
+  respondWithError(res, 500, 'Internal server error', { cause: error });
+}
+
+/**
+ * Report promise rejections that escaped every handler
+ */
+function reportUnhandledRejections() {
+  process.on('unhandledRejection', reason => {
+    console.error('Unhandled rejection:', reason);
+    captureException(reason, { tags: { mechanism: 'unhandledRejection' } });
+  });
+}
+
+module.exports = {
+  recoverAsyncHandlers,
+  recoveryMiddleware,
+  reportUnhandledRejections
+};
@@ -0,0 +1,34 @@
+const crypto = require('crypto');
+const db = require('../database/db');
+
+// Incoming IDs are reused only when they cannot smuggle anything into headers or logs
+const REQUEST_ID_PATTERN = /^[\w.:-]{1,128}$/;
+
+/**
+ * Reuse a sane incoming X-Request-Id, otherwise generate one; echoed on every response and in error envelopes
+ */
+function requestIdMiddleware(req, res, next) {
+  const incoming = req.get('X-Request-Id');
+  req.id = incoming && REQUEST_ID_PATTERN.test(incoming) ? incoming : crypto.randomUUID();
+  res.setHeader('X-Request-Id', req.id);
+  next();
+}
+
+/**
+ * Prefix console output written while handling a request with "[<request id>]",
+ * so handler, database and notification logs can be matched to the X-Request-Id a user reports
+ */
+function prefixLogsWithRequestId() {
+  ['log', 'info', 'warn', 'error'].forEach(method => {
+    const write = console[method].bind(console);
+    console[method] = (...args) => {
+      const context = db.requestContext.getStore();
+      return context && context.requestId ? write(`[${context.requestId}]`, ...args) : write(...args);
+    };
+  });
+}
+
+module.exports = {
+  requestIdMiddleware,
+  prefixLogsWithRequestId
+};
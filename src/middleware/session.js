@@ -0,0 +1,109 @@
+const crypto = require('crypto');
+const config = require('../config');
+const { generateJWT } = require('../utils/jwt');
+
+const SESSION_COOKIE = config.session.cookieName;
+const CSRF_COOKIE = `${SESSION_COOKIE}_csrf`;
+const CSRF_HEADER = 'x-csrf-token';
+const SESSION_MAX_AGE_SECONDS = 7 * 24 * 60 * 60;
+// Cross-origin web UIs need SameSite=None (which browsers only accept with Secure)
+const COOKIE_SAMESITE = config.session.cookieSameSite;
+const COOKIE_SECURE = config.session.cookieSecure === null
+  ? config.server.env !== 'development'
+  : config.session.cookieSecure;
+const SAFE_METHODS = ['GET', 'HEAD', 'OPTIONS'];
+
+/**
+ * Parse a Cookie header into a name → value map
+ */
+function parseCookies(header) {
+  const cookies = {};
+  String(header || '').split(';').forEach(pair => {
+    const index = pair.indexOf('=');
+    if (index > 0) {
+      const name = pair.slice(0, index).trim();
+      try {
+        cookies[name] = decodeURIComponent(pair.slice(index + 1).trim());
+      } catch (error) {
+        // Malformed values are ignored like missing cookies
+      }
+    }
+  });
+  return cookies;
+}
+
+/**
+ * Serialize a Set-Cookie value with the session attributes
+ */
+function serializeCookie(name, value, { httpOnly, maxAge }) {
+  return [
+    `${name}=${encodeURIComponent(value)}`,
+    'Path=/',
+    `Max-Age=${maxAge}`,
+    `SameSite=${COOKIE_SAMESITE}`,
+    COOKIE_SECURE ? 'Secure' : null,
+    httpOnly ? 'HttpOnly' : null
+  ].filter(Boolean).join('; ');
+}
+
+/**
+ * Start a cookie session: an httpOnly cookie with the JWT, bound to a fresh CSRF token.
+ * The CSRF token is also set as a readable cookie and returned so the UI can send it back in X-CSRF-Token.
+ */
+function issueSession(res, user) {
+  const csrfToken = crypto.randomBytes(32).toString('base64url');
+  const token = generateJWT(user.id, user.username, { csrf: csrfToken });
+
+  res.append('Set-Cookie', serializeCookie(SESSION_COOKIE, token, { httpOnly: true, maxAge: SESSION_MAX_AGE_SECONDS }));
+  res.append('Set-Cookie', serializeCookie(CSRF_COOKIE, csrfToken, { httpOnly: false, maxAge: SESSION_MAX_AGE_SECONDS }));
+  return csrfToken;
+}
+
+/**
+ * Expire both session cookies
+ */
+function clearSession(res) {
+  res.append('Set-Cookie', serializeCookie(SESSION_COOKIE, '', { httpOnly: true, maxAge: 0 }));
+  res.append('Set-Cookie', serializeCookie(CSRF_COOKIE, '', { httpOnly: false, maxAge: 0 }));
+}
+
+/**
+ * Session JWT from the request cookies, or null
+ */
+function readSessionToken(req) {
+  return parseCookies(req.headers.cookie)[SESSION_COOKIE] || null;
+}
+
+/**
+ * Whether a cookie-authenticated request may proceed: unsafe methods must echo the
+ * session's CSRF token in X-CSRF-Token (constant-time comparison)
+ */
+function isValidCsrf(req, decoded) {
+  if (SAFE_METHODS.includes(req.method)) {
+    return true;
+  }
+  if (!decoded.csrf) {
+    return false;
+  }
+
+  const expected = Buffer.from(decoded.csrf);
+  const actual = Buffer.from(String(req.headers[CSRF_HEADER] || ''));
+  return actual.length === expected.length && crypto.timingSafeEqual(actual, expected);
+}
+
+/**
+ * Whether the client asked for a cookie session instead of a Bearer token (?session=cookie)
+ */
+function wantsCookieSession(req) {
+  return req.query.session === 'cookie';
+}
+
+module.exports = {
+  SESSION_COOKIE,
+  parseCookies,
+  issueSession,
+  clearSession,
+  readSessionToken,
+  isValidCsrf,
+  wantsCookieSession
+};
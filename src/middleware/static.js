@@ -0,0 +1,137 @@
+const path = require('path');
+const express = require('express');
+const config = require('../config');
+
+// Relative STATIC_DIR values resolve from the project root, not the working directory
+const STATIC_ROOT = path.resolve(__dirname, '../..', config.server.staticDir);
+const INDEX_PATH = '/index.html';
+
+/**
+ * Assets embedded by `npm run build`, or null when serving from disk.
+ * STATIC_SOURCE=disk serves STATIC_DIR directly (development, edits show up without a rebuild);
+ * embedded requires the bundle; auto uses the bundle when it has been built.
+ */
+function loadEmbeddedAssets() {
+  if (config.server.staticSource === 'disk') {
+    return null;
+  }
+  try {
+    const assets = require('../../dist/web-assets');
+    return Object.fromEntries(Object.entries(assets).map(([urlPath, asset]) => [
+      urlPath,
+      { etag: asset.etag, body: Buffer.from(asset.body, 'base64') }
+    ]));
+  } catch (error) {
+    if (error.code !== 'MODULE_NOT_FOUND') {
+      throw error;
+    }
+    if (config.server.staticSource === 'embedded') {
+      throw new Error('STATIC_SOURCE is embedded but dist/web-assets.js is missing; run `npm run build`');
+    }
+    return null;
+  }
+}
+
+const EMBEDDED_ASSETS = loadEmbeddedAssets();
+
+/**
+ * Cache headers: pages are revalidated on every load so a deploy shows up immediately; assets are not
+ * fingerprinted, so they are cached for STATIC_MAX_AGE_SECONDS rather than forever
+ */
+function setCacheHeaders(res, filePath) {
+  res.setHeader('X-Content-Type-Options', 'nosniff');
+  if (path.extname(filePath) === '.html') {
+    res.setHeader('Cache-Control', 'no-cache');
+  } else {
+    res.setHeader('Cache-Control', `public, max-age=${config.server.staticMaxAgeSeconds}`);
+  }
+}
+
+/**
+ * Send an embedded asset, answering 304 when the client's copy (If-None-Match) is current
+ */
+function sendEmbeddedAsset(req, res, urlPath) {
+  const asset = EMBEDDED_ASSETS[urlPath];
+  setCacheHeaders(res, urlPath);
+  res.type(path.extname(urlPath));
+  res.setHeader('ETag', asset.etag);
+
+  if (req.fresh) {
+    return res.status(304).end();
+  }
+  res.setHeader('Content-Length', asset.body.length);
+  return req.method === 'HEAD' ? res.end() : res.end(asset.body);
+}
+
+/**
+ * Serve embedded assets by path ("/" and directory paths map to their index.html); unknown paths fall through
+ */
+function embeddedStatic(req, res, next) {
+  if (req.method !== 'GET' && req.method !== 'HEAD') {
+    return next();
+  }
+
+  let urlPath;
+  try {
+    urlPath = decodeURIComponent(req.path);
+  } catch (error) {
+    return next();
+  }
+  if (urlPath.endsWith('/')) {
+    urlPath += 'index.html';
+  }
+  if (!Object.prototype.hasOwnProperty.call(EMBEDDED_ASSETS, urlPath)) {
+    return next();
+  }
+  return sendEmbeddedAsset(req, res, urlPath);
+}
+
+/**
+ * Serve the web frontend, embedded or from STATIC_DIR only (never the working directory); dotfiles
+ * such as .env are never served, and missing files fall through to the next handler
+ */
+function createStaticMiddleware() {
+  if (EMBEDDED_ASSETS) {
+    return embeddedStatic;
+  }
+
+  return express.static(STATIC_ROOT, {
+    dotfiles: 'ignore',
+    index: 'index.html',
+    redirect: false,
+    setHeaders: setCacheHeaders
+  });
+}
+
+/**
+ * Answer page navigations to unknown paths with index.html, so client-side routes survive a reload.
+ * API paths, paths with a file extension or a dot segment, and non-HTML requests still get a 404.
+ */
+function spaFallback(req, res, next) {
+  const segments = req.path.split('/');
+  const extension = path.extname(segments[segments.length - 1]);
+  if ((req.method !== 'GET' && req.method !== 'HEAD')
+    || req.path.startsWith('/api/')
+    || extension
+    || segments.some(segment => segment.startsWith('.'))
+    || !req.accepts('html')) {
+    return next();
+  }
+
+  if (EMBEDDED_ASSETS) {
+    return EMBEDDED_ASSETS[INDEX_PATH] ? sendEmbeddedAsset(req, res, INDEX_PATH) : next();
+  }
+
+  const indexFile = path.join(STATIC_ROOT, INDEX_PATH);
+  setCacheHeaders(res, indexFile);
+  res.sendFile(indexFile, error => {
+    if (error && !res.headersSent) {
+      next();
+    }
+  });
+}
+
+module.exports = {
+  createStaticMiddleware,
+  spaFallback
+};
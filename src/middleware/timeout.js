@@ -0,0 +1,32 @@
+const db = require('../database/db');
+const config = require('../config');
+
+const REQUEST_TIMEOUT_MS = config.limits.requestTimeoutMs;
+
+/**
+ * Run the request inside a query context that cancels its database work
+ * once REQUEST_TIMEOUT_MS passes or the client disconnects (the context also carries the request ID for logs)
+ */
+function queryTimeoutMiddleware(req, res, next) {
+  const controller = new AbortController();
+  const timer = setTimeout(() => {
+    const error = new Error(`Request exceeded ${REQUEST_TIMEOUT_MS}ms`);
+    error.code = 'REQUEST_TIMEOUT';
+    controller.abort(error);
+  }, REQUEST_TIMEOUT_MS);
+
+  res.on('close', () => {
+    clearTimeout(timer);
+    if (!res.writableFinished) {
+      const error = new Error('Client disconnected');
+      error.code = 'CLIENT_DISCONNECTED';
+      controller.abort(error);
+    }
+  });
+
+  db.requestContext.run({ signal: controller.signal, requestId: req.id }, next);
+}
+
+module.exports = {
+  queryTimeoutMiddleware
+};
@@ -1,3 +1,5 @@
+const { DEFAULT_CURRENCY } = require('../utils/currency');
+
 // User model
 class User {
   constructor({
@@ -5,6 +7,15 @@ class User {
     username,
     passwordHash,
     fullName = null,
+    email = null,
+    phone = null,
+    address = null,
+    emailNotifications = true,
+    smsBorrowerReminders = false,
+    preferredCurrency = null,
+    calendar = null,
+    timezone = null,
+    locale = null,
     createdAt = new Date(),
     updatedAt = new Date(),
     deletedAt = null
@@ -13,6 +24,19 @@ class User {
     this.username = username;
     this.passwordHash = passwordHash;
     this.fullName = fullName;
+    this.email = email;
+    this.phone = phone;
+    this.address = address;
+    this.emailNotifications = emailNotifications;
+    this.smsBorrowerReminders = smsBorrowerReminders;
+    // Currency for consolidated dashboard totals (null: DEFAULT_CURRENCY)
+    this.preferredCurrency = preferredCurrency;
+    // Calendar dates are shown in: gregorian or buddhist (null: gregorian); input accepts both
+    this.calendar = calendar;
+    // IANA time zone deciding "today" for due and overdue dates (null: DEFAULT_TIMEZONE)
+    this.timezone = timezone;
+    // BCP 47 locale for amount separators and symbol placement (null: each currency's own locale)
+    this.locale = locale;
     this.createdAt = createdAt;
     this.updatedAt = updatedAt;
     this.deletedAt = deletedAt;
@@ -24,16 +48,49 @@ class User {
   }
 }
 
+// Borrower model
+class Borrower {
+  constructor({
+    id = null,
+    userId,
+    name,
+    phone = null,
+    email = null,
+    lineId = null,
+    address = null,
+    createdAt = new Date(),
+    updatedAt = new Date()
+  }) {
+    this.id = id;
+    this.userId = userId;
+    this.name = name;
+    this.phone = phone;
+    this.email = email;
+    this.lineId = lineId;
+    this.address = address;
+    this.createdAt = createdAt;
+    this.updatedAt = updatedAt;
+  }
+}
+
+const LOAN_DIRECTIONS = ['lent', 'borrowed'];
+const LOAN_STATUSES = ['active', 'paid', 'overdue', 'defaulted'];
+
 // Loan model
 class Loan {
   constructor({
     id = null,
     userId,
+    borrowerId = null,
     borrowerName,
     borrowerPhone = null,
     borrowerAddress = null,
     amount,
     interestRate,
+    interestType = 'reducing',
+    termMonths = null,
+    direction = 'lent',
+    currency = DEFAULT_CURRENCY,
     loanDate,
     dueDate,
     status = 'active',
@@ -43,11 +100,16 @@ class Loan {
   }) {
     this.id = id;
     this.userId = userId;
+    this.borrowerId = borrowerId;
     this.borrowerName = borrowerName;
     this.borrowerPhone = borrowerPhone;
     this.borrowerAddress = borrowerAddress;
     this.amount = parseFloat(amount);
     this.interestRate = parseFloat(interestRate);
+    this.interestType = interestType;
+    this.termMonths = termMonths;
+    this.direction = direction;
+    this.currency = currency;
     this.loanDate = loanDate;
     this.dueDate = dueDate;
     this.status = status;
@@ -64,9 +126,12 @@ class Transaction {
     loanId,
     userId,
     amount,
+    currency = DEFAULT_CURRENCY,
     transactionType,
     transactionDate,
     description = null,
+    status = 'confirmed',
+    confirmedAt = null,
     createdAt = new Date(),
     updatedAt = new Date()
   }) {
@@ -74,14 +139,170 @@ class Transaction {
     this.loanId = loanId;
     this.userId = userId;
     this.amount = parseFloat(amount);
+    this.currency = currency;
     this.transactionType = transactionType;
     this.transactionDate = transactionDate;
     this.description = description;
+    this.status = status;
+    this.confirmedAt = confirmedAt;
+    this.createdAt = createdAt;
+    this.updatedAt = updatedAt;
+  }
+}
+
+// Payment plan model
+class PaymentPlan {
+  constructor({
+    id = null,
+    loanId,
+    userId,
+    amount,
+    frequency,
+    startDate,
+    nextDueDate,
+    endDate = null,
+    active = true,
+    createdAt = new Date(),
+    updatedAt = new Date()
+  }) {
+    this.id = id;
+    this.loanId = loanId;
+    this.userId = userId;
+    this.amount = parseFloat(amount);
+    this.frequency = frequency;
+    this.startDate = startDate;
+    this.nextDueDate = nextDueDate;
+    this.endDate = endDate;
+    this.active = active;
+    this.createdAt = createdAt;
+    this.updatedAt = updatedAt;
+  }
+}
+
+class ReminderRule {
+  constructor({
+    id = null,
+    userId,
+    loanId = null,
+    ruleType,
+    daysBefore = null,
+    repeatEveryDays = null,
+    active = true,
+    createdAt = new Date(),
+    updatedAt = new Date()
+  }) {
+    this.id = id;
+    this.userId = userId;
+    this.loanId = loanId;
+    this.ruleType = ruleType;
+    this.daysBefore = daysBefore;
+    this.repeatEveryDays = repeatEveryDays;
+    this.active = active;
+    this.createdAt = createdAt;
+    this.updatedAt = updatedAt;
+  }
+}
+
+class WebhookEndpoint {
+  constructor({
+    id = null,
+    userId,
+    url,
+    events = [],
+    active = true,
+    createdAt = new Date(),
+    updatedAt = new Date()
+  }) {
+    this.id = id;
+    this.userId = userId;
+    this.url = url;
+    this.events = events;
+    this.active = active;
+    this.createdAt = createdAt;
+    this.updatedAt = updatedAt;
+  }
+}
+
+class UserDevice {
+  constructor({
+    id = null,
+    userAgent = null,
+    lastIp = null,
+    lastCountry = null,
+    countries = [],
+    trusted = false,
+    firstSeenAt = new Date(),
+    lastSeenAt = new Date()
+  }) {
+    this.id = id;
+    this.userAgent = userAgent;
+    this.lastIp = lastIp;
+    this.lastCountry = lastCountry;
+    this.countries = countries;
+    this.trusted = trusted;
+    this.firstSeenAt = firstSeenAt;
+    this.lastSeenAt = lastSeenAt;
+  }
+}
+
+class SavedReport {
+  constructor({
+    id = null,
+    userId,
+    name,
+    definition,
+    schedule = null,
+    nextRunAt = null,
+    lastRunAt = null,
+    createdAt = new Date(),
+    updatedAt = new Date()
+  }) {
+    this.id = id;
+    this.userId = userId;
+    this.name = name;
+    this.definition = definition;
+    this.schedule = schedule;
+    this.nextRunAt = nextRunAt;
+    this.lastRunAt = lastRunAt;
     this.createdAt = createdAt;
     this.updatedAt = updatedAt;
   }
 }
 
+class ExportJob {
+  constructor({
+    id = null,
+    userId,
+    format,
+    params = {},
+    encrypted = false,
+    status = 'pending',
+    fileName = null,
+    size = null,
+    error = null,
+    createdAt = new Date(),
+    startedAt = null,
+    completedAt = null,
+    expiresAt = null,
+    downloadUrl = null
+  }) {
+    this.id = id;
+    this.userId = userId;
+    this.format = format;
+    this.params = params;
+    this.encrypted = encrypted;
+    this.status = status;
+    this.fileName = fileName;
+    this.size = size;
+    this.error = error;
+    this.createdAt = createdAt;
+    this.startedAt = startedAt;
+    this.completedAt = completedAt;
+    this.expiresAt = expiresAt;
+    this.downloadUrl = downloadUrl;
+  }
+}
+
 // Request/Response DTOs
 class AuthRequest {
   constructor({ username, password, email, fullName = null }) {
@@ -101,20 +322,30 @@ class LoginRequest {
 
 class LoanCreateRequest {
   constructor({
+    borrowerId,
     borrowerName,
     borrowerPhone,
     borrowerAddress,
     amount,
     interestRate,
+    interestType,
+    termMonths,
+    direction,
+    currency,
     loanDate,
     dueDate,
     notes
   }) {
+    this.borrowerId = borrowerId;
     this.borrowerName = borrowerName;
     this.borrowerPhone = borrowerPhone;
     this.borrowerAddress = borrowerAddress;
     this.amount = amount;
     this.interestRate = interestRate;
+    this.interestType = interestType;
+    this.termMonths = termMonths;
+    this.direction = direction;
+    this.currency = currency;
     this.loanDate = loanDate;
     this.dueDate = dueDate;
     this.notes = notes;
@@ -125,12 +356,14 @@ class TransactionCreateRequest {
   constructor({
     loanId,
     amount,
+    currency,
     transactionType,
     transactionDate,
     description
   }) {
     this.loanId = loanId;
     this.amount = amount;
+    this.currency = currency;
     this.transactionType = transactionType;
     this.transactionDate = transactionDate;
     this.description = description;
@@ -138,10 +371,14 @@ class TransactionCreateRequest {
 }
 
 // Response DTOs
+// Bearer clients get the token; cookie sessions get only the CSRF token (the JWT stays in an httpOnly cookie)
 class AuthResponse {
-  constructor({ user, token }) {
+  constructor({ user, token = null, csrfToken }) {
     this.user = user.toJSON ? user.toJSON() : user;
     this.token = token;
+    if (csrfToken) {
+      this.csrfToken = csrfToken;
+    }
   }
 }
 
@@ -152,25 +389,92 @@ class DashboardStats {
     totalAmount = 0,
     totalInterest = 0,
     overdueLoans = 0,
-    recentTransactions = []
+    missedPayments = 0,
+    pendingTransactions = 0,
+    pendingAmount = 0,
+    currency = DEFAULT_CURRENCY,
+    byCurrency = [],
+    consolidated = null,
+    recentTransactions = [],
+    range = null
   }) {
     this.totalLoans = totalLoans;
     this.activeLoans = activeLoans;
     this.totalAmount = totalAmount;
     this.totalInterest = totalInterest;
     this.overdueLoans = overdueLoans;
+    this.missedPayments = missedPayments;
+    this.pendingTransactions = pendingTransactions;
+    this.pendingAmount = pendingAmount;
+    // Amounts above are in currency; null (with byCurrency listing each) when loans span several currencies
+    this.currency = currency;
+    this.byCurrency = byCurrency;
+    // Totals converted into the user's preferred currency (null when a rate is unavailable)
+    this.consolidated = consolidated;
     this.recentTransactions = recentTransactions;
+    this.range = range;
+  }
+}
+
+// Machine-readable error codes, used when a handler does not give a more specific one
+const ERROR_CODES = {
+  400: 'VALIDATION_ERROR',
+  401: 'UNAUTHENTICATED',
+  403: 'FORBIDDEN',
+  404: 'NOT_FOUND',
+  409: 'CONFLICT',
+  413: 'PAYLOAD_TOO_LARGE',
+  429: 'RATE_LIMITED',
+  500: 'INTERNAL_ERROR',
+  503: 'SERVICE_UNAVAILABLE'
+};
+
+// Error envelope returned by every failed request
+class ErrorResponse {
+  constructor({
+    status = 500,
+    code = null,
+    message = 'An error occurred',
+    details = [],
+    requestId = null
+  }) {
+    this.code = code || ERROR_CODES[status] || (status >= 500 ? 'INTERNAL_ERROR' : 'ERROR');
+    this.message = message;
+    this.details = details;
+    this.requestId = requestId;
+    this.status = status;
+  }
+
+  toJSON() {
+    return {
+      code: this.code,
+      message: this.message,
+      details: this.details,
+      request_id: this.requestId,
+      status: this.status
+    };
   }
 }
 
 module.exports = {
+  LOAN_DIRECTIONS,
+  LOAN_STATUSES,
+  ERROR_CODES,
   User,
+  Borrower,
   Loan,
   Transaction,
+  PaymentPlan,
+  ReminderRule,
+  WebhookEndpoint,
+  UserDevice,
+  SavedReport,
+  ExportJob,
   AuthRequest,
   LoginRequest,
   LoanCreateRequest,
   TransactionCreateRequest,
   AuthResponse,
-  DashboardStats
+  DashboardStats,
+  ErrorResponse
 };
\ No newline at end of file
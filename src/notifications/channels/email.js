@@ -0,0 +1,77 @@
+const config = require('../../config');
+
+/**
+ * Email channel with pluggable HTTP provider (log, sendgrid, resend)
+ */
+class EmailChannel {
+  constructor() {
+    this.name = 'email';
+    this.provider = config.notifications.email.provider;
+    this.apiKey = config.notifications.email.apiKey;
+    this.from = config.notifications.email.from;
+  }
+
+  /**
+   * Resolve user's email address unless they opted out of email
+   */
+  async resolveRecipient(userId, event, payload, client) {
+    const result = await client.query(
+      'SELECT email, email_notifications FROM users WHERE id = $1',
+      [userId]
+    );
+
+    const user = result.rows[0];
+    if (!user || !user.email || user.email_notifications === false) {
+      return null;
+    }
+    return user.email;
+  }
+
+  /**
+   * Deliver email via configured provider
+   */
+  async send(notification) {
+    switch (this.provider) {
+      case 'log':
+        console.log(`[email] to=${notification.recipient} subject="${notification.subject}"`);
+        return true;
+      case 'sendgrid':
+        return this.post('https://api.sendgrid.com/v3/mail/send', {
+          personalizations: [{ to: [{ email: notification.recipient }] }],
+          from: { email: this.from },
+          subject: notification.subject,
+          content: [{ type: 'text/plain', value: notification.body }]
+        });
+      case 'resend':
+        return this.post('https://api.resend.com/emails', {
+          from: this.from,
+          to: [notification.recipient],
+          subject: notification.subject,
+          text: notification.body
+        });
+      default:
+        throw new Error(`Unsupported email provider: ${this.provider}`);
+    }
+  }
+
+  /**
+   * POST JSON to provider API
+   */
+  async post(url, body) {
+    const response = await fetch(url, {
+      method: 'POST',
+      headers: {
+        'Authorization': `Bearer ${this.apiKey}`,
+        'Content-Type': 'application/json'
+      },
+      body: JSON.stringify(body)
+    });
+
+    if (!response.ok) {
+      throw new Error(`Email provider responded with ${response.status}: ${await response.text()}`);
+    }
+    return true;
+  }
+}
+
+module.exports = EmailChannel;
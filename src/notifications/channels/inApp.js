@@ -0,0 +1,24 @@
+/**
+ * In-app channel: notifications are stored and read through the API, so delivery is immediate
+ */
+class InAppChannel {
+  constructor() {
+    this.name = 'in_app';
+  }
+
+  /**
+   * Resolve recipient for a user (always the user)
+   */
+  async resolveRecipient(userId) {
+    return userId;
+  }
+
+  /**
+   * Deliver notification
+   */
+  async send() {
+    return true;
+  }
+}
+
+module.exports = InAppChannel;
@@ -0,0 +1,53 @@
+const db = require('../../database/db');
+
+const LINE_NOTIFY_API_URL = 'https://notify-api.line.me/api/notify';
+
+/**
+ * LINE Notify channel: pushes messages to the user's connected LINE Notify token
+ */
+class LineChannel {
+  constructor() {
+    this.name = 'line';
+  }
+
+  /**
+   * Resolve user's LINE integration (recipient is the integration ID, never the token)
+   */
+  async resolveRecipient(userId, event, payload, client) {
+    const result = await client.query(
+      `SELECT id FROM user_integrations WHERE user_id = $1 AND provider = 'line'`,
+      [userId]
+    );
+    return result.rows.length > 0 ? result.rows[0].id : null;
+  }
+
+  /**
+   * Deliver message via LINE Notify
+   */
+  async send(notification) {
+    const result = await db.query(
+      'SELECT access_token FROM user_integrations WHERE id = $1',
+      [notification.recipient]
+    );
+
+    if (result.rows.length === 0) {
+      throw new Error('LINE integration was disconnected');
+    }
+
+    const response = await fetch(LINE_NOTIFY_API_URL, {
+      method: 'POST',
+      headers: {
+        'Authorization': `Bearer ${result.rows[0].access_token}`,
+        'Content-Type': 'application/x-www-form-urlencoded'
+      },
+      body: new URLSearchParams({ message: `\n${notification.subject}\n${notification.body}` })
+    });
+
+    if (!response.ok) {
+      throw new Error(`LINE Notify responded with ${response.status}: ${await response.text()}`);
+    }
+    return true;
+  }
+}
+
+module.exports = LineChannel;
@@ -0,0 +1,94 @@
+const config = require('../../config');
+const TwilioProvider = require('../sms/twilio');
+const ThaiBulkSmsProvider = require('../sms/thaiBulkSms');
+
+// Borrower-facing events that may be texted
+const SMS_EVENTS = [
+  'loan.due_soon',
+  'loan.overdue',
+  'loan.escalation.gentle',
+  'loan.escalation.firm',
+  'loan.escalation.final'
+];
+
+/**
+ * Create SMS provider from configuration
+ *
+ * Providers implement send(to, message), where `to` is an E.164 number.
+ */
+function createSmsProvider() {
+  switch (config.notifications.sms.provider) {
+    case 'twilio':
+      return new TwilioProvider();
+    case 'thaibulksms':
+      return new ThaiBulkSmsProvider();
+    case 'log':
+      return { send: async (to, message) => console.log(`[sms] to=${to} message="${message}"`) };
+    default:
+      return null;
+  }
+}
+
+/**
+ * Normalize a phone number to E.164, assuming Thai numbers when no country code is given
+ */
+function toE164(phone) {
+  if (!phone) {
+    return null;
+  }
+  const digits = phone.replace(/[^\d+]/g, '');
+  if (digits.startsWith('+')) {
+    return digits;
+  }
+  if (digits.startsWith('0') && digits.length >= 9) {
+    return `+66${digits.slice(1)}`;
+  }
+  return digits.length >= 9 ? `+${digits}` : null;
+}
+
+/**
+ * SMS channel: texts reminders to the borrower's phone number
+ */
+class SmsChannel {
+  constructor() {
+    this.name = 'sms';
+    this.audience = 'borrower';
+    this.provider = createSmsProvider();
+  }
+
+  /**
+   * Resolve borrower phone for borrower-facing loan events when the lender opted in
+   */
+  async resolveRecipient(userId, event, payload, client) {
+    if (!this.provider || !SMS_EVENTS.includes(event) || !payload.loanId) {
+      return null;
+    }
+
+    const result = await client.query(
+      `SELECT COALESCE(b.phone, l.borrower_phone) as phone, u.sms_borrower_reminders
+       FROM loans l
+       JOIN users u ON u.id = l.user_id
+       LEFT JOIN borrowers b ON b.id = l.borrower_id
+       WHERE l.id = $1 AND l.user_id = $2 AND l.deleted_at IS NULL`,
+      [payload.loanId, userId]
+    );
+
+    const row = result.rows[0];
+    if (!row || !row.sms_borrower_reminders) {
+      return null;
+    }
+    return toE164(row.phone);
+  }
+
+  /**
+   * Deliver SMS
+   */
+  async send(notification) {
+    if (!this.provider) {
+      throw new Error('SMS provider is not configured');
+    }
+    return this.provider.send(notification.recipient, notification.body);
+  }
+}
+
+module.exports = SmsChannel;
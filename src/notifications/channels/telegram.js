@@ -0,0 +1,47 @@
+const config = require('../../config');
+const db = require('../../database/db');
+const { sendTelegramMessage } = require('../../utils/telegram');
+
+/**
+ * Telegram channel: messages the chat the user linked with the bot
+ */
+class TelegramChannel {
+  constructor() {
+    this.name = 'telegram';
+  }
+
+  /**
+   * Resolve user's Telegram integration (recipient is the integration ID)
+   */
+  async resolveRecipient(userId, event, payload, client) {
+    if (!config.integrations.telegram.botToken) {
+      return null;
+    }
+
+    const result = await client.query(
+      `SELECT id FROM user_integrations WHERE user_id = $1 AND provider = 'telegram'`,
+      [userId]
+    );
+    return result.rows.length > 0 ? result.rows[0].id : null;
+  }
+
+  /**
+   * Deliver message via the Telegram Bot API
+   */
+  async send(notification) {
+    const result = await db.query(
+      'SELECT access_token FROM user_integrations WHERE id = $1',
+      [notification.recipient]
+    );
+
+    if (result.rows.length === 0) {
+      throw new Error('Telegram chat was disconnected');
+    }
+
+    // access_token holds the linked chat ID for Telegram
+    await sendTelegramMessage(result.rows[0].access_token, `${notification.subject}\n\n${notification.body}`);
+    return true;
+  }
+}
+
+module.exports = TelegramChannel;
@@ -0,0 +1,90 @@
+const config = require('../../config');
+const db = require('../../database/db');
+const { sendWebPush } = require('../../utils/webPush');
+
+// Events worth interrupting the user for in the browser/PWA
+const PUSH_EVENTS = [
+  'loan.due_soon',
+  'loan.overdue',
+  'loan.completed',
+  'transaction.created',
+  'loan.escalation.gentle',
+  'loan.escalation.firm',
+  'loan.escalation.final',
+  'auth.new_login'
+];
+
+/**
+ * Web Push channel: delivers to each browser subscription registered by the user
+ */
+class WebPushChannel {
+  constructor() {
+    this.name = 'web_push';
+    this.vapid = {
+      publicKey: config.notifications.webPush.publicKey,
+      privateKey: config.notifications.webPush.privateKey,
+      subject: config.notifications.webPush.subject
+    };
+  }
+
+  /**
+   * Whether VAPID keys are configured
+   */
+  isConfigured() {
+    return Boolean(this.vapid.publicKey && this.vapid.privateKey);
+  }
+
+  /**
+   * Resolve user's push subscriptions (one notification per device)
+   */
+  async resolveRecipient(userId, event, payload, client) {
+    if (!this.isConfigured() || !PUSH_EVENTS.includes(event)) {
+      return null;
+    }
+
+    const result = await client.query(
+      'SELECT id FROM push_subscriptions WHERE user_id = $1',
+      [userId]
+    );
+    return result.rows.map(row => row.id);
+  }
+
+  /**
+   * Deliver push message to a subscription
+   */
+  async send(notification) {
+    const result = await db.query(
+      'SELECT endpoint, p256dh, auth FROM push_subscriptions WHERE id = $1',
+      [notification.recipient]
+    );
+
+    if (result.rows.length === 0) {
+      throw new Error('Push subscription was removed');
+    }
+
+    const subscription = result.rows[0];
+    const response = await sendWebPush(
+      { endpoint: subscription.endpoint, keys: { p256dh: subscription.p256dh, auth: subscription.auth } },
+      JSON.stringify({
+        title: notification.subject,
+        body: notification.body,
+        event: notification.event,
+        data: notification.payload
+      }),
+      { vapid: this.vapid }
+    );
+
+    // Subscription expired or was revoked in the browser
+    if (response.status === 404 || response.status === 410) {
+      await db.query('DELETE FROM push_subscriptions WHERE id = $1', [notification.recipient]);
+      throw new Error(`Push subscription expired (${response.status})`);
+    }
+
+    if (!response.ok) {
+      throw new Error(`Push service responded with ${response.status}: ${await response.text()}`);
+    }
+    return true;
+  }
+}
+
+module.exports = WebPushChannel;
@@ -0,0 +1,166 @@
+const crypto = require('crypto');
+const http = require('http');
+const https = require('https');
+const config = require('../../config');
+const db = require('../../database/db');
+const { publicLookup, blockedHostReason } = require('../../utils/publicAddress');
+
+const WEBHOOK_EVENTS = ['loan.created', 'transaction.created', 'loan.completed', 'loan.overdue'];
+const REQUEST_TIMEOUT_MS = 10000;
+const MAX_LOGGED_RESPONSE_LENGTH = 2000;
+// Stop reading responses after this many bytes; only the start is logged
+const MAX_RESPONSE_BYTES = 64 * 1024;
+
+/**
+ * Sign a webhook body; receivers recompute HMAC-SHA256 over "<timestamp>.<body>"
+ */
+function signPayload(secret, timestamp, body) {
+  const signature = crypto.createHmac('sha256', secret).update(`${timestamp}.${body}`).digest('hex');
+  return `t=${timestamp},v1=${signature}`;
+}
+
+/**
+ * POST body to a webhook URL; resolves { status, ok, text }.
+ * Hosts must resolve to public addresses (checked on the connecting socket's own lookup, which also
+ * defeats DNS rebinding), redirects are not followed and production only allows https.
+ */
+function postWebhook(url, headers, body) {
+  return new Promise((resolve, reject) => {
+    const target = new URL(url);
+    const blocked = blockedHostReason(target);
+    if (blocked) {
+      return reject(new Error(blocked));
+    }
+    if (target.protocol !== 'https:' && (target.protocol !== 'http:' || config.server.production)) {
+      return reject(new Error(config.server.production ? 'Webhook URL must use https' : 'Webhook URL must use http or https'));
+    }
+
+    const request = (target.protocol === 'https:' ? https : http).request(target, {
+      method: 'POST',
+      headers: { ...headers, 'Content-Length': Buffer.byteLength(body) },
+      lookup: publicLookup,
+      timeout: REQUEST_TIMEOUT_MS
+    }, response => {
+      const chunks = [];
+      let length = 0;
+      response.on('data', chunk => {
+        if (length < MAX_RESPONSE_BYTES) {
+          chunks.push(chunk);
+          length += chunk.length;
+        }
+      });
+      response.on('end', () => resolve({
+        status: response.statusCode,
+        // 3xx counts as a failure: following redirects could reach internal hosts
+        ok: response.statusCode >= 200 && response.statusCode < 300,
+        text: Buffer.concat(chunks).toString('utf8')
+      }));
+      response.on('error', reject);
+    });
+    request.on('timeout', () => request.destroy(new Error(`Webhook timed out after ${REQUEST_TIMEOUT_MS}ms`)));
+    request.on('error', reject);
+    request.end(body);
+  });
+}
+
+/**
+ * Webhook channel: POSTs signed JSON to the user's webhook endpoints subscribed to the event
+ */
+class WebhookChannel {
+  constructor() {
+    this.name = 'webhook';
+    // Events are filtered per endpoint instead of via user preferences
+    this.configurable = false;
+  }
+
+  /**
+   * Resolve active webhook endpoints subscribed to the event (one notification per endpoint)
+   */
+  async resolveRecipient(userId, event, payload, client) {
+    if (!WEBHOOK_EVENTS.includes(event)) {
+      return null;
+    }
+
+    const result = await client.query(
+      `SELECT id FROM webhook_endpoints
+       WHERE user_id = $1 AND active = true AND $2 = ANY(events)`,
+      [userId, event]
+    );
+    return result.rows.map(row => row.id);
+  }
+
+  /**
+   * Record a delivery attempt in the webhook delivery log
+   */
+  async logDelivery(notification, { statusCode = null, responseBody = null, error = null, durationMs }) {
+    await db.query(
+      `INSERT INTO webhook_deliveries (webhook_id, notification_id, event, attempt, status_code, response_body, error, duration_ms)
+       VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+      [
+        notification.recipient,
+        notification.id,
+        notification.event,
+        notification.attempts,
+        statusCode,
+        responseBody ? responseBody.slice(0, MAX_LOGGED_RESPONSE_LENGTH) : null,
+        error,
+        durationMs
+      ]
+    );
+  }
+
+  /**
+   * Deliver signed payload to the webhook endpoint
+   */
+  async send(notification) {
+    const result = await db.query(
+      'SELECT url, secret, active FROM webhook_endpoints WHERE id = $1',
+      [notification.recipient]
+    );
+
+    if (result.rows.length === 0 || !result.rows[0].active) {
+      throw new Error('Webhook endpoint was removed or disabled');
+    }
+
+    const webhook = result.rows[0];
+    const body = JSON.stringify({
+      id: notification.id,
+      event: notification.event,
+      createdAt: notification.created_at,
+      data: notification.payload
+    });
+    const timestamp = Math.floor(Date.now() / 1000);
+    const startedAt = Date.now();
+
+    let response;
+    try {
+      response = await postWebhook(webhook.url, {
+        'Content-Type': 'application/json',
+        'User-Agent': 'loan-money-webhooks/1.0',
+        'X-Webhook-Event': notification.event,
+        'X-Webhook-Delivery': notification.id,
+        'X-Webhook-Signature': signPayload(webhook.secret, timestamp, body)
+      }, body);
+    } catch (error) {
+      await this.logDelivery(notification, { error: error.message, durationMs: Date.now() - startedAt });
+      throw error;
+    }
+
+    const responseBody = response.text;
+    await this.logDelivery(notification, {
+      statusCode: response.status,
+      responseBody,
+      durationMs: Date.now() - startedAt
+    });
+
+    if (!response.ok) {
+      throw new Error(`Webhook responded with ${response.status}`);
+    }
+    return true;
+  }
+}
+
+WebhookChannel.WEBHOOK_EVENTS = WEBHOOK_EVENTS;
+WebhookChannel.signPayload = signPayload;
+
+module.exports = WebhookChannel;
@@ -0,0 +1,150 @@
+const db = require('../database/db');
+const eventStream = require('../events');
+const { renderTemplate, NOTIFICATION_EVENTS } = require('./templates');
+const InAppChannel = require('./channels/inApp');
+const EmailChannel = require('./channels/email');
+const LineChannel = require('./channels/line');
+const TelegramChannel = require('./channels/telegram');
+const SmsChannel = require('./channels/sms');
+const WebPushChannel = require('./channels/webPush');
+const WebhookChannel = require('./channels/webhook');
+
+/**
+ * Notification service
+ *
+ * Channels implement:
+ *   name - unique channel identifier
+ *   resolveRecipient(userId, event, payload, client) - recipient address (or list of them), or null to skip
+ *   send(notification) - deliver, throwing on failure so the outbox retries
+ *   audience (optional) - 'borrower' for channels that message the borrower instead of the user
+ *   configurable (optional) - false to hide the channel from per-user preferences
+ */
+class NotificationService {
+  constructor() {
+    this.channels = new Map();
+  }
+
+  /**
+   * Register a delivery channel
+   */
+  registerChannel(channel) {
+    this.channels.set(channel.name, channel);
+  }
+
+  /**
+   * Get registered channel by name
+   */
+  getChannel(name) {
+    return this.channels.get(name);
+  }
+
+  /**
+   * Names of channels users can toggle per event
+   */
+  getConfigurableChannels() {
+    return [...this.channels.values()]
+      .filter(channel => channel.configurable !== false)
+      .map(channel => channel.name);
+  }
+
+  /**
+   * Get event/channel matrix of preferences for a user (missing rows default to enabled)
+   */
+  async getPreferences(userId, client = db) {
+    const result = await client.query(
+      'SELECT event, channel, enabled FROM notification_preferences WHERE user_id = $1',
+      [userId]
+    );
+
+    const preferences = {};
+    for (const event of NOTIFICATION_EVENTS) {
+      preferences[event] = {};
+      for (const channel of this.getConfigurableChannels()) {
+        preferences[event][channel] = true;
+      }
+    }
+    for (const row of result.rows) {
+      if (preferences[row.event] && row.channel in preferences[row.event]) {
+        preferences[row.event][row.channel] = row.enabled;
+      }
+    }
+    return preferences;
+  }
+
+  /**
+   * Queue notifications for an event on every channel that can reach the user.
+   * Pass channels to restrict delivery, and a transaction client to enqueue atomically with the triggering write.
+   * On a transaction client the work runs in a savepoint, so a failed query here does not abort the caller's transaction.
+   */
+  async emit(event, { userId, payload = {}, channels = null }, client = db) {
+    const inTransaction = client !== db;
+
+    try {
+      if (inTransaction) {
+        await client.query('SAVEPOINT notification_emit');
+      }
+
+      await eventStream.publish(event, { userId, payload }, client);
+      await this.enqueue(event, { userId, payload, channels }, client);
+
+      if (inTransaction) {
+        await client.query('RELEASE SAVEPOINT notification_emit');
+      }
+    } catch (error) {
+      // Notifications must never break the triggering request
+      console.error(`Notification emit error (${event}):`, error);
+      if (inTransaction) {
+        await client.query('ROLLBACK TO SAVEPOINT notification_emit');
+      }
+    }
+  }
+
+  /**
+   * Insert an outbox row per channel and recipient for the event
+   */
+  async enqueue(event, { userId, payload, channels }, client) {
+    const disabled = await client.query(
+      `SELECT channel FROM notification_preferences
+       WHERE user_id = $1 AND event = $2 AND enabled = false`,
+      [userId, event]
+    );
+    const disabledChannels = new Set(disabled.rows.map(row => row.channel));
+
+    for (const channel of this.channels.values()) {
+      if (channels && !channels.includes(channel.name)) {
+        continue;
+      }
+
+      if (channel.configurable !== false && disabledChannels.has(channel.name)) {
+        continue;
+      }
+
+      const resolved = await channel.resolveRecipient(userId, event, payload, client);
+      const recipients = [].concat(resolved || []);
+      if (recipients.length === 0) {
+        continue;
+      }
+
+      const { subject, body } = renderTemplate(event, payload, channel.audience);
+
+      for (const recipient of recipients) {
+        await client.query(
+          `INSERT INTO notifications (user_id, event, channel, recipient, subject, body, payload)
+           VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+          [userId, event, channel.name, recipient, subject, body, JSON.stringify(payload)]
+        );
+      }
+    }
+  }
+}
+
+const notificationService = new NotificationService();
+notificationService.registerChannel(new InAppChannel());
+notificationService.registerChannel(new EmailChannel());
+notificationService.registerChannel(new LineChannel());
+notificationService.registerChannel(new TelegramChannel());
+notificationService.registerChannel(new SmsChannel());
+notificationService.registerChannel(new WebPushChannel());
+notificationService.registerChannel(new WebhookChannel());
+
+module.exports = notificationService;
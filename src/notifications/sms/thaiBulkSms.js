@@ -0,0 +1,39 @@
+const config = require('../../config');
+
+/**
+ * ThaiBulkSMS provider (api-v2.thaibulksms.com)
+ */
+class ThaiBulkSmsProvider {
+  constructor() {
+    this.apiKey = config.notifications.sms.thaiBulkSmsApiKey;
+    this.apiSecret = config.notifications.sms.thaiBulkSmsApiSecret;
+    this.sender = config.notifications.sms.thaiBulkSmsSender;
+  }
+
+  /**
+   * Send SMS message (ThaiBulkSMS expects local 0XXXXXXXXX numbers)
+   */
+  async send(to, message) {
+    const msisdn = to.startsWith('+66') ? `0${to.slice(3)}` : to;
+    const params = new URLSearchParams({ msisdn, message });
+    if (this.sender) {
+      params.append('sender', this.sender);
+    }
+
+    const response = await fetch('https://api-v2.thaibulksms.com/sms', {
+      method: 'POST',
+      headers: {
+        'Authorization': `Basic ${Buffer.from(`${this.apiKey}:${this.apiSecret}`).toString('base64')}`,
+        'Content-Type': 'application/x-www-form-urlencoded'
+      },
+      body: params
+    });
+
+    if (!response.ok) {
+      throw new Error(`ThaiBulkSMS responded with ${response.status}: ${await response.text()}`);
+    }
+    return true;
+  }
+}
+
+module.exports = ThaiBulkSmsProvider;
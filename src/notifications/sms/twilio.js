@@ -0,0 +1,33 @@
+const config = require('../../config');
+
+/**
+ * Twilio SMS provider
+ */
+class TwilioProvider {
+  constructor() {
+    this.accountSid = config.notifications.sms.twilioAccountSid;
+    this.authToken = config.notifications.sms.twilioAuthToken;
+    this.from = config.notifications.sms.twilioFrom;
+  }
+
+  /**
+   * Send SMS message
+   */
+  async send(to, message) {
+    const response = await fetch(`https://api.twilio.com/2010-04-01/Accounts/${this.accountSid}/Messages.json`, {
+      method: 'POST',
+      headers: {
+        'Authorization': `Basic ${Buffer.from(`${this.accountSid}:${this.authToken}`).toString('base64')}`,
+        'Content-Type': 'application/x-www-form-urlencoded'
+      },
+      body: new URLSearchParams({ To: to, From: this.from, Body: message })
+    });
+
+    if (!response.ok) {
+      throw new Error(`Twilio responded with ${response.status}: ${await response.text()}`);
+    }
+    return true;
+  }
+}
+
+module.exports = TwilioProvider;
@@ -0,0 +1,117 @@
+const { formatMoney } = require('../utils/currency');
+
+// Default subject/body templates per event; {{name}} placeholders are filled from the payload
+const TEMPLATES = {
+  'loan.created': {
+    subject: 'New loan for {{borrowerName}}',
+    body: 'A loan of {{amountFormatted}} to {{borrowerName}} was recorded.'
+  },
+  'loan.completed': {
+    subject: 'Loan for {{borrowerName}} fully repaid',
+    body: 'The loan to {{borrowerName}} has been fully repaid.'
+  },
+  'loan.due_soon': {
+    subject: 'Loan for {{borrowerName}} is due on {{dueDate}}',
+    body: 'The loan to {{borrowerName}} is due in {{daysUntilDue}} day(s) on {{dueDate}}. Remaining debt: {{remainingDebtFormatted}}.'
+  },
+  'loan.overdue': {
+    subject: 'Loan for {{borrowerName}} is overdue',
+    body: 'The loan to {{borrowerName}} was due on {{dueDate}} and is {{daysOverdue}} day(s) overdue. Remaining debt: {{remainingDebtFormatted}}.'
+  },
+  'loan.escalation.gentle': {
+    subject: 'Loan for {{borrowerName}} is {{daysOverdue}} days overdue',
+    body: 'The loan to {{borrowerName}} is {{daysOverdue}} day(s) past its due date of {{dueDate}}. A friendly reminder has been scheduled. Remaining debt: {{remainingDebtFormatted}}.'
+  },
+  'loan.escalation.firm': {
+    subject: 'Loan for {{borrowerName}} is {{daysOverdue}} days overdue',
+    body: 'The loan to {{borrowerName}} is {{daysOverdue}} day(s) overdue (due {{dueDate}}). Consider contacting the borrower directly. Remaining debt: {{remainingDebtFormatted}}.'
+  },
+  'loan.escalation.final': {
+    subject: 'Final notice: loan for {{borrowerName}}',
+    body: 'The loan to {{borrowerName}} is {{daysOverdue}} day(s) overdue (due {{dueDate}}) and a final notice has been issued. Remaining debt: {{remainingDebtFormatted}}.'
+  },
+  'transaction.created': {
+    subject: 'Payment received from {{borrowerName}}',
+    body: 'A {{transactionType}} of {{amountFormatted}} was recorded for {{borrowerName}}.'
+  },
+  'auth.new_login': {
+    subject: 'New sign-in to your account',
+    body: 'Your account was signed in from a {{reason}} ({{device}}, {{location}}) at {{occurredAt}}. If this was not you, change your password now.'
+  },
+  'report.scheduled': {
+    subject: 'Scheduled report: {{reportName}}',
+    body: '{{summary}}'
+  }
+};
+
+// Templates for channels that message the borrower directly (e.g. SMS)
+const BORROWER_TEMPLATES = {
+  'loan.due_soon': {
+    subject: 'Payment reminder',
+    body: 'เรียนคุณ {{borrowerName}} ยอดค้างชำระ {{remainingDebtFormatted}} ครบกำหนดวันที่ {{dueDate}} / Reminder: {{remainingDebtFormatted}} is due on {{dueDate}}.'
+  },
+  'loan.overdue': {
+    subject: 'Overdue payment',
+    body: 'เรียนคุณ {{borrowerName}} ยอดค้างชำระ {{remainingDebtFormatted}} เลยกำหนดวันที่ {{dueDate}} แล้ว / Your payment of {{remainingDebtFormatted}} was due on {{dueDate}}.'
+  },
+  'loan.escalation.gentle': {
+    subject: 'Friendly reminder',
+    body: 'เรียนคุณ {{borrowerName}} ขอแจ้งเตือนยอดค้างชำระ {{remainingDebtFormatted}} ซึ่งเลยกำหนดวันที่ {{dueDate}} มา {{daysOverdue}} วัน / A friendly reminder that {{remainingDebtFormatted}} is {{daysOverdue}} days overdue.'
+  },
+  'loan.escalation.firm': {
+    subject: 'Payment overdue',
+    body: 'เรียนคุณ {{borrowerName}} ยอดค้างชำระ {{remainingDebtFormatted}} เลยกำหนดมา {{daysOverdue}} วันแล้ว กรุณาชำระโดยเร็ว / Your payment of {{remainingDebtFormatted}} is {{daysOverdue}} days overdue. Please pay promptly.'
+  },
+  'loan.escalation.final': {
+    subject: 'Final notice',
+    body: 'เรียนคุณ {{borrowerName}} แจ้งเตือนครั้งสุดท้าย ยอดค้างชำระ {{remainingDebtFormatted}} เลยกำหนดมา {{daysOverdue}} วัน กรุณาติดต่อกลับทันที / Final notice: {{remainingDebtFormatted}} is {{daysOverdue}} days overdue. Please contact us immediately.'
+  }
+};
+
+const NOTIFICATION_EVENTS = Object.keys(TEMPLATES);
+
+/**
+ * Replace {{name}} placeholders with values
+ */
+function interpolate(template, values) {
+  return template.replace(/\{\{\s*(\w+)\s*\}\}/g, (match, key) => (
+    values[key] !== undefined && values[key] !== null ? String(values[key]) : ''
+  ));
+}
+
+/**
+ * Add display-formatted values derived from the payload
+ */
+function buildTemplateValues(payload) {
+  const values = { ...payload };
+  ['amount', 'remainingDebt'].forEach(field => {
+    if (payload[field] !== undefined && payload[field] !== null) {
+      values[`${field}Formatted`] = formatMoney(payload[field], payload.currency);
+    }
+  });
+  return values;
+}
+
+/**
+ * Render subject and body for an event
+ */
+function renderTemplate(event, payload, audience = 'user') {
+  const template = audience === 'borrower' ? BORROWER_TEMPLATES[event] : TEMPLATES[event];
+  if (!template) {
+    return { subject: event, body: JSON.stringify(payload) };
+  }
+
+  const values = buildTemplateValues(payload);
+  return {
+    subject: interpolate(template.subject, values),
+    body: interpolate(template.body, values)
+  };
+}
+
+module.exports = {
+  NOTIFICATION_EVENTS,
+  TEMPLATES,
+  BORROWER_TEMPLATES,
+  interpolate,
+  renderTemplate
+};
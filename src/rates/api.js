@@ -0,0 +1,74 @@
+const config = require('../config');
+const { DEFAULT_CURRENCY } = require('../utils/currency');
+
+const REQUEST_TIMEOUT_MS = 5000;
+
+/**
+ * Exchange rates from an HTTP API answering { rates: { CODE: units per one base unit } }
+ * (open.er-api.com, Frankfurter and exchangerate.host all do). Rates are cached for
+ * EXCHANGE_RATES_CACHE_SECONDS; when a refresh fails the last rates keep being used.
+ */
+class ApiRatesProvider {
+  constructor() {
+    this.name = 'api';
+    this.url = config.rates.apiUrl.replace('{base}', encodeURIComponent(DEFAULT_CURRENCY));
+    this.cached = null;
+    this.pending = null;
+  }
+
+  /**
+   * Fetch and invert the API rates so rates[code] is the value of one unit of code in DEFAULT_CURRENCY
+   */
+  async fetchRates() {
+    const response = await fetch(this.url, {
+      headers: { 'Accept': 'application/json' },
+      signal: AbortSignal.timeout(REQUEST_TIMEOUT_MS)
+    });
+    if (!response.ok) {
+      throw new Error(`Exchange rate API responded with ${response.status}: ${await response.text()}`);
+    }
+
+    const body = await response.json();
+    if (!body || typeof body.rates !== 'object' || body.rates === null) {
+      throw new Error('Exchange rate API response has no rates');
+    }
+
+    const rates = { [DEFAULT_CURRENCY]: 1 };
+    Object.entries(body.rates).forEach(([code, perBase]) => {
+      if (Number.isFinite(perBase) && perBase > 0) {
+        rates[code] = 1 / perBase;
+      }
+    });
+    return { base: DEFAULT_CURRENCY, rates, asOf: body.date || body.time_last_update_utc || new Date().toISOString() };
+  }
+
+  /**
+   * Current rates as { base, rates, asOf }, refreshed once the cache expires (one request at a time)
+   */
+  async getRates() {
+    if (this.cached && this.cached.expiresAt > Date.now()) {
+      return this.cached.value;
+    }
+
+    if (!this.pending) {
+      this.pending = this.fetchRates()
+        .then(value => {
+          this.cached = { value, expiresAt: Date.now() + config.rates.cacheSeconds * 1000 };
+          return value;
+        })
+        .catch(error => {
+          if (!this.cached) {
+            throw error;
+          }
+          console.warn('Exchange rate refresh failed, using cached rates:', error.message);
+          return this.cached.value;
+        })
+        .finally(() => {
+          this.pending = null;
+        });
+    }
+    return this.pending;
+  }
+}
+
+module.exports = ApiRatesProvider;
@@ -0,0 +1,64 @@
+const config = require('../config');
+const { roundCurrency } = require('../utils/currency');
+const ManualRatesProvider = require('./manual');
+const ApiRatesProvider = require('./api');
+
+/**
+ * Currency conversion on top of a rates provider implementing getRates(), which resolves to
+ * { base, rates, asOf } with rates[code] the value of one unit of code in base.
+ */
+class ExchangeRates {
+  constructor(provider) {
+    this.provider = provider;
+  }
+
+  /**
+   * Rate to multiply an amount in from by to get to; throws when either currency has no rate
+   */
+  async getRate(from, to) {
+    if (from === to) {
+      return 1;
+    }
+
+    const { rates } = await this.provider.getRates();
+    const missing = [from, to].filter(code => !rates[code]);
+    if (missing.length > 0) {
+      throw new Error(`No exchange rate for ${missing.join(', ')}`);
+    }
+    return rates[from] / rates[to];
+  }
+
+  /**
+   * Sum the given fields of per-currency totals (rows with a currency) in one currency.
+   * Returns { currency, <field>: total, rates, asOf, source }, where rates are the ones applied per currency.
+   */
+  async consolidate(rows, fields, currency) {
+    const rates = {};
+    for (const row of rows) {
+      rates[row.currency] = await this.getRate(row.currency, currency);
+    }
+    const converted = rows.some(row => row.currency !== currency);
+    const { asOf } = converted ? await this.provider.getRates() : { asOf: null };
+
+    const totals = Object.fromEntries(fields.map(field => [
+      field,
+      roundCurrency(rows.reduce((sum, row) => sum + row[field] * rates[row.currency], 0), currency)
+    ]));
+    return { currency, ...totals, rates, asOf, source: converted ? this.provider.name : null };
+  }
+}
+
+/**
+ * Create exchange rates from configuration (EXCHANGE_RATES_PROVIDER: manual or api)
+ */
+function createExchangeRates() {
+  switch (config.rates.provider) {
+    case 'api':
+      return new ExchangeRates(new ApiRatesProvider());
+    case 'manual':
+    default:
+      return new ExchangeRates(new ManualRatesProvider());
+  }
+}
+
+module.exports = createExchangeRates();
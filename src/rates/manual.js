@@ -0,0 +1,25 @@
+const config = require('../config');
+const { DEFAULT_CURRENCY } = require('../utils/currency');
+
+/**
+ * Manual exchange rates from EXCHANGE_RATES ("USD=36.5,EUR=39.2": value of one unit in DEFAULT_CURRENCY).
+ * Read on every call, so a config reload applies new rates right away.
+ */
+class ManualRatesProvider {
+  constructor() {
+    this.name = 'manual';
+  }
+
+  /**
+   * Current rates as { base, rates, asOf }, where rates[code] is the value of one unit of code in base
+   */
+  async getRates() {
+    return {
+      base: DEFAULT_CURRENCY,
+      rates: { ...config.rates.manual, [DEFAULT_CURRENCY]: 1 },
+      asOf: null
+    };
+  }
+}
+
+module.exports = ManualRatesProvider;
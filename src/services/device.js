@@ -0,0 +1,81 @@
+const crypto = require('crypto');
+const db = require('../database/db');
+const notificationService = require('../notifications');
+
+// Geo-IP country headers set by the edge (Vercel, Cloudflare, CloudFront)
+const COUNTRY_HEADERS = ['x-vercel-ip-country', 'cf-ipcountry', 'cloudfront-viewer-country'];
+
+/**
+ * Known sign-in devices per user. A device is identified by its user agent; a login from an
+ * unseen device or country alerts the user unless the device has been marked trusted.
+ */
+class DeviceService {
+  /**
+   * Stable identifier for the requesting device
+   */
+  fingerprint(req) {
+    return crypto.createHash('sha256').update(String(req.headers['user-agent'] || '').trim()).digest('hex');
+  }
+
+  /**
+   * ISO country code of the request from edge headers, or null when unknown
+   */
+  country(req) {
+    for (const header of COUNTRY_HEADERS) {
+      const value = String(req.headers[header] || '').toUpperCase();
+      if (/^[A-Z]{2}$/.test(value) && value !== 'XX') {
+        return value;
+      }
+    }
+    return null;
+  }
+
+  /**
+   * Record a successful sign-in and emit 'auth.new_login' when it comes from a new device or country.
+   * The first device of an account is recorded silently. Failures are logged, never thrown.
+   */
+  async recordLogin(req, user) {
+    try {
+      const fingerprint = this.fingerprint(req);
+      const country = this.country(req);
+      const userAgent = req.headers['user-agent'] || null;
+
+      const known = await db.query(
+        'SELECT fingerprint, trusted, countries FROM user_devices WHERE user_id = $1',
+        [user.id]
+      );
+      const device = known.rows.find(row => row.fingerprint === fingerprint);
+      const newCountry = country && !known.rows.some(row => row.countries.includes(country));
+
+      await db.query(
+        `INSERT INTO user_devices (user_id, fingerprint, user_agent, last_ip, last_country, countries)
+         VALUES ($1, $2, $3, $4, $5::text, CASE WHEN $5::text IS NULL THEN '{}'::text[] ELSE ARRAY[$5::text] END)
+         ON CONFLICT (user_id, fingerprint) DO UPDATE
+         SET last_ip = EXCLUDED.last_ip,
+             last_country = COALESCE(EXCLUDED.last_country, user_devices.last_country),
+             countries = ARRAY(SELECT DISTINCT unnest(user_devices.countries || EXCLUDED.countries)),
+             last_seen_at = now()`,
+        [user.id, fingerprint, userAgent, req.ip || null, country]
+      );
+
+      if (known.rows.length === 0 || (device && device.trusted) || (device && !newCountry)) {
+        return;
+      }
+
+      await notificationService.emit('auth.new_login', {
+        userId: user.id,
+        payload: {
+          reason: device ? 'new country' : 'new device',
+          device: userAgent ? userAgent.slice(0, 120) : 'unknown device',
+          location: [req.ip, country].filter(Boolean).join(', ') || 'unknown location',
+          country,
+          occurredAt: new Date().toISOString()
+        }
+      });
+    } catch (error) {
+      console.error('Record login device error:', error);
+    }
+  }
+}
+
+module.exports = new DeviceService();
@@ -0,0 +1,145 @@
+const db = require('../database/db');
+const notificationService = require('../notifications');
+const { ValidationError } = require('../utils/response');
+const { formatMoney } = require('../utils/currency');
+const { LOAN_STATUSES } = require('../models');
+
+/**
+ * Loan business rules, kept out of the HTTP handlers so every entry point
+ * (REST, batch, gRPC, imports) applies them the same way.
+ * Methods take db or a transaction client and throw ValidationError on rule violations.
+ */
+class LoanService {
+  /**
+   * Balance columns of a loan, or null when it does not exist.
+   * On a transaction client the row is locked, so concurrent payments validate one after the other.
+   */
+  async getBalance(client, loanId) {
+    const result = await client.query(
+      `SELECT id, status, currency, amount, total_paid, remaining_debt, pending_amount FROM loans WHERE id = $1${client === db ? '' : ' FOR UPDATE'}`,
+      [loanId]
+    );
+    return result.rows[0] || null;
+  }
+
+  /**
+   * Reject an entry in another currency than its loan; every amount on a loan is in the loan currency
+   */
+  validateCurrency(loan, currency) {
+    if (currency && currency !== loan.currency) {
+      throw new ValidationError(`Currency ${currency} does not match the loan currency ${loan.currency}`, [
+        { field: 'currency', issue: 'currency_mismatch', loanCurrency: loan.currency }
+      ], 'CURRENCY_MISMATCH');
+    }
+  }
+
+  /**
+   * Reject an entry whose currency (when given) differs from the loan's, and a payment larger than the remaining debt.
+   * replacing is the existing transaction row when an entry is being edited, so its own amount is credited back.
+   * locale formats the amount in the error message (default the loan currency's locale).
+   */
+  async validatePayment(client, { loanId, transactionType, amount, currency = null, replacing = null, locale = null }) {
+    if (transactionType !== 'payment' && !currency) {
+      return;
+    }
+
+    const loan = await this.getBalance(client, loanId);
+    if (!loan) {
+      return;
+    }
+
+    this.validateCurrency(loan, currency);
+    if (transactionType !== 'payment') {
+      return;
+    }
+
+    let remainingDebt = parseFloat(loan.remaining_debt);
+    if (replacing && replacing.loan_id === loanId && replacing.status === 'confirmed') {
+      // Editing a confirmed entry removes its previous effect on the balance first
+      remainingDebt += replacing.transaction_type === 'payment'
+        ? parseFloat(replacing.amount)
+        : -parseFloat(replacing.amount);
+    }
+
+    if (parseFloat(amount) > remainingDebt) {
+      throw new ValidationError(`Payment exceeds the remaining debt of ${formatMoney(Math.max(remainingDebt, 0), loan.currency, { locale })}`, [
+        { field: 'amount', issue: 'exceeds_remaining_debt', remainingDebt: Math.max(remainingDebt, 0) }
+      ]);
+    }
+  }
+
+  /**
+   * Check a manual status change: 'paid' is derived from the ledger, so it can only be set once
+   * the debt is settled and cannot be left while nothing is owed
+   */
+  validateStatusChange(loan, status) {
+    if (!LOAN_STATUSES.includes(status)) {
+      throw new ValidationError(`Status must be one of: ${LOAN_STATUSES.join(', ')}`, [
+        { field: 'status', issue: 'invalid' }
+      ]);
+    }
+
+    const settled = parseFloat(loan.remaining_debt) <= 0;
+    if (status === 'paid' && !settled) {
+      throw new ValidationError('Loan cannot be marked paid while debt remains', [
+        { field: 'status', issue: 'outstanding_debt' }
+      ]);
+    }
+    if (status !== 'paid' && loan.status === 'paid' && settled) {
+      throw new ValidationError('Loan is fully paid; add a transaction to reopen it', [
+        { field: 'status', issue: 'fully_paid' }
+      ]);
+    }
+  }
+
+  /**
+   * Recalculate loan status from its ledger balance and announce loans that just became fully paid
+   */
+  async syncLoanStatus(client, loanId) {
+    const result = await client.query(
+      `WITH previous AS (SELECT id, status FROM loans WHERE id = $1)
+       UPDATE loans l
+       SET status = CASE
+             WHEN lb.remaining_debt <= 0 THEN 'paid'
+             WHEN l.status = 'paid' THEN 'active'
+             ELSE l.status
+           END,
+           updated_at = CURRENT_TIMESTAMP
+       FROM loan_balances lb, previous
+       WHERE lb.loan_id = l.id AND previous.id = l.id AND l.id = $1
+       RETURNING l.id, l.user_id, l.borrower_name, l.status, previous.status as previous_status`,
+      [loanId]
+    );
+
+    const loan = result.rows[0];
+    if (loan && loan.status === 'paid' && loan.previous_status !== 'paid') {
+      await notificationService.emit('loan.completed', {
+        userId: loan.user_id,
+        payload: { loanId: loan.id, borrowerName: loan.borrower_name }
+      }, client);
+    }
+  }
+
+  /**
+   * Apply a validated manual status change for a user's loan; returns the updated row or null if not found
+   */
+  async updateStatus(userId, loanId, status, client = db) {
+    const existing = await client.query(
+      'SELECT id, status, remaining_debt FROM loans WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL',
+      [loanId, userId]
+    );
+    if (existing.rows.length === 0) {
+      return null;
+    }
+
+    this.validateStatusChange(existing.rows[0], status);
+
+    const result = await client.query(
+      'UPDATE loans SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND user_id = $3 RETURNING *',
+      [status, loanId, userId]
+    );
+    return result.rows[0];
+  }
+}
+
+module.exports = new LoanService();
@@ -0,0 +1,54 @@
+const db = require('../database/db');
+const { hashPassword } = require('../utils/hash');
+const { validatePassword } = require('../utils/password');
+const { ValidationError } = require('../utils/response');
+const { User } = require('../models');
+
+/**
+ * Account creation shared by registration and the CLI, so both apply the same password policy
+ */
+class UserService {
+  /**
+   * Create an account; throws ValidationError for a weak password or a taken username (code USERNAME_TAKEN)
+   */
+  async createUser({ username, password, fullName = null, email = null }, client = db) {
+    await validatePassword(password);
+
+    const existingUser = await client.query('SELECT id FROM users WHERE username = $1', [username]);
+    if (existingUser.rows.length > 0) {
+      throw new ValidationError('Username already exists', [{ field: 'username', issue: 'taken' }], 'USERNAME_TAKEN');
+    }
+
+    const passwordHash = await hashPassword(password);
+    const result = await client.query(
+      `INSERT INTO users (username, password_hash, full_name, email)
+       VALUES ($1, $2, $3, $4)
+       RETURNING *`,
+      [username, passwordHash, fullName, email]
+    );
+
+    const userData = result.rows[0];
+    return new User({
+      id: userData.id,
+      username: userData.username,
+      fullName: userData.full_name,
+      email: userData.email,
+      createdAt: userData.created_at,
+      updatedAt: userData.updated_at
+    });
+  }
+
+  /**
+   * Find an active account by id or username, or null
+   */
+  async findUser(idOrUsername, client = db) {
+    const result = await client.query(
+      `SELECT id, username, full_name, email FROM users
+       WHERE (id::text = $1 OR username = $1) AND deleted_at IS NULL`,
+      [idOrUsername]
+    );
+    return result.rows[0] || null;
+  }
+}
+
+module.exports = new UserService();
@@ -0,0 +1,20 @@
+const config = require('../config');
+const LocalStorage = require('./local');
+
+/**
+ * Create storage backend from configuration
+ *
+ * Every backend implements save(key, buffer, contentType), get(key) and delete(key).
+ */
+function createStorage() {
+  const driver = config.storage.driver;
+
+  switch (driver) {
+    case 'local':
+      return new LocalStorage(config.storage.dir);
+    default:
+      throw new Error(`Unsupported storage driver: ${driver}`);
+  }
+}
+
+module.exports = createStorage();
@@ -0,0 +1,51 @@
+const fs = require('fs');
+const path = require('path');
+
+class LocalStorage {
+  constructor(baseDir) {
+    this.baseDir = path.resolve(baseDir);
+  }
+
+  /**
+   * Resolve key to a path inside the base directory
+   */
+  resolvePath(key) {
+    const filePath = path.resolve(this.baseDir, key);
+    if (!filePath.startsWith(this.baseDir + path.sep)) {
+      throw new Error('Invalid storage key');
+    }
+    return filePath;
+  }
+
+  /**
+   * Store file contents under key
+   */
+  async save(key, buffer) {
+    const filePath = this.resolvePath(key);
+    await fs.promises.mkdir(path.dirname(filePath), { recursive: true });
+    await fs.promises.writeFile(filePath, buffer);
+    return key;
+  }
+
+  /**
+   * Read file contents for key
+   */
+  async get(key) {
+    return fs.promises.readFile(this.resolvePath(key));
+  }
+
+  /**
+   * Delete file for key (ignores missing files)
+   */
+  async delete(key) {
+    try {
+      await fs.promises.unlink(this.resolvePath(key));
+    } catch (error) {
+      if (error.code !== 'ENOENT') {
+        throw error;
+      }
+    }
+  }
+}
+
+module.exports = LocalStorage;
@@ -0,0 +1,120 @@
+const { DEFAULT_CURRENCY, toCurrencyString } = require('./currency');
+
+/**
+ * Format a date as YYYY-MM-DD
+ */
+function toISODate(date) {
+  return date instanceof Date ? date.toISOString().slice(0, 10) : String(date).slice(0, 10);
+}
+
+/**
+ * Strip line breaks that would break line-based formats
+ */
+function toSingleLine(value) {
+  return String(value || '').replace(/[\r\n]+/g, ' ').trim();
+}
+
+/**
+ * Escape text for OFX SGML/XML content
+ */
+function escapeOFX(value) {
+  return toSingleLine(value)
+    .replace(/&/g, '&amp;')
+    .replace(/</g, '&lt;')
+    .replace(/>/g, '&gt;');
+}
+
+/**
+ * Generate QIF (Quicken Interchange Format) text from cash entries.
+ * Entries: { date, amount (signed, + is money in), payee, memo, category }
+ */
+function generateQIF(entries, { accountName = 'Loans', currency = DEFAULT_CURRENCY } = {}) {
+  const lines = [
+    '!Account',
+    `N${toSingleLine(accountName)}`,
+    'TBank',
+    '^',
+    '!Type:Bank'
+  ];
+
+  entries.forEach(entry => {
+    // QIF dates are MM/DD/YYYY in most importers
+    const [year, month, day] = toISODate(entry.date).split('-');
+    lines.push(`D${month}/${day}/${year}`);
+    lines.push(`T${toCurrencyString(entry.amount, currency)}`);
+    lines.push(`P${toSingleLine(entry.payee)}`);
+    if (entry.memo) {
+      lines.push(`M${toSingleLine(entry.memo)}`);
+    }
+    if (entry.category) {
+      lines.push(`L${toSingleLine(entry.category)}`);
+    }
+    lines.push('^');
+  });
+
+  return lines.join('\r\n') + '\r\n';
+}
+
+/**
+ * Generate an OFX 2.x bank statement from cash entries (entry id becomes FITID for de-duplication)
+ */
+function generateOFX(entries, { accountId, from, to, currency = DEFAULT_CURRENCY } = {}) {
+  const toOFXDate = date => toISODate(date).replace(/-/g, '');
+  const dates = entries.map(entry => toISODate(entry.date)).sort();
+  const start = from || dates[0] || toISODate(new Date());
+  const end = to || dates[dates.length - 1] || toISODate(new Date());
+  const balance = entries.reduce((sum, entry) => sum + parseFloat(entry.amount), 0);
+  const now = new Date().toISOString().replace(/[-:T]/g, '').slice(0, 14);
+
+  const transactions = entries.map(entry => [
+    '<STMTTRN>',
+    `<TRNTYPE>${entry.amount >= 0 ? 'CREDIT' : 'DEBIT'}</TRNTYPE>`,
+    `<DTPOSTED>${toOFXDate(entry.date)}</DTPOSTED>`,
+    `<TRNAMT>${toCurrencyString(entry.amount, currency)}</TRNAMT>`,
+    `<FITID>${entry.id}</FITID>`,
+    `<NAME>${escapeOFX(entry.payee).slice(0, 32)}</NAME>`,
+    entry.memo ? `<MEMO>${escapeOFX(entry.memo).slice(0, 255)}</MEMO>` : null,
+    '</STMTTRN>'
+  ].filter(Boolean).join('\n')).join('\n');
+
+  return `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+<SIGNONMSGSRSV1>
+<SONRS>
+<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>
+<DTSERVER>${now}</DTSERVER>
+<LANGUAGE>ENG</LANGUAGE>
+</SONRS>
+</SIGNONMSGSRSV1>
+<BANKMSGSRSV1>
+<STMTTRNRS>
+<TRNUID>${now}</TRNUID>
+<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>
+<STMTRS>
+<CURDEF>${currency}</CURDEF>
+<BANKACCTFROM>
+<BANKID>LOANMONEY</BANKID>
+<ACCTID>${escapeOFX(accountId)}</ACCTID>
+<ACCTTYPE>CHECKING</ACCTTYPE>
+</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>${toOFXDate(start)}</DTSTART>
+<DTEND>${toOFXDate(end)}</DTEND>
+${transactions}
+</BANKTRANLIST>
+<LEDGERBAL>
+<BALAMT>${toCurrencyString(balance, currency)}</BALAMT>
+<DTASOF>${toOFXDate(end)}</DTASOF>
+</LEDGERBAL>
+</STMTRS>
+</STMTTRNRS>
+</BANKMSGSRSV1>
+</OFX>
+`;
+}
+
+module.exports = {
+  generateQIF,
+  generateOFX
+};
@@ -0,0 +1,14 @@
+/**
+ * Record an audit log entry (works with db or a transaction client)
+ */
+async function recordAudit(client, { userId, action, entityType, entityId = null, details = null }) {
+  await client.query(
+    `INSERT INTO audit_logs (user_id, action, entity_type, entity_id, details)
+     VALUES ($1, $2, $3, $4, $5)`,
+    [userId, action, entityType, entityId, details ? JSON.stringify(details) : null]
+  );
+}
+
+module.exports = {
+  recordAudit
+};
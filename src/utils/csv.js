@@ -0,0 +1,156 @@
+/**
+ * Parse CSV text into an array of rows (arrays of strings)
+ */
+function parseCSV(text) {
+  const rows = [];
+  let row = [];
+  let field = '';
+  let inQuotes = false;
+
+  const input = text.replace(/^\uFEFF/, '');
+
+  for (let i = 0; i < input.length; i++) {
+    const char = input[i];
+
+    if (inQuotes) {
+      if (char === '"') {
+        if (input[i + 1] === '"') {
+          field += '"';
+          i++;
+        } else {
+          inQuotes = false;
+        }
+      } else {
+        field += char;
+      }
+      continue;
+    }
+
+    if (char === '"') {
+      inQuotes = true;
+    } else if (char === ',') {
+      row.push(field);
+      field = '';
+    } else if (char === '\n' || char === '\r') {
+      if (char === '\r' && input[i + 1] === '\n') {
+        i++;
+      }
+      row.push(field);
+      rows.push(row);
+      row = [];
+      field = '';
+    } else {
+      field += char;
+    }
+  }
+
+  if (field !== '' || row.length > 0) {
+    row.push(field);
+    rows.push(row);
+  }
+
+  // Drop blank lines
+  return rows.filter(r => r.some(value => value.trim() !== ''));
+}
+
+/**
+ * Normalize a header name to snake_case
+ */
+function normalizeHeader(header) {
+  return header
+    .trim()
+    .replace(/([a-z0-9])([A-Z])/g, '$1_$2')
+    .replace(/[\s-]+/g, '_')
+    .toLowerCase();
+}
+
+/**
+ * Parse CSV text with a header row into an array of objects keyed by snake_case header
+ */
+function parseCSVRecords(text) {
+  return rowsToRecords(parseCSV(text));
+}
+
+/**
+ * Convert rows with a header row into an array of objects keyed by snake_case header
+ */
+function rowsToRecords(rows) {
+  if (rows.length === 0) {
+    return { headers: [], records: [] };
+  }
+
+  const headers = rows[0].map(normalizeHeader);
+  const records = rows.slice(1).map(values => {
+    const record = {};
+    headers.forEach((header, index) => {
+      record[header] = values[index] !== undefined ? values[index].trim() : '';
+    });
+    return record;
+  });
+
+  return { headers, records };
+}
+
+/**
+ * Remap records using a { targetField: sourceHeader } mapping; unmapped fields keep their own header.
+ * Returns { records } or { error } when the mapping references unknown fields or headers.
+ */
+function applyColumnMapping(headers, records, mapping, fields) {
+  if (!mapping) {
+    return { records };
+  }
+
+  if (typeof mapping !== 'object' || Array.isArray(mapping)) {
+    return { error: 'mapping must be an object of { field: "CSV header" }' };
+  }
+
+  const resolved = {};
+  for (const [field, header] of Object.entries(mapping)) {
+    if (!fields.includes(field)) {
+      return { error: `Unknown mapping field: ${field}. Must be one of: ${fields.join(', ')}` };
+    }
+    const source = normalizeHeader(String(header));
+    if (!headers.includes(source)) {
+      return { error: `Mapped column not found in CSV: ${header}` };
+    }
+    resolved[field] = source;
+  }
+
+  return {
+    records: records.map(record => {
+      const mapped = { ...record };
+      Object.entries(resolved).forEach(([field, source]) => {
+        mapped[field] = record[source];
+      });
+      return mapped;
+    })
+  };
+}
+
+/**
+ * Escape a single CSV value
+ */
+function escapeCSVValue(value) {
+  if (value === null || value === undefined) {
+    return '';
+  }
+  const str = value instanceof Date ? value.toISOString() : String(value);
+  return /[",\r\n]/.test(str) ? `"${str.replace(/"/g, '""')}"` : str;
+}
+
+/**
+ * Convert rows (arrays) to CSV text
+ */
+function toCSV(rows) {
+  return rows.map(row => row.map(escapeCSVValue).join(',')).join('\r\n');
+}
+
+module.exports = {
+  parseCSV,
+  parseCSVRecords,
+  rowsToRecords,
+  normalizeHeader,
+  applyColumnMapping,
+  escapeCSVValue,
+  toCSV
+};
@@ -0,0 +1,119 @@
+const config = require('../config');
+
+const DEFAULT_CURRENCY = config.loans.defaultCurrency;
+
+// Currency registry: decimal places and rounding mode per ISO 4217 code
+const CURRENCIES = {
+  THB: { decimals: 2, rounding: 'half-up', symbol: '฿', locale: 'th-TH' },
+  USD: { decimals: 2, rounding: 'half-up', symbol: '$', locale: 'en-US' },
+  EUR: { decimals: 2, rounding: 'half-even', symbol: '€', locale: 'de-DE' },
+  JPY: { decimals: 0, rounding: 'half-up', symbol: '¥', locale: 'ja-JP' },
+  LAK: { decimals: 0, rounding: 'half-up', symbol: '₭', locale: 'lo-LA' },
+  MMK: { decimals: 0, rounding: 'half-up', symbol: 'K', locale: 'my-MM' }
+};
+
+/**
+ * Get currency definition, falling back to the default currency
+ */
+function getCurrency(code) {
+  return CURRENCIES[code] || CURRENCIES[DEFAULT_CURRENCY];
+}
+
+/**
+ * Check whether a currency code is supported
+ */
+function isSupportedCurrency(code) {
+  return Object.prototype.hasOwnProperty.call(CURRENCIES, code);
+}
+
+/**
+ * Round amount according to the currency's decimal places and rounding mode
+ */
+function roundCurrency(amount, code = DEFAULT_CURRENCY) {
+  const { decimals, rounding } = getCurrency(code);
+  const factor = Math.pow(10, decimals);
+  // Scale with a small epsilon correction to avoid binary float artifacts (e.g. 1.005)
+  const scaled = parseFloat((Number(amount) * factor).toPrecision(15));
+
+  let rounded;
+  switch (rounding) {
+    case 'half-even': {
+      const floor = Math.floor(scaled);
+      const diff = scaled - floor;
+      if (diff > 0.5) {
+        rounded = floor + 1;
+      } else if (diff < 0.5) {
+        rounded = floor;
+      } else {
+        rounded = floor % 2 === 0 ? floor : floor + 1;
+      }
+      break;
+    }
+    case 'down':
+      rounded = Math.trunc(scaled);
+      break;
+    case 'up':
+      rounded = scaled < 0 ? Math.floor(scaled) : Math.ceil(scaled);
+      break;
+    case 'half-up':
+    default:
+      rounded = Math.sign(scaled) * Math.round(Math.abs(scaled));
+  }
+
+  return rounded / factor;
+}
+
+/**
+ * Format amount as a fixed-decimal string for the given currency (for exports)
+ */
+function toCurrencyString(amount, code = DEFAULT_CURRENCY) {
+  return roundCurrency(amount, code).toFixed(getCurrency(code).decimals);
+}
+
+/**
+ * Whether locale is a BCP 47 language tag the runtime can format numbers for
+ */
+function isValidLocale(locale) {
+  try {
+    return typeof locale === 'string' && Intl.NumberFormat.supportedLocalesOf(locale).length > 0;
+  } catch (error) {
+    return false;
+  }
+}
+
+/**
+ * Locale a user's amounts are formatted in (users.locale), or null for each currency's own locale
+ */
+function userLocale(user) {
+  return (user && user.locale) || null;
+}
+
+/**
+ * Format amount for display (messages, receipts, reports): the currency's decimal places and registry
+ * symbol, placed and separated per locale (default the currency's own locale).
+ * display 'code' shows the ISO code instead, for output whose fonts lack the symbol (PDF).
+ */
+function formatMoney(amount, code = DEFAULT_CURRENCY, { locale = null, display = 'symbol' } = {}) {
+  const { decimals, symbol, locale: currencyLocale } = getCurrency(code);
+  const currency = isSupportedCurrency(code) ? code : DEFAULT_CURRENCY;
+  const parts = new Intl.NumberFormat(locale || currencyLocale, {
+    style: 'currency',
+    currency,
+    currencyDisplay: display === 'code' ? 'code' : 'narrowSymbol',
+    minimumFractionDigits: decimals,
+    maximumFractionDigits: decimals
+  }).formatToParts(roundCurrency(amount, currency));
+  return parts.map(part => (part.type === 'currency' && display !== 'code' ? symbol : part.value)).join('');
+}
+
+module.exports = {
+  DEFAULT_CURRENCY,
+  CURRENCIES,
+  getCurrency,
+  isSupportedCurrency,
+  roundCurrency,
+  toCurrencyString,
+  isValidLocale,
+  userLocale,
+  formatMoney
+};
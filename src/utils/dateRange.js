@@ -0,0 +1,109 @@
+const { DATE_FORMAT_HINT, parseDate } = require('./dates');
+
+const DATE_RANGE_PRESETS = [
+  'today',
+  'this_week',
+  'this_month',
+  'last_month',
+  'last_30_days',
+  'this_quarter',
+  'ytd',
+  'last_year',
+  'last_12_months',
+  'all'
+];
+
+/**
+ * Format a Date as YYYY-MM-DD (UTC)
+ */
+function toDateString(date) {
+  return date.toISOString().slice(0, 10);
+}
+
+/**
+ * Resolve a preset name to { from, to } date strings relative to today
+ */
+function resolvePreset(preset, today = new Date()) {
+  const year = today.getUTCFullYear();
+  const month = today.getUTCMonth();
+  const day = today.getUTCDate();
+  const utc = (y, m, d) => new Date(Date.UTC(y, m, d));
+
+  switch (preset) {
+    case 'today':
+      return { from: toDateString(today), to: toDateString(today) };
+    case 'this_week': {
+      // Weeks start on Monday
+      const weekday = (today.getUTCDay() + 6) % 7;
+      return { from: toDateString(utc(year, month, day - weekday)), to: toDateString(today) };
+    }
+    case 'this_month':
+      return { from: toDateString(utc(year, month, 1)), to: toDateString(today) };
+    case 'last_month':
+      return { from: toDateString(utc(year, month - 1, 1)), to: toDateString(utc(year, month, 0)) };
+    case 'last_30_days':
+      return { from: toDateString(utc(year, month, day - 29)), to: toDateString(today) };
+    case 'this_quarter':
+      return { from: toDateString(utc(year, month - (month % 3), 1)), to: toDateString(today) };
+    case 'ytd':
+      return { from: toDateString(utc(year, 0, 1)), to: toDateString(today) };
+    case 'last_year':
+      return { from: toDateString(utc(year - 1, 0, 1)), to: toDateString(utc(year - 1, 11, 31)) };
+    case 'last_12_months':
+      return { from: toDateString(utc(year, month - 11, 1)), to: toDateString(today) };
+    default:
+      return { from: null, to: null };
+  }
+}
+
+/**
+ * Parse from/to/range query params into a date range; presets are relative to today (a Date, or a
+ * YYYY-MM-DD string such as the user's local date).
+ * Returns { from, to, preset } with null bounds when open-ended, or { error } when invalid.
+ */
+function parseDateRange(query, defaultPreset = 'all', today = new Date()) {
+  const preset = query.range || (query.from || query.to ? null : defaultPreset);
+
+  if (preset) {
+    if (!DATE_RANGE_PRESETS.includes(preset)) {
+      return { error: `Range must be one of: ${DATE_RANGE_PRESETS.join(', ')}` };
+    }
+    return { ...resolvePreset(preset, typeof today === 'string' ? new Date(`${today}T00:00:00Z`) : today), preset };
+  }
+
+  const from = query.from ? parseDate(query.from) : null;
+  const to = query.to ? parseDate(query.to) : null;
+
+  if ((query.from && !from) || (query.to && !to)) {
+    return { error: `from and to must be valid dates (${DATE_FORMAT_HINT})` };
+  }
+
+  if (from && to && from > to) {
+    return { error: 'from must be on or before to' };
+  }
+
+  return { from, to, preset: null };
+}
+
+/**
+ * Append SQL conditions limiting a date column to the range; pushes values onto params
+ */
+function dateRangeCondition(column, range, params) {
+  let condition = '';
+  if (range.from) {
+    params.push(range.from);
+    condition += ` AND ${column} >= $${params.length}`;
+  }
+  if (range.to) {
+    params.push(range.to);
+    condition += ` AND ${column} <= $${params.length}`;
+  }
+  return condition;
+}
+
+module.exports = {
+  DATE_RANGE_PRESETS,
+  resolvePreset,
+  parseDateRange,
+  dateRangeCondition
+};
@@ -0,0 +1,186 @@
+const config = require('../config');
+
+// Calendars dates can be shown in; stored dates are always Gregorian
+const CALENDARS = ['gregorian', 'buddhist'];
+const BUDDHIST_ERA_OFFSET = 543;
+// Years from 2400 are read as Buddhist Era (2400 BE is 1857 CE, long before any loan)
+const BUDDHIST_ERA_MIN_YEAR = 2400;
+
+// Date fields of loan, transaction and ledger rows and models, rewritten for display
+const DATE_FIELDS = ['loan_date', 'due_date', 'transaction_date', 'entry_date', 'loanDate', 'dueDate', 'transactionDate'];
+
+const DATE_FORMAT_HINT = 'YYYY-MM-DD or DD/MM/YYYY, Buddhist Era years accepted';
+
+/**
+ * YYYY-MM-DD for a Gregorian year, month and day, or null when there is no such day
+ */
+function toISODate(year, month, day) {
+  const date = new Date(Date.UTC(year, month - 1, day));
+  if (date.getUTCFullYear() !== year || date.getUTCMonth() !== month - 1 || date.getUTCDate() !== day) {
+    return null;
+  }
+  return date.toISOString().slice(0, 10);
+}
+
+/**
+ * Parse a date typed by a user into a Gregorian YYYY-MM-DD string, or null when invalid.
+ * Accepts YYYY-MM-DD (a trailing time is ignored) and day-first DD/MM/YYYY (also with - or .);
+ * years from 2400 are Buddhist Era, so 25/12/2567 and 2567-12-25 are both 2024-12-25.
+ */
+function parseDate(value) {
+  if (value instanceof Date) {
+    return isNaN(value.getTime()) ? null : toISODate(value.getFullYear(), value.getMonth() + 1, value.getDate());
+  }
+  if (typeof value !== 'string') {
+    return null;
+  }
+
+  const text = value.trim();
+  const iso = text.match(/^(\d{4})-(\d{1,2})-(\d{1,2})(?:[T ].*)?$/);
+  const dayFirst = text.match(/^(\d{1,2})[/.-](\d{1,2})[/.-](\d{4})$/);
+  if (!iso && !dayFirst) {
+    return null;
+  }
+
+  const [year, month, day] = (iso ? [iso[1], iso[2], iso[3]] : [dayFirst[3], dayFirst[2], dayFirst[1]]).map(Number);
+  return toISODate(year >= BUDDHIST_ERA_MIN_YEAR ? year - BUDDHIST_ERA_OFFSET : year, month, day);
+}
+
+/**
+ * Parse the given date fields of a request body; returns the parsed values keyed by field
+ * (missing or empty fields are passed through), or { error } naming the first invalid one
+ */
+function parseDateFields(source, fields) {
+  const values = {};
+  for (const field of fields) {
+    const raw = source[field];
+    if (raw === undefined || raw === null || raw === '') {
+      values[field] = raw;
+      continue;
+    }
+    values[field] = parseDate(raw);
+    if (!values[field]) {
+      return { error: `${field} must be a valid date (${DATE_FORMAT_HINT})` };
+    }
+  }
+  return values;
+}
+
+/**
+ * Format a date (YYYY-MM-DD string or a DATE column value) for display: YYYY-MM-DD in the Gregorian
+ * calendar, DD/MM/YYYY with the Buddhist Era year in the Buddhist calendar. null stays null.
+ */
+function formatDate(value, calendar = 'gregorian') {
+  const iso = value === null || value === undefined ? null : parseDate(value);
+  if (!iso) {
+    return value === undefined ? null : value;
+  }
+  if (calendar !== 'buddhist') {
+    return iso;
+  }
+  const [year, month, day] = iso.split('-');
+  return `${day}/${month}/${Number(year) + BUDDHIST_ERA_OFFSET}`;
+}
+
+/**
+ * Format the month of a date for display: YYYY-MM (Gregorian) or MM/YYYY with the Buddhist Era year
+ */
+function formatMonth(value, calendar = 'gregorian') {
+  const iso = parseDate(value);
+  if (!iso) {
+    return value;
+  }
+  const [year, month] = iso.split('-');
+  return calendar === 'buddhist' ? `${month}/${Number(year) + BUDDHIST_ERA_OFFSET}` : `${year}-${month}`;
+}
+
+/**
+ * Copy of a row (or rows) with its date fields formatted for the calendar; unchanged for the Gregorian calendar
+ */
+function formatDateFields(rows, calendar) {
+  if (calendar !== 'buddhist') {
+    return rows;
+  }
+  if (Array.isArray(rows)) {
+    return rows.map(row => formatDateFields(row, calendar));
+  }
+  if (!rows || typeof rows !== 'object') {
+    return rows;
+  }
+
+  const formatted = Object.assign(Object.create(Object.getPrototypeOf(rows)), rows);
+  DATE_FIELDS.forEach(field => {
+    if (formatted[field] !== undefined) {
+      formatted[field] = formatDate(formatted[field], calendar);
+    }
+  });
+  return formatted;
+}
+
+/**
+ * Whether name is an IANA time zone the runtime knows
+ */
+function isValidTimezone(name) {
+  try {
+    new Intl.DateTimeFormat('en-US', { timeZone: name });
+    return typeof name === 'string' && name !== '';
+  } catch (error) {
+    return false;
+  }
+}
+
+/**
+ * Time zone that decides a user's "today" (users.timezone, default DEFAULT_TIMEZONE)
+ */
+function userTimezone(user) {
+  return (user && user.timezone) || config.dates.defaultTimezone;
+}
+
+/**
+ * Today's date (YYYY-MM-DD) in a time zone
+ */
+function todayIn(timezone, now = new Date()) {
+  const parts = Object.fromEntries(new Intl.DateTimeFormat('en-US', {
+    timeZone: timezone, year: 'numeric', month: '2-digit', day: '2-digit'
+  }).formatToParts(now).map(part => [part.type, part.value]));
+  return `${parts.year}-${parts.month}-${parts.day}`;
+}
+
+/**
+ * Today's date (YYYY-MM-DD) for a user, rather than in the server's or the database's time zone
+ */
+function userToday(user) {
+  return todayIn(userTimezone(user));
+}
+
+/**
+ * SQL expression for today's date in the time zone held by column (for queries spanning users);
+ * falls back to DEFAULT_TIMEZONE when the column is null
+ */
+function localTodaySQL(column) {
+  const fallback = config.dates.defaultTimezone.replace(/'/g, "''");
+  return `(CURRENT_TIMESTAMP AT TIME ZONE COALESCE(${column}, '${fallback}'))::date`;
+}
+
+/**
+ * Calendar to show a user's dates in (users.calendar, default Gregorian)
+ */
+function userCalendar(user) {
+  return user && user.calendar === 'buddhist' ? 'buddhist' : 'gregorian';
+}
+
+module.exports = {
+  CALENDARS,
+  DATE_FORMAT_HINT,
+  parseDate,
+  parseDateFields,
+  formatDate,
+  formatMonth,
+  formatDateFields,
+  userCalendar,
+  isValidTimezone,
+  userTimezone,
+  todayIn,
+  userToday,
+  localTodaySQL
+};
@@ -0,0 +1,61 @@
+const crypto = require('crypto');
+const fs = require('fs');
+const path = require('path');
+
+/**
+ * Bundle the web frontend into one generated module (`npm run build`), so the server and the Vercel
+ * function ship the same assets inside the deployment and never read the working directory at runtime.
+ */
+
+const PROJECT_ROOT = path.resolve(__dirname, '../..');
+const OUTPUT_DIR = path.join(PROJECT_ROOT, 'dist');
+const OUTPUT_FILE = path.join(OUTPUT_DIR, 'web-assets.js');
+
+/**
+ * Files under dir as URL paths (/js/api.js); dotfiles and dot directories are left out of the bundle
+ */
+function listFiles(dir, prefix = '') {
+  return fs.readdirSync(dir, { withFileTypes: true })
+    .filter(entry => !entry.name.startsWith('.'))
+    .flatMap(entry => {
+      const urlPath = `${prefix}/${entry.name}`;
+      const filePath = path.join(dir, entry.name);
+      return entry.isDirectory() ? listFiles(filePath, urlPath) : [urlPath];
+    });
+}
+
+/**
+ * Read every file of the static directory with its content hash (used as the ETag)
+ */
+function collectAssets(root) {
+  return Object.fromEntries(listFiles(root).sort().map(urlPath => {
+    const body = fs.readFileSync(path.join(root, urlPath));
+    return [urlPath, {
+      etag: `"${crypto.createHash('sha1').update(body).digest('base64url')}"`,
+      body: body.toString('base64')
+    }];
+  }));
+}
+
+/**
+ * Write the bundle module; an empty dist/public is the static output for Vercel, so every path reaches the function
+ */
+function writeBundle(root) {
+  const assets = collectAssets(root);
+  fs.mkdirSync(path.join(OUTPUT_DIR, 'public'), { recursive: true });
+  fs.writeFileSync(OUTPUT_FILE, `// Generated by src/utils/embedWeb.js from ${path.relative(PROJECT_ROOT, root)}/, do not edit\nmodule.exports = ${JSON.stringify(assets, null, 2)};\n`);
+  return Object.keys(assets);
+}
+
+if (require.main === module) {
+  require('dotenv').config();
+  // Read directly rather than through config: building must not need the runtime secrets
+  const staticDir = process.env.STATIC_DIR || 'web';
+  const files = writeBundle(path.resolve(PROJECT_ROOT, staticDir));
+  console.log(`Embedded ${files.length} files from ${staticDir}/ into ${path.relative(PROJECT_ROOT, OUTPUT_FILE)}`);
+}
+
+module.exports = {
+  collectAssets,
+  writeBundle
+};
@@ -0,0 +1,90 @@
+const crypto = require('crypto');
+
+// OpenSSL-compatible format, decryptable with:
+//   openssl enc -d -aes-256-cbc -pbkdf2 -iter 100000 -md sha256 -in export.enc -out export
+const SALT_HEADER = Buffer.from('Salted__');
+const PBKDF2_ITERATIONS = 100000;
+const MIN_PASSPHRASE_LENGTH = 8;
+// Passphrases travel in a header so they never end up in URLs or access logs
+const PASSPHRASE_HEADER = 'X-Export-Passphrase';
+
+/**
+ * Validate an export passphrase; returns an error message or null
+ */
+function validatePassphrase(passphrase) {
+  if (typeof passphrase !== 'string' || passphrase.length < MIN_PASSPHRASE_LENGTH) {
+    return `Passphrase must be at least ${MIN_PASSPHRASE_LENGTH} characters`;
+  }
+  return null;
+}
+
+/**
+ * Read the optional export passphrase from a request; returns { passphrase } or { error }
+ */
+function getRequestPassphrase(req) {
+  const passphrase = req.get(PASSPHRASE_HEADER);
+  if (passphrase === undefined) {
+    return { passphrase: null };
+  }
+
+  const error = validatePassphrase(passphrase);
+  return error ? { error } : { passphrase };
+}
+
+/**
+ * Derive the AES key and IV from a passphrase (random salt unless given)
+ */
+function deriveKey(passphrase, salt = crypto.randomBytes(8)) {
+  const derived = crypto.pbkdf2Sync(passphrase, salt, PBKDF2_ITERATIONS, 48, 'sha256');
+  return { salt, key: derived.slice(0, 32), iv: derived.slice(32) };
+}
+
+/**
+ * Encrypt a buffer with an already derived key
+ */
+function encryptWithKey(buffer, { salt, key, iv }) {
+  const cipher = crypto.createCipheriv('aes-256-cbc', key, iv);
+  return Buffer.concat([SALT_HEADER, salt, cipher.update(buffer), cipher.final()]);
+}
+
+/**
+ * Encrypt a buffer with a passphrase (AES-256-CBC, PBKDF2-SHA256 key derivation)
+ */
+function encryptArchive(buffer, passphrase) {
+  return encryptWithKey(buffer, deriveKey(passphrase));
+}
+
+/**
+ * Check whether a buffer is an encrypted archive
+ */
+function isEncryptedArchive(buffer) {
+  return Buffer.isBuffer(buffer) && buffer.length > 16 && buffer.slice(0, 8).equals(SALT_HEADER);
+}
+
+/**
+ * Decrypt an encrypted archive; throws when the passphrase is wrong
+ */
+function decryptArchive(buffer, passphrase) {
+  if (!isEncryptedArchive(buffer)) {
+    throw new Error('Not an encrypted archive');
+  }
+
+  const { key, iv } = deriveKey(passphrase, buffer.slice(8, 16));
+  const decipher = crypto.createDecipheriv('aes-256-cbc', key, iv);
+  try {
+    return Buffer.concat([decipher.update(buffer.slice(16)), decipher.final()]);
+  } catch (error) {
+    throw new Error('Incorrect passphrase');
+  }
+}
+
+module.exports = {
+  PASSPHRASE_HEADER,
+  validatePassphrase,
+  getRequestPassphrase,
+  deriveKey,
+  encryptWithKey,
+  encryptArchive,
+  isEncryptedArchive,
+  decryptArchive
+};
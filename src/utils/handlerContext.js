@@ -0,0 +1,65 @@
+/**
+ * Minimal Express-like request/response pair so REST handlers and middleware can be invoked
+ * outside of HTTP routing (gRPC calls, batch sub-requests).
+ * done resolves with { status, headers, payload } once the handler responds.
+ */
+function createContext({ headers = {}, params = {}, query = {}, body = {}, user, id = null, method = 'GET', path = '/' }) {
+  let resolve;
+  const done = new Promise(r => { resolve = r; });
+
+  const req = {
+    id,
+    method,
+    path,
+    originalUrl: path,
+    headers,
+    params,
+    query,
+    body,
+    files: [],
+    user,
+    protocol: 'http',
+    get: name => headers[name.toLowerCase()]
+  };
+
+  const res = {
+    req,
+    statusCode: 200,
+    headers: {},
+    headersSent: false,
+    status(code) {
+      this.statusCode = code;
+      return this;
+    },
+    setHeader(name, value) {
+      this.headers[name.toLowerCase()] = value;
+    },
+    set(name, value) {
+      const values = typeof name === 'object' ? name : { [name]: value };
+      Object.entries(values).forEach(([key, val]) => this.setHeader(key, val));
+      return this;
+    },
+    type(value) {
+      this.setHeader('content-type', value);
+      return this;
+    },
+    json(payload) {
+      return this.send(payload);
+    },
+    send(payload) {
+      this.headersSent = true;
+      resolve({ status: this.statusCode, headers: this.headers, payload });
+      return this;
+    },
+    redirect(location) {
+      this.setHeader('location', location);
+      return this.status(302).send(null);
+    }
+  };
+
+  return { req, res, done };
+}
+
+module.exports = {
+  createContext
+};
@@ -0,0 +1,85 @@
+const { parseCSV, rowsToRecords } = require('./csv');
+const { readWorkbook } = require('./xlsx');
+
+const XLSX_CONTENT_TYPE = 'application/vnd.openxmlformats-officedocument.spreadsheetml.sheet';
+
+// Importable fields per target, with common spreadsheet header aliases used to suggest a mapping
+const IMPORT_TARGETS = {
+  loans: {
+    required: ['borrower_name', 'amount', 'loan_date'],
+    fields: {
+      borrower_name: ['borrower', 'name', 'customer', 'debtor'],
+      borrower_phone: ['phone', 'mobile', 'tel', 'telephone'],
+      borrower_address: ['address'],
+      amount: ['principal', 'loan_amount', 'amount_lent'],
+      interest_rate: ['rate', 'interest', 'interest_percent'],
+      interest_type: [],
+      term_months: ['term', 'months', 'installments'],
+      direction: ['type', 'lent_or_borrowed'],
+      currency: ['ccy', 'currency_code'],
+      loan_date: ['date', 'start_date', 'lent_on', 'issued'],
+      due_date: ['due', 'repay_by', 'maturity', 'maturity_date'],
+      status: [],
+      paid_amount: ['paid', 'repaid', 'amount_paid', 'total_paid'],
+      notes: ['note', 'remark', 'remarks', 'comment', 'comments']
+    }
+  },
+  transactions: {
+    required: ['loan_id', 'amount'],
+    fields: {
+      loan_id: ['loan'],
+      amount: ['paid', 'payment'],
+      transaction_type: ['type'],
+      transaction_date: ['date', 'payment_date', 'paid_on'],
+      description: ['note', 'notes', 'remark', 'memo']
+    }
+  }
+};
+
+/**
+ * Check whether an uploaded file is an XLSX workbook (by name, type or ZIP signature)
+ */
+function isWorkbook(file) {
+  return /\.xlsx$/i.test(file.fileName || '') ||
+    file.contentType === XLSX_CONTENT_TYPE ||
+    (file.buffer.length > 4 && file.buffer.readUInt32LE(0) === 0x04034B50);
+}
+
+/**
+ * Read an uploaded CSV or XLSX file into rows (arrays of strings), header row first
+ */
+function readImportRows(file) {
+  return isWorkbook(file) ? readWorkbook(file.buffer) : parseCSV(file.buffer.toString('utf8'));
+}
+
+/**
+ * Read an uploaded CSV or XLSX file into { headers, records } keyed by snake_case header
+ */
+function readImportFile(file) {
+  return rowsToRecords(readImportRows(file));
+}
+
+/**
+ * Suggest a { field: header } mapping by matching headers to field names and aliases
+ */
+function suggestMapping(headers, target) {
+  const mapping = {};
+  const used = new Set();
+
+  Object.entries(IMPORT_TARGETS[target].fields).forEach(([field, aliases]) => {
+    const header = [field, ...aliases].find(name => headers.includes(name) && !used.has(name));
+    if (header) {
+      mapping[field] = header;
+      used.add(header);
+    }
+  });
+
+  return mapping;
+}
+
+module.exports = {
+  IMPORT_TARGETS,
+  readImportRows,
+  readImportFile,
+  suggestMapping
+};
@@ -0,0 +1,131 @@
+const { DEFAULT_CURRENCY, roundCurrency } = require('./currency');
+
+const INTEREST_TYPES = ['reducing', 'flat'];
+
+// Payment periods per year for each supported frequency
+const PAYMENT_FREQUENCIES = {
+  daily: 365,
+  weekly: 52,
+  biweekly: 26,
+  monthly: 12
+};
+
+// PostgreSQL interval for one period of each frequency
+const FREQUENCY_INTERVALS = {
+  daily: '1 day',
+  weekly: '7 days',
+  biweekly: '14 days',
+  monthly: '1 month'
+};
+
+/**
+ * SQL CASE expression mapping a frequency column to its period interval
+ */
+function frequencyIntervalSQL(column) {
+  return `CASE ${column} ${Object.entries(FREQUENCY_INTERVALS)
+    .map(([frequency, interval]) => `WHEN '${frequency}' THEN INTERVAL '${interval}'`)
+    .join(' ')} END`;
+}
+
+/**
+ * Calculate flat-rate interest (on original principal for the full term)
+ */
+function calculateFlatInterest(principal, annualRate, termMonths, currency = DEFAULT_CURRENCY) {
+  return roundCurrency(principal * (annualRate / 100) * (termMonths / 12), currency);
+}
+
+/**
+ * Calculate installment per period for reducing-balance loan
+ */
+function calculateReducingInstallment(principal, periodRate, periods) {
+  if (periodRate === 0) {
+    return principal / periods;
+  }
+  return principal * periodRate / (1 - Math.pow(1 + periodRate, -periods));
+}
+
+/**
+ * Get number of payment periods for a term in months
+ */
+function getPeriodCount(termMonths, frequency = 'monthly') {
+  return Math.max(Math.round(termMonths * PAYMENT_FREQUENCIES[frequency] / 12), 1);
+}
+
+/**
+ * Build amortization schedule for a loan.
+ * Rows are rounded to the currency; the last row absorbs the rounding residual so principal sums to the
+ * loan amount (and, for flat loans, interest to the flat interest) and the final balance is 0.
+ */
+function buildAmortizationSchedule({ principal, annualRate, termMonths, interestType = 'reducing', frequency = 'monthly', currency = DEFAULT_CURRENCY }) {
+  const roundAmount = amount => roundCurrency(amount, currency);
+  const periods = getPeriodCount(termMonths, frequency);
+  const schedule = [];
+  let balance = principal;
+  let principalPaid = 0;
+  let interestPaid = 0;
+
+  const pushRow = (period, principalPart, interestPart) => {
+    const last = period === periods;
+    const principalAmount = last ? roundAmount(principal - principalPaid) : roundAmount(principalPart);
+    const interestAmount = roundAmount(interestPart);
+    principalPaid = roundAmount(principalPaid + principalAmount);
+    interestPaid = roundAmount(interestPaid + interestAmount);
+    schedule.push({
+      period,
+      payment: roundAmount(principalAmount + interestAmount),
+      principal: principalAmount,
+      interest: interestAmount,
+      balance: last ? 0 : roundAmount(Math.max(principal - principalPaid, 0))
+    });
+  };
+
+  if (interestType === 'flat') {
+    const totalInterest = calculateFlatInterest(principal, annualRate, termMonths, currency);
+    const principalPart = principal / periods;
+    const interestPart = totalInterest / periods;
+
+    for (let period = 1; period <= periods; period++) {
+      pushRow(period, principalPart, period === periods ? totalInterest - interestPaid : interestPart);
+    }
+    return schedule;
+  }
+
+  const periodRate = annualRate / 100 / PAYMENT_FREQUENCIES[frequency];
+  const installment = calculateReducingInstallment(principal, periodRate, periods);
+
+  for (let period = 1; period <= periods; period++) {
+    const interestPart = balance * periodRate;
+    const principalPart = installment - interestPart;
+    balance -= principalPart;
+    pushRow(period, principalPart, interestPart);
+  }
+  return schedule;
+}
+
+/**
+ * Calculate total interest for a loan based on its interest type
+ */
+function calculateTotalInterest({ principal, annualRate, termMonths, interestType = 'reducing', currency = DEFAULT_CURRENCY }) {
+  if (!termMonths || !annualRate) {
+    return 0;
+  }
+
+  if (interestType === 'flat') {
+    return calculateFlatInterest(principal, annualRate, termMonths, currency);
+  }
+
+  const schedule = buildAmortizationSchedule({ principal, annualRate, termMonths, interestType, currency });
+  return roundCurrency(schedule.reduce((sum, row) => sum + row.interest, 0), currency);
+}
+
+module.exports = {
+  INTEREST_TYPES,
+  PAYMENT_FREQUENCIES,
+  FREQUENCY_INTERVALS,
+  frequencyIntervalSQL,
+  calculateFlatInterest,
+  calculateReducingInstallment,
+  getPeriodCount,
+  buildAmortizationSchedule,
+  calculateTotalInterest
+};
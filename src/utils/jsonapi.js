@@ -0,0 +1,91 @@
+// JSON:API (https://jsonapi.org) responses, served when the client asks for them via the Accept header
+const JSONAPI_CONTENT_TYPE = 'application/vnd.api+json';
+
+/**
+ * Check whether a request negotiates JSON:API
+ */
+function wantsJSONAPI(req) {
+  return Boolean(req && req.get && (req.get('Accept') || '').includes(JSONAPI_CONTENT_TYPE));
+}
+
+/**
+ * Parse ?include=a,b into a list of relationship names
+ */
+function parseInclude(query) {
+  return String(query.include || '').split(',').map(name => name.trim()).filter(Boolean);
+}
+
+/**
+ * Build a resource object (id and relationship keys are moved out of attributes)
+ */
+function toResource(type, row, relationships = {}, omit = []) {
+  const { id, ...attributes } = row;
+  omit.forEach(key => delete attributes[key]);
+
+  const resource = { type, id: String(id), attributes, links: { self: `/api/v1/${type}/${id}` } };
+  if (Object.keys(relationships).length > 0) {
+    resource.relationships = relationships;
+  }
+  return resource;
+}
+
+/**
+ * Loan resource with borrower and transactions relationships (pass transactions to link them as data)
+ */
+function loanResource(loan, transactions = null) {
+  const relationships = {
+    borrower: { data: loan.borrower_id ? { type: 'borrowers', id: loan.borrower_id } : null },
+    transactions: { links: { related: `/api/v1/transactions?loanId=${loan.id}` } }
+  };
+
+  if (transactions) {
+    relationships.transactions.data = transactions.map(transaction => ({ type: 'transactions', id: transaction.id }));
+  }
+
+  return toResource('loans', loan, relationships, ['borrower_id', 'borrower']);
+}
+
+/**
+ * Transaction resource with its loan relationship
+ */
+function transactionResource(transaction) {
+  return toResource('transactions', transaction, {
+    loan: {
+      data: { type: 'loans', id: transaction.loan_id },
+      links: { related: `/api/v1/loans/${transaction.loan_id}` }
+    }
+  }, ['loan_id']);
+}
+
+/**
+ * Convert an ErrorResponse into a JSON:API errors document
+ */
+function errorDocument(error) {
+  const { code, message, details, request_id: requestId, status } = error.toJSON();
+  return {
+    errors: [{
+      id: requestId || undefined,
+      status: String(status),
+      code,
+      title: message,
+      meta: details.length > 0 ? { details } : undefined
+    }]
+  };
+}
+
+/**
+ * Send a JSON:API document
+ */
+function respondWithJSONAPI(res, code, document) {
+  return res.status(code).type(JSONAPI_CONTENT_TYPE).json({ jsonapi: { version: '1.0' }, ...document });
+}
+
+module.exports = {
+  JSONAPI_CONTENT_TYPE,
+  wantsJSONAPI,
+  parseInclude,
+  loanResource,
+  transactionResource,
+  errorDocument,
+  respondWithJSONAPI
+};
@@ -1,12 +1,51 @@
 const jwt = require('jsonwebtoken');
+const config = require('../config');
 
-const JWT_SECRET = process.env.JWT_SECRET || 'your-super-secret-jwt-key-change-in-production';
+const DEV_SECRET = 'your-super-secret-jwt-key-change-in-production';
 
 /**
- * Generate JWT token for user
+ * Signing secret followed by previous secrets still accepted when verifying (JWT_PREVIOUS_SECRETS),
+ * so JWT_SECRET can be rotated without signing everyone out.
+ * Config validation refuses to start production without a real JWT_SECRET, so the dev fallback is development-only.
  */
-function generateJWT(userId, username) {
+function loadSecrets() {
+  let current = config.jwt.secret;
+  if (!current || current === DEV_SECRET) {
+    console.warn('JWT_SECRET is not set; using the insecure development secret');
+    current = DEV_SECRET;
+  }
+
+  return [current, ...config.jwt.previousSecrets.filter(secret => secret !== current)];
+}
+
+const JWT_SECRETS = loadSecrets();
+const JWT_SECRET = JWT_SECRETS[0];
+
+/**
+ * Verify a token against the current secret, then each previous one.
+ * An expired token is rejected immediately: its signature matched, so older secrets are irrelevant.
+ */
+function verifyWithSecrets(token) {
+  let lastError;
+  for (const secret of JWT_SECRETS) {
+    try {
+      return jwt.verify(token, secret);
+    } catch (error) {
+      if (error.name !== 'JsonWebTokenError' || error.message !== 'invalid signature') {
+        throw error;
+      }
+      lastError = error;
+    }
+  }
+  throw lastError;
+}
+
+/**
+ * Generate JWT token for user (claims adds extra payload fields, e.g. a session's CSRF token)
+ */
+function generateJWT(userId, username, claims = {}) {
   const payload = {
+    ...claims,
     userId,
     username,
     iat: Math.floor(Date.now() / 1000),
@@ -21,7 +60,29 @@ function generateJWT(userId, username) {
  */
 function validateJWT(token) {
   try {
-    return jwt.verify(token, JWT_SECRET);
+    return verifyWithSecrets(token);
+  } catch (error) {
+    throw new Error('Invalid or expired token');
+  }
+}
+
+/**
+ * Sign a short-lived purpose-bound token (e.g. OAuth state)
+ */
+function signToken(purpose, subject, expiresInSeconds = 10 * 60) {
+  return jwt.sign({ purpose, sub: subject }, JWT_SECRET, { expiresIn: expiresInSeconds });
+}
+
+/**
+ * Verify a purpose-bound token and return its subject
+ */
+function verifyToken(token, purpose) {
+  try {
+    const decoded = verifyWithSecrets(token);
+    if (decoded.purpose !== purpose) {
+      throw new Error('Token purpose mismatch');
+    }
+    return decoded.sub;
   } catch (error) {
     throw new Error('Invalid or expired token');
   }
@@ -39,7 +100,10 @@ function extractTokenFromHeader(authHeader) {
 }
 
 module.exports = {
+  JWT_SECRET,
   generateJWT,
   validateJWT,
+  signToken,
+  verifyToken,
   extractTokenFromHeader
 };
\ No newline at end of file
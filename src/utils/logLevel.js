@@ -0,0 +1,24 @@
+const config = require('../config');
+const { LOG_LEVELS } = require('../config/settings');
+
+// console methods by the level they log at; console.log counts as info
+const METHOD_LEVELS = { debug: 'debug', log: 'info', info: 'info', warn: 'warn', error: 'error' };
+
+/**
+ * Drop console output below LOG_LEVEL. The level is checked per call, so a config reload applies immediately.
+ */
+function applyLogLevel() {
+  Object.entries(METHOD_LEVELS).forEach(([method, level]) => {
+    const write = console[method].bind(console);
+    const rank = LOG_LEVELS.indexOf(level);
+    console[method] = (...args) => {
+      if (rank >= LOG_LEVELS.indexOf(config.logging.level)) {
+        write(...args);
+      }
+    };
+  });
+}
+
+module.exports = {
+  applyLogLevel
+};
@@ -0,0 +1,113 @@
+const express = require('express');
+
+/**
+ * Parse header lines of a multipart part
+ */
+function parsePartHeaders(headerText) {
+  const headers = {};
+  headerText.split('\r\n').forEach(line => {
+    const index = line.indexOf(':');
+    if (index > 0) {
+      headers[line.slice(0, index).trim().toLowerCase()] = line.slice(index + 1).trim();
+    }
+  });
+  return headers;
+}
+
+/**
+ * Extract a parameter (e.g. name, filename) from a header value
+ */
+function getHeaderParam(value, param) {
+  const match = new RegExp(`${param}="([^"]*)"`, 'i').exec(value || '');
+  return match ? match[1] : null;
+}
+
+/**
+ * Parse a multipart/form-data buffer into fields and files
+ */
+function parseMultipart(buffer, contentType) {
+  const boundaryMatch = /boundary=(?:"([^"]+)"|([^;]+))/i.exec(contentType || '');
+  if (!boundaryMatch) {
+    throw new Error('Missing multipart boundary');
+  }
+
+  const boundary = Buffer.from(`--${boundaryMatch[1] || boundaryMatch[2]}`);
+  const fields = {};
+  const files = [];
+
+  let start = buffer.indexOf(boundary);
+  while (start !== -1) {
+    const partStart = start + boundary.length;
+    // Closing boundary ends with "--"
+    if (buffer.slice(partStart, partStart + 2).toString() === '--') {
+      break;
+    }
+
+    const next = buffer.indexOf(boundary, partStart);
+    if (next === -1) {
+      break;
+    }
+
+    // Skip CRLF after boundary, drop CRLF before next boundary
+    const part = buffer.slice(partStart + 2, next - 2);
+    const headerEnd = part.indexOf('\r\n\r\n');
+    if (headerEnd !== -1) {
+      const headers = parsePartHeaders(part.slice(0, headerEnd).toString());
+      const body = part.slice(headerEnd + 4);
+      const disposition = headers['content-disposition'];
+      const name = getHeaderParam(disposition, 'name');
+      const filename = getHeaderParam(disposition, 'filename');
+
+      if (filename !== null) {
+        files.push({
+          fieldName: name,
+          fileName: filename,
+          contentType: headers['content-type'] || 'application/octet-stream',
+          size: body.length,
+          buffer: body
+        });
+      } else if (name) {
+        fields[name] = body.toString();
+      }
+    }
+
+    start = next;
+  }
+
+  return { fields, files };
+}
+
+/**
+ * Middleware that parses multipart/form-data into req.body and req.files
+ */
+function multipartBody({ limit = '10mb' } = {}) {
+  const raw = express.raw({ type: 'multipart/form-data', limit });
+
+  return (req, res, next) => {
+    raw(req, res, error => {
+      if (error) {
+        return next(error);
+      }
+
+      if (!Buffer.isBuffer(req.body)) {
+        req.files = [];
+        return next();
+      }
+
+      try {
+        const { fields, files } = parseMultipart(req.body, req.headers['content-type']);
+        req.body = fields;
+        req.files = files;
+        next();
+      } catch (parseError) {
+        parseError.status = 400;
+        next(parseError);
+      }
+    });
+  };
+}
+
+module.exports = {
+  parseMultipart,
+  multipartBody
+};
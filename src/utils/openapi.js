@@ -0,0 +1,155 @@
+const { authMiddleware } = require('../middleware/auth');
+const { SESSION_COOKIE } = require('../middleware/session');
+const { SCHEMAS, OPERATIONS } = require('./openapiSchemas');
+const packageJson = require('../../package.json');
+
+/**
+ * Turn a handler method name into a summary (getLoanLedger -> "Get loan ledger")
+ */
+function toSummary(name) {
+  const words = name.replace(/([a-z0-9])([A-Z])/g, '$1 $2').toLowerCase();
+  return words.charAt(0).toUpperCase() + words.slice(1);
+}
+
+/**
+ * Wrap a response schema in the success envelope used by respondWithJSON
+ */
+function successEnvelope(schema, status) {
+  return {
+    type: 'object',
+    properties: {
+      data: schema,
+      success: { type: 'boolean' },
+      status: { type: 'integer', example: status }
+    }
+  };
+}
+
+/**
+ * Success response of a declared operation (JSON responses use the respondWithJSON envelope)
+ */
+function buildSuccessResponse(declaration, status) {
+  if (declaration.redirect) {
+    return { description: 'Redirect', headers: { Location: { schema: { type: 'string' } } } };
+  }
+  if (declaration.content) {
+    const schema = declaration.content.startsWith('text/') ? { type: 'string' } : { type: 'string', format: 'binary' };
+    return { description: 'Success', content: { [declaration.content]: { schema } } };
+  }
+
+  let schema = declaration.response || { type: 'object' };
+  if (declaration.list) {
+    schema = { type: 'array', items: schema };
+  }
+
+  const content = {
+    'application/json': { schema: declaration.raw ? schema : successEnvelope(schema, status) }
+  };
+  if (declaration.jsonapi) {
+    content['application/vnd.api+json'] = { schema: { type: 'object', description: 'JSON:API document (Accept: application/vnd.api+json)' } };
+  }
+  if (declaration.alternate) {
+    content[declaration.alternate] = { schema: { type: 'string', format: 'binary' } };
+  }
+  return { description: 'Success', content };
+}
+
+/**
+ * Build an operation object from an Express route and its declaration in openapiSchemas.js
+ */
+function buildOperation(path, method, route) {
+  const key = `${method.toUpperCase()} ${route.path}`;
+  const declaration = OPERATIONS[key];
+  if (!declaration) {
+    throw new Error(`No OpenAPI declaration for ${key}; add it to src/utils/openapiSchemas.js`);
+  }
+
+  const handlers = route.stack.map(layer => layer.handle);
+  const handler = route.stack[route.stack.length - 1];
+  const operationName = handler.name.replace(/^bound /, '') || `${method}${path}`;
+  const status = declaration.status || (declaration.redirect ? 302 : 200);
+  const tag = (path.split('/')[3] || 'general').replace(/[{}]/g, '');
+
+  const parameters = (path.match(/\{(\w+)\}/g) || []).map(param => ({
+    name: param.slice(1, -1),
+    in: 'path',
+    required: true,
+    schema: { type: 'string', format: 'uuid' }
+  }));
+
+  const responses = { [status]: buildSuccessResponse(declaration, status) };
+  Object.entries(declaration.failure || {}).forEach(([failureStatus, schema]) => {
+    responses[failureStatus] = {
+      description: 'Failure',
+      content: { 'application/json': { schema: successEnvelope(schema, Number(failureStatus)) } }
+    };
+  });
+  responses.default = {
+    description: 'Error',
+    content: { 'application/json': { schema: { $ref: '#/components/schemas/Error' } } }
+  };
+
+  const operation = {
+    operationId: operationName,
+    summary: toSummary(operationName),
+    tags: [tag],
+    parameters: [...parameters, ...(declaration.params || [])],
+    responses
+  };
+
+  if (declaration.request) {
+    operation.requestBody = {
+      required: true,
+      content: { [declaration.requestContent || 'application/json']: { schema: declaration.request } }
+    };
+  }
+
+  const security = declaration.security || (handlers.includes(authMiddleware) ? [{ bearerAuth: [] }, { cookieAuth: [] }] : []);
+  if (security.length > 0) {
+    operation.security = security;
+  }
+
+  return operation;
+}
+
+/**
+ * Build an OpenAPI 3 document from the routes registered on the app
+ */
+function buildOpenAPISpec(app) {
+  const paths = {};
+
+  app._router.stack
+    .filter(layer => layer.route && typeof layer.route.path === 'string')
+    .forEach(({ route }) => {
+      const path = route.path.replace(/:(\w+)/g, '{$1}');
+      Object.keys(route.methods).forEach(method => {
+        paths[path] = paths[path] || {};
+        paths[path][method] = buildOperation(path, method, route);
+      });
+    });
+
+  return {
+    openapi: '3.0.3',
+    info: {
+      title: 'Loan Money API',
+      version: packageJson.version,
+      description: packageJson.description
+    },
+    servers: [{ url: '/' }],
+    paths,
+    components: {
+      securitySchemes: {
+        bearerAuth: { type: 'http', scheme: 'bearer', bearerFormat: 'JWT' },
+        cookieAuth: { type: 'apiKey', in: 'cookie', name: SESSION_COOKIE, description: 'Session cookie; writes also need the X-CSRF-Token header' },
+        adminToken: { type: 'http', scheme: 'bearer', description: 'ADMIN_TOKEN' },
+        metricsToken: { type: 'http', scheme: 'bearer', description: 'METRICS_TOKEN, when set' },
+        telegramSecret: { type: 'apiKey', in: 'header', name: 'X-Telegram-Bot-Api-Secret-Token' }
+      },
+      schemas: SCHEMAS
+    }
+  };
+}
+
+module.exports = {
+  buildOpenAPISpec
+};
@@ -0,0 +1,1145 @@
+const { LOAN_DIRECTIONS, LOAN_STATUSES } = require('../models');
+const { INTEREST_TYPES, PAYMENT_FREQUENCIES } = require('./interest');
+const { DATE_RANGE_PRESETS } = require('./dateRange');
+const { CURRENCIES } = require('./currency');
+const { WEBHOOK_EVENTS } = require('../notifications/channels/webhook');
+
+const TRANSACTION_TYPES = ['payment', 'interest', 'fee', 'adjustment'];
+const TRANSACTION_STATUSES = ['pending', 'confirmed', 'rejected'];
+const NOTE_TYPES = ['note', 'call', 'visit', 'message', 'promise'];
+const EXPORT_FORMATS = ['xlsx', 'qif', 'ofx', 'backup'];
+
+const string = { type: 'string' };
+const uuid = { type: 'string', format: 'uuid' };
+const dateTime = { type: 'string', format: 'date-time' };
+// DATE columns; users on the buddhist calendar get DD/MM/YYYY with the Buddhist Era year
+const date = { type: 'string', description: 'YYYY-MM-DD, or DD/MM/YYYY (Buddhist Era) for users on the buddhist calendar' };
+// NUMERIC columns come back from PostgreSQL as strings to keep their precision
+const decimal = { type: 'string', format: 'decimal', example: '1500.00' };
+const integer = { type: 'integer' };
+const number = { type: 'number' };
+const boolean = { type: 'boolean' };
+const currency = { type: 'string', enum: Object.keys(CURRENCIES) };
+const month = { type: 'string', example: '2026-01' };
+
+const nullable = schema => ({ ...schema, nullable: true });
+const enumOf = values => ({ type: 'string', enum: values });
+const ref = name => ({ $ref: `#/components/schemas/${name}` });
+const arrayOf = items => ({ type: 'array', items });
+const object = (properties, required) => ({ type: 'object', properties, ...(required ? { required } : {}) });
+const mapOf = values => ({ type: 'object', additionalProperties: values });
+
+const message = object({ message: string }, ['message']);
+const pagination = object({ page: integer, limit: integer, total: integer });
+const cursorPagination = object({ limit: integer, hasMore: boolean, nextCursor: nullable(string) });
+const paged = (key, items) => object({ [key]: arrayOf(items), pagination }, [key, 'pagination']);
+
+// Amount totals repeated per currency next to the single-currency figures
+const currencyTotals = properties => arrayOf(object({ currency, ...properties }));
+// Totals converted into the user's preferred currency (null when a rate is unavailable)
+const consolidated = fields => nullable(object({
+  currency,
+  ...Object.fromEntries(fields.map(field => [field, number])),
+  rates: mapOf(number),
+  asOf: nullable(dateTime),
+  source: nullable(string)
+}));
+
+// Component schemas; camelCase schemas mirror src/models, snake_case ones are table rows returned as-is
+const SCHEMAS = {
+  User: object({
+    id: uuid,
+    username: string,
+    fullName: nullable(string),
+    email: nullable(string),
+    phone: nullable(string),
+    address: nullable(string),
+    emailNotifications: boolean,
+    smsBorrowerReminders: boolean,
+    preferredCurrency: nullable(currency),
+    calendar: nullable(enumOf(['gregorian', 'buddhist'])),
+    timezone: nullable(string),
+    locale: nullable(string),
+    createdAt: dateTime,
+    updatedAt: dateTime,
+    deletedAt: nullable(dateTime)
+  }, ['id', 'username']),
+  Borrower: object({
+    id: uuid,
+    userId: uuid,
+    name: string,
+    phone: nullable(string),
+    email: nullable(string),
+    lineId: nullable(string),
+    address: nullable(string),
+    createdAt: dateTime,
+    updatedAt: dateTime
+  }, ['id', 'name']),
+  Loan: object({
+    id: uuid,
+    userId: uuid,
+    borrowerId: nullable(uuid),
+    borrowerName: string,
+    borrowerPhone: nullable(string),
+    borrowerAddress: nullable(string),
+    amount: number,
+    interestRate: number,
+    interestType: enumOf(INTEREST_TYPES),
+    termMonths: nullable(integer),
+    direction: enumOf(LOAN_DIRECTIONS),
+    currency,
+    loanDate: date,
+    dueDate: nullable(date),
+    status: enumOf(LOAN_STATUSES),
+    notes: nullable(string),
+    createdAt: dateTime,
+    updatedAt: dateTime
+  }, ['id', 'amount', 'currency', 'status']),
+  Transaction: object({
+    id: uuid,
+    loanId: uuid,
+    userId: uuid,
+    amount: number,
+    currency,
+    transactionType: enumOf(TRANSACTION_TYPES),
+    transactionDate: date,
+    description: nullable(string),
+    status: enumOf(TRANSACTION_STATUSES),
+    confirmedAt: nullable(dateTime),
+    createdAt: dateTime,
+    updatedAt: dateTime
+  }, ['id', 'loanId', 'amount', 'transactionType']),
+  PaymentPlan: object({
+    id: uuid,
+    loanId: uuid,
+    userId: uuid,
+    amount: number,
+    frequency: enumOf(Object.keys(PAYMENT_FREQUENCIES)),
+    startDate: date,
+    nextDueDate: date,
+    endDate: nullable(date),
+    active: boolean,
+    createdAt: dateTime,
+    updatedAt: dateTime
+  }, ['id', 'loanId', 'amount', 'frequency']),
+  ReminderRule: object({
+    id: uuid,
+    userId: uuid,
+    loanId: nullable(uuid),
+    ruleType: enumOf(['before_due', 'overdue']),
+    daysBefore: nullable(arrayOf(integer)),
+    repeatEveryDays: nullable(integer),
+    active: boolean,
+    createdAt: dateTime,
+    updatedAt: dateTime
+  }, ['id', 'ruleType']),
+  WebhookEndpoint: object({
+    id: uuid,
+    userId: uuid,
+    url: { type: 'string', format: 'uri' },
+    events: arrayOf(enumOf(WEBHOOK_EVENTS)),
+    active: boolean,
+    secret: { type: 'string', description: 'Signing secret, only returned when the endpoint is created' },
+    createdAt: dateTime,
+    updatedAt: dateTime
+  }, ['id', 'url', 'events']),
+  UserDevice: object({
+    id: uuid,
+    userAgent: nullable(string),
+    lastIp: nullable(string),
+    lastCountry: nullable(string),
+    countries: arrayOf(string),
+    trusted: boolean,
+    firstSeenAt: dateTime,
+    lastSeenAt: dateTime
+  }, ['id']),
+  SavedReport: object({
+    id: uuid,
+    userId: uuid,
+    name: string,
+    definition: ref('ReportDefinition'),
+    schedule: nullable(enumOf(['daily', 'weekly', 'monthly'])),
+    nextRunAt: nullable(dateTime),
+    lastRunAt: nullable(dateTime),
+    createdAt: dateTime,
+    updatedAt: dateTime
+  }, ['id', 'name', 'definition']),
+  ReportDefinition: object({
+    source: enumOf(['loans', 'transactions']),
+    groupBy: nullable(string),
+    filters: object({
+      status: string,
+      borrowerId: uuid,
+      transactionType: enumOf(TRANSACTION_TYPES),
+      minAmount: number,
+      maxAmount: number
+    }),
+    range: object({ preset: enumOf(DATE_RANGE_PRESETS), from: date, to: date })
+  }, ['source']),
+  ExportJob: object({
+    id: uuid,
+    userId: uuid,
+    format: enumOf(EXPORT_FORMATS),
+    params: object({ range: enumOf(DATE_RANGE_PRESETS), from: date, to: date, currency }),
+    encrypted: boolean,
+    status: enumOf(['pending', 'processing', 'completed', 'failed', 'expired']),
+    fileName: nullable(string),
+    size: nullable(integer),
+    error: nullable(string),
+    createdAt: dateTime,
+    startedAt: nullable(dateTime),
+    completedAt: nullable(dateTime),
+    expiresAt: nullable(dateTime),
+    downloadUrl: nullable(string)
+  }, ['id', 'format', 'status']),
+  AuthRequest: object({
+    username: string,
+    password: { type: 'string', format: 'password' },
+    fullName: nullable(string)
+  }, ['username', 'password']),
+  LoginRequest: object({
+    username: string,
+    password: { type: 'string', format: 'password' }
+  }, ['username', 'password']),
+  LoanCreateRequest: object({
+    borrowerId: uuid,
+    borrowerName: string,
+    borrowerPhone: string,
+    borrowerAddress: string,
+    amount: number,
+    interestRate: number,
+    interestType: enumOf(INTEREST_TYPES),
+    termMonths: integer,
+    direction: enumOf(LOAN_DIRECTIONS),
+    currency,
+    loanDate: date,
+    dueDate: date,
+    notes: string
+  }, ['amount', 'interestRate', 'loanDate']),
+  TransactionCreateRequest: object({
+    loanId: uuid,
+    amount: number,
+    currency,
+    transactionType: enumOf(TRANSACTION_TYPES),
+    transactionDate: date,
+    description: string,
+    status: enumOf(TRANSACTION_STATUSES)
+  }, ['loanId', 'amount', 'transactionType', 'transactionDate']),
+  AuthResponse: object({
+    user: ref('User'),
+    token: { type: 'string', nullable: true, description: 'JWT for bearer clients; null for cookie sessions' },
+    csrfToken: { type: 'string', description: 'Only for cookie sessions' }
+  }, ['user']),
+  DashboardStats: object({
+    totalLoans: integer,
+    activeLoans: integer,
+    totalAmount: number,
+    totalInterest: number,
+    overdueLoans: integer,
+    missedPayments: integer,
+    pendingTransactions: integer,
+    pendingAmount: number,
+    currency: { ...currency, nullable: true, description: 'null when loans span several currencies' },
+    byCurrency: currencyTotals({ totalLoans: integer, totalAmount: number, pendingAmount: number }),
+    consolidated: consolidated(['totalAmount', 'pendingAmount']),
+    recentTransactions: arrayOf(ref('TransactionRow')),
+    range: nullable(ref('DateRange'))
+  }),
+  ProjectionMonth: object({
+    month,
+    expected: number,
+    fromPlans: { type: 'number', description: 'Expected from payment plans' },
+    fromDueDates: { type: 'number', description: 'Remaining debt of loans without a plan, due this month' },
+    loansCount: integer
+  }),
+  DateRange: object({ from: nullable(date), to: nullable(date), preset: nullable(enumOf(DATE_RANGE_PRESETS)) }),
+  ErrorResponse: object({
+    code: { type: 'string', example: 'VALIDATION_ERROR' },
+    message: string,
+    details: arrayOf({ type: 'object' }),
+    request_id: nullable(string),
+    status: integer
+  }, ['code', 'message', 'status']),
+  Error: object({ error: ref('ErrorResponse') }, ['error']),
+
+  LoanRow: object({
+    id: uuid,
+    user_id: uuid,
+    borrower_id: nullable(uuid),
+    borrower_name: string,
+    borrower_phone: nullable(string),
+    borrower_address: nullable(string),
+    amount: decimal,
+    interest_rate: decimal,
+    interest_type: enumOf(INTEREST_TYPES),
+    term_months: nullable(integer),
+    direction: enumOf(LOAN_DIRECTIONS),
+    currency,
+    loan_date: date,
+    due_date: nullable(date),
+    status: enumOf(LOAN_STATUSES),
+    notes: nullable(string),
+    total_paid: decimal,
+    total_charges: decimal,
+    remaining_debt: nullable(decimal),
+    created_at: dateTime,
+    updated_at: dateTime,
+    deleted_at: nullable(dateTime)
+  }, ['id', 'amount', 'currency', 'status']),
+  TransactionRow: object({
+    id: uuid,
+    loan_id: uuid,
+    user_id: uuid,
+    amount: decimal,
+    currency,
+    transaction_type: enumOf(TRANSACTION_TYPES),
+    transaction_date: date,
+    payment_date: nullable(dateTime),
+    description: nullable(string),
+    status: enumOf(TRANSACTION_STATUSES),
+    confirmed_at: nullable(dateTime),
+    borrower_name: string,
+    loan_amount: decimal,
+    created_at: dateTime,
+    updated_at: nullable(dateTime),
+    deleted_at: nullable(dateTime)
+  }, ['id', 'loan_id', 'amount', 'transaction_type']),
+  LedgerEntry: object({
+    transaction_id: nullable(uuid),
+    entry_type: enumOf(['disbursement', ...TRANSACTION_TYPES]),
+    amount: decimal,
+    balance_effect: decimal,
+    entry_date: date,
+    description: nullable(string),
+    created_at: dateTime
+  }, ['entry_type', 'amount', 'balance_effect', 'entry_date']),
+  Attachment: object({
+    id: uuid,
+    transaction_id: uuid,
+    file_name: string,
+    content_type: enumOf(['image/jpeg', 'image/png', 'image/webp', 'image/heic', 'application/pdf']),
+    size: integer,
+    created_at: dateTime
+  }, ['id', 'file_name', 'content_type', 'size']),
+  BorrowerNote: object({
+    id: uuid,
+    borrower_id: uuid,
+    user_id: uuid,
+    note_type: enumOf(NOTE_TYPES),
+    content: string,
+    promised_amount: nullable(decimal),
+    promised_date: nullable(date),
+    occurred_at: dateTime,
+    created_at: dateTime
+  }, ['id', 'note_type', 'content']),
+  ExpectedPayment: object({
+    id: uuid,
+    plan_id: uuid,
+    loan_id: uuid,
+    due_date: date,
+    amount: decimal,
+    status: enumOf(['pending', 'paid', 'missed']),
+    created_at: dateTime
+  }, ['id', 'due_date', 'amount', 'status']),
+  Notification: object({
+    id: uuid,
+    event: string,
+    subject: nullable(string),
+    body: nullable(string),
+    payload: nullable({ type: 'object' }),
+    read_at: nullable(dateTime),
+    created_at: dateTime
+  }, ['id', 'event']),
+  WebhookDelivery: object({
+    id: uuid,
+    notification_id: nullable(uuid),
+    event: enumOf(WEBHOOK_EVENTS),
+    attempt: integer,
+    status_code: nullable(integer),
+    response_body: nullable(string),
+    error: nullable(string),
+    duration_ms: nullable(integer),
+    delivery_status: nullable(enumOf(['pending', 'sent', 'failed'])),
+    created_at: dateTime
+  }, ['id', 'event', 'attempt']),
+  RiskScore: object({
+    score: { type: 'integer', nullable: true, description: '0-100, null without repayment history' },
+    rating: enumOf(['good', 'fair', 'risky', 'unknown']),
+    onTimeRatio: nullable(number),
+    averageDaysLate: nullable(number),
+    defaultedLoans: integer
+  }, ['score', 'rating']),
+  BatchResult: object({
+    committed: boolean,
+    succeeded: integer,
+    failed: integer,
+    results: arrayOf(object({ id: uuid, success: boolean, error: string }, ['id', 'success']))
+  }, ['committed', 'succeeded', 'failed', 'results']),
+  AdminUser: object({
+    id: uuid,
+    username: string,
+    full_name: nullable(string),
+    email: nullable(string),
+    created_at: dateTime,
+    updated_at: dateTime,
+    deleted_at: nullable(dateTime)
+  }, ['id', 'username'])
+};
+
+const query = (name, schema, description) => ({ name, in: 'query', schema, ...(description ? { description } : {}) });
+const pageParams = [query('page', integer), query('limit', integer)];
+const rangeParams = [query('range', enumOf(DATE_RANGE_PRESETS)), query('from', string), query('to', string)];
+const dryRun = query('dry_run', boolean, 'Validate only; nothing is saved');
+const filterParam = (name, schema) => query(name, schema, `Also accepts ${name}[op] with op one of eq, ne, gt, gte, lt, lte`);
+const sortParam = columns => query('sort', string, `Comma-separated, "-" prefix for descending: ${columns.join(', ')}`);
+
+const adminSecurity = [{ adminToken: [] }];
+const passphraseHeader = { name: 'X-Export-Passphrase', in: 'header', schema: string, description: 'Encrypts the file with this passphrase' };
+
+const loansResponse = object({ loans: arrayOf(ref('LoanRow')), pagination }, ['loans', 'pagination']);
+const transactionsResponse = object({ transactions: arrayOf(ref('TransactionRow')), pagination }, ['transactions', 'pagination']);
+const lineIntegration = object({ connected: boolean, target: string, connectedAt: dateTime, updatedAt: dateTime }, ['connected']);
+const telegramIntegration = object({ connected: boolean, username: nullable(string), connectedAt: dateTime, updatedAt: dateTime }, ['connected']);
+const notificationPreferences = object({
+  events: arrayOf(string),
+  channels: arrayOf(string),
+  preferences: mapOf(mapOf(boolean))
+}, ['events', 'channels', 'preferences']);
+const loanListParams = [
+  ...pageParams,
+  query('fields', string, 'Comma-separated columns to return'),
+  query('include_deleted', boolean),
+  sortParam(['created_at', 'loan_date', 'due_date', 'amount', 'borrower_name', 'status', 'remaining_debt']),
+  filterParam('status', enumOf(LOAN_STATUSES)),
+  filterParam('direction', enumOf(LOAN_DIRECTIONS)),
+  filterParam('currency', currency),
+  filterParam('borrowerId', uuid),
+  query('search', string, 'Matches the borrower name'),
+  filterParam('amount', number),
+  filterParam('loanDate', string),
+  filterParam('dueDate', string)
+];
+const transactionListParams = [
+  ...pageParams,
+  query('fields', string, 'Comma-separated columns to return'),
+  query('include_deleted', boolean),
+  sortParam(['created_at', 'transaction_date', 'amount', 'borrower_name']),
+  filterParam('loanId', uuid),
+  filterParam('transactionType', enumOf(TRANSACTION_TYPES)),
+  filterParam('status', enumOf(TRANSACTION_STATUSES)),
+  query('from', string),
+  query('to', string),
+  query('min_amount', number),
+  query('max_amount', number),
+  filterParam('amount', number),
+  query('search', string, 'Matches the description and borrower name')
+];
+const fileExport = (contentType) => ({
+  content: contentType,
+  params: [...rangeParams, query('currency', currency), passphraseHeader]
+});
+const loanImportResult = object({
+  dryRun: boolean,
+  totalRows: integer,
+  validRows: integer,
+  invalidRows: integer,
+  imported: integer,
+  rows: arrayOf(object({
+    row: integer,
+    status: enumOf(['valid', 'invalid']),
+    errors: arrayOf(string),
+    loan: { type: 'object', description: 'Parsed values of a valid row' },
+    loanId: { ...uuid, description: 'Set once the row is imported' }
+  }, ['row', 'status', 'errors']))
+}, ['dryRun', 'totalRows', 'rows']);
+const transactionImportResult = object({
+  dryRun: boolean,
+  totalRows: integer,
+  validRows: integer,
+  invalidRows: integer,
+  imported: integer,
+  errors: arrayOf(object({ row: integer, errors: arrayOf(string) }))
+}, ['dryRun', 'totalRows', 'errors']);
+const rate = { type: 'number', nullable: true, description: 'Fraction between 0 and 1, null without matured loans' };
+const expectedVsActualTotals = { expected: number, collected: number, shortfall: number };
+const incomeTotals = { collected: number, principalRecovered: number, incomeCollected: number };
+const accrued = object({ interest: number, fees: number, total: number });
+const netPositionTotals = { owedToMe: number, iOwe: number, netPosition: number };
+
+// Request and response declarations per route, keyed by "METHOD /express/path".
+// request/response are schemas; list wraps the response in an array, status is the success code,
+// content the media type of a non-JSON response, params the query and header parameters
+const OPERATIONS = {
+  'GET /health': { response: object({ status: string, timestamp: dateTime, service: string }), security: [] },
+  'GET /metrics': { content: 'text/plain', security: [{ metricsToken: [] }] },
+
+  'GET /api/v1/admin/users': {
+    params: [...pageParams, query('include_deleted', boolean)],
+    response: paged('users', ref('AdminUser')),
+    security: adminSecurity
+  },
+  'GET /api/v1/admin/metrics': {
+    response: object({
+      users: integer,
+      active_loans: integer,
+      outstanding: object({ total: number, lent: number, borrowed: number }),
+      payments_last_24h: object({ count: integer, amount: number }),
+      generated_at: dateTime
+    }),
+    security: adminSecurity
+  },
+  'POST /api/v1/admin/users/:id/restore': { response: ref('AdminUser'), security: adminSecurity },
+  'POST /api/v1/admin/config/reload': {
+    response: object({ changed: arrayOf(string), restart_required: arrayOf(string) }),
+    security: adminSecurity
+  },
+  'GET /api/v1/admin/debug/cpu-profile': {
+    params: [query('seconds', integer)],
+    response: { type: 'object', description: 'V8 CPU profile (.cpuprofile)' },
+    security: adminSecurity
+  },
+  'GET /api/v1/admin/debug/heap-snapshot': {
+    response: { type: 'object', description: 'V8 heap snapshot, streamed (.heapsnapshot)' },
+    raw: true,
+    security: adminSecurity
+  },
+
+  'GET /api/v1/openapi.json': { response: { type: 'object', description: 'This document' }, raw: true, security: [] },
+  'GET /api/v1/docs': { redirect: true, security: [] },
+
+  'POST /api/v1/register': { request: ref('AuthRequest'), response: ref('AuthResponse'), status: 201, security: [] },
+  'POST /api/v1/login': { request: ref('LoginRequest'), response: ref('AuthResponse'), security: [] },
+  'POST /api/v1/logout': { response: object({ loggedOut: boolean }), security: [] },
+  'GET /api/v1/session': { response: ref('AuthResponse') },
+
+  'POST /api/v1/calculator': {
+    request: object({
+      principal: number,
+      interestRate: number,
+      termMonths: integer,
+      frequency: enumOf(Object.keys(PAYMENT_FREQUENCIES)),
+      interestType: enumOf(INTEREST_TYPES)
+    }, ['principal', 'termMonths']),
+    response: object({
+      principal: number,
+      interestRate: number,
+      interestType: enumOf(INTEREST_TYPES),
+      termMonths: integer,
+      frequency: enumOf(Object.keys(PAYMENT_FREQUENCIES)),
+      installment: number,
+      totalPayment: number,
+      totalInterest: number,
+      schedule: arrayOf(object({ period: integer, payment: number, principal: number, interest: number, balance: number }))
+    }),
+    security: []
+  },
+
+  'GET /api/v1/profile': { response: ref('User') },
+  'PATCH /api/v1/profile': {
+    request: object({
+      fullName: string,
+      phone: string,
+      address: string,
+      email: string,
+      emailNotifications: boolean,
+      smsBorrowerReminders: boolean,
+      preferredCurrency: nullable(currency),
+      calendar: nullable(enumOf(['gregorian', 'buddhist'])),
+      timezone: nullable(string),
+      locale: nullable(string)
+    }),
+    response: ref('User')
+  },
+  'DELETE /api/v1/profile': { request: object({ password: string }, ['password']), response: message },
+  'PATCH /api/v1/change-password': {
+    request: object({ currentPassword: string, newPassword: string }, ['currentPassword', 'newPassword']),
+    response: message
+  },
+  'GET /api/v1/profile/notifications': { response: notificationPreferences },
+  'PATCH /api/v1/profile/notifications': {
+    request: object({ preferences: mapOf(mapOf(boolean)) }, ['preferences']),
+    response: notificationPreferences
+  },
+
+  'GET /api/v1/profile/devices': { response: { allOf: [ref('UserDevice'), object({ current: boolean })] }, list: true },
+  'PATCH /api/v1/profile/devices/:id': { request: object({ trusted: boolean }, ['trusted']), response: ref('UserDevice') },
+  'DELETE /api/v1/profile/devices/:id': { response: message },
+
+  'GET /api/v1/profile/integrations/line': { response: lineIntegration },
+  'POST /api/v1/profile/integrations/line': {
+    request: object({ token: string }, ['token']),
+    response: object({ connected: boolean, target: string })
+  },
+  'DELETE /api/v1/profile/integrations/line': { response: message },
+  'POST /api/v1/profile/integrations/line/authorize': { response: object({ authorizeUrl: { type: 'string', format: 'uri' } }) },
+  'GET /api/v1/profile/integrations/line/callback': {
+    params: [query('code', string), query('state', string)],
+    redirect: true,
+    security: []
+  },
+  'GET /api/v1/profile/integrations/telegram': { response: telegramIntegration },
+  'POST /api/v1/profile/integrations/telegram/link': {
+    response: object({ deepLink: { type: 'string', format: 'uri' }, expiresAt: dateTime })
+  },
+  'DELETE /api/v1/profile/integrations/telegram': { response: message },
+  'POST /api/v1/integrations/telegram/webhook': {
+    request: { type: 'object', description: 'Telegram Update' },
+    response: object({ ok: boolean }),
+    security: [{ telegramSecret: [] }]
+  },
+
+  'GET /api/v1/dashboard': {
+    params: rangeParams,
+    response: object({
+      stats: ref('DashboardStats'),
+      monthly: arrayOf(object({ period: string, loans_count: integer, total_amount: decimal }))
+    })
+  },
+  'GET /api/v1/dashboard/stats': { params: rangeParams, response: ref('DashboardStats') },
+  'GET /api/v1/dashboard/recent-transactions': {
+    params: [query('limit', integer), ...rangeParams],
+    response: ref('TransactionRow'),
+    list: true
+  },
+  'GET /api/v1/dashboard/loan-summary': {
+    params: rangeParams,
+    response: object({ status: enumOf(LOAN_STATUSES), currency, count: integer, total_amount: decimal }),
+    list: true
+  },
+  'GET /api/v1/dashboard/monthly-stats': {
+    params: [query('granularity', enumOf(['day', 'week', 'month'])), ...rangeParams],
+    response: object({ period: string, month: string, loans_count: integer, total_amount: decimal }),
+    list: true
+  },
+  'GET /api/v1/dashboard/overdue-loans': { params: rangeParams, response: ref('LoanRow'), list: true },
+  'GET /api/v1/dashboard/missed-payments': {
+    params: rangeParams,
+    response: { allOf: [ref('ExpectedPayment'), object({ borrower_name: string, frequency: string })] },
+    list: true
+  },
+  'GET /api/v1/dashboard/projection': {
+    params: [query('months', integer)],
+    response: object({
+      currency: nullable(currency),
+      months: arrayOf(ref('ProjectionMonth')),
+      totalExpected: nullable(number),
+      overdue: nullable(number),
+      unscheduled: nullable(number),
+      byCurrency: currencyTotals({ months: arrayOf(ref('ProjectionMonth')), totalExpected: number, overdue: number, unscheduled: number })
+    })
+  },
+  'GET /api/v1/dashboard/top-borrowers': {
+    params: [query('limit', integer)],
+    response: object({
+      id: uuid,
+      name: string,
+      phone: nullable(string),
+      currency,
+      loansCount: integer,
+      activeLoansCount: integer,
+      outstanding: number,
+      overdue: number,
+      lifetimeLent: number
+    }),
+    list: true
+  },
+  'GET /api/v1/dashboard/collections': {
+    params: rangeParams,
+    response: object({
+      months: arrayOf(object({
+        month: dateTime,
+        maturedLoans: integer,
+        repaidLoans: integer,
+        onTimeRate: rate,
+        avgDaysToRepay: nullable(number),
+        defaultRate: rate
+      })),
+      overall: object({ maturedLoans: integer, onTimeRate: rate, defaultRate: rate }),
+      graceDays: integer,
+      defaultAfterDays: integer
+    })
+  },
+  'GET /api/v1/dashboard/income': {
+    params: rangeParams,
+    response: object({
+      range: ref('DateRange'),
+      currency: nullable(currency),
+      collected: nullable(number),
+      principalRecovered: nullable(number),
+      incomeCollected: nullable(number),
+      accrued: nullable(accrued),
+      months: arrayOf(object({ month: dateTime, ...incomeTotals })),
+      byCurrency: currencyTotals({ ...incomeTotals, accrued, months: arrayOf(object({ month: dateTime, ...incomeTotals })) })
+    })
+  },
+  'GET /api/v1/dashboard/velocity': {
+    response: object({
+      portfolio: object({
+        activeLoans: integer,
+        stallingLoans: integer,
+        behindScheduleLoans: integer,
+        avgDaysBetweenPayments: nullable(number),
+        projectedCompletionDate: nullable(string)
+      }),
+      loans: arrayOf(object({
+        loanId: uuid,
+        borrowerName: string,
+        dueDate: nullable(dateTime),
+        remainingDebt: number,
+        paymentsCount: integer,
+        lastPaymentDate: nullable(dateTime),
+        daysSinceLastPayment: integer,
+        avgDaysBetweenPayments: nullable(number),
+        avgPayment: nullable(number),
+        projectedCompletionDate: nullable(string),
+        behindSchedule: boolean,
+        stalling: boolean
+      }))
+    })
+  },
+  'GET /api/v1/dashboard/expected-vs-actual': {
+    response: object({
+      asOf: string,
+      currency: nullable(currency),
+      expected: nullable(number),
+      collected: nullable(number),
+      shortfall: nullable(number),
+      collectionRate: rate,
+      byCurrency: currencyTotals({ ...expectedVsActualTotals, collectionRate: rate }),
+      borrowers: arrayOf(object({
+        borrowerId: nullable(uuid),
+        borrowerName: string,
+        currency,
+        ...expectedVsActualTotals,
+        loans: arrayOf(object({ loanId: uuid, status: enumOf(LOAN_STATUSES), ...expectedVsActualTotals }))
+      }))
+    })
+  },
+  'GET /api/v1/dashboard/net-position': {
+    params: [query('months', integer)],
+    response: object({
+      currency: nullable(currency),
+      owedToMe: nullable(number),
+      iOwe: nullable(number),
+      netPosition: nullable(number),
+      byCurrency: currencyTotals(netPositionTotals),
+      consolidated: consolidated(Object.keys(netPositionTotals)),
+      trend: arrayOf(object({ month, ...netPositionTotals }))
+    })
+  },
+
+  'GET /api/v1/events': { content: 'text/event-stream' },
+
+  'GET /api/v1/reports': { response: ref('SavedReport'), list: true },
+  'POST /api/v1/reports': {
+    request: object({ name: string, definition: ref('ReportDefinition'), schedule: nullable(enumOf(['daily', 'weekly', 'monthly'])) }, ['name', 'definition']),
+    response: ref('SavedReport'),
+    status: 201
+  },
+  'GET /api/v1/reports/:id': { response: ref('SavedReport') },
+  'PATCH /api/v1/reports/:id': {
+    request: object({ name: string, definition: ref('ReportDefinition'), schedule: nullable(enumOf(['daily', 'weekly', 'monthly'])) }),
+    response: ref('SavedReport')
+  },
+  'DELETE /api/v1/reports/:id': { response: message },
+  'GET /api/v1/reports/:id/run': {
+    response: object({
+      report: ref('SavedReport'),
+      generatedAt: dateTime,
+      range: ref('DateRange'),
+      totals: object({ count: integer, totalAmount: number }),
+      rows: arrayOf({ type: 'object' })
+    })
+  },
+
+  'GET /api/v1/export/xlsx': fileExport('application/vnd.openxmlformats-officedocument.spreadsheetml.sheet'),
+  'GET /api/v1/export/qif': fileExport('application/qif'),
+  'GET /api/v1/export/ofx': fileExport('application/x-ofx'),
+  'GET /api/v1/exports': { response: ref('ExportJob'), list: true },
+  'POST /api/v1/exports': {
+    request: object({ format: enumOf(EXPORT_FORMATS), range: enumOf(DATE_RANGE_PRESETS), from: date, to: date, currency }, ['format']),
+    params: [passphraseHeader],
+    response: ref('ExportJob'),
+    status: 202
+  },
+  'GET /api/v1/exports/:id': { response: ref('ExportJob') },
+  'GET /api/v1/exports/:id/download': {
+    params: [query('expires', integer), query('signature', string)],
+    content: 'application/octet-stream',
+    security: []
+  },
+
+  'POST /api/v1/imports/preview': {
+    request: object({ file: { type: 'string', format: 'binary' }, target: { ...enumOf(['loans', 'transactions']), default: 'loans' } }, ['file']),
+    requestContent: 'multipart/form-data',
+    response: object({
+      uploadId: uuid,
+      target: enumOf(['loans', 'transactions']),
+      fileName: string,
+      rowCount: integer,
+      columns: arrayOf(object({ header: string, key: string, samples: arrayOf(string) })),
+      sampleRows: arrayOf(arrayOf(string)),
+      fields: arrayOf(string),
+      requiredFields: arrayOf(string),
+      suggestedMapping: mapOf(string),
+      expiresAt: dateTime
+    }),
+    status: 201
+  },
+  'POST /api/v1/imports/:id/run': {
+    params: [dryRun],
+    request: object({ mapping: mapOf(string) }, ['mapping']),
+    response: { allOf: [object({ target: enumOf(['loans', 'transactions']) }), { oneOf: [loanImportResult, transactionImportResult] }] },
+    status: 201
+  },
+  'DELETE /api/v1/imports/:id': { response: message },
+
+  'GET /api/v1/backup': {
+    params: [passphraseHeader],
+    response: object({
+      format: enumOf(['loan-money-backup']),
+      version: integer,
+      exportedAt: dateTime,
+      profile: object({
+        full_name: nullable(string),
+        email: nullable(string),
+        phone: nullable(string),
+        address: nullable(string),
+        email_notifications: boolean,
+        sms_borrower_reminders: boolean,
+        preferred_currency: nullable(currency),
+        calendar: nullable(string),
+        timezone: nullable(string),
+        locale: nullable(string)
+      }),
+      data: { ...mapOf(arrayOf({ type: 'object' })), description: 'Table rows by table name' }
+    })
+  },
+  'POST /api/v1/restore': {
+    params: [query('strategy', enumOf(['skip', 'overwrite', 'fail'])), passphraseHeader],
+    request: { type: 'object', description: 'Backup archive from GET /api/v1/backup, or the encrypted file as application/octet-stream' },
+    requestContent: 'application/json',
+    response: object({
+      strategy: enumOf(['skip', 'overwrite', 'fail']),
+      // Per table, plus "profile" for the account settings
+      tables: mapOf(object({ inserted: integer, updated: integer, skipped: integer, rejected: integer }))
+    })
+  },
+
+  'GET /api/v1/loans': {
+    params: [...loanListParams, query('cursor', string, 'Switches to cursor pagination (see /api/v2/loans)')],
+    response: loansResponse,
+    jsonapi: true
+  },
+  'POST /api/v1/loans': {
+    request: ref('LoanCreateRequest'),
+    response: {
+      allOf: [ref('Loan'), object({
+        borrower: ref('Borrower'),
+        borrowerRisk: ref('RiskScore'),
+        warnings: arrayOf(string),
+        totalInterest: number
+      })]
+    },
+    status: 201
+  },
+  'POST /api/v1/loans/import': {
+    params: [dryRun, query('mapping', string, 'JSON object of column header -> field')],
+    request: object({ file: { type: 'string', format: 'binary' }, mapping: { type: 'string', description: 'JSON column mapping' } }, ['file']),
+    requestContent: 'multipart/form-data',
+    response: loanImportResult,
+    status: 201
+  },
+  'GET /api/v1/loans/:id': {
+    response: { allOf: [ref('LoanRow'), object({ total_interest: number, borrower: nullable(ref('Borrower')) })] },
+    jsonapi: true
+  },
+  'PATCH /api/v1/loans/:id': {
+    request: object({
+      borrowerName: string,
+      borrowerPhone: string,
+      borrowerAddress: string,
+      amount: number,
+      interestRate: number,
+      interestType: enumOf(INTEREST_TYPES),
+      termMonths: integer,
+      dueDate: date,
+      notes: string
+    }),
+    response: ref('LoanRow')
+  },
+  'DELETE /api/v1/loans/:id': { response: message },
+  'POST /api/v1/loans/:id/restore': { response: ref('LoanRow') },
+  'GET /api/v1/loans/:id/ledger': {
+    response: object({
+      entries: arrayOf({ allOf: [ref('LedgerEntry'), object({ running_balance: decimal })] }),
+      balance: { ...decimal, description: 'Running balance after the last entry (0 without entries)' }
+    })
+  },
+  'GET /api/v1/loans/:id/escalations': {
+    response: object({ step: string, due_date: date, days_overdue: integer, fired_at: dateTime }),
+    list: true
+  },
+  'PATCH /api/v1/loans/:id/status': {
+    request: object({ status: enumOf(LOAN_STATUSES) }, ['status']),
+    response: ref('LoanRow')
+  },
+
+  'GET /api/v1/borrowers': {
+    params: [...pageParams, sortParam(['name', 'created_at', 'updated_at']), query('search', string, 'Matches name, phone and email')],
+    response: paged('borrowers', ref('Borrower'))
+  },
+  'POST /api/v1/borrowers': {
+    request: object({ name: string, phone: string, email: string, lineId: string, address: string }, ['name']),
+    response: ref('Borrower'),
+    status: 201
+  },
+  'POST /api/v1/borrowers/import': {
+    params: [dryRun],
+    request: { type: 'string', description: 'CSV with a header row' },
+    requestContent: 'text/csv',
+    response: object({
+      dryRun: boolean,
+      totalRows: integer,
+      newRows: integer,
+      duplicateRows: integer,
+      invalidRows: integer,
+      imported: integer,
+      rows: arrayOf(object({
+        row: integer,
+        name: nullable(string),
+        phone: nullable(string),
+        email: nullable(string),
+        lineId: nullable(string),
+        address: nullable(string),
+        status: enumOf(['new', 'duplicate', 'invalid']),
+        duplicateOf: nullable(uuid),
+        errors: arrayOf(string)
+      }, ['row', 'status', 'errors']))
+    }),
+    status: 201
+  },
+  'GET /api/v1/borrowers/:id': { response: ref('Borrower') },
+  'PATCH /api/v1/borrowers/:id': {
+    request: object({ name: string, phone: nullable(string), email: nullable(string), lineId: nullable(string), address: nullable(string) }),
+    response: ref('Borrower')
+  },
+  'GET /api/v1/borrowers/:id/summary': {
+    response: object({
+      borrower: ref('Borrower'),
+      loans: arrayOf(ref('LoanRow')),
+      totalLoans: integer,
+      activeLoans: integer,
+      currency: nullable(currency),
+      totalLent: number,
+      totalRepaid: number,
+      totalOutstanding: number,
+      overdueLoans: integer,
+      overdueAmount: number,
+      byCurrency: currencyTotals({ totalLent: number, totalRepaid: number, totalOutstanding: number, overdueAmount: number }),
+      risk: ref('RiskScore')
+    })
+  },
+  'GET /api/v1/borrowers/:id/score': { response: ref('RiskScore') },
+  'GET /api/v1/borrowers/:id/statement': {
+    params: [query('from', string), query('to', string), query('format', enumOf(['json', 'pdf']))],
+    response: object({
+      borrower: ref('Borrower'),
+      period: object({ from: nullable(date), to: date }),
+      currency: nullable(currency),
+      loans: arrayOf(object({
+        id: uuid,
+        currency,
+        amount: decimal,
+        loan_date: date,
+        due_date: nullable(date),
+        status: enumOf(LOAN_STATUSES),
+        opening_balance: number,
+        closing_balance: number,
+        entries: arrayOf({ allOf: [ref('LedgerEntry'), object({ loan_id: uuid })] })
+      })),
+      totalPaid: nullable(number),
+      openingBalance: nullable(number),
+      closingBalance: nullable(number),
+      byCurrency: currencyTotals({ totalPaid: number, openingBalance: number, closingBalance: number }),
+      generatedAt: dateTime
+    }),
+    alternate: 'application/pdf'
+  },
+  'POST /api/v1/borrowers/:id/merge': {
+    request: object({ duplicateId: uuid }, ['duplicateId']),
+    response: object({ borrower: ref('Borrower'), movedLoans: integer, movedNotes: integer })
+  },
+  'GET /api/v1/borrowers/:id/notes': { params: pageParams, response: paged('notes', ref('BorrowerNote')) },
+  'POST /api/v1/borrowers/:id/notes': {
+    request: object({
+      content: string,
+      noteType: enumOf(NOTE_TYPES),
+      promisedAmount: number,
+      promisedDate: date,
+      occurredAt: dateTime
+    }, ['content']),
+    response: ref('BorrowerNote'),
+    status: 201
+  },
+  'DELETE /api/v1/borrowers/:id/notes/:noteId': { response: message },
+
+  'GET /api/v1/transactions': {
+    params: [...transactionListParams, query('cursor', string, 'Switches to cursor pagination (see /api/v2/transactions)')],
+    response: transactionsResponse,
+    jsonapi: true
+  },
+  'POST /api/v1/transactions': { request: ref('TransactionCreateRequest'), response: ref('Transaction'), status: 201 },
+  'POST /api/v1/transactions/import': {
+    params: [dryRun],
+    request: { type: 'string', description: 'CSV with a header row' },
+    requestContent: 'text/csv',
+    response: transactionImportResult,
+    status: 201
+  },
+  'PATCH /api/v1/transactions/batch': {
+    request: object({
+      ids: arrayOf(uuid),
+      changes: object({ transactionType: enumOf(TRANSACTION_TYPES), transactionDate: date, paymentDate: dateTime, description: string })
+    }, ['ids', 'changes']),
+    response: ref('BatchResult'),
+    failure: { 400: ref('BatchResult') }
+  },
+  'DELETE /api/v1/transactions/batch': {
+    request: object({ ids: arrayOf(uuid) }, ['ids']),
+    response: ref('BatchResult'),
+    failure: { 400: ref('BatchResult') }
+  },
+  'GET /api/v1/transactions/:id': {
+    response: { allOf: [ref('TransactionRow'), object({ attachments: arrayOf(ref('Attachment')) })] },
+    jsonapi: true
+  },
+  'PATCH /api/v1/transactions/:id': {
+    request: object({ amount: number, transactionType: enumOf(TRANSACTION_TYPES), transactionDate: date, description: string }),
+    response: ref('TransactionRow')
+  },
+  'DELETE /api/v1/transactions/:id': { response: message },
+  'POST /api/v1/transactions/:id/restore': { response: ref('TransactionRow') },
+  'POST /api/v1/transactions/:id/move': { request: object({ targetLoanId: uuid }, ['targetLoanId']), response: ref('TransactionRow') },
+  'PATCH /api/v1/transactions/:id/status': {
+    request: object({ status: enumOf(TRANSACTION_STATUSES) }, ['status']),
+    response: ref('TransactionRow')
+  },
+  'GET /api/v1/loans/:loanId/transactions': {
+    params: pageParams,
+    response: object({
+      transactions: arrayOf({ allOf: [ref('TransactionRow'), object({ balance_after: number })] }),
+      pagination
+    })
+  },
+
+  'POST /api/v1/transactions/:id/attachments': {
+    request: object({ files: arrayOf({ type: 'string', format: 'binary' }) }, ['files']),
+    requestContent: 'multipart/form-data',
+    response: ref('Attachment'),
+    list: true,
+    status: 201
+  },
+  'GET /api/v1/attachments/:id': { content: 'application/octet-stream' },
+  'DELETE /api/v1/attachments/:id': { response: message },
+
+  'GET /api/v1/loans/:loanId/payment-plans': { response: ref('PaymentPlan'), list: true },
+  'POST /api/v1/loans/:loanId/payment-plans': {
+    request: object({
+      amount: number,
+      frequency: enumOf(Object.keys(PAYMENT_FREQUENCIES)),
+      startDate: date,
+      endDate: date
+    }, ['amount', 'frequency', 'startDate']),
+    response: ref('PaymentPlan'),
+    status: 201
+  },
+  'GET /api/v1/loans/:loanId/expected-payments': {
+    params: [...pageParams, query('status', enumOf(['pending', 'paid', 'missed'])), query('from', string), query('to', string)],
+    response: ref('ExpectedPayment'),
+    list: true
+  },
+  'DELETE /api/v1/payment-plans/:id': { response: message },
+
+  'GET /api/v1/reminder-rules': { response: ref('ReminderRule'), list: true },
+  'POST /api/v1/reminder-rules': {
+    request: object({
+      loanId: uuid,
+      ruleType: enumOf(['before_due', 'overdue']),
+      daysBefore: arrayOf(integer),
+      repeatEveryDays: integer
+    }, ['ruleType']),
+    response: ref('ReminderRule'),
+    status: 201
+  },
+  'PATCH /api/v1/reminder-rules/:id': {
+    request: object({ active: boolean, daysBefore: arrayOf(integer), repeatEveryDays: integer }),
+    response: ref('ReminderRule')
+  },
+  'DELETE /api/v1/reminder-rules/:id': { response: message },
+
+  'GET /api/v1/notifications': {
+    params: [...pageParams, query('unread', boolean)],
+    response: object({ notifications: arrayOf(ref('Notification')), unreadCount: integer, pagination })
+  },
+  'POST /api/v1/notifications/read-all': { response: object({ updated: integer }) },
+  'PATCH /api/v1/notifications/:id/read': { response: ref('Notification') },
+  'GET /api/v1/notifications/push/public-key': { response: object({ publicKey: string }) },
+  'POST /api/v1/notifications/push/subscriptions': {
+    request: object({ endpoint: { type: 'string', format: 'uri' }, keys: object({ p256dh: string, auth: string }, ['p256dh', 'auth']) }, ['endpoint', 'keys']),
+    response: object({ id: uuid, endpoint: string, created_at: dateTime }),
+    status: 201
+  },
+  'DELETE /api/v1/notifications/push/subscriptions': {
+    request: object({ endpoint: string }, ['endpoint']),
+    response: message
+  },
+
+  'GET /api/v1/webhooks': { response: ref('WebhookEndpoint'), list: true },
+  'POST /api/v1/webhooks': {
+    request: object({ url: { type: 'string', format: 'uri' }, events: arrayOf(enumOf(WEBHOOK_EVENTS)), secret: string }, ['url']),
+    response: ref('WebhookEndpoint'),
+    status: 201
+  },
+  'PATCH /api/v1/webhooks/:id': {
+    request: object({ url: { type: 'string', format: 'uri' }, events: arrayOf(enumOf(WEBHOOK_EVENTS)), active: boolean }),
+    response: ref('WebhookEndpoint')
+  },
+  'DELETE /api/v1/webhooks/:id': { response: message },
+  'GET /api/v1/webhooks/:id/deliveries': { params: pageParams, response: paged('deliveries', ref('WebhookDelivery')) },
+  'POST /api/v1/webhooks/:id/deliveries/:notificationId/redeliver': {
+    response: object({ id: uuid, status: enumOf(['pending']) })
+  },
+
+  'POST /api/v1/batch': {
+    request: object({
+      operations: arrayOf(object({ method: enumOf(['GET', 'POST', 'PATCH', 'DELETE']), path: string, body: { type: 'object' } }, ['method', 'path'])),
+      transaction: boolean
+    }, ['operations']),
+    response: object({
+      transaction: boolean,
+      committed: nullable(boolean),
+      results: arrayOf(object({
+        index: integer,
+        status: integer,
+        headers: mapOf(string),
+        body: {},
+        encoding: enumOf(['base64'])
+      }))
+    })
+  },
+
+  'GET /api/v2/loans': {
+    params: [...loanListParams.filter(param => param.name !== 'page'), query('cursor', string)],
+    response: object({ loans: arrayOf(ref('LoanRow')), pagination: cursorPagination }, ['loans', 'pagination'])
+  },
+  'GET /api/v2/transactions': {
+    params: [...transactionListParams.filter(param => param.name !== 'page'), query('cursor', string)],
+    response: object({ transactions: arrayOf(ref('TransactionRow')), pagination: cursorPagination }, ['transactions', 'pagination'])
+  }
+};
+
+module.exports = {
+  SCHEMAS,
+  OPERATIONS
+};
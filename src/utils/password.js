@@ -0,0 +1,90 @@
+const crypto = require('crypto');
+const config = require('../config');
+const { ValidationError } = require('./response');
+
+const PASSWORD_MIN_LENGTH = config.password.minLength;
+// bcrypt only reads the first 72 bytes; longer inputs are refused rather than silently truncated
+const PASSWORD_MAX_BYTES = 72;
+const PASSWORD_BREACH_CHECK = config.password.breachCheck;
+const PWNED_PASSWORDS_URL = config.password.pwnedPasswordsUrl;
+const BREACH_CHECK_TIMEOUT_MS = 3000;
+
+const CHARACTER_CLASSES = {
+  lowercase: { pattern: /[a-z]/, label: 'a lowercase letter' },
+  uppercase: { pattern: /[A-Z]/, label: 'an uppercase letter' },
+  digit: { pattern: /[0-9]/, label: 'a digit' },
+  symbol: { pattern: /[^A-Za-z0-9]/, label: 'a symbol' }
+};
+// config/settings.js only accepts the CHARACTER_CLASSES names
+const REQUIRED_CLASSES = config.password.requiredClasses;
+
+/**
+ * Whether a password appears in the Have I Been Pwned corpus. Uses the k-anonymity range API,
+ * so only the first 5 hex characters of its SHA-1 leave the server.
+ * Lookup failures are logged and treated as not breached.
+ */
+async function isBreachedPassword(password) {
+  const hash = crypto.createHash('sha1').update(password).digest('hex').toUpperCase();
+  const prefix = hash.slice(0, 5);
+  const suffix = hash.slice(5);
+
+  try {
+    const response = await fetch(`${PWNED_PASSWORDS_URL}/${prefix}`, {
+      headers: { 'Add-Padding': 'true', 'User-Agent': 'loan-money-api' },
+      signal: AbortSignal.timeout(BREACH_CHECK_TIMEOUT_MS)
+    });
+    if (!response.ok) {
+      throw new Error(`Pwned Passwords responded with ${response.status}`);
+    }
+
+    const body = await response.text();
+    return body.split('\n').some(line => {
+      const [candidate, count] = line.trim().split(':');
+      return candidate === suffix && parseInt(count) > 0;
+    });
+  } catch (error) {
+    console.error('Password breach check error:', error);
+    return false;
+  }
+}
+
+/**
+ * Enforce the password policy on a new password (length, required character classes and,
+ * when PASSWORD_BREACH_CHECK is on, known breaches). Throws ValidationError for the given field.
+ */
+async function validatePassword(password, field = 'password') {
+  if (typeof password !== 'string') {
+    throw new ValidationError('Password must be a string', [{ field, issue: 'invalid' }]);
+  }
+
+  const issues = [];
+  if (password.length < PASSWORD_MIN_LENGTH) {
+    issues.push({ issue: 'too_short', message: `at least ${PASSWORD_MIN_LENGTH} characters`, minLength: PASSWORD_MIN_LENGTH });
+  }
+  if (Buffer.byteLength(password) > PASSWORD_MAX_BYTES) {
+    issues.push({ issue: 'too_long', message: `at most ${PASSWORD_MAX_BYTES} bytes`, maxBytes: PASSWORD_MAX_BYTES });
+  }
+  REQUIRED_CLASSES.forEach(name => {
+    if (!CHARACTER_CLASSES[name].pattern.test(password)) {
+      issues.push({ issue: `missing_${name}`, message: CHARACTER_CLASSES[name].label });
+    }
+  });
+
+  if (issues.length > 0) {
+    throw new ValidationError(
+      `Password must have ${issues.map(issue => issue.message).join(', ')}`,
+      issues.map(({ message, ...detail }) => ({ field, ...detail }))
+    );
+  }
+
+  if (PASSWORD_BREACH_CHECK && await isBreachedPassword(password)) {
+    throw new ValidationError('This password has appeared in a data breach; please choose a different one', [
+      { field, issue: 'breached' }
+    ]);
+  }
+}
+
+module.exports = {
+  validatePassword,
+  isBreachedPassword
+};
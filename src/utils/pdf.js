@@ -0,0 +1,83 @@
+const PAGE_WIDTH = 595; // A4 in points
+const PAGE_HEIGHT = 842;
+const MARGIN = 50;
+const LINE_HEIGHT = 14;
+const LINES_PER_PAGE = Math.floor((PAGE_HEIGHT - MARGIN * 2) / LINE_HEIGHT);
+
+/**
+ * Escape text for a PDF string literal.
+ * Uses the built-in Helvetica font, so characters outside Latin-1 are replaced.
+ */
+function escapePDFText(text) {
+  return String(text)
+    .replace(/[^\x20-\x7E\xA0-\xFF]/g, '?')
+    .replace(/\\/g, '\\\\')
+    .replace(/\(/g, '\\(')
+    .replace(/\)/g, '\\)');
+}
+
+/**
+ * Build content stream for a page of lines
+ */
+function buildPageContent(lines) {
+  const commands = ['BT', `/F1 10 Tf`, `${LINE_HEIGHT} TL`, `${MARGIN} ${PAGE_HEIGHT - MARGIN} Td`];
+  lines.forEach(line => {
+    const bold = typeof line === 'object' && line.bold;
+    const text = typeof line === 'object' ? line.text : line;
+    commands.push(bold ? '/F2 10 Tf' : '/F1 10 Tf');
+    commands.push(`(${escapePDFText(text)}) Tj T*`);
+  });
+  commands.push('ET');
+  return commands.join('\n');
+}
+
+/**
+ * Generate a simple text PDF document.
+ * Lines are strings or { text, bold } objects; pages break automatically.
+ */
+function generateTextPDF(lines) {
+  const pages = [];
+  for (let i = 0; i < lines.length; i += LINES_PER_PAGE) {
+    pages.push(lines.slice(i, i + LINES_PER_PAGE));
+  }
+  if (pages.length === 0) {
+    pages.push([]);
+  }
+
+  // Object layout: 1 catalog, 2 pages, 3 regular font, 4 bold font, then page/content pairs
+  const objects = [];
+  const pageIds = pages.map((_, index) => 5 + index * 2);
+
+  objects[1] = '<< /Type /Catalog /Pages 2 0 R >>';
+  objects[2] = `<< /Type /Pages /Kids [${pageIds.map(id => `${id} 0 R`).join(' ')}] /Count ${pages.length} >>`;
+  objects[3] = '<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>';
+  objects[4] = '<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>';
+
+  pages.forEach((pageLines, index) => {
+    const pageId = pageIds[index];
+    const content = buildPageContent(pageLines);
+    objects[pageId] = `<< /Type /Page /Parent 2 0 R /MediaBox [0 0 ${PAGE_WIDTH} ${PAGE_HEIGHT}] ` +
+      `/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents ${pageId + 1} 0 R >>`;
+    objects[pageId + 1] = `<< /Length ${Buffer.byteLength(content, 'latin1')} >>\nstream\n${content}\nendstream`;
+  });
+
+  let output = '%PDF-1.4\n';
+  const offsets = [];
+  for (let id = 1; id < objects.length; id++) {
+    offsets[id] = Buffer.byteLength(output, 'latin1');
+    output += `${id} 0 obj\n${objects[id]}\nendobj\n`;
+  }
+
+  const xrefOffset = Buffer.byteLength(output, 'latin1');
+  output += `xref\n0 ${objects.length}\n0000000000 65535 f \n`;
+  for (let id = 1; id < objects.length; id++) {
+    output += `${String(offsets[id]).padStart(10, '0')} 00000 n \n`;
+  }
+  output += `trailer\n<< /Size ${objects.length} /Root 1 0 R >>\nstartxref\n${xrefOffset}\n%%EOF`;
+
+  return Buffer.from(output, 'latin1');
+}
+
+module.exports = {
+  generateTextPDF
+};
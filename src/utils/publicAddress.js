@@ -0,0 +1,61 @@
+const dns = require('dns');
+const net = require('net');
+
+// Addresses user-supplied URLs may not reach: loopback, private, link-local (cloud metadata), CGNAT and multicast
+const BLOCKED = new net.BlockList();
+[
+  ['0.0.0.0', 8], ['10.0.0.0', 8], ['100.64.0.0', 10], ['127.0.0.0', 8], ['169.254.0.0', 16],
+  ['172.16.0.0', 12], ['192.0.0.0', 24], ['192.168.0.0', 16], ['198.18.0.0', 15], ['224.0.0.0', 4], ['240.0.0.0', 4]
+].forEach(([address, prefix]) => BLOCKED.addSubnet(address, prefix, 'ipv4'));
+[
+  ['::', 128], ['::1', 128], ['64:ff9b::', 96], ['fc00::', 7], ['fe80::', 10], ['ff00::', 8]
+].forEach(([address, prefix]) => BLOCKED.addSubnet(address, prefix, 'ipv6'));
+
+/**
+ * Whether an IP address is publicly routable (IPv4-mapped IPv6 is checked as IPv4)
+ */
+function isPublicAddress(address) {
+  const mapped = /^::ffff:(\d+\.\d+\.\d+\.\d+)$/i.exec(address);
+  const ip = mapped ? mapped[1] : address;
+  const family = net.isIP(ip);
+  return family !== 0 && !BLOCKED.check(ip, family === 4 ? 'ipv4' : 'ipv6');
+}
+
+/**
+ * dns.lookup replacement for http(s) requests that fails for non-public addresses.
+ * The socket connects to the address checked here, so a DNS answer cannot change between check and use.
+ */
+function publicLookup(hostname, options, callback) {
+  dns.lookup(hostname, { ...options, all: true }, (error, addresses) => {
+    if (error) {
+      return callback(error);
+    }
+    const blocked = addresses.find(entry => !isPublicAddress(entry.address));
+    if (blocked || addresses.length === 0) {
+      const refused = new Error(`${hostname} resolves to a non-public address`);
+      refused.code = 'EADDRNOTPUBLIC';
+      return callback(refused);
+    }
+    return options.all ? callback(null, addresses) : callback(null, addresses[0].address, addresses[0].family);
+  });
+}
+
+/**
+ * Reason a URL host is never reachable (IP literal or localhost outside the public internet), or null
+ */
+function blockedHostReason(url) {
+  const host = url.hostname.replace(/^\[|\]$/g, '');
+  if (host === 'localhost' || host.endsWith('.localhost')) {
+    return 'URL must not point to localhost';
+  }
+  if (net.isIP(host) && !isPublicAddress(host)) {
+    return 'URL must not point to a private, loopback or link-local address';
+  }
+  return null;
+}
+
+module.exports = {
+  isPublicAddress,
+  publicLookup,
+  blockedHostReason
+};
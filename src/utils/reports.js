@@ -0,0 +1,197 @@
+const { parseDateRange, dateRangeCondition } = require('./dateRange');
+const { formatMoney } = require('./currency');
+const { formatDate, formatMonth } = require('./dates');
+
+const MAX_REPORT_ROWS = 1000;
+const REPORT_SCHEDULES = {
+  daily: '1 day',
+  weekly: '7 days',
+  monthly: '1 month'
+};
+
+// Query building blocks per report source
+const REPORT_SOURCES = {
+  loans: {
+    from: `FROM loans l
+           LEFT JOIN borrowers b ON b.id = l.borrower_id
+           WHERE l.user_id = $1 AND l.deleted_at IS NULL`,
+    columns: `l.id, COALESCE(b.name, l.borrower_name) as borrower_name, l.currency, l.amount, l.loan_date, l.due_date, l.status`,
+    dateColumn: 'l.loan_date',
+    amountColumn: 'l.amount',
+    statusColumn: 'l.status',
+    orderBy: 'l.loan_date DESC',
+    groups: {
+      status: 'l.status',
+      borrower: 'COALESCE(b.name, l.borrower_name)',
+      month: "DATE_TRUNC('month', l.loan_date)"
+    }
+  },
+  transactions: {
+    from: `FROM transactions t
+           JOIN loans l ON l.id = t.loan_id
+           LEFT JOIN borrowers b ON b.id = l.borrower_id
+           WHERE t.user_id = $1 AND t.deleted_at IS NULL AND l.deleted_at IS NULL`,
+    columns: `t.id, t.loan_id, COALESCE(b.name, l.borrower_name) as borrower_name, l.currency, t.amount, t.transaction_type,
+              COALESCE(t.transaction_date, t.created_at::date) as transaction_date, t.status`,
+    dateColumn: 'COALESCE(t.transaction_date, t.created_at::date)',
+    amountColumn: 't.amount',
+    statusColumn: 't.status',
+    orderBy: 'COALESCE(t.transaction_date, t.created_at::date) DESC',
+    groups: {
+      status: 't.status',
+      borrower: 'COALESCE(b.name, l.borrower_name)',
+      month: "DATE_TRUNC('month', COALESCE(t.transaction_date, t.created_at::date))",
+      transaction_type: 't.transaction_type'
+    }
+  }
+};
+
+/**
+ * Validate a report definition; returns an error message or null
+ */
+function validateReportDefinition(definition) {
+  if (!definition || typeof definition !== 'object') {
+    return 'Definition must be an object';
+  }
+
+  const source = REPORT_SOURCES[definition.source];
+  if (!source) {
+    return `Source must be one of: ${Object.keys(REPORT_SOURCES).join(', ')}`;
+  }
+
+  if (definition.groupBy && !source.groups[definition.groupBy]) {
+    return `groupBy for ${definition.source} must be one of: ${Object.keys(source.groups).join(', ')}`;
+  }
+
+  const filters = definition.filters || {};
+  if (typeof filters !== 'object') {
+    return 'filters must be an object';
+  }
+  if ((filters.minAmount !== undefined && isNaN(parseFloat(filters.minAmount))) ||
+      (filters.maxAmount !== undefined && isNaN(parseFloat(filters.maxAmount)))) {
+    return 'minAmount and maxAmount must be numbers';
+  }
+  if (filters.transactionType && definition.source !== 'transactions') {
+    return 'transactionType filter is only supported for transactions';
+  }
+
+  const range = parseDateRange(toRangeQuery(definition.range));
+  return range.error || null;
+}
+
+/**
+ * Convert a definition range ({ preset } or { from, to }) to date range query params
+ */
+function toRangeQuery(range = {}) {
+  return { range: range.preset, from: range.from, to: range.to };
+}
+
+/**
+ * Run a report definition for a user and return grouped or row-level results
+ */
+async function runReport(client, userId, definition) {
+  const source = REPORT_SOURCES[definition.source];
+  const filters = definition.filters || {};
+  const range = parseDateRange(toRangeQuery(definition.range));
+  const params = [userId];
+
+  let where = source.from + dateRangeCondition(source.dateColumn, range, params);
+
+  if (filters.status) {
+    params.push(filters.status);
+    where += ` AND ${source.statusColumn} = $${params.length}`;
+  }
+  if (filters.borrowerId) {
+    params.push(filters.borrowerId);
+    where += ` AND l.borrower_id = $${params.length}`;
+  }
+  if (filters.transactionType) {
+    params.push(filters.transactionType);
+    where += ` AND t.transaction_type = $${params.length}`;
+  }
+  if (filters.minAmount !== undefined) {
+    params.push(parseFloat(filters.minAmount));
+    where += ` AND ${source.amountColumn} >= $${params.length}`;
+  }
+  if (filters.maxAmount !== undefined) {
+    params.push(parseFloat(filters.maxAmount));
+    where += ` AND ${source.amountColumn} <= $${params.length}`;
+  }
+
+  const totalsResult = await client.query(
+    `SELECT COUNT(*) as count, COALESCE(SUM(${source.amountColumn}), 0) as total_amount ${where}`,
+    params
+  );
+
+  let rows;
+  if (definition.groupBy) {
+    // groupBy is validated against the source's whitelist
+    const groupColumn = source.groups[definition.groupBy];
+    const result = await client.query(
+      `SELECT ${groupColumn} as group_key, COUNT(*) as count, COALESCE(SUM(${source.amountColumn}), 0) as total_amount
+       ${where}
+       GROUP BY ${groupColumn}
+       ORDER BY total_amount DESC
+       LIMIT ${MAX_REPORT_ROWS}`,
+      params
+    );
+    rows = result.rows.map(row => ({
+      group: row.group_key,
+      count: parseInt(row.count),
+      totalAmount: parseFloat(row.total_amount)
+    }));
+  } else {
+    const result = await client.query(
+      `SELECT ${source.columns} ${where} ORDER BY ${source.orderBy} LIMIT ${MAX_REPORT_ROWS}`,
+      params
+    );
+    rows = result.rows;
+  }
+
+  return {
+    range: { from: range.from, to: range.to, preset: range.preset },
+    totals: {
+      count: parseInt(totalsResult.rows[0].count),
+      totalAmount: parseFloat(totalsResult.rows[0].total_amount)
+    },
+    rows
+  };
+}
+
+/**
+ * Format report results as plain text (for email delivery), with dates in the given calendar
+ * and amounts in the given locale (row amounts in their own currency)
+ */
+function formatReportText(definition, result, calendar = 'gregorian', locale = null) {
+  const period = result.range.preset ||
+    `${formatDate(result.range.from, calendar) || 'start'} to ${formatDate(result.range.to, calendar) || 'today'}`;
+  const lines = [
+    `Period: ${period}`,
+    `Total: ${result.totals.count} ${definition.source}, ${formatMoney(result.totals.totalAmount, undefined, { locale })}`,
+    ''
+  ];
+
+  if (definition.groupBy) {
+    result.rows.forEach(row => {
+      const label = row.group instanceof Date ? formatMonth(row.group, calendar) : row.group;
+      lines.push(`${label}: ${row.count} / ${formatMoney(row.totalAmount, undefined, { locale })}`);
+    });
+  } else {
+    result.rows.slice(0, 50).forEach(row => {
+      lines.push(`${row.borrower_name}: ${formatMoney(row.amount, row.currency, { locale })} (${row.status})`);
+    });
+    if (result.rows.length > 50) {
+      lines.push(`... and ${result.rows.length - 50} more`);
+    }
+  }
+
+  return lines.join('\n');
+}
+
+module.exports = {
+  REPORT_SOURCES,
+  REPORT_SCHEDULES,
+  validateReportDefinition,
+  runReport,
+  formatReportText
+};
@@ -1,13 +1,54 @@
+const { ErrorResponse } = require('../models');
+const { wantsJSONAPI, errorDocument, respondWithJSONAPI } = require('./jsonapi');
+const { captureException } = require('./sentry');
+
 /**
- * Send error response
+ * Request validation failure carrying per-field details
  */
-function respondWithError(res, code, message) {
-  return res.status(code).json({
-    error: {
-      message: message || 'An error occurred',
-      status: code
-    }
+class ValidationError extends Error {
+  constructor(message, details = [], code = null) {
+    super(message);
+    this.details = details;
+    this.code = code;
+  }
+}
+
+/**
+ * Send error response in the standard envelope.
+ * Options: code (overrides the status default), details, and cause (a caught error; validation errors become 400s, timeouts 503s)
+ */
+function respondWithError(res, status, message, { code = null, details = [], cause = null } = {}) {
+  if (cause instanceof ValidationError) {
+    status = 400;
+    code = cause.code || code;
+    message = cause.message;
+    details = cause.details;
+  }
+
+  // Statement cancelled by the request timeout or the server statement_timeout
+  if (cause && (cause.code === 'REQUEST_TIMEOUT' || cause.code === '57014')) {
+    status = 503;
+    code = 'QUERY_TIMEOUT';
+    message = 'The request took too long and was cancelled';
+  }
+
+  // Server failures go to error tracking; 503s are expected (timeouts, unconfigured features)
+  if (status >= 500 && status !== 503) {
+    captureException(cause || new Error(message), { req: res.req, status });
+  }
+
+  const error = new ErrorResponse({
+    status,
+    code,
+    message: message || 'An error occurred',
+    details,
+    requestId: res.req ? res.req.id : null
   });
+
+  if (wantsJSONAPI(res.req)) {
+    return respondWithJSONAPI(res, status, errorDocument(error));
+  }
+  return res.status(status).json({ error });
 }
 
 /**
@@ -63,7 +104,27 @@ function validateRequiredFields(data, requiredFields) {
   });
   
   if (missingFields.length > 0) {
-    throw new Error(`Missing required fields: ${missingFields.join(', ')}`);
+    throw new ValidationError(
+      `Missing required fields: ${missingFields.join(', ')}`,
+      missingFields.map(field => ({ field, issue: 'required' }))
+    );
+  }
+}
+
+/**
+ * Reject a body that is not a JSON object or carries fields the endpoint does not accept
+ */
+function rejectUnknownFields(data, allowedFields) {
+  if (!data || typeof data !== 'object' || Array.isArray(data)) {
+    throw new ValidationError('Request body must be a JSON object', [{ field: 'body', issue: 'invalid' }]);
+  }
+
+  const unknownFields = Object.keys(data).filter(field => !allowedFields.includes(field));
+  if (unknownFields.length > 0) {
+    throw new ValidationError(
+      `Unknown fields: ${unknownFields.join(', ')}`,
+      unknownFields.map(field => ({ field, issue: 'unknown' }))
+    );
   }
 }
 
@@ -79,22 +140,93 @@ function parsePagination(query) {
 }
 
 /**
- * Format currency
+ * Whether soft-deleted rows were requested (?include_deleted=true)
+ */
+function parseIncludeDeleted(query) {
+  return query.include_deleted === 'true';
+}
+
+/**
+ * Parse a sparse fieldset (?fields=id,borrower_name); returns null when not requested
  */
-function formatCurrency(amount) {
-  return new Intl.NumberFormat('th-TH', {
-    style: 'currency',
-    currency: 'THB'
-  }).format(amount);
+function parseFields(query) {
+  if (query.fields === undefined) {
+    return null;
+  }
+
+  const fields = [...new Set(String(query.fields).split(',').map(field => field.trim()).filter(Boolean))];
+  if (fields.length === 0) {
+    throw new ValidationError('fields must list at least one field', [{ field: 'fields', issue: 'empty' }]);
+  }
+  return fields;
+}
+
+/**
+ * Reduce rows to the requested fields; unknown fields raise a ValidationError
+ */
+function selectFields(rows, fields, available) {
+  if (!fields) {
+    return rows;
+  }
+
+  const unknown = fields.filter(field => !available.includes(field));
+  if (unknown.length > 0) {
+    throw new ValidationError(
+      `Unknown fields: ${unknown.join(', ')}`,
+      unknown.map(field => ({ field, issue: 'unknown' }))
+    );
+  }
+
+  return rows.map(row => Object.fromEntries(fields.map(field => [field, row[field]])));
+}
+
+/**
+ * Encode an opaque pagination cursor from the last row's sort key
+ */
+function encodeCursor(createdAt, id) {
+  return Buffer.from(JSON.stringify([createdAt, id])).toString('base64url');
+}
+
+/**
+ * Parse cursor pagination parameters (?after= or ?cursor=, and limit); returns { limit, after } or { error }
+ */
+function parseCursorPagination(query, maxLimit = 100) {
+  const requested = parseInt(query.limit);
+  if (requested < 1) {
+    return { error: 'limit must be a positive integer' };
+  }
+
+  const limit = Math.min(requested || 20, maxLimit);
+  const cursor = query.after || query.cursor;
+
+  if (!cursor) {
+    return { limit, after: null };
+  }
+
+  try {
+    const [createdAt, id] = JSON.parse(Buffer.from(String(cursor), 'base64url').toString('utf8'));
+    if (typeof createdAt !== 'string' || !/^[0-9a-f-]{36}$/i.test(id) || isNaN(Date.parse(createdAt))) {
+      throw new Error('Invalid cursor');
+    }
+    return { limit, after: { createdAt, id } };
+  } catch (error) {
+    return { error: 'cursor must be a nextCursor returned by a previous page' };
+  }
 }
 
 module.exports = {
+  ValidationError,
   respondWithError,
   respondWithJSON,
   respondWithMessage,
   logDatabaseError,
   logAPICall,
   validateRequiredFields,
+  rejectUnknownFields,
   parsePagination,
-  formatCurrency
+  parseIncludeDeleted,
+  parseFields,
+  selectFields,
+  encodeCursor,
+  parseCursorPagination
 };
\ No newline at end of file
@@ -0,0 +1,52 @@
+const config = require('../config');
+
+// Days late at which the lateness component bottoms out
+const MAX_DAYS_LATE = 90;
+const RISKY_SCORE_THRESHOLD = config.loans.riskyBorrowerScore;
+
+/**
+ * Calculate borrower reliability score (0-100) from repayment history
+ */
+function calculateRiskScore({ onTimeCount = 0, lateCount = 0, totalDaysLate = 0, defaultedCount = 0, loanCount = 0 }) {
+  const evaluated = onTimeCount + lateCount;
+
+  if (evaluated === 0 && defaultedCount === 0) {
+    return {
+      score: null,
+      rating: 'unknown',
+      onTimeRatio: null,
+      averageDaysLate: null,
+      defaultedLoans: 0
+    };
+  }
+
+  const onTimeRatio = evaluated > 0 ? onTimeCount / evaluated : 0;
+  const averageDaysLate = evaluated > 0 ? totalDaysLate / evaluated : 0;
+  const defaultRatio = loanCount > 0 ? defaultedCount / loanCount : 1;
+
+  const score = Math.round(100 * (
+    0.5 * onTimeRatio +
+    0.3 * (1 - Math.min(averageDaysLate, MAX_DAYS_LATE) / MAX_DAYS_LATE) +
+    0.2 * (1 - defaultRatio)
+  ));
+
+  let rating = 'good';
+  if (score < RISKY_SCORE_THRESHOLD) {
+    rating = 'risky';
+  } else if (score < 80) {
+    rating = 'fair';
+  }
+
+  return {
+    score,
+    rating,
+    onTimeRatio: Math.round(onTimeRatio * 100) / 100,
+    averageDaysLate: Math.round(averageDaysLate * 10) / 10,
+    defaultedLoans: defaultedCount
+  };
+}
+
+module.exports = {
+  RISKY_SCORE_THRESHOLD,
+  calculateRiskScore
+};
@@ -0,0 +1,138 @@
+const crypto = require('crypto');
+const os = require('os');
+const config = require('../config');
+const { version } = require('../../package.json');
+
+const SENTRY_DSN = config.sentry.dsn || '';
+const SENTRY_ENVIRONMENT = config.sentry.environment || config.server.env || 'development';
+const SENTRY_RELEASE = config.sentry.release || `loan-money-api@${version}`;
+const SEND_TIMEOUT_MS = 5000;
+// Never forward credentials to the tracker
+const REDACTED_HEADERS = ['authorization', 'cookie', 'x-csrf-token', 'x-export-passphrase'];
+
+/**
+ * Parse a DSN (https://<key>@<host>/<project>) into the envelope endpoint and auth header, or null when unset/invalid
+ */
+function parseDsn(dsn) {
+  if (!dsn) {
+    return null;
+  }
+  try {
+    const url = new URL(dsn);
+    const projectId = url.pathname.split('/').filter(Boolean).pop();
+    if (!url.username || !projectId) {
+      throw new Error('DSN must include a public key and project id');
+    }
+    const prefix = url.pathname.slice(0, url.pathname.lastIndexOf('/'));
+    return {
+      endpoint: `${url.protocol}//${url.host}${prefix}/api/${projectId}/envelope/`,
+      auth: `Sentry sentry_version=7, sentry_key=${url.username}, sentry_client=loan-money/${version}`
+    };
+  } catch (error) {
+    console.error('Invalid SENTRY_DSN:', error.message);
+    return null;
+  }
+}
+
+const target = parseDsn(SENTRY_DSN);
+
+/**
+ * Convert a V8 stack trace into Sentry frames (oldest call first)
+ */
+function parseStack(stack) {
+  return String(stack || '').split('\n').slice(1).map(line => {
+    const match = line.match(/^\s*at (?:(.+?) \()?(.+?):(\d+):(\d+)\)?$/);
+    if (!match) {
+      return null;
+    }
+    const [, fn, filename, lineno, colno] = match;
+    return {
+      function: fn || '<anonymous>',
+      filename,
+      lineno: parseInt(lineno),
+      colno: parseInt(colno),
+      in_app: !filename.includes('node_modules') && !filename.startsWith('node:')
+    };
+  }).filter(Boolean).reverse();
+}
+
+/**
+ * Route pattern a request matched (e.g. /api/v1/loans/:id), falling back to the raw path
+ */
+function routeOf(req) {
+  return req.route ? `${req.baseUrl || ''}${req.route.path}` : req.path;
+}
+
+/**
+ * Request context for an event, with credential headers removed
+ */
+function requestContext(req) {
+  const headers = {};
+  Object.entries(req.headers || {}).forEach(([name, value]) => {
+    headers[name] = REDACTED_HEADERS.includes(name) ? '[Filtered]' : value;
+  });
+  return {
+    method: req.method,
+    url: `${req.protocol}://${req.get ? req.get('host') : ''}${req.path}`,
+    query_string: req.originalUrl && req.originalUrl.includes('?') ? req.originalUrl.split('?')[1] : '',
+    headers
+  };
+}
+
+/**
+ * Report an error to Sentry with request, route and user context. A no-op without SENTRY_DSN;
+ * delivery is fire-and-forget and never throws into the caller.
+ */
+function captureException(error, { req = null, status = 500, tags = {} } = {}) {
+  if (!target) {
+    return;
+  }
+
+  try {
+    const exception = error instanceof Error ? error : new Error(String(error));
+    const eventId = crypto.randomUUID().replace(/-/g, '');
+    const route = req ? routeOf(req) : null;
+    const event = {
+      event_id: eventId,
+      timestamp: Date.now() / 1000,
+      platform: 'node',
+      level: 'error',
+      environment: SENTRY_ENVIRONMENT,
+      release: SENTRY_RELEASE,
+      server_name: os.hostname(),
+      transaction: route ? `${req.method} ${route}` : undefined,
+      exception: {
+        values: [{
+          type: exception.name,
+          value: exception.message,
+          stacktrace: { frames: parseStack(exception.stack) }
+        }]
+      },
+      tags: {
+        ...tags,
+        ...(req ? { route, status: String(status), request_id: req.id } : {})
+      },
+      request: req ? requestContext(req) : undefined,
+      user: req && req.user ? { id: req.user.id, username: req.user.username } : undefined
+    };
+
+    const envelope = [
+      JSON.stringify({ event_id: eventId, sent_at: new Date().toISOString() }),
+      JSON.stringify({ type: 'event' }),
+      JSON.stringify(event)
+    ].join('\n');
+
+    fetch(target.endpoint, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/x-sentry-envelope', 'X-Sentry-Auth': target.auth },
+      body: envelope,
+      signal: AbortSignal.timeout(SEND_TIMEOUT_MS)
+    }).catch(sendError => console.error('Sentry send error:', sendError.message));
+  } catch (captureError) {
+    console.error('Sentry capture error:', captureError);
+  }
+}
+
+module.exports = {
+  captureException
+};
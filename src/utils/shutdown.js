@@ -0,0 +1,95 @@
+const config = require('../config');
+
+// How long in-flight requests and running jobs get to finish before connections are cut
+const SHUTDOWN_TIMEOUT_MS = config.limits.shutdownTimeoutMs;
+
+let shuttingDown = false;
+
+/**
+ * Whether a shutdown signal has been received
+ */
+function isShuttingDown() {
+  return shuttingDown;
+}
+
+/**
+ * Ask clients to reconnect elsewhere once shutdown starts, so keep-alive connections do not pin the draining instance
+ */
+function drainingMiddleware(req, res, next) {
+  if (shuttingDown) {
+    res.setHeader('Connection', 'close');
+  }
+  next();
+}
+
+/**
+ * Stop accepting connections and resolve once in-flight requests finish; idle keep-alive sockets close right away
+ */
+function closeServer(server) {
+  return new Promise((resolve, reject) => {
+    server.close(error => (error && error.code !== 'ERR_SERVER_NOT_RUNNING' ? reject(error) : resolve()));
+    if (typeof server.closeIdleConnections === 'function') {
+      server.closeIdleConnections();
+    }
+  });
+}
+
+/**
+ * Resolve after ms, reporting whether the deadline (rather than the work) won
+ */
+function withDeadline(promise, ms) {
+  let timer;
+  const deadline = new Promise(resolve => {
+    timer = setTimeout(() => resolve(true), ms);
+  });
+  return Promise.race([promise.then(() => false), deadline]).finally(() => clearTimeout(timer));
+}
+
+/**
+ * Shut down on SIGTERM/SIGINT: stop accepting work, drain servers, streams and jobs for up to
+ * SHUTDOWN_TIMEOUT_MS (then force-close what is left), run cleanup steps in order and exit.
+ * A second signal exits immediately.
+ */
+function handleShutdownSignals({ servers = [], drain = [], cleanup = [] }) {
+  const shutdown = async signal => {
+    if (shuttingDown) {
+      console.error(`${signal} received again, exiting immediately`);
+      process.exit(1);
+    }
+    shuttingDown = true;
+    console.log(`${signal} received, draining for up to ${SHUTDOWN_TIMEOUT_MS}ms`);
+
+    const draining = Promise.all([
+      ...servers.map(server => closeServer(server)),
+      ...drain.map(({ name, run }) => run().catch(error => console.error(`Shutdown ${name} error:`, error)))
+    ]).catch(error => console.error('Shutdown drain error:', error));
+
+    if (await withDeadline(draining, SHUTDOWN_TIMEOUT_MS)) {
+      console.error('Shutdown drain timed out; closing remaining connections');
+      servers.forEach(server => {
+        if (typeof server.closeAllConnections === 'function') {
+          server.closeAllConnections();
+        }
+      });
+    }
+
+    for (const { name, run } of cleanup) {
+      try {
+        await run();
+      } catch (error) {
+        console.error(`Shutdown ${name} error:`, error);
+      }
+    }
+
+    console.log('Shutdown complete');
+    process.exit(0);
+  };
+
+  ['SIGTERM', 'SIGINT'].forEach(signal => process.on(signal, () => shutdown(signal)));
+}
+
+module.exports = {
+  isShuttingDown,
+  drainingMiddleware,
+  handleShutdownSignals
+};
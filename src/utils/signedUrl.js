@@ -0,0 +1,39 @@
+const crypto = require('crypto');
+const config = require('../config');
+const { JWT_SECRET } = require('./jwt');
+
+const URL_SIGNING_SECRET = config.jwt.urlSigningSecret || JWT_SECRET;
+
+/**
+ * Compute HMAC signature for a path and expiry (unix seconds)
+ */
+function computeSignature(path, expires) {
+  return crypto.createHmac('sha256', URL_SIGNING_SECRET).update(`${path}:${expires}`).digest('hex');
+}
+
+/**
+ * Sign a path so it can be fetched without authentication until expiresAt
+ */
+function signPath(path, expiresAt) {
+  const expires = Math.floor(new Date(expiresAt).getTime() / 1000);
+  return `${path}?expires=${expires}&signature=${computeSignature(path, expires)}`;
+}
+
+/**
+ * Verify a signed path; returns false when the signature is invalid or expired
+ */
+function verifySignedPath(path, expires, signature) {
+  const expiresAt = parseInt(expires);
+  if (!expiresAt || !signature || expiresAt * 1000 < Date.now()) {
+    return false;
+  }
+
+  const expected = Buffer.from(computeSignature(path, expiresAt));
+  const provided = Buffer.from(String(signature));
+  return expected.length === provided.length && crypto.timingSafeEqual(expected, provided);
+}
+
+module.exports = {
+  signPath,
+  verifySignedPath
+};
@@ -0,0 +1,37 @@
+const config = require('../config');
+
+const TELEGRAM_API_URL = 'https://api.telegram.org';
+
+/**
+ * Call a Telegram Bot API method with the configured bot token
+ */
+async function callTelegram(method, body) {
+  const botToken = config.integrations.telegram.botToken;
+  if (!botToken) {
+    throw new Error('Telegram bot is not configured');
+  }
+
+  const response = await fetch(`${TELEGRAM_API_URL}/bot${botToken}/${method}`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(body)
+  });
+
+  const data = await response.json().catch(() => ({}));
+  if (!response.ok || !data.ok) {
+    throw new Error(`Telegram ${method} responded with ${response.status}: ${data.description || 'unknown error'}`);
+  }
+  return data.result;
+}
+
+/**
+ * Send a plain text message to a Telegram chat
+ */
+function sendTelegramMessage(chatId, text) {
+  return callTelegram('sendMessage', { chat_id: chatId, text });
+}
+
+module.exports = {
+  callTelegram,
+  sendTelegramMessage
+};
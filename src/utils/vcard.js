@@ -0,0 +1,81 @@
+/**
+ * Unescape vCard text value
+ */
+function unescapeValue(value) {
+  return value
+    .replace(/\\n/gi, '\n')
+    .replace(/\\([,;\\])/g, '$1')
+    .trim();
+}
+
+/**
+ * Parse vCard text (v2.1/3.0/4.0) into contact objects
+ */
+function parseVCards(text) {
+  // Unfold continuation lines (lines starting with space or tab)
+  const lines = text.replace(/\r\n/g, '\n').replace(/\n[ \t]/g, '').split('\n');
+  const contacts = [];
+  let current = null;
+
+  lines.forEach(line => {
+    const trimmed = line.trim();
+    if (/^BEGIN:VCARD$/i.test(trimmed)) {
+      current = { name: null, phones: [], emails: [], address: null, lineId: null };
+      return;
+    }
+
+    if (/^END:VCARD$/i.test(trimmed)) {
+      if (current) {
+        contacts.push(current);
+      }
+      current = null;
+      return;
+    }
+
+    if (!current) {
+      return;
+    }
+
+    const separator = trimmed.indexOf(':');
+    if (separator === -1) {
+      return;
+    }
+
+    // Property name may be grouped (item1.TEL) and carry parameters (TEL;TYPE=CELL)
+    const property = trimmed.slice(0, separator).split(';')[0].split('.').pop().toUpperCase();
+    const value = unescapeValue(trimmed.slice(separator + 1));
+
+    switch (property) {
+      case 'FN':
+        current.name = value;
+        break;
+      case 'N':
+        if (!current.name) {
+          const [family, given] = value.split(';');
+          current.name = [given, family].filter(Boolean).join(' ').trim() || null;
+        }
+        break;
+      case 'TEL':
+        current.phones.push(value.replace(/^tel:/i, ''));
+        break;
+      case 'EMAIL':
+        current.emails.push(value);
+        break;
+      case 'ADR':
+        current.address = value.split(';').filter(Boolean).join(' ').trim() || null;
+        break;
+      case 'X-LINE':
+      case 'X-LINE-ID':
+        current.lineId = value;
+        break;
+      default:
+        break;
+    }
+  });
+
+  return contacts;
+}
+
+module.exports = {
+  parseVCards
+};
@@ -0,0 +1,114 @@
+const crypto = require('crypto');
+
+const RECORD_SIZE = 4096;
+const VAPID_TOKEN_TTL_SECONDS = 12 * 60 * 60;
+
+/**
+ * Base64url helpers
+ */
+function toBase64Url(buffer) {
+  return Buffer.from(buffer).toString('base64').replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+function fromBase64Url(value) {
+  return Buffer.from(value.replace(/-/g, '+').replace(/_/g, '/'), 'base64');
+}
+
+/**
+ * Generate a VAPID key pair (base64url-encoded raw P-256 keys)
+ */
+function generateVapidKeys() {
+  const ecdh = crypto.createECDH('prime256v1');
+  ecdh.generateKeys();
+  return {
+    publicKey: toBase64Url(ecdh.getPublicKey()),
+    privateKey: toBase64Url(ecdh.getPrivateKey())
+  };
+}
+
+/**
+ * Build VAPID Authorization header for a push service endpoint (RFC 8292)
+ */
+function buildVapidAuthorization(endpoint, { publicKey, privateKey, subject }) {
+  const rawPublicKey = fromBase64Url(publicKey);
+  const key = crypto.createPrivateKey({
+    key: {
+      kty: 'EC',
+      crv: 'P-256',
+      d: privateKey,
+      x: toBase64Url(rawPublicKey.subarray(1, 33)),
+      y: toBase64Url(rawPublicKey.subarray(33, 65))
+    },
+    format: 'jwk'
+  });
+
+  const header = toBase64Url(JSON.stringify({ typ: 'JWT', alg: 'ES256' }));
+  const claims = toBase64Url(JSON.stringify({
+    aud: new URL(endpoint).origin,
+    exp: Math.floor(Date.now() / 1000) + VAPID_TOKEN_TTL_SECONDS,
+    sub: subject
+  }));
+  const signature = crypto.sign('sha256', Buffer.from(`${header}.${claims}`), { key, dsaEncoding: 'ieee-p1363' });
+
+  return `vapid t=${header}.${claims}.${toBase64Url(signature)}, k=${publicKey}`;
+}
+
+/**
+ * Encrypt payload for a subscription using aes128gcm content encoding (RFC 8291)
+ */
+function encryptPayload(payload, { p256dh, auth }) {
+  const userPublicKey = fromBase64Url(p256dh);
+  const authSecret = fromBase64Url(auth);
+
+  const ecdh = crypto.createECDH('prime256v1');
+  ecdh.generateKeys();
+  const serverPublicKey = ecdh.getPublicKey();
+  const sharedSecret = ecdh.computeSecret(userPublicKey);
+
+  const keyInfo = Buffer.concat([Buffer.from('WebPush: info\0'), userPublicKey, serverPublicKey]);
+  const ikm = Buffer.from(crypto.hkdfSync('sha256', sharedSecret, authSecret, keyInfo, 32));
+
+  const salt = crypto.randomBytes(16);
+  const contentKey = Buffer.from(crypto.hkdfSync('sha256', ikm, salt, Buffer.from('Content-Encoding: aes128gcm\0'), 16));
+  const nonce = Buffer.from(crypto.hkdfSync('sha256', ikm, salt, Buffer.from('Content-Encoding: nonce\0'), 12));
+
+  // Single record: payload followed by the last-record delimiter
+  const cipher = crypto.createCipheriv('aes-128-gcm', contentKey, nonce);
+  const ciphertext = Buffer.concat([
+    cipher.update(Buffer.concat([Buffer.from(payload), Buffer.from([2])])),
+    cipher.final(),
+    cipher.getAuthTag()
+  ]);
+
+  const header = Buffer.alloc(21);
+  salt.copy(header, 0);
+  header.writeUInt32BE(RECORD_SIZE, 16);
+  header.writeUInt8(serverPublicKey.length, 20);
+
+  return Buffer.concat([header, serverPublicKey, ciphertext]);
+}
+
+/**
+ * Send an encrypted push message; returns the push service response
+ */
+async function sendWebPush(subscription, payload, { vapid, ttl = 24 * 60 * 60 }) {
+  const body = encryptPayload(payload, subscription.keys);
+
+  return fetch(subscription.endpoint, {
+    method: 'POST',
+    headers: {
+      'Authorization': buildVapidAuthorization(subscription.endpoint, vapid),
+      'Content-Encoding': 'aes128gcm',
+      'Content-Type': 'application/octet-stream',
+      'TTL': String(ttl)
+    },
+    body
+  });
+}
+
+module.exports = {
+  generateVapidKeys,
+  buildVapidAuthorization,
+  encryptPayload,
+  sendWebPush
+};
@@ -0,0 +1,311 @@
+const { createZip, readZip } = require('./zip');
+const { CURRENCIES } = require('./currency');
+
+const EXCEL_EPOCH = Date.UTC(1899, 11, 30);
+const DAY_MS = 24 * 60 * 60 * 1000;
+
+// Cell style indexes in styles.xml
+const STYLES = {
+  default: 0,
+  header: 1,
+  currency: 2,
+  date: 3,
+  number: 0,
+  string: 0
+};
+
+// Amount styles per registry currency follow the fixed styles, in registry order
+const CURRENCY_CODES = Object.keys(CURRENCIES);
+const CURRENCY_STYLE_BASE = 4;
+
+/**
+ * Number format for amounts in a currency: its symbol and decimal places (Excel applies the reader's separators)
+ */
+function currencyFormatCode({ symbol, decimals }) {
+  return `&quot;${symbol}&quot;#,##0${decimals > 0 ? `.${'0'.repeat(decimals)}` : ''}`;
+}
+
+/**
+ * Cell style for an amount in currency code; codes outside the registry get the plain amount style
+ */
+function currencyStyle(code) {
+  const index = CURRENCY_CODES.indexOf(code);
+  return index === -1 ? STYLES.currency : CURRENCY_STYLE_BASE + index;
+}
+
+const STYLES_XML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="${2 + CURRENCY_CODES.length}"><numFmt numFmtId="164" formatCode="#,##0.00"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd"/>${CURRENCY_CODES.map((code, index) => `<numFmt numFmtId="${166 + index}" formatCode="${currencyFormatCode(CURRENCIES[code])}"/>`).join('')}</numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="${CURRENCY_STYLE_BASE + CURRENCY_CODES.length}">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+${CURRENCY_CODES.map((code, index) => `<xf numFmtId="${166 + index}" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>`).join('\n')}
+</cellXfs>
+</styleSheet>`;
+
+/**
+ * Escape text for XML content
+ */
+function escapeXML(value) {
+  return String(value)
+    .replace(/[\u0000-\u0008\u000B\u000C\u000E-\u001F]/g, '')
+    .replace(/&/g, '&amp;')
+    .replace(/</g, '&lt;')
+    .replace(/>/g, '&gt;')
+    .replace(/"/g, '&quot;');
+}
+
+/**
+ * Convert a zero-based column index to a column letter (0 -> A, 26 -> AA)
+ */
+function columnLetter(index) {
+  let letter = '';
+  for (let n = index + 1; n > 0; n = Math.floor((n - 1) / 26)) {
+    letter = String.fromCharCode(65 + ((n - 1) % 26)) + letter;
+  }
+  return letter;
+}
+
+/**
+ * Build XML for a single cell; style overrides the type's default style
+ */
+function buildCell(ref, value, type, style = STYLES[type]) {
+  if (value === null || value === undefined || value === '') {
+    return '';
+  }
+
+  if (type === 'date') {
+    const time = value instanceof Date ? value.getTime() : Date.parse(value);
+    if (!isNaN(time)) {
+      const serial = Math.floor((time - EXCEL_EPOCH) / DAY_MS);
+      return `<c r="${ref}" s="${STYLES.date}"><v>${serial}</v></c>`;
+    }
+  }
+
+  if ((type === 'currency' || type === 'number') && !isNaN(parseFloat(value))) {
+    return `<c r="${ref}" s="${style}"><v>${parseFloat(value)}</v></c>`;
+  }
+
+  return `<c r="${ref}" t="inlineStr"><is><t xml:space="preserve">${escapeXML(value)}</t></is></c>`;
+}
+
+/**
+ * Build worksheet XML from columns ({ header, key, type, width, currencyKey }) and row objects;
+ * currencyKey names the row field holding the currency of a currency column's amounts
+ */
+function buildSheet({ columns, rows }) {
+  const cols = columns
+    .map((column, index) => `<col min="${index + 1}" max="${index + 1}" width="${column.width || 15}" customWidth="1"/>`)
+    .join('');
+
+  const header = columns
+    .map((column, index) => `<c r="${columnLetter(index)}1" s="${STYLES.header}" t="inlineStr"><is><t>${escapeXML(column.header)}</t></is></c>`)
+    .join('');
+
+  const body = rows.map((row, rowIndex) => {
+    const rowNumber = rowIndex + 2;
+    const cells = columns
+      .map((column, index) => buildCell(
+        `${columnLetter(index)}${rowNumber}`,
+        row[column.key],
+        column.type,
+        column.currencyKey ? currencyStyle(row[column.currencyKey]) : undefined
+      ))
+      .join('');
+    return `<row r="${rowNumber}">${cells}</row>`;
+  }).join('');
+
+  return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>
+<cols>${cols}</cols>
+<sheetData><row r="1">${header}</row>${body}</sheetData>
+</worksheet>`;
+}
+
+/**
+ * Generate an XLSX workbook buffer from sheets of { name, columns, rows }
+ */
+function generateWorkbook(sheets) {
+  const sheetEntries = sheets.map((sheet, index) => ({
+    name: `xl/worksheets/sheet${index + 1}.xml`,
+    data: buildSheet(sheet)
+  }));
+
+  const contentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+${sheets.map((_, index) => `<Override PartName="/xl/worksheets/sheet${index + 1}.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`).join('\n')}
+</Types>`;
+
+  const rootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`;
+
+  // Sheet names are limited to 31 characters and cannot contain []:*?/\
+  const workbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets>${sheets.map((sheet, index) => `<sheet name="${escapeXML(sheet.name.replace(/[[\]:*?/\\]/g, '').slice(0, 31))}" sheetId="${index + 1}" r:id="rId${index + 1}"/>`).join('')}</sheets>
+</workbook>`;
+
+  const workbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+${sheets.map((_, index) => `<Relationship Id="rId${index + 1}" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet${index + 1}.xml"/>`).join('\n')}
+<Relationship Id="rId${sheets.length + 1}" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`;
+
+  return createZip([
+    { name: '[Content_Types].xml', data: contentTypes },
+    { name: '_rels/.rels', data: rootRels },
+    { name: 'xl/workbook.xml', data: workbook },
+    { name: 'xl/_rels/workbook.xml.rels', data: workbookRels },
+    { name: 'xl/styles.xml', data: STYLES_XML },
+    ...sheetEntries
+  ]);
+}
+
+/**
+ * Decode XML entities in text content
+ */
+function unescapeXML(value) {
+  return value
+    .replace(/&lt;/g, '<')
+    .replace(/&gt;/g, '>')
+    .replace(/&quot;/g, '"')
+    .replace(/&apos;/g, "'")
+    .replace(/&#(\d+);/g, (_, code) => String.fromCodePoint(parseInt(code)))
+    .replace(/&#x([0-9a-f]+);/gi, (_, code) => String.fromCodePoint(parseInt(code, 16)))
+    .replace(/&amp;/g, '&');
+}
+
+/**
+ * Concatenate the text runs (<t>) inside an XML fragment
+ */
+function extractText(xml) {
+  let text = '';
+  const pattern = /<t(?:\s[^>]*)?>([\s\S]*?)<\/t>/g;
+  let match;
+  while ((match = pattern.exec(xml)) !== null) {
+    text += unescapeXML(match[1]);
+  }
+  return text;
+}
+
+/**
+ * Convert a column letter reference (e.g. "AB12") to a zero-based column index
+ */
+function columnIndex(ref) {
+  const letters = /^[A-Z]+/.exec(ref)[0];
+  return letters.split('').reduce((index, letter) => index * 26 + letter.charCodeAt(0) - 64, 0) - 1;
+}
+
+// Workbook, relationships, shared strings, styles and worksheets
+const WORKBOOK_PARTS = /^xl\/(workbook\.xml|_rels\/workbook\.xml\.rels|sharedStrings\.xml|styles\.xml|worksheets\/[^/]+\.xml)$/;
+
+/**
+ * Get the set of cell style indexes that format numbers as dates
+ */
+function getDateStyles(stylesXml) {
+  const dateFormats = new Set([14, 15, 16, 17, 18, 19, 20, 21, 22, 45, 46, 47]);
+  const numFmtPattern = /<numFmt\s+numFmtId="(\d+)"\s+formatCode="([^"]*)"/g;
+  let match;
+  while ((match = numFmtPattern.exec(stylesXml)) !== null) {
+    // Custom format is a date if it has day/month/year tokens outside quoted text
+    if (/[dmy]/i.test(unescapeXML(match[2]).replace(/"[^"]*"|\[[^\]]*\]/g, ''))) {
+      dateFormats.add(parseInt(match[1]));
+    }
+  }
+
+  const cellXfs = /<cellXfs[^>]*>([\s\S]*?)<\/cellXfs>/.exec(stylesXml);
+  const dateStyles = new Set();
+  if (cellXfs) {
+    const xfs = cellXfs[1].match(/<xf\b[^>]*>/g) || [];
+    xfs.forEach((xf, index) => {
+      const numFmtId = /numFmtId="(\d+)"/.exec(xf);
+      if (numFmtId && dateFormats.has(parseInt(numFmtId[1]))) {
+        dateStyles.add(index);
+      }
+    });
+  }
+  return dateStyles;
+}
+
+/**
+ * Read the first worksheet of an XLSX workbook into rows (arrays of strings).
+ * Date-formatted cells become YYYY-MM-DD strings.
+ */
+function readWorkbook(buffer) {
+  // Only the parts read below are extracted
+  const files = readZip(buffer, name => WORKBOOK_PARTS.test(name));
+  const read = name => (files.has(name) ? files.get(name).toString('utf8') : '');
+
+  const sharedStrings = (read('xl/sharedStrings.xml').match(/<si>[\s\S]*?<\/si>/g) || []).map(extractText);
+  const dateStyles = getDateStyles(read('xl/styles.xml'));
+
+  // First sheet in workbook order, resolved via its relationship
+  const workbookXml = read('xl/workbook.xml');
+  const relsXml = read('xl/_rels/workbook.xml.rels');
+  const sheetRel = /<sheet\b[^>]*r:id="([^"]+)"/.exec(workbookXml);
+  let sheetPath = 'xl/worksheets/sheet1.xml';
+  if (sheetRel) {
+    const target = new RegExp(`<Relationship\\b[^>]*Id="${sheetRel[1]}"[^>]*Target="([^"]+)"`).exec(relsXml) ||
+      new RegExp(`<Relationship\\b[^>]*Target="([^"]+)"[^>]*Id="${sheetRel[1]}"`).exec(relsXml);
+    if (target) {
+      sheetPath = target[1].startsWith('/') ? target[1].slice(1) : `xl/${target[1]}`;
+    }
+  }
+
+  const sheetXml = read(sheetPath);
+  if (!sheetXml) {
+    throw new Error('Workbook has no worksheet');
+  }
+
+  const rows = [];
+  const rowPattern = /<row\b[^>]*>([\s\S]*?)<\/row>/g;
+  let rowMatch;
+  while ((rowMatch = rowPattern.exec(sheetXml)) !== null) {
+    const row = [];
+    const cellPattern = /<c\b([^>]*?)(?:\/>|>([\s\S]*?)<\/c>)/g;
+    let cellMatch;
+    while ((cellMatch = cellPattern.exec(rowMatch[1])) !== null) {
+      const attributes = cellMatch[1];
+      const content = cellMatch[2] || '';
+      const ref = /r="([A-Z]+\d+)"/.exec(attributes);
+      const type = (/t="([^"]+)"/.exec(attributes) || [])[1];
+      const style = parseInt((/s="(\d+)"/.exec(attributes) || [])[1] || 0);
+      const rawValue = (/<v>([\s\S]*?)<\/v>/.exec(content) || [])[1];
+
+      let value = '';
+      if (type === 's') {
+        value = sharedStrings[parseInt(rawValue)] || '';
+      } else if (type === 'inlineStr') {
+        value = extractText(content);
+      } else if (rawValue !== undefined) {
+        value = unescapeXML(rawValue);
+        if (type !== 'str' && type !== 'b' && type !== 'e' && dateStyles.has(style) && !isNaN(parseFloat(value))) {
+          value = new Date(EXCEL_EPOCH + Math.round(parseFloat(value) * DAY_MS)).toISOString().slice(0, 10);
+        }
+      }
+
+      row[ref ? columnIndex(ref[1]) : row.length] = value;
+    }
+    rows.push(Array.from(row, value => value || ''));
+  }
+
+  return rows.filter(r => r.some(value => value.trim() !== ''));
+}
+
+module.exports = {
+  generateWorkbook,
+  readWorkbook
+};
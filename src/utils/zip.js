@@ -0,0 +1,174 @@
+const zlib = require('zlib');
+
+// Limits for reading uploaded archives: a small upload can otherwise inflate to gigabytes
+const MAX_READ_ENTRIES = 1000;
+const MAX_INFLATED_BYTES = 64 * 1024 * 1024;
+
+const CRC_TABLE = (() => {
+  const table = new Uint32Array(256);
+  for (let n = 0; n < 256; n++) {
+    let c = n;
+    for (let k = 0; k < 8; k++) {
+      c = c & 1 ? 0xEDB88320 ^ (c >>> 1) : c >>> 1;
+    }
+    table[n] = c >>> 0;
+  }
+  return table;
+})();
+
+/**
+ * CRC-32 checksum of a buffer
+ */
+function crc32(buffer) {
+  let crc = 0xFFFFFFFF;
+  for (let i = 0; i < buffer.length; i++) {
+    crc = CRC_TABLE[(crc ^ buffer[i]) & 0xFF] ^ (crc >>> 8);
+  }
+  return (crc ^ 0xFFFFFFFF) >>> 0;
+}
+
+/**
+ * Convert a Date to MS-DOS time and date fields
+ */
+function toDosDateTime(date) {
+  return {
+    time: (date.getHours() << 11) | (date.getMinutes() << 5) | Math.floor(date.getSeconds() / 2),
+    date: ((date.getFullYear() - 1980) << 9) | ((date.getMonth() + 1) << 5) | date.getDate()
+  };
+}
+
+/**
+ * Create a ZIP archive from entries of { name, data } (data is a string or Buffer)
+ */
+function createZip(entries, modifiedAt = new Date()) {
+  const { time, date } = toDosDateTime(modifiedAt);
+  const localParts = [];
+  const centralParts = [];
+  let offset = 0;
+
+  for (const entry of entries) {
+    const name = Buffer.from(entry.name, 'utf8');
+    const data = Buffer.isBuffer(entry.data) ? entry.data : Buffer.from(entry.data, 'utf8');
+    const compressed = zlib.deflateRawSync(data);
+    const checksum = crc32(data);
+
+    const local = Buffer.alloc(30);
+    local.writeUInt32LE(0x04034B50, 0);
+    local.writeUInt16LE(20, 4); // version needed
+    local.writeUInt16LE(0x0800, 6); // UTF-8 names
+    local.writeUInt16LE(8, 8); // deflate
+    local.writeUInt16LE(time, 10);
+    local.writeUInt16LE(date, 12);
+    local.writeUInt32LE(checksum, 14);
+    local.writeUInt32LE(compressed.length, 18);
+    local.writeUInt32LE(data.length, 22);
+    local.writeUInt16LE(name.length, 26);
+    local.writeUInt16LE(0, 28);
+
+    const central = Buffer.alloc(46);
+    central.writeUInt32LE(0x02014B50, 0);
+    central.writeUInt16LE(20, 4); // version made by
+    central.writeUInt16LE(20, 6);
+    central.writeUInt16LE(0x0800, 8);
+    central.writeUInt16LE(8, 10);
+    central.writeUInt16LE(time, 12);
+    central.writeUInt16LE(date, 14);
+    central.writeUInt32LE(checksum, 16);
+    central.writeUInt32LE(compressed.length, 20);
+    central.writeUInt32LE(data.length, 24);
+    central.writeUInt16LE(name.length, 28);
+    central.writeUInt32LE(offset, 42);
+
+    localParts.push(local, name, compressed);
+    centralParts.push(central, name);
+    offset += local.length + name.length + compressed.length;
+  }
+
+  const centralDirectory = Buffer.concat(centralParts);
+  const end = Buffer.alloc(22);
+  end.writeUInt32LE(0x06054B50, 0);
+  end.writeUInt16LE(entries.length, 8);
+  end.writeUInt16LE(entries.length, 10);
+  end.writeUInt32LE(centralDirectory.length, 12);
+  end.writeUInt32LE(offset, 16);
+
+  return Buffer.concat([...localParts, centralDirectory, end]);
+}
+
+/**
+ * Read a ZIP archive into a Map of entry name -> Buffer (stored and deflated entries only).
+ * Only entries accepted by include are extracted; archives with more than MAX_READ_ENTRIES entries
+ * or extracting to more than MAX_INFLATED_BYTES in total are rejected.
+ */
+function readZip(buffer, include = () => true) {
+  // End of central directory record is within the last 64KB + 22 bytes
+  let endOffset = -1;
+  for (let i = buffer.length - 22; i >= Math.max(0, buffer.length - 65557); i--) {
+    if (buffer.readUInt32LE(i) === 0x06054B50) {
+      endOffset = i;
+      break;
+    }
+  }
+  if (endOffset === -1) {
+    throw new Error('Not a ZIP archive');
+  }
+
+  const entryCount = buffer.readUInt16LE(endOffset + 10);
+  if (entryCount > MAX_READ_ENTRIES) {
+    throw new Error(`ZIP archive has more than ${MAX_READ_ENTRIES} entries`);
+  }
+  let offset = buffer.readUInt32LE(endOffset + 16);
+  const entries = new Map();
+  let remaining = MAX_INFLATED_BYTES;
+
+  for (let i = 0; i < entryCount; i++) {
+    if (buffer.readUInt32LE(offset) !== 0x02014B50) {
+      throw new Error('Invalid ZIP central directory');
+    }
+
+    const method = buffer.readUInt16LE(offset + 10);
+    const compressedSize = buffer.readUInt32LE(offset + 20);
+    const nameLength = buffer.readUInt16LE(offset + 28);
+    const extraLength = buffer.readUInt16LE(offset + 30);
+    const commentLength = buffer.readUInt16LE(offset + 32);
+    const localOffset = buffer.readUInt32LE(offset + 42);
+    const name = buffer.slice(offset + 46, offset + 46 + nameLength).toString('utf8');
+    offset += 46 + nameLength + extraLength + commentLength;
+    if (!include(name)) {
+      continue;
+    }
+
+    // Local header has its own name/extra lengths
+    const dataStart = localOffset + 30 + buffer.readUInt16LE(localOffset + 26) + buffer.readUInt16LE(localOffset + 28);
+    const data = buffer.slice(dataStart, dataStart + compressedSize);
+
+    let content;
+    if (method === 0) {
+      content = data;
+    } else if (method === 8) {
+      try {
+        content = zlib.inflateRawSync(data, { maxOutputLength: Math.max(remaining, 1) });
+      } catch (error) {
+        throw error.code === 'ERR_BUFFER_TOO_LARGE'
+          ? new Error(`ZIP contents exceed ${MAX_INFLATED_BYTES} bytes`)
+          : error;
+      }
+    } else {
+      continue;
+    }
+
+    remaining -= content.length;
+    if (remaining < 0) {
+      throw new Error(`ZIP contents exceed ${MAX_INFLATED_BYTES} bytes`);
+    }
+    entries.set(name, content);
+  }
+
+  return entries;
+}
+
+module.exports = {
+  crc32,
+  createZip,
+  readZip
+};
@@ -0,0 +1,20 @@
+const { describe, it } = require('node:test');
+const assert = require('node:assert/strict');
+
+const cache = require('../../src/cache');
+
+describe('Cache', () => {
+  it('orphans a value loaded before the user was invalidated', async () => {
+    const version = await cache.getVersion('user-1');
+    await cache.invalidateUser('user-1');
+    await cache.set('user-1', 'response:/dashboard', { total: 1 }, 60, version);
+
+    assert.equal(await cache.get('user-1', 'response:/dashboard'), null);
+  });
+
+  it('serves a value stored under the current version', async () => {
+    await cache.set('user-2', 'response:/dashboard', { total: 2 }, 60, await cache.getVersion('user-2'));
+
+    assert.deepEqual(await cache.get('user-2', 'response:/dashboard'), { total: 2 });
+  });
+});
@@ -0,0 +1,36 @@
+const { describe, it } = require('node:test');
+const assert = require('node:assert/strict');
+
+const MemoryCache = require('../../src/cache/memory');
+
+describe('MemoryCache', () => {
+  it('evicts the oldest cached value when full', async () => {
+    const cache = new MemoryCache(2);
+    await cache.set('a', '1', 60);
+    await cache.set('b', '2', 60);
+    await cache.set('c', '3', 60);
+
+    assert.equal(await cache.get('a'), null);
+    assert.equal(await cache.get('c'), '3');
+  });
+
+  it('keeps counters when cached values fill the cache', async () => {
+    const cache = new MemoryCache(2);
+    await cache.increment('ratelimit:login', 60);
+    for (let i = 0; i < 10; i++) {
+      await cache.set(`response:${i}`, 'body', 60);
+    }
+
+    assert.equal(await cache.increment('ratelimit:login', 60), 2);
+  });
+
+  it('only sweeps expired counters to make room', async () => {
+    const cache = new MemoryCache(2);
+    await cache.increment('expired', -1);
+    await cache.increment('live', 60);
+    await cache.increment('new', 60);
+
+    assert.equal(cache.counters.has('expired'), false);
+    assert.equal(await cache.increment('live', 60), 2);
+  });
+});
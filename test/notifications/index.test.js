@@ -0,0 +1,40 @@
+const { describe, it } = require('node:test');
+const assert = require('node:assert/strict');
+
+const notificationService = require('../../src/notifications');
+
+/**
+ * Transaction client stub failing queries that match failOn and recording every statement
+ */
+function stubClient(failOn) {
+  const statements = [];
+  return {
+    statements,
+    async query(sql) {
+      statements.push(sql.trim().split(/\s+/).slice(0, 4).join(' '));
+      if (failOn && failOn.test(sql)) {
+        throw new Error('relation "notification_preferences" does not exist');
+      }
+      return { rows: [], rowCount: 0 };
+    }
+  };
+}
+
+describe('NotificationService.emit', () => {
+  it('runs in a savepoint released on success', async () => {
+    const client = stubClient();
+    await notificationService.emit('loan.completed', { userId: 'user-1', payload: { loanId: 'loan-1' } }, client);
+
+    assert.equal(client.statements[0], 'SAVEPOINT notification_emit');
+    assert.equal(client.statements[client.statements.length - 1], 'RELEASE SAVEPOINT notification_emit');
+  });
+
+  it('rolls back to the savepoint instead of throwing when a query fails', async () => {
+    const client = stubClient(/notification_preferences/);
+    await notificationService.emit('loan.completed', { userId: 'user-1', payload: { loanId: 'loan-1' } }, client);
+
+    assert.equal(client.statements[0], 'SAVEPOINT notification_emit');
+    assert.equal(client.statements[client.statements.length - 1], 'ROLLBACK TO SAVEPOINT notification_emit');
+    assert.ok(!client.statements.includes('RELEASE SAVEPOINT notification_emit'));
+  });
+});
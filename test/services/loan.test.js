@@ -0,0 +1,155 @@
+const { describe, it, beforeEach, afterEach } = require('node:test');
+const assert = require('node:assert/strict');
+
+const loanService = require('../../src/services/loan');
+const notificationService = require('../../src/notifications');
+const { ValidationError } = require('../../src/utils/response');
+
+/**
+ * Client stub answering every query with the given rows and recording the calls
+ */
+function stubClient(rows) {
+  const calls = [];
+  return {
+    calls,
+    async query(sql, params) {
+      calls.push({ sql, params });
+      return { rows, rowCount: rows.length };
+    }
+  };
+}
+
+const activeLoan = { id: 'loan-1', status: 'active', currency: 'THB', amount: '1000', total_paid: '400', remaining_debt: '600', pending_amount: '0' };
+
+describe('LoanService.validatePayment', () => {
+  it('accepts a payment up to the remaining debt', async () => {
+    const client = stubClient([activeLoan]);
+    await loanService.validatePayment(client, { loanId: 'loan-1', transactionType: 'payment', amount: '600' });
+    assert.deepEqual(client.calls[0].params, ['loan-1']);
+  });
+
+  it('rejects a payment larger than the remaining debt', async () => {
+    const client = stubClient([activeLoan]);
+    await assert.rejects(
+      loanService.validatePayment(client, { loanId: 'loan-1', transactionType: 'payment', amount: '600.01' }),
+      error => error instanceof ValidationError && error.details[0].issue === 'exceeds_remaining_debt' &&
+        error.details[0].remainingDebt === 600
+    );
+  });
+
+  it('credits back the amount of the confirmed payment being edited', async () => {
+    const client = stubClient([activeLoan]);
+    const replacing = { loan_id: 'loan-1', status: 'confirmed', transaction_type: 'payment', amount: '100' };
+    await loanService.validatePayment(client, { loanId: 'loan-1', transactionType: 'payment', amount: '700', replacing });
+  });
+
+  it('ignores a pending entry being edited', async () => {
+    const client = stubClient([activeLoan]);
+    const replacing = { loan_id: 'loan-1', status: 'pending', transaction_type: 'payment', amount: '100' };
+    await assert.rejects(
+      loanService.validatePayment(client, { loanId: 'loan-1', transactionType: 'payment', amount: '700', replacing }),
+      ValidationError
+    );
+  });
+
+  it('rejects an entry in another currency than the loan', async () => {
+    const client = stubClient([activeLoan]);
+    await assert.rejects(
+      loanService.validatePayment(client, { loanId: 'loan-1', transactionType: 'disbursement', amount: '10', currency: 'USD' }),
+      error => error instanceof ValidationError && error.code === 'CURRENCY_MISMATCH'
+    );
+  });
+
+  it('skips the lookup for a non-payment without a currency', async () => {
+    const client = stubClient([activeLoan]);
+    await loanService.validatePayment(client, { loanId: 'loan-1', transactionType: 'disbursement', amount: '5000' });
+    assert.equal(client.calls.length, 0);
+  });
+
+  it('locks the loan row on a transaction client', async () => {
+    const client = stubClient([activeLoan]);
+    await loanService.validatePayment(client, { loanId: 'loan-1', transactionType: 'payment', amount: '100' });
+    assert.match(client.calls[0].sql, /FOR UPDATE$/);
+  });
+
+  it('does nothing when the loan does not exist', async () => {
+    const client = stubClient([]);
+    await loanService.validatePayment(client, { loanId: 'missing', transactionType: 'payment', amount: '1' });
+  });
+});
+
+describe('LoanService.validateStatusChange', () => {
+  it('rejects an unknown status', () => {
+    assert.throws(
+      () => loanService.validateStatusChange(activeLoan, 'closed'),
+      error => error instanceof ValidationError && error.details[0].issue === 'invalid'
+    );
+  });
+
+  it('refuses to mark a loan paid while debt remains', () => {
+    assert.throws(
+      () => loanService.validateStatusChange(activeLoan, 'paid'),
+      error => error instanceof ValidationError && error.details[0].issue === 'outstanding_debt'
+    );
+  });
+
+  it('allows marking a settled loan paid', () => {
+    loanService.validateStatusChange({ status: 'active', remaining_debt: '0' }, 'paid');
+  });
+
+  it('refuses to reopen a fully paid loan by status alone', () => {
+    assert.throws(
+      () => loanService.validateStatusChange({ status: 'paid', remaining_debt: '0' }, 'active'),
+      error => error instanceof ValidationError && error.details[0].issue === 'fully_paid'
+    );
+  });
+
+  it('allows other changes on a loan with debt remaining', () => {
+    loanService.validateStatusChange(activeLoan, 'defaulted');
+  });
+});
+
+describe('LoanService.syncLoanStatus', () => {
+  const originalEmit = notificationService.emit;
+  let emitted;
+
+  beforeEach(() => {
+    emitted = [];
+    notificationService.emit = async (event, data, client) => {
+      emitted.push({ event, data, client });
+    };
+  });
+
+  afterEach(() => {
+    notificationService.emit = originalEmit;
+  });
+
+  it('announces a loan that just became paid on the same client', async () => {
+    const client = stubClient([{ id: 'loan-1', user_id: 'user-1', borrower_name: 'Somchai', status: 'paid', previous_status: 'active' }]);
+    await loanService.syncLoanStatus(client, 'loan-1');
+
+    assert.deepEqual(client.calls[0].params, ['loan-1']);
+    assert.equal(emitted.length, 1);
+    assert.equal(emitted[0].event, 'loan.completed');
+    assert.deepEqual(emitted[0].data, { userId: 'user-1', payload: { loanId: 'loan-1', borrowerName: 'Somchai' } });
+    assert.equal(emitted[0].client, client);
+  });
+
+  it('does not announce a loan that was already paid', async () => {
+    const client = stubClient([{ id: 'loan-1', user_id: 'user-1', borrower_name: 'Somchai', status: 'paid', previous_status: 'paid' }]);
+    await loanService.syncLoanStatus(client, 'loan-1');
+    assert.equal(emitted.length, 0);
+  });
+
+  it('does not announce a reopened loan', async () => {
+    const client = stubClient([{ id: 'loan-1', user_id: 'user-1', borrower_name: 'Somchai', status: 'active', previous_status: 'paid' }]);
+    await loanService.syncLoanStatus(client, 'loan-1');
+    assert.equal(emitted.length, 0);
+  });
+
+  it('does nothing when the loan has no balance row', async () => {
+    const client = stubClient([]);
+    await loanService.syncLoanStatus(client, 'missing');
+    assert.equal(emitted.length, 0);
+  });
+});
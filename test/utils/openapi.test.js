@@ -0,0 +1,53 @@
+const { describe, it } = require('node:test');
+const assert = require('node:assert/strict');
+
+const { app } = require('../../src/index');
+const models = require('../../src/models');
+const { buildOpenAPISpec } = require('../../src/utils/openapi');
+const { SCHEMAS, OPERATIONS } = require('../../src/utils/openapiSchemas');
+
+// Constructor arguments for models that dereference their input
+const MODEL_SAMPLES = {
+  AuthResponse: { user: {}, csrfToken: 'csrf' }
+};
+
+/**
+ * "METHOD /path" of every route registered on the app
+ */
+function registeredRoutes() {
+  return app._router.stack
+    .filter(layer => layer.route)
+    .flatMap(({ route }) => Object.keys(route.methods).map(method => `${method.toUpperCase()} ${route.path}`));
+}
+
+describe('OpenAPI spec', () => {
+  const spec = buildOpenAPISpec(app);
+
+  it('declares every registered route', () => {
+    const undeclared = registeredRoutes().filter(route => !OPERATIONS[route]);
+    assert.deepEqual(undeclared, []);
+  });
+
+  it('has no declarations for routes that no longer exist', () => {
+    const routes = new Set(registeredRoutes());
+    assert.deepEqual(Object.keys(OPERATIONS).filter(route => !routes.has(route)), []);
+  });
+
+  it('resolves every schema reference', () => {
+    const refs = JSON.stringify(spec).match(/#\/components\/schemas\/\w+/g) || [];
+    const missing = [...new Set(refs.map(ref => ref.split('/').pop()))].filter(name => !spec.components.schemas[name]);
+    assert.deepEqual(missing, []);
+  });
+
+  it('documents exactly the serialized properties of each response model', () => {
+    Object.entries(models)
+      .filter(([name, Model]) => typeof Model === 'function' && SCHEMAS[name] && !name.endsWith('Request'))
+      .forEach(([name, Model]) => {
+        const instance = new Model(MODEL_SAMPLES[name] || {});
+        const serialized = typeof instance.toJSON === 'function' ? instance.toJSON() : instance;
+        // The webhook secret is only added to the model when an endpoint is created
+        const documented = Object.keys(SCHEMAS[name].properties).filter(key => !(name === 'WebhookEndpoint' && key === 'secret'));
+        assert.deepEqual(documented.sort(), Object.keys(serialized).sort(), name);
+      });
+  });
+});
@@ -0,0 +1,16 @@
+const { describe, it } = require('node:test');
+const assert = require('node:assert/strict');
+
+const { parseCursorPagination } = require('../../src/utils/response');
+
+describe('parseCursorPagination', () => {
+  it('defaults and caps the limit', () => {
+    assert.deepEqual(parseCursorPagination({}), { limit: 20, after: null });
+    assert.deepEqual(parseCursorPagination({ limit: '500' }), { limit: 100, after: null });
+  });
+
+  it('rejects a limit below 1', () => {
+    assert.deepEqual(parseCursorPagination({ limit: '-5' }), { error: 'limit must be a positive integer' });
+    assert.deepEqual(parseCursorPagination({ limit: '0' }), { error: 'limit must be a positive integer' });
+  });
+});
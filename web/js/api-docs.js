@@ -0,0 +1,170 @@
+// Renders the OpenAPI document served by the API as a browsable list of operations
+const SPEC_URL = '/api/v1/openapi.json';
+const METHODS = ['get', 'post', 'put', 'patch', 'delete'];
+
+// Create an element with optional class and text
+function element(tag, className, text) {
+  const node = document.createElement(tag);
+  if (className) {
+    node.className = className;
+  }
+  if (text !== undefined) {
+    node.textContent = text;
+  }
+  return node;
+}
+
+// Resolve a local $ref (#/components/schemas/Name) against the spec
+function resolveRef(spec, schema) {
+  if (!schema || !schema.$ref) {
+    return schema;
+  }
+  return schema.$ref.replace(/^#\//, '').split('/').reduce((node, key) => node && node[key], spec);
+}
+
+// Describe a schema as a TypeScript-like type, expanding referenced schemas up to a depth
+function describeSchema(spec, schema, indent = '', depth = 0) {
+  if (!schema) {
+    return 'any';
+  }
+  if (schema.$ref) {
+    const name = schema.$ref.split('/').pop();
+    return depth >= 3 ? name : `${name} ${describeSchema(spec, resolveRef(spec, schema), indent, depth + 1)}`;
+  }
+  const nullable = schema.nullable ? ' | null' : '';
+  if (schema.allOf) {
+    return schema.allOf.map(part => describeSchema(spec, part, indent, depth)).join(' & ') + nullable;
+  }
+  if (schema.oneOf) {
+    return schema.oneOf.map(part => describeSchema(spec, part, indent, depth)).join(' | ') + nullable;
+  }
+  if (schema.enum) {
+    return schema.enum.map(value => JSON.stringify(value)).join(' | ') + nullable;
+  }
+  if (schema.type === 'array') {
+    return `${describeSchema(spec, schema.items, indent, depth)}[]${nullable}`;
+  }
+  if (schema.type === 'object' && schema.properties) {
+    const required = schema.required || [];
+    const inner = `${indent}  `;
+    const lines = Object.entries(schema.properties).map(([key, value]) =>
+      `${inner}${key}${required.includes(key) ? '' : '?'}: ${describeSchema(spec, value, inner, depth)}`);
+    return `{\n${lines.join(',\n')}\n${indent}}${nullable}`;
+  }
+  if (schema.type === 'object' && schema.additionalProperties) {
+    return `{ [key: string]: ${describeSchema(spec, schema.additionalProperties, indent, depth)} }${nullable}`;
+  }
+  return (schema.format ? `${schema.type}<${schema.format}>` : schema.type || 'any') + nullable;
+}
+
+// Render one media type schema as a preformatted block
+function renderContent(spec, content) {
+  const fragment = document.createDocumentFragment();
+  Object.entries(content || {}).forEach(([mediaType, media]) => {
+    fragment.appendChild(element('div', 'summary-text', mediaType));
+    fragment.appendChild(element('pre', null, describeSchema(spec, media.schema)));
+  });
+  return fragment;
+}
+
+// Render the parameters table of an operation
+function renderParameters(parameters) {
+  const table = element('table');
+  const head = element('tr');
+  ['Name', 'In', 'Type', 'Description'].forEach(label => head.appendChild(element('th', null, label)));
+  table.appendChild(head);
+  parameters.forEach(parameter => {
+    const row = element('tr');
+    const schema = parameter.schema || {};
+    row.appendChild(element('td', 'path', `${parameter.name}${parameter.required ? ' *' : ''}`));
+    row.appendChild(element('td', null, parameter.in));
+    row.appendChild(element('td', null, schema.enum ? schema.enum.join(' | ') : schema.format || schema.type || ''));
+    row.appendChild(element('td', null, parameter.description || ''));
+    table.appendChild(row);
+  });
+  return table;
+}
+
+// Render a collapsible operation with its parameters, request body and responses
+function renderOperation(spec, path, method, operation) {
+  const details = element('details');
+  details.dataset.search = `${method} ${path} ${operation.summary || ''}`.toLowerCase();
+
+  const summary = element('summary');
+  summary.appendChild(element('span', `method ${method}`, method));
+  summary.appendChild(element('span', 'path', path));
+  summary.appendChild(element('span', 'summary-text', operation.summary || ''));
+  if (operation.security && operation.security.length > 0) {
+    summary.appendChild(element('span', 'lock', operation.security.map(entry => Object.keys(entry).join(', ')).join(' or ')));
+  }
+  details.appendChild(summary);
+
+  const body = element('div', 'body');
+  if (operation.parameters && operation.parameters.length > 0) {
+    body.appendChild(element('h3', null, 'Parameters'));
+    body.appendChild(renderParameters(operation.parameters));
+  }
+  if (operation.requestBody) {
+    body.appendChild(element('h3', null, 'Request body'));
+    body.appendChild(renderContent(spec, operation.requestBody.content));
+  }
+  Object.entries(operation.responses || {}).forEach(([status, response]) => {
+    body.appendChild(element('h3', null, `${status} ${response.description || ''}`));
+    body.appendChild(renderContent(spec, response.content));
+  });
+  details.appendChild(body);
+  return details;
+}
+
+// Render every operation grouped by tag
+function renderSpec(spec) {
+  document.getElementById('title').textContent = `${spec.info.title} ${spec.info.version}`;
+  document.getElementById('description').textContent = spec.info.description || '';
+
+  const groups = new Map();
+  Object.entries(spec.paths).forEach(([path, item]) => {
+    METHODS.filter(method => item[method]).forEach(method => {
+      const tag = (item[method].tags || ['general'])[0];
+      groups.set(tag, [...(groups.get(tag) || []), renderOperation(spec, path, method, item[method])]);
+    });
+  });
+
+  const container = document.getElementById('operations');
+  container.textContent = '';
+  groups.forEach((operations, tag) => {
+    const section = element('section');
+    section.appendChild(element('h2', null, tag));
+    operations.forEach(operation => section.appendChild(operation));
+    container.appendChild(section);
+  });
+}
+
+// Hide operations (and empty sections) not matching the filter text
+function applyFilter(text) {
+  const query = text.trim().toLowerCase();
+  document.querySelectorAll('#operations section').forEach(section => {
+    let visible = 0;
+    section.querySelectorAll('details').forEach(details => {
+      const match = details.dataset.search.includes(query);
+      details.hidden = !match;
+      visible += match ? 1 : 0;
+    });
+    section.hidden = visible === 0;
+  });
+}
+
+document.getElementById('filter').addEventListener('input', event => applyFilter(event.target.value));
+
+fetch(SPEC_URL)
+  .then(response => {
+    if (!response.ok) {
+      throw new Error(`Failed to load ${SPEC_URL} (${response.status})`);
+    }
+    return response.json();
+  })
+  .then(renderSpec)
+  .catch(error => {
+    const container = document.getElementById('operations');
+    container.textContent = error.message;
+    container.className = 'error';
+  });
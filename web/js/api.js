@@ -2,30 +2,26 @@
 const API_BASE_URL = 'http://localhost:3001';
 
 // Utility functions for API calls
+// Uses a cookie session: the JWT lives in an httpOnly cookie, only the CSRF token is kept (in memory)
 class ApiClient {
   constructor() {
-    this.token = localStorage.getItem('token');
+    this.csrfToken = null;
     this.baseURL = `${API_BASE_URL}/api/v1`;
   }
 
-  // Set authentication token
-  setToken(token) {
-    this.token = token;
-    if (token) {
-      localStorage.setItem('token', token);
-    } else {
-      localStorage.removeItem('token');
-    }
+  // Set the CSRF token of the current session
+  setCsrfToken(csrfToken) {
+    this.csrfToken = csrfToken;
   }
 
-  // Get authentication headers
-  getHeaders() {
+  // Get request headers (writes must echo the session's CSRF token)
+  getHeaders(method = 'GET') {
     const headers = {
       'Content-Type': 'application/json'
     };
     
-    if (this.token) {
-      headers['Authorization'] = `Bearer ${this.token}`;
+    if (this.csrfToken && !['GET', 'HEAD', 'OPTIONS'].includes(method)) {
+      headers['X-CSRF-Token'] = this.csrfToken;
     }
     
     return headers;
@@ -35,7 +31,8 @@ class ApiClient {
   async request(endpoint, options = {}) {
     const url = `${this.baseURL}${endpoint}`;
     const config = {
-      headers: this.getHeaders(),
+      headers: this.getHeaders(options.method),
+      credentials: 'include',
       ...options
     };
 
@@ -44,7 +41,12 @@ class ApiClient {
       const data = await response.json();
 
       if (!response.ok) {
-        throw new Error(data.error?.message || 'API request failed');
+        // Show the request ID so a reported error can be found in the server logs
+        const requestId = data.error?.request_id || response.headers.get('X-Request-Id');
+        const message = data.error?.message || 'API request failed';
+        const error = new Error(requestId ? `${message} (ref: ${requestId})` : message);
+        error.requestId = requestId;
+        throw error;
       }
 
       return data;
@@ -53,7 +55,7 @@ class ApiClient {
       
       // Handle authentication errors
       if (error.message.includes('token') || error.message.includes('unauthorized')) {
-        this.setToken(null);
+        this.setCsrfToken(null);
         window.location.href = '/index.html';
       }
       
@@ -63,17 +65,33 @@ class ApiClient {
 
   // Authentication methods
   async register(userData) {
-    return this.request('/register', {
+    const data = await this.request('/register?session=cookie', {
       method: 'POST',
       body: JSON.stringify(userData)
     });
+    this.setCsrfToken(data.data.csrfToken);
+    return data;
   }
 
   async login(credentials) {
-    return this.request('/login', {
+    const data = await this.request('/login?session=cookie', {
       method: 'POST',
       body: JSON.stringify(credentials)
     });
+    this.setCsrfToken(data.data.csrfToken);
+    return data;
+  }
+
+  // Restore the session after a page load; throws when not signed in
+  async getSession() {
+    const data = await this.request('/session');
+    this.setCsrfToken(data.data.csrfToken);
+    return data;
+  }
+
+  async logout() {
+    await this.request('/logout', { method: 'POST' });
+    this.setCsrfToken(null);
   }
 
   // Profile methods
@@ -229,19 +247,25 @@ function showNotification(message, type = 'info') {
 }
 
 // Authentication check
-function checkAuth() {
-  const token = localStorage.getItem('token');
+async function checkAuth() {
   const currentPage = window.location.pathname;
   
   // Public pages that don't require authentication
   const publicPages = ['/index.html', '/register.html', '/'];
   
-  if (!token && !publicPages.includes(currentPage)) {
+  let signedIn = true;
+  try {
+    await window.api.getSession();
+  } catch (error) {
+    signedIn = false;
+  }
+  
+  if (!signedIn && !publicPages.includes(currentPage)) {
     window.location.href = '/index.html';
     return false;
   }
   
-  if (token && publicPages.includes(currentPage)) {
+  if (signedIn && publicPages.includes(currentPage)) {
     window.location.href = '/dashboard.html';
     return false;
   }
@@ -250,8 +274,8 @@ function checkAuth() {
 }
 
 // Logout function
-function logout() {
-  window.api.setToken(null);
+async function logout() {
+  await window.api.logout();
   window.location.href = '/index.html';
 }
 